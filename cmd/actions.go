@@ -3,6 +3,7 @@ package cmd
 import (
 	"fmt"
 	"strconv"
+	"strings"
 
 	"github.com/AlecAivazis/survey/v2"
 	z "go.dedis.ch/cs438/internal/testing"
@@ -20,8 +21,16 @@ import (
 var basicActionOpts = []string{
 	ShowAssets,
 	AddAsset,
+	UpdateAsset,
+	RemoveAsset,
+	TransferAsset,
+	SetAssetPolicy,
 	ShowBalance,
+	StakeCoins,
+	ShowReceipt,
 	ShowBlockchain,
+	SyncBlockchain,
+	SaveBlockchain,
 	AddPeer,
 	ShowEncKey,
 	Refresh,
@@ -72,22 +81,43 @@ func startMPC(node *z.TestNode, actionMap map[string]ActionFunc) error {
 	expr := ""
 	fmt.Scanln(&expr)
 
-	printData("Your balance is %f\n", node.BCGetBalance())
+	printData("Your balance is %s\n", node.BCGetBalance())
 	fmt.Println("Enter the budget: ")
 	budgetStr := ""
 	fmt.Scanln(&budgetStr)
-	budget, err := strconv.ParseFloat(budgetStr, 64)
+	budget, err := permissioned.ParseAmount(budgetStr)
 	if err != nil {
 		return err
 	}
 
+	fmt.Println("Enter the participation fee (empty for default): ")
+	feeStr := ""
+	fmt.Scanln(&feeStr)
+	var fee permissioned.Amount
+	if feeStr != "" {
+		fee, err = permissioned.ParseAmount(feeStr)
+		if err != nil {
+			return err
+		}
+	}
+
 	// local check for efficiency
 	block := node.BCGetLatestBlock()
 	if block == nil {
 		printError(fmt.Errorf("blockchain not initialized"))
 		return nil
 	}
-	_, total, err := permissioned.CalculateTotalPrice(block.States, expr)
+	addr, err := node.BCGetAddress()
+	if err != nil {
+		printError(err)
+		return nil
+	}
+	err = permissioned.CheckAssetPolicies(block.States, addr.Hex, expr)
+	if err != nil {
+		printError(err)
+		return nil
+	}
+	_, total, err := permissioned.CalculateTotalPrice(block.States, addr.Hex, expr, fee)
 	if err != nil {
 		printError(err)
 		return nil
@@ -104,30 +134,30 @@ func startMPC(node *z.TestNode, actionMap map[string]ActionFunc) error {
 	}
 
 	// calculate
-	value, err := node.Calculate(expr, budget)
+	value, err := node.CalculateWithFee(expr, budget, fee)
 	if err != nil {
 		printError(fmt.Errorf("calculation fails: %s", err))
 		return nil
 	}
 	printData("The result of %s is %d\n", expr, value)
-	printData("Your balance is %f\nPlease check balance later for the automatical deposit claim\n", node.BCGetBalance())
+	printData("Your balance is %s\nPlease check balance later for the automatical deposit claim\n", node.BCGetBalance())
 
 	return nil
 }
 
 func showAssets(node *z.TestNode, actionMap map[string]ActionFunc) error {
-	records := node.GetAllPeerAssetPrices()
-
-	hasValue := false
-	for addr, record := range records {
-		hasValue = true
-		printData("Peer %s: \n", addr)
-		for key, price := range record {
-			printData("- Value: %s\t Price:%f\n", key, price)
+	fmt.Println("Enter a search keyword (empty for all): ")
+	query := ""
+	fmt.Scanln(&query)
+
+	infos := node.BCSearchAssets(query)
+	for _, info := range infos {
+		printData("- Value: %s\t Price:%s\t Owner: %s\n", info.Key, info.Price, info.Owner)
+		if info.Schema.Description != "" || info.Schema.Unit != "" || len(info.Schema.Tags) > 0 {
+			printData("\t %s\n", info.Schema.String())
 		}
-		printData("\n")
 	}
-	if !hasValue {
+	if len(infos) == 0 {
 		printData("No assets in the network :(")
 	}
 
@@ -150,18 +180,147 @@ func addAsset(node *z.TestNode, actionMap map[string]ActionFunc) error {
 	fmt.Println("Enter the price: ")
 	priceStr := ""
 	fmt.Scanln(&priceStr)
-	price, err := strconv.ParseFloat(priceStr, 64)
+	price, err := permissioned.ParseAmount(priceStr)
 	if err != nil {
 		return err
 	}
 
-	err = node.SetValueDBAsset(key, int(value), price)
+	description := ""
+	err = survey.AskOne(&survey.Input{
+		Message: "Enter the description (empty to skip the schema): ",
+	}, &description)
+	if err != nil {
+		return err
+	}
+	if description == "" {
+		err = node.SetValueDBAsset(key, int(value), price)
+		if err != nil {
+			printError(fmt.Errorf("fail to set value: %s", err))
+		}
+		return nil
+	}
+
+	schema := permissioned.AssetSchema{Description: description}
+	fmt.Println("Enter the unit: ")
+	fmt.Scanln(&schema.Unit)
+	fmt.Println("Enter the type: ")
+	fmt.Scanln(&schema.Type)
+	fmt.Println("Enter the valid range as min,max (empty for no range): ")
+	rangeStr := ""
+	fmt.Scanln(&rangeStr)
+	if bounds := splitList(rangeStr); len(bounds) == 2 {
+		schema.Bounded = true
+		schema.Min, err = strconv.Atoi(bounds[0])
+		if err != nil {
+			return err
+		}
+		schema.Max, err = strconv.Atoi(bounds[1])
+		if err != nil {
+			return err
+		}
+	}
+	err = survey.AskOne(&survey.Input{Message: "Enter the owner contact: "}, &schema.Contact)
+	if err != nil {
+		return err
+	}
+	fmt.Println("Enter the tags, separated by commas: ")
+	tags := ""
+	fmt.Scanln(&tags)
+	schema.Tags = splitList(tags)
+
+	err = node.SetValueDBAssetWithSchema(key, int(value), price, &schema)
 	if err != nil {
 		printError(fmt.Errorf("fail to set value: %s", err))
 	}
 	return nil
 }
 
+func updateAsset(node *z.TestNode, actionMap map[string]ActionFunc) error {
+	fmt.Println("Enter the key: ")
+	key := ""
+	fmt.Scanln(&key)
+
+	fmt.Println("Enter the new price: ")
+	priceStr := ""
+	fmt.Scanln(&priceStr)
+	price, err := permissioned.ParseAmount(priceStr)
+	if err != nil {
+		return err
+	}
+
+	err = node.UpdateAssetPrice(key, price)
+	if err != nil {
+		printError(fmt.Errorf("fail to update price: %s", err))
+	}
+	return nil
+}
+
+func removeAsset(node *z.TestNode, actionMap map[string]ActionFunc) error {
+	fmt.Println("Enter the key: ")
+	key := ""
+	fmt.Scanln(&key)
+
+	err := node.RemoveValueDBAsset(key)
+	if err != nil {
+		printError(fmt.Errorf("fail to remove asset: %s", err))
+	}
+	return nil
+}
+
+func transferAsset(node *z.TestNode, actionMap map[string]ActionFunc) error {
+	fmt.Println("Enter the key: ")
+	key := ""
+	fmt.Scanln(&key)
+
+	fmt.Println("Enter the blockchain address of the new owner: ")
+	to := ""
+	fmt.Scanln(&to)
+
+	err := node.TransferAsset(key, to)
+	if err != nil {
+		printError(fmt.Errorf("fail to transfer asset: %s", err))
+	}
+	return nil
+}
+
+func setAssetPolicy(node *z.TestNode, actionMap map[string]ActionFunc) error {
+	fmt.Println("Enter the key: ")
+	key := ""
+	fmt.Scanln(&key)
+
+	fmt.Println("Enter the allowed initiators, separated by commas (empty for everyone): ")
+	initiators := ""
+	fmt.Scanln(&initiators)
+
+	fmt.Println("Enter the allowed operations, e.g. +,* (empty for all): ")
+	ops := ""
+	fmt.Scanln(&ops)
+
+	fmt.Println("Enter the minimum number of inputs (empty for no minimum): ")
+	minInputs, err := scanUint()
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Enter the quota per initiator (empty for unlimited): ")
+	quota, err := scanUint()
+	if err != nil {
+		return err
+	}
+
+	policy := permissioned.AssetPolicy{
+		AllowedInitiators: splitList(initiators),
+		AllowedOps:        splitList(ops),
+		MinInputs:         minInputs,
+		Quota:             quota,
+	}
+	err = node.SetAssetPolicy(key, policy)
+	if err != nil {
+		printError(fmt.Errorf("fail to set policy: %s", err))
+	}
+	return nil
+}
+
 func addPeer(node *z.TestNode, actionMap map[string]ActionFunc) error {
 	fmt.Println("Enter the peer IP address: ")
 	addr := ""
@@ -171,6 +330,20 @@ func addPeer(node *z.TestNode, actionMap map[string]ActionFunc) error {
 	return nil
 }
 
+func syncBlockchain(node *z.TestNode, actionMap map[string]ActionFunc) error {
+	err := node.BCSync()
+	if err != nil {
+		printError(fmt.Errorf("fail to sync: %s", err))
+		return nil
+	}
+
+	block := node.BCGetLatestBlock()
+	if block != nil {
+		printData("Synced until height %d\n", block.Height)
+	}
+	return nil
+}
+
 func getEnckey(node *z.TestNode, actionMap map[string]ActionFunc) error {
 	pub, err := node.GetPubkeyString()
 	if err != nil {
@@ -182,7 +355,46 @@ func getEnckey(node *z.TestNode, actionMap map[string]ActionFunc) error {
 }
 
 func getBCBalance(node *z.TestNode, actionMap map[string]ActionFunc) error {
-	printData("Your balance is %f\n", node.BCGetBalance())
+	printData("Your balance is %s\n", node.BCGetBalance())
+	printData("Your stake is %s\n", node.BCGetStake())
+	return nil
+}
+
+func stakeCoins(node *z.TestNode, actionMap map[string]ActionFunc) error {
+	printData("Your balance is %s, your stake is %s\n", node.BCGetBalance(), node.BCGetStake())
+	fmt.Println("Enter the amount to stake (negative to withdraw): ")
+	amountStr := ""
+	fmt.Scanln(&amountStr)
+	amount, err := permissioned.ParseAmount(amountStr)
+	if err != nil {
+		return err
+	}
+
+	var txnID string
+	if amount >= 0 {
+		txnID, err = node.BCStake(amount)
+	} else {
+		txnID, err = node.BCUnstake(-amount)
+	}
+	if err != nil {
+		printError(fmt.Errorf("fail to change stake: %s", err))
+		return nil
+	}
+	printData("Stake txn %s sent. Please check balance later\n", txnID)
+	return nil
+}
+
+func showReceipt(node *z.TestNode, actionMap map[string]ActionFunc) error {
+	fmt.Println("Enter the transaction ID: ")
+	txnID := ""
+	fmt.Scanln(&txnID)
+
+	receipt, err := node.BCGetReceipt(txnID)
+	if err != nil {
+		printError(fmt.Errorf("fail to get receipt: %s", err))
+		return nil
+	}
+	printData("%s\n", receipt.String())
 	return nil
 }
 
@@ -321,7 +533,7 @@ func getBlockDetails(block *permissioned.Block, node *z.TestNode, actionMap map[
 				printData("- Nonce: %d\n", txn.Txn.Nonce)
 				printData("- From: %s\n", txn.Txn.From)
 				printData("- To: %s\n", txn.Txn.To)
-				printData("- Value: %f\n", txn.Txn.Value)
+				printData("- Value: %s\n", txn.Txn.Value)
 				if txn.Txn.Data != nil {
 					switch vv := txn.Txn.Data.(type) {
 					case permissioned.Describable:
@@ -356,3 +568,27 @@ func getBlockDetails(block *permissioned.Block, node *z.TestNode, actionMap map[
 		}
 	}
 }
+
+// -----------------------------------------------------------------------------
+// Input Helpers
+
+func scanUint() (uint, error) {
+	str := ""
+	fmt.Scanln(&str)
+	if str == "" {
+		return 0, nil
+	}
+	value, err := strconv.ParseUint(str, 10, 32)
+	return uint(value), err
+}
+
+func splitList(str string) []string {
+	list := []string{}
+	for _, item := range strings.Split(str, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/AlecAivazis/survey/v2"
+	z "go.dedis.ch/cs438/internal/testing"
+	"go.dedis.ch/cs438/permissioned-chain"
+	"go.dedis.ch/cs438/storage/file"
+)
+
+// -----------------------------------------------------------------------------
+// Chain actions
+
+// Export the chain of the running node
+func exportChain(node *z.TestNode, actionMap map[string]ActionFunc) error {
+	fmt.Println("Where do you want to export the chain? Enter the path:  ")
+	fp := ""
+	fmt.Scanln(&fp)
+
+	err := checkNewFile(fp)
+	if err != nil {
+		return err
+	}
+	snapshots := false
+	err = survey.AskOne(&survey.Confirm{Message: "Include the state snapshots?"}, &snapshots)
+	if err != nil {
+		return err
+	}
+
+	err = node.BCExportChain(fp, snapshots)
+	if err != nil {
+		return err
+	}
+	printData("Chain exported in %s\n", fp)
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// Chain commands
+
+// ExportChain writes the chain persisted in the storage folder of a stopped
+// node in the file
+func ExportChain(folder, path string, snapshots bool) error {
+	bc, err := openChain(folder)
+	if err != nil {
+		return err
+	}
+	err = checkNewFile(path)
+	if err != nil {
+		return err
+	}
+
+	buf, err := bc.Export(snapshots)
+	if err != nil {
+		return err
+	}
+	err = os.WriteFile(path, buf, 0600)
+	if err != nil {
+		return err
+	}
+	fmt.Println("Chain up to height", bc.GetLatestBlock().Height, "exported in", path)
+	return nil
+}
+
+// ImportChain replays an exported chain into the storage folder of a fresh
+// node. The node resumes it when started with this folder
+func ImportChain(path, folder string) error {
+	export, err := readExport(path)
+	if err != nil {
+		return err
+	}
+	bc, err := openChain(folder)
+	if err != nil {
+		return err
+	}
+
+	err = bc.Import(export)
+	if err != nil {
+		return err
+	}
+	fmt.Println("Chain up to height", bc.GetLatestBlock().Height, "imported in", folder)
+	return nil
+}
+
+// AuditChain replays an exported chain offline and prints the report. It
+// fails if anomalies are found
+func AuditChain(path string) error {
+	export, err := readExport(path)
+	if err != nil {
+		return err
+	}
+
+	report := permissioned.Audit(export)
+	fmt.Print(report)
+	if len(report.Anomalies) > 0 {
+		return fmt.Errorf("%d anomalies found", len(report.Anomalies))
+	}
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// Utils
+
+func openChain(folder string) (*permissioned.Blockchain, error) {
+	if folder == "" {
+		return nil, fmt.Errorf("empty storage folder")
+	}
+	storage, err := file.NewPersistency(folder)
+	if err != nil {
+		return nil, err
+	}
+	return permissioned.NewBlockchainWithStore(storage.GetBlockchainStore())
+}
+
+func readExport(path string) (*permissioned.ChainExport, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return permissioned.DecodeExport(buf)
+}
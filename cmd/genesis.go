@@ -0,0 +1,183 @@
+package cmd
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	z "go.dedis.ch/cs438/internal/testing"
+	"go.dedis.ch/cs438/permissioned-chain"
+)
+
+// GenesisOptions are the parameters of a new chain given to the genesis
+// command
+type GenesisOptions struct {
+	// members as <address>[=<balance>]. The balance is 0 if not set
+	Members []string
+	// key signing the genesis, in plaintext or in a keystore
+	Key      string
+	Keystore string
+
+	MaxTxnsPerBlk       int
+	WaitTimeout         string
+	MPCGain             string
+	JoinThreshold       float64
+	RevocationThreshold float64
+	MinStake            string
+	EndorseDeadline     uint
+	SlashRatio          float64
+	SlashReward         float64
+	Finality            bool
+	LightNodes          []string
+}
+
+// -----------------------------------------------------------------------------
+// Genesis actions
+
+// Start the blockchain from a genesis file
+func startGenesisBC(node *z.TestNode, actionMap map[string]ActionFunc) error {
+	fmt.Println("Enter the path to the genesis file: ")
+	fp := ""
+	fmt.Scanln(&fp)
+
+	genesis, err := permissioned.LoadGenesis(fp)
+	if err != nil {
+		return err
+	}
+
+	// node must be inside the chain participants
+	addr, err := node.BCGetAddress()
+	if err != nil {
+		return err
+	}
+	config := permissioned.GetConfigFromWorldState(genesis.Block.States)
+	_, ok := config.Participants[addr.Hex]
+	if !ok {
+		return fmt.Errorf("you should be in the chain participant list")
+	}
+
+	return node.InitBlockchainFromGenesis(genesis)
+}
+
+// -----------------------------------------------------------------------------
+// Genesis commands
+
+// BuildGenesis validates the options and writes the signed genesis file
+// that every member loads to start the chain
+func BuildGenesis(path string, opts GenesisOptions) error {
+	err := checkNewFile(path)
+	if err != nil {
+		return err
+	}
+
+	members := make([]permissioned.GenesisMember, len(opts.Members))
+	for i, spec := range opts.Members {
+		member, err := parseGenesisMember(spec)
+		if err != nil {
+			return err
+		}
+		members[i] = *member
+	}
+	config, err := genesisConfig(opts)
+	if err != nil {
+		return err
+	}
+	privateKey, err := genesisKey(opts)
+	if err != nil {
+		return err
+	}
+
+	genesis, err := permissioned.NewGenesis(*config, members, privateKey)
+	if err != nil {
+		return err
+	}
+	err = genesis.Save(path)
+	if err != nil {
+		return err
+	}
+	fmt.Println("Genesis block", genesis.Block.Hash(), "signed by", genesis.Signer,
+		"written in", path)
+	return nil
+}
+
+// VerifyGenesis checks a genesis file and prints the chain it starts
+func VerifyGenesis(path string) error {
+	genesis, err := permissioned.LoadGenesis(path)
+	if err != nil {
+		return err
+	}
+
+	config := permissioned.GetConfigFromWorldState(genesis.Block.States)
+	fmt.Println("Genesis block", genesis.Block.Hash(), "signed by", genesis.Signer)
+	fmt.Println("Chain config:", config)
+	for participant := range config.Participants {
+		account := permissioned.GetAccountFromWorldState(genesis.Block.States, participant)
+		fmt.Printf("\t%s: %s\n", participant, account.GetBalance())
+	}
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// Utils
+
+// parseGenesisMember reads a member given as <address>[=<balance>]
+func parseGenesisMember(spec string) (*permissioned.GenesisMember, error) {
+	addr, balance, found := strings.Cut(spec, "=")
+	member := permissioned.GenesisMember{Address: addr}
+	if !found {
+		return &member, nil
+	}
+
+	amount, err := permissioned.ParseAmount(balance)
+	if err != nil {
+		return nil, fmt.Errorf("invalid balance of member %s: %v", addr, err)
+	}
+	member.Balance = amount
+	return &member, nil
+}
+
+// genesisConfig returns the config of the options. Participants are set
+// from the members by NewGenesis
+func genesisConfig(opts GenesisOptions) (*permissioned.ChainConfig, error) {
+	mpcGain, err := permissioned.ParseAmount(opts.MPCGain)
+	if err != nil {
+		return nil, fmt.Errorf("invalid MPC gain: %v", err)
+	}
+	minStake, err := permissioned.ParseAmount(opts.MinStake)
+	if err != nil {
+		return nil, fmt.Errorf("invalid min stake: %v", err)
+	}
+
+	config := permissioned.NewChainConfig(nil, opts.MaxTxnsPerBlk, opts.WaitTimeout,
+		mpcGain, opts.JoinThreshold)
+	config.RevocationThreshold = opts.RevocationThreshold
+	config.MinStake = minStake
+	config.EndorseDeadline = opts.EndorseDeadline
+	config.SlashRatio = opts.SlashRatio
+	config.SlashReward = opts.SlashReward
+	config.Finality = opts.Finality
+	config.LightNodes = opts.LightNodes
+	return config, nil
+}
+
+// genesisKey loads the key signing the genesis
+func genesisKey(opts GenesisOptions) (*ecdsa.PrivateKey, error) {
+	switch {
+	case opts.Key != "" && opts.Keystore != "":
+		return nil, fmt.Errorf("sign with either a key or a keystore")
+	case opts.Key != "":
+		return crypto.LoadECDSA(opts.Key)
+	case opts.Keystore != "":
+		passphrase, err := askPassphrase("Passphrase:")
+		if err != nil {
+			return nil, err
+		}
+		keys, err := permissioned.LoadKeystore(opts.Keystore, passphrase)
+		if err != nil {
+			return nil, err
+		}
+		return keys.ChainKey, nil
+	}
+	return nil, fmt.Errorf("a key or a keystore is needed to sign the genesis")
+}
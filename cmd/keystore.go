@@ -0,0 +1,167 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/ethereum/go-ethereum/crypto"
+	z "go.dedis.ch/cs438/internal/testing"
+	"go.dedis.ch/cs438/permissioned-chain"
+)
+
+// -----------------------------------------------------------------------------
+// Keystore actions
+
+// Create a new identity in a keystore
+func createKeystore(node *z.TestNode, actionMap map[string]ActionFunc) error {
+	fmt.Println("Where do you want to store the keystore? Enter the path:  ")
+	fp := ""
+	fmt.Scanln(&fp)
+
+	err := checkNewFile(fp)
+	if err != nil {
+		return err
+	}
+	passphrase, err := askNewPassphrase()
+	if err != nil {
+		return err
+	}
+
+	return node.BCGenerateKeystore(fp, passphrase)
+}
+
+// Unlock a keystore from filesystem
+func unlockKeystore(node *z.TestNode, actionMap map[string]ActionFunc) error {
+	fmt.Println("Enter the path to your keystore:  ")
+	fp := ""
+	fmt.Scanln(&fp)
+
+	passphrase, err := askPassphrase("Passphrase:")
+	if err != nil {
+		return err
+	}
+
+	return node.BCLoadKeystore(fp, passphrase)
+}
+
+// -----------------------------------------------------------------------------
+// Keystore commands
+
+// CreateKeystore generates a new identity in a keystore file
+func CreateKeystore(path string) error {
+	err := checkNewFile(path)
+	if err != nil {
+		return err
+	}
+	passphrase, err := askNewPassphrase()
+	if err != nil {
+		return err
+	}
+
+	keys, err := permissioned.NewKeystore()
+	if err != nil {
+		return err
+	}
+	err = keys.Save(path, passphrase)
+	if err != nil {
+		return err
+	}
+	fmt.Println("Keystore of account", keys.Address().Hex, "saved in", path)
+	return nil
+}
+
+// ImportKeystore protects a plaintext chain key in a keystore file. A new
+// encryption key is generated
+func ImportKeystore(keyPath, path string) error {
+	chainKey, err := crypto.LoadECDSA(keyPath)
+	if err != nil {
+		return err
+	}
+	err = checkNewFile(path)
+	if err != nil {
+		return err
+	}
+	passphrase, err := askNewPassphrase()
+	if err != nil {
+		return err
+	}
+
+	keys, err := permissioned.NewKeystoreFromKey(chainKey)
+	if err != nil {
+		return err
+	}
+	err = keys.Save(path, passphrase)
+	if err != nil {
+		return err
+	}
+	fmt.Println("Keystore of account", keys.Address().Hex, "saved in", path)
+	fmt.Println("You can now delete the plaintext key", keyPath)
+	return nil
+}
+
+// ExportKeystore writes the chain key of a keystore in plaintext, e.g. to
+// use it with BCLoadKeyPair
+func ExportKeystore(path, keyPath string) error {
+	passphrase, err := askPassphrase("Passphrase:")
+	if err != nil {
+		return err
+	}
+	keys, err := permissioned.LoadKeystore(path, passphrase)
+	if err != nil {
+		return err
+	}
+
+	err = checkNewFile(keyPath)
+	if err != nil {
+		return err
+	}
+	err = crypto.SaveECDSA(keyPath, keys.ChainKey)
+	if err != nil {
+		return err
+	}
+	fmt.Println("Plaintext key of account", keys.Address().Hex, "written in", keyPath)
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// Utils
+
+// checkNewFile makes sure a key is not written over an existing file
+func checkNewFile(path string) error {
+	if path == "" {
+		return fmt.Errorf("empty path")
+	}
+	_, err := os.Stat(path)
+	if err == nil {
+		return fmt.Errorf("file %s already exists", path)
+	}
+	if !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func askPassphrase(message string) (string, error) {
+	passphrase := ""
+	err := survey.AskOne(&survey.Password{Message: message}, &passphrase)
+	return passphrase, err
+}
+
+func askNewPassphrase() (string, error) {
+	passphrase, err := askPassphrase("New passphrase:")
+	if err != nil {
+		return "", err
+	}
+	if passphrase == "" {
+		return "", fmt.Errorf("the passphrase must not be empty")
+	}
+	again, err := askPassphrase("Repeat the passphrase:")
+	if err != nil {
+		return "", err
+	}
+	if again != passphrase {
+		return "", fmt.Errorf("the passphrases do not match")
+	}
+	return passphrase, nil
+}
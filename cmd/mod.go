@@ -21,17 +21,28 @@ import (
 
 const (
 	StartNewBC      = "🌱 Start a new blockchain"
+	StartGenesisBC  = "🌾 Start a blockchain from a genesis file"
 	StartExistingBC = "🌿 Use an existing blockchain"
 
 	GenerateBCAccount = "🌱 Generate a new blockchain account"
 	LoadBCAccount     = "🌿 Use an existing blockchain account"
+	CreateBCKeystore  = "🔐 Create a new keystore"
+	UnlockBCKeystore  = "🔓 Unlock a keystore"
 
 	MPCCalc = "🦑 MPC Calculate"
 
 	ShowAssets     = "🐥 Show Assets"
 	AddAsset       = "🐣 Add Asset to Database"
+	UpdateAsset    = "🐤 Update Asset Price"
+	RemoveAsset    = "🐔 Remove Asset"
+	TransferAsset  = "🦆 Transfer Asset"
+	SetAssetPolicy = "🦉 Set Asset Policy"
 	ShowBalance    = "🐳 Show Balance"
+	StakeCoins     = "🦀 Stake Coins"
+	ShowReceipt    = "🦐 Show Transaction Receipt"
 	ShowBlockchain = "🐋 Show Blockchain"
+	SyncBlockchain = "🐬 Sync Blockchain"
+	SaveBlockchain = "🐠 Export Blockchain"
 	AddPeer        = "🦈 Add Peer"
 	ShowEncKey     = "🐊 Show Encryption Pubkey"
 	Refresh        = "🐙 Refresh"
@@ -46,17 +57,27 @@ type ActionFunc func(*z.TestNode, map[string]ActionFunc) error
 
 var actionMap = map[string]ActionFunc{
 	StartNewBC:      startNewBC,
+	StartGenesisBC:  startGenesisBC,
 	StartExistingBC: startExistingBC,
 
 	GenerateBCAccount: createAccount,
 	LoadBCAccount:     loadAccount,
+	CreateBCKeystore:  createKeystore,
+	UnlockBCKeystore:  unlockKeystore,
 
 	MPCCalc: startMPC,
 
 	ShowAssets:     showAssets,
 	AddAsset:       addAsset,
+	UpdateAsset:    updateAsset,
+	RemoveAsset:    removeAsset,
+	TransferAsset:  transferAsset,
+	SetAssetPolicy: setAssetPolicy,
 	ShowBalance:    getBCBalance,
+	StakeCoins:     stakeCoins,
+	ShowReceipt:    showReceipt,
 	ShowBlockchain: getBCInfo,
+	SaveBlockchain: exportChain,
 	AddPeer:        addPeer,
 	ShowEncKey:     getEnckey,
 	Refresh:        refresh,
@@ -85,9 +85,9 @@ func loadConfigAndInit(node *z.TestNode) error {
 
 	// initial Gain is equal per account. (100)
 	// FIXME: customize setting?
-	initialGain := make(map[string]float64)
+	initialGain := make(map[string]permissioned.Amount)
 	for key := range config.Participants {
-		initialGain[key] = 100
+		initialGain[key] = permissioned.Coins(100)
 	}
 
 	// init blockchain
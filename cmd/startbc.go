@@ -19,6 +19,8 @@ var promptAccount = &survey.Select{
 }
 
 var actionOptsAccount = []string{
+	UnlockBCKeystore,
+	CreateBCKeystore,
 	GenerateBCAccount,
 	LoadBCAccount,
 	Exit,
@@ -34,6 +36,7 @@ var promptInit = &survey.Select{
 
 var actionOptsInit = []string{
 	StartNewBC,
+	StartGenesisBC,
 	StartExistingBC,
 	Exit,
 }
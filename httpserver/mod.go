@@ -7,6 +7,7 @@ import (
 
 	"github.com/rs/zerolog/log"
 	z "go.dedis.ch/cs438/internal/testing"
+	permissioned "go.dedis.ch/cs438/permissioned-chain"
 )
 
 type PeerJson struct {
@@ -14,20 +15,21 @@ type PeerJson struct {
 }
 
 type AssetJson struct {
-	Key   string  `json:"Key"`
-	Value int     `json:"Value"`
-	Price float64 `json:"Price"`
+	Key   string              `json:"Key"`
+	Value int                 `json:"Value"`
+	Price permissioned.Amount `json:"Price"`
 }
 
 type MPCRequestJson struct {
-	Expr   string `json:"Expr"`
-	Budget int    `json:"Budget"`
+	Expr   string              `json:"Expr"`
+	Budget permissioned.Amount `json:"Budget"`
+	Fee    permissioned.Amount `json:"Fee"`
 }
 
 type MPCReplyJson struct {
-	Expr    string  `json:"Expr"`
-	Balance float64 `json:"Balance"`
-	Result  int     `json:"Result"`
+	Expr    string              `json:"Expr"`
+	Balance permissioned.Amount `json:"Balance"`
+	Result  int                 `json:"Result"`
 }
 
 func handler(n *z.TestNode) http.HandlerFunc {
@@ -95,7 +97,7 @@ func assetHandler(n *z.TestNode) http.HandlerFunc {
 				return
 			}
 
-			log.Info().Msgf("HTTP adding assets key: %s, value: %d, price: %f", asset.Key, asset.Value, asset.Price)
+			log.Info().Msgf("HTTP adding assets key: %s, value: %d, price: %s", asset.Key, asset.Value, asset.Price)
 			err = n.SetValueDBAsset(asset.Key, asset.Value, asset.Price)
 			if err != nil {
 				http.Error(w, err.Error(), http.StatusBadRequest)
@@ -122,7 +124,7 @@ func balanceHandler(n *z.TestNode) http.HandlerFunc {
 			// Set the content type to JSON
 			w.Header().Set("Content-Type", "application/json")
 
-			balance := map[string]float64{}
+			balance := map[string]permissioned.Amount{}
 			addr, _ := n.BCGetAddress()
 			balance[addr.Hex] = n.BCGetBalance()
 
@@ -172,8 +174,8 @@ func mpcHandler(n *z.TestNode) http.HandlerFunc {
 				return
 			}
 
-			log.Info().Msgf("Http MPC Calculate expr: %s, budget: %d", mpcRequest.Expr, mpcRequest.Budget)
-			ans, err := n.Calculate(mpcRequest.Expr, float64(mpcRequest.Budget))
+			log.Info().Msgf("Http MPC Calculate expr: %s, budget: %s", mpcRequest.Expr, mpcRequest.Budget)
+			ans, err := n.CalculateWithFee(mpcRequest.Expr, mpcRequest.Budget, mpcRequest.Fee)
 			if err != nil {
 				http.Error(w, err.Error(), http.StatusBadRequest)
 				return
@@ -192,6 +194,66 @@ func mpcHandler(n *z.TestNode) http.HandlerFunc {
 	}
 }
 
+// eventsHandler streams the chain events matching the query as Server-Sent
+// Events. The filter is given by the repeatable parameters type, txnType,
+// account, asset and mpc
+func eventsHandler(n *z.TestNode) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Allow-Methods", "GET")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		if r.Method != http.MethodGet {
+			return
+		}
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+			return
+		}
+
+		query := r.URL.Query()
+		filter := permissioned.EventFilter{
+			Accounts: query["account"],
+			Assets:   query["asset"],
+			MPCs:     query["mpc"],
+		}
+		for _, eventType := range query["type"] {
+			filter.Types = append(filter.Types, permissioned.EventType(eventType))
+		}
+		for _, txnType := range query["txnType"] {
+			filter.TxnTypes = append(filter.TxnTypes, permissioned.TxnType(txnType))
+		}
+
+		id, events := n.BCSubscribe(filter)
+		defer n.BCUnsubscribe(id)
+		log.Info().Msgf("HTTP subscription %s", id)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		flusher.Flush()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case event, ok := <-events:
+				if !ok {
+					// the client lagged behind and must reconnect
+					return
+				}
+				data, err := json.Marshal(event)
+				if err != nil {
+					log.Err(err).Msgf("failed to marshal event %s", event)
+					continue
+				}
+				fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+				flusher.Flush()
+			}
+		}
+	}
+}
+
 func MainHttp(n *z.TestNode, port string) {
 	http.HandleFunc("/", handler(n))
 	http.HandleFunc("/peer", peerHandler(n))
@@ -199,6 +261,7 @@ func MainHttp(n *z.TestNode, port string) {
 	http.HandleFunc("/balance", balanceHandler(n))
 	http.HandleFunc("/blockchain", blockchainHandler(n))
 	http.HandleFunc("/mpcCalculate", mpcHandler(n))
+	http.HandleFunc("/events", eventsHandler(n))
 
 	http.ListenAndServe(port, nil)
 
@@ -217,6 +280,9 @@ func MainHttp(n *z.TestNode, port string) {
 	// // get blockchain
 	// curl http://127.0.0.1:7122/blockchain
 
+	// // stream the txns of an account
+	// curl -N "http://127.0.0.1:7122/events?type=txn&account=0x..."
+
 	// // post mpcCalculate
 	// curl -X POST -H "Content-Type: application/json" -d '{"Expr":"a+b", "Budget":10}' http://127.0.0.1:7122/mpcCalculate
 
@@ -155,6 +155,7 @@ type configTemplate struct {
 	disableAnnonceEnckey bool
 	MPCtype              peer.MPCConsensus
 	MPCMaxWaitBlock      int
+	lightChain           bool
 }
 
 func newConfigTemplate() configTemplate {
@@ -193,6 +194,7 @@ func newConfigTemplate() configTemplate {
 		disableAnnonceEnckey: false,
 		MPCtype:              peer.MPCConsensusBC,
 		MPCMaxWaitBlock:      2,
+		lightChain:           false,
 	}
 }
 
@@ -330,6 +332,13 @@ func WithMPCMaxWaitBlock(t int) Option {
 	}
 }
 
+// WithLightChain runs the node as a light node of the permissioned chain.
+func WithLightChain() Option {
+	return func(ct *configTemplate) {
+		ct.lightChain = true
+	}
+}
+
 // NewTestNode returns a new test node.
 func NewTestNode(t require.TestingT, f peer.Factory, trans transport.Transport,
 	addr string, opts ...Option) TestNode {
@@ -361,6 +370,7 @@ func NewTestNode(t require.TestingT, f peer.Factory, trans transport.Transport,
 	config.DisableAnnonceEnckey = template.disableAnnonceEnckey
 	config.MPCType = template.MPCtype
 	config.MPCMaxWaitBlock = template.MPCMaxWaitBlock
+	config.LightChain = template.lightChain
 
 	node := f(config)
 
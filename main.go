@@ -5,6 +5,9 @@ import (
 	"github.com/spf13/cobra"
 
 	cli "go.dedis.ch/cs438/cmd"
+	z "go.dedis.ch/cs438/internal/testing"
+	permissioned "go.dedis.ch/cs438/permissioned-chain"
+	"go.dedis.ch/cs438/storage/file"
 )
 
 func main() {
@@ -13,6 +16,9 @@ func main() {
 	}
 	addCliCmd(command)
 	addDaemonCmd(command)
+	addKeystoreCmd(command)
+	addChainCmd(command)
+	addGenesisCmd(command)
 
 	err := command.Execute()
 	if err != nil {
@@ -23,6 +29,8 @@ func main() {
 // addCliCmd starts a node with customization
 func addCliCmd(command *cobra.Command) {
 	var port int
+	var storagePath string
+	var light bool
 	// var opts []z.Option
 
 	startCmd := &cobra.Command{
@@ -32,11 +40,22 @@ func addCliCmd(command *cobra.Command) {
 		Args:  cobra.MinimumNArgs(0),
 		Run: func(cmd *cobra.Command, args []string) {
 			zerolog.SetGlobalLevel(zerolog.ErrorLevel)
-			cli.StartCMD(port, false)
+			opts, err := storageOpts(storagePath)
+			if err != nil {
+				panic(err)
+			}
+			if light {
+				opts = append(opts, z.WithLightChain())
+			}
+			cli.StartCMD(port, false, opts...)
 		},
 	}
 
 	startCmd.Flags().IntVarP(&port, "port", "p", 0, "Start node on a customized port")
+	startCmd.Flags().StringVarP(&storagePath, "storage", "s", "",
+		"Persist the node's data in a folder to resume it after a restart")
+	startCmd.Flags().BoolVarP(&light, "light", "l", false,
+		"Only follow the block headers and query full nodes for proven values")
 
 	command.AddCommand(startCmd)
 }
@@ -44,6 +63,8 @@ func addCliCmd(command *cobra.Command) {
 // addStartCmd starts a node with customization
 func addDaemonCmd(command *cobra.Command) {
 	var port int
+	var storagePath string
+	var light bool
 	// var opts []z.Option
 
 	daemonCmd := &cobra.Command{
@@ -53,11 +74,187 @@ func addDaemonCmd(command *cobra.Command) {
 		Args:  cobra.MinimumNArgs(0),
 		Run: func(cmd *cobra.Command, args []string) {
 			zerolog.SetGlobalLevel(zerolog.ErrorLevel)
-			cli.StartCMD(port, true)
+			opts, err := storageOpts(storagePath)
+			if err != nil {
+				panic(err)
+			}
+			if light {
+				opts = append(opts, z.WithLightChain())
+			}
+			cli.StartCMD(port, true, opts...)
 		},
 	}
 
 	daemonCmd.Flags().IntVarP(&port, "port", "p", 0, "Start node on a customized port")
+	daemonCmd.Flags().StringVarP(&storagePath, "storage", "s", "",
+		"Persist the node's data in a folder to resume it after a restart")
+	daemonCmd.Flags().BoolVarP(&light, "light", "l", false,
+		"Only follow the block headers and query full nodes for proven values")
 
 	command.AddCommand(daemonCmd)
 }
+
+// addKeystoreCmd manages the passphrase-protected keystores holding the
+// identity of a node
+func addKeystoreCmd(command *cobra.Command) {
+	keystoreCmd := &cobra.Command{
+		Use:   "keystore",
+		Short: "Manage keystores",
+		Long:  "Create, import and export the keystores holding the chain and encryption keys of a node",
+	}
+
+	createCmd := &cobra.Command{
+		Use:   "create <keystore>",
+		Short: "Create a keystore with a new identity",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cli.CreateKeystore(args[0])
+		},
+	}
+
+	importCmd := &cobra.Command{
+		Use:   "import <key> <keystore>",
+		Short: "Protect a plaintext chain key in a keystore",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cli.ImportKeystore(args[0], args[1])
+		},
+	}
+
+	exportCmd := &cobra.Command{
+		Use:   "export <keystore> <key>",
+		Short: "Write the chain key of a keystore in plaintext",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cli.ExportKeystore(args[0], args[1])
+		},
+	}
+
+	keystoreCmd.AddCommand(createCmd, importCmd, exportCmd)
+	command.AddCommand(keystoreCmd)
+}
+
+// addChainCmd takes the chain out of the storage folder of a stopped node
+// and audits it offline
+func addChainCmd(command *cobra.Command) {
+	var storagePath string
+	var snapshots bool
+
+	chainCmd := &cobra.Command{
+		Use:   "chain",
+		Short: "Export, import and audit chains",
+		Long:  "Export the chain of a node, import it into a fresh node and audit it offline",
+	}
+
+	exportCmd := &cobra.Command{
+		Use:   "export <file>",
+		Short: "Export the chain persisted in a storage folder",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cli.ExportChain(storagePath, args[0], snapshots)
+		},
+	}
+	exportCmd.Flags().StringVarP(&storagePath, "storage", "s", "",
+		"Storage folder of the node")
+	exportCmd.Flags().BoolVar(&snapshots, "snapshots", false,
+		"Include the world state snapshots")
+
+	importCmd := &cobra.Command{
+		Use:   "import <file>",
+		Short: "Import an exported chain into the storage folder of a fresh node",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cli.ImportChain(args[0], storagePath)
+		},
+	}
+	importCmd.Flags().StringVarP(&storagePath, "storage", "s", "",
+		"Storage folder of the node")
+
+	auditCmd := &cobra.Command{
+		Use:   "audit <file>",
+		Short: "Replay an exported chain and check its balance invariants",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cli.AuditChain(args[0])
+		},
+	}
+
+	chainCmd.AddCommand(exportCmd, importCmd, auditCmd)
+	command.AddCommand(chainCmd)
+}
+
+// addGenesisCmd builds the signed genesis file of a new chain, loaded by
+// every member instead of hand-editing a config
+func addGenesisCmd(command *cobra.Command) {
+	var opts cli.GenesisOptions
+
+	genesisCmd := &cobra.Command{
+		Use:   "genesis",
+		Short: "Build and verify genesis files",
+		Long:  "Build the signed genesis file of a new chain and verify it before starting the nodes",
+	}
+
+	buildCmd := &cobra.Command{
+		Use:   "build <file>",
+		Short: "Validate the members and parameters of a new chain and sign its genesis",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cli.BuildGenesis(args[0], opts)
+		},
+	}
+	buildCmd.Flags().StringArrayVarP(&opts.Members, "member", "m", nil,
+		"Member of the chain as <address>[=<balance>], repeated for each member")
+	buildCmd.Flags().StringVarP(&opts.Key, "key", "k", "",
+		"Plaintext chain key of the member signing the genesis")
+	buildCmd.Flags().StringVar(&opts.Keystore, "keystore", "",
+		"Keystore of the member signing the genesis")
+	buildCmd.Flags().IntVar(&opts.MaxTxnsPerBlk, "max-txns", 10,
+		"Maximal number of transactions per block")
+	buildCmd.Flags().StringVar(&opts.WaitTimeout, "wait-timeout", "2s",
+		"Maximal time a miner waits for the next transaction")
+	buildCmd.Flags().StringVar(&opts.MPCGain, "mpc-gain", "1",
+		"Fee paid to each committee member of an MPC")
+	buildCmd.Flags().Float64Var(&opts.JoinThreshold, "join-threshold", 1,
+		"Fraction of the participants endorsing a new participant")
+	buildCmd.Flags().Float64Var(&opts.RevocationThreshold, "revocation-threshold",
+		permissioned.DEFAULT_REVOCATION_THRESHOLD,
+		"Fraction of the other participants approving a key revocation, more than half")
+	buildCmd.Flags().StringVar(&opts.MinStake, "min-stake", "0",
+		"Minimal stake to be eligible for MPC committees")
+	buildCmd.Flags().UintVar(&opts.EndorseDeadline, "endorse-deadline", 0,
+		"Number of blocks to endorse an MPC, 0 for no deadline")
+	buildCmd.Flags().Float64Var(&opts.SlashRatio, "slash-ratio", 0,
+		"Fraction of the offender's stake removed by a slash")
+	buildCmd.Flags().Float64Var(&opts.SlashReward, "slash-reward", 0,
+		"Fraction of the slashed amount given to the reporter")
+	buildCmd.Flags().BoolVar(&opts.Finality, "finality", false,
+		"Blocks are final once voted by a quorum of participants")
+	buildCmd.Flags().StringArrayVar(&opts.LightNodes, "light", nil,
+		"Member running as a light node, repeated for each of them")
+
+	verifyCmd := &cobra.Command{
+		Use:   "verify <file>",
+		Short: "Verify a genesis file and print the chain it starts",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cli.VerifyGenesis(args[0])
+		},
+	}
+
+	genesisCmd.AddCommand(buildCmd, verifyCmd)
+	command.AddCommand(genesisCmd)
+}
+
+// storageOpts returns the option to use a file storage in the folder.
+// Nodes keep their data in memory if the folder is empty
+func storageOpts(folder string) ([]z.Option, error) {
+	if folder == "" {
+		return nil, nil
+	}
+
+	storage, err := file.NewPersistency(folder)
+	if err != nil {
+		return nil, err
+	}
+	return []z.Option{z.WithStorage(storage)}, nil
+}
@@ -6,11 +6,26 @@ import (
 	permissioned "go.dedis.ch/cs438/permissioned-chain"
 )
 
+// PermissionedChain is the interface of a node of the permissioned chain.
+// A light node (see Configuration.LightChain) implements it with the block
+// headers only: transactions and state values are proven by full nodes,
+// and the returned blocks only have their header, except for the genesis
+// block.
+//
+// A light node cannot check the stake-weighted miner selection, so it
+// trusts the validators not to sign a longer private chain: any single one
+// could make it accept forged values. With ChainConfig.Finality, values are
+// only proven on the headers a quorum of validators voted for, which
+// removes this assumption as long as the quorum is honest
 type PermissionedChain interface {
 	// InitBlockchain inits a new blockchain
 	// by creating and distributing a genesis block
 	// with the given config
-	InitBlockchain(config permissioned.ChainConfig, initialGain map[string]float64) error
+	InitBlockchain(config permissioned.ChainConfig, initialGain map[string]permissioned.Amount) error
+
+	// InitBlockchainFromGenesis inits a new blockchain with a genesis
+	// built beforehand. It is not distributed: every participant loads it
+	InitBlockchainFromGenesis(genesis *permissioned.Genesis) error
 
 	// BCSendTransaction signs the given transaction
 	// and broadcast it to the network in private message
@@ -24,13 +39,37 @@ type PermissionedChain interface {
 	// the blockchain. It returns nil if txn not exists
 	BCGetTransaction(txnID string) *permissioned.SignedTransaction
 
+	// BCGetReceipt returns the receipt of a transaction sent by the node:
+	// pending, included with its block position, or rejected with the
+	// reason. It returns an error if the node did not send the transaction
+	BCGetReceipt(txnID string) (*permissioned.TxnReceipt, error)
+
+	// BCBumpTransaction replaces a pending transaction of the node by the
+	// same transaction with a higher tip, so that it is included first.
+	// It returns the ID of the replacing transaction
+	BCBumpTransaction(txnID string, tip permissioned.Amount) (string, error)
+
+	// BCCancelTransaction replaces a pending transaction of the node by a
+	// transaction doing nothing with a higher tip. It returns the ID of
+	// the replacing transaction
+	BCCancelTransaction(txnID string, tip permissioned.Amount) (string, error)
+
 	// BCWaitBlock blocks the thread until the genesis block is found
 	BCWaitBlock() *permissioned.Block
 
+	// BCSync catches up with the longest chain of the neighbors.
+	// It blocks until the missing blocks are appended
+	BCSync() error
+
 	// BCGetLastBlock returns the latest block of the blockchain
 	// it returns nil if blockchain not initialize
 	BCGetLatestBlock() *permissioned.Block
 
+	// BCGetFinalBlock returns the latest final block. Blocks up to it can
+	// no longer be reverted. Without finality, it is the genesis block.
+	// It returns nil if blockchain not initialize
+	BCGetFinalBlock() *permissioned.Block
+
 	// BCGetBlock returns the requested block of the blockchain
 	// it returns nil if blockchain not initialize or block not exists
 	BCGetBlock(blockID string) *permissioned.Block
@@ -41,21 +80,96 @@ type PermissionedChain interface {
 	BCGetAddress() (permissioned.Address, error)
 
 	// BCGetBalance returns the current balance of the node's account
-	BCGetBalance() float64
+	BCGetBalance() permissioned.Amount
+
+	// BCStake locks the given amount of the node's balance as stake
+	// so that the node is eligible for MPC committees.
+	// It returns the ID of the sent transaction
+	BCStake(amount permissioned.Amount) (string, error)
+
+	// BCUnstake withdraws the given amount of stake back to balance.
+	// It returns the ID of the sent transaction
+	BCUnstake(amount permissioned.Amount) (string, error)
+
+	// BCRegMultisig creates a multisig account whose transactions need the
+	// signatures of the policy's threshold of owners. The deposit is moved
+	// from the node's balance to the account. It returns the address of the
+	// account, which exists once the transaction is included
+	BCRegMultisig(policy permissioned.MultisigPolicy, deposit permissioned.Amount) (permissioned.Address, error)
+
+	// BCCosignTransaction adds the node's signature to a transaction of a
+	// multisig account the node owns. Owners pass the transaction to each
+	// other off-chain, then send it with BCSendTransaction
+	BCCosignTransaction(txn *permissioned.SignedTransaction) error
+
+	// BCGetStake returns the current stake of the node's account
+	BCGetStake() permissioned.Amount
+
+	// BCSearchAssets returns the registered assets whose key, owner or
+	// schema contains the query. An empty query returns all assets.
+	// A light node has no assets to search
+	BCSearchAssets(query string) []permissioned.AssetInfo
+
+	// BCGetTxnProof returns the proof that the transaction is included
+	// in the chain, verifiable against the header of its block
+	BCGetTxnProof(txnID string) (*permissioned.TxnProof, error)
+
+	// BCGetAccountProof returns the proof of the current state of the
+	// account, verifiable against the header of the latest block
+	BCGetAccountProof(addr string) (*permissioned.StateProof, error)
+
+	// BCGetAssetsProof returns the proof of the current assets of the
+	// owner, verifiable against the header of the latest block
+	BCGetAssetsProof(owner string) (*permissioned.StateProof, error)
+
+	// BCRotateKey moves the node's account, balance, stake, assets and
+	// participant slot to the address of the new key. The transaction is
+	// signed by the current key and the node switches to the new key once
+	// it is included. It returns the ID of the sent transaction
+	BCRotateKey(newKey ecdsa.PrivateKey) (string, error)
+
+	// BCRevokeKey approves moving the identity of another participant whose
+	// key leaked to a new address. It happens once the RevocationThreshold of the
+	// other participants approved. The member then sets its new key with
+	// BCSetKeyPair. It returns the ID of the sent transaction
+	BCRevokeKey(revocation permissioned.KeyRevocation) (string, error)
+
+	// BCSubscribe subscribes to the blocks, txns and MPC state changes
+	// matching the filter. It returns the ID of the subscription and the
+	// channel of its events. The channel is closed on BCUnsubscribe, or if
+	// the subscriber does not keep up with the chain
+	BCSubscribe(filter permissioned.EventFilter) (string, <-chan permissioned.Event)
+
+	// BCUnsubscribe closes a subscription
+	BCUnsubscribe(id string)
 
 	// BCGenerateKeyPair generates an ECDSA key pair
 	// and write it in the file
 	BCGenerateKeyPair(path string) error
 
-	// BCSetKeyPair sets an ECDSA key pair to the node
+	// BCSetKeyPair sets an ECDSA key pair to the node. Once set, it can
+	// only be replaced by the key the account was rotated or revoked to
 	BCSetKeyPair(privkey ecdsa.PrivateKey) error
 
 	// BCLoadKeyPair loads an ECDSA key pair from file
 	BCLoadKeyPair(path string) error
 
+	// BCGenerateKeystore generates a chain key and a message encryption
+	// key, sets them to the node and saves them in a keystore file
+	// protected by the passphrase
+	BCGenerateKeystore(path, passphrase string) error
+
+	// BCLoadKeystore unlocks a keystore file with its passphrase and sets
+	// its keys to the node
+	BCLoadKeystore(path, passphrase string) error
+
 	// BCAllEncryptKeySet checks if all encryption keys of nodes are registered
 	BCAllEncryptKeySet() bool
 
 	// BCSprintBlockchain returns a decription string of the blockchain
 	BCSprintBlockchain() string
+
+	// BCExportChain writes the canonical chain in the file, with the world
+	// state snapshots if asked. See permissioned.DecodeExport
+	BCExportChain(path string, snapshots bool) error
 }
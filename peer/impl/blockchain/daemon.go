@@ -51,6 +51,12 @@ out:
 				m.txnPool.PushBackSeveral(newBlock.Transactions)
 				continue out
 			}
+			err := m.wallet.SignBlock(newBlock)
+			if err != nil {
+				log.Err(err).Msgf("failed to sign mined block")
+				m.txnPool.PushBackSeveral(newBlock.Transactions)
+				continue out
+			}
 			log.Info().Msgf("Mined block %s on height=%d. Broadcasting...",
 				newBlock.Hash(), newBlock.Height)
 
@@ -60,7 +66,7 @@ out:
 			for p := range config.Participants {
 				participants[p] = struct{}{}
 			}
-			err := m.broadcastBCBlkMessage(participants, newBlock)
+			err = m.broadcastBCBlkMessage(participants, newBlock)
 			if err != nil {
 				log.Err(err).Send()
 			}
@@ -73,25 +79,29 @@ func createBlock(ctx context.Context, txnPool *TxnPool,
 
 	worldState := prevBlock.GetWorldStateCopy()
 	config := permissioned.GetConfigFromWorldState(worldState)
+	_, proof := permissioned.SelectMiner(prevBlock)
 	blkBuilder := permissioned.NewBlockBuilder()
 	blkBuilder.SetPrevHash(prevBlock.Hash()).
 		SetHeight(prevBlock.Height + 1).
-		SetMiner(miner)
+		SetMiner(miner).
+		SetSelectionProof(proof)
 	txnCount := 0
 
+	// next nonce of the accounts in the block being built
+	nextNonce := func(addr string) uint {
+		return permissioned.GetAccountFromWorldState(worldState, addr).GetNonce()
+	}
+
 	duration := getBlockTimeout(config)
 	timeout := time.After(duration)
 out:
 	for {
-		select {
-		case <-ctx.Done():
-			return nil
-		case <-timeout:
-			if txnCount > 0 {
-				// if any txn, then produce the block
-				break out
+		// take the ready txns by priority
+		for txnCount < config.MaxTxnsPerBlk {
+			signedTxn := txnPool.Pop(nextNonce)
+			if signedTxn == nil {
+				break
 			}
-		case signedTxn := <-txnPool.channel:
 			// coinbase trasaction can only be created by miner
 			if signedTxn.Txn.Type == permissioned.TxnTypeCoinbase {
 				continue
@@ -99,12 +109,8 @@ out:
 
 			err := signedTxn.Verify(worldState)
 			if err != nil {
-				// if too advance nonce, put txn back to txn pool
-				if err == permissioned.ErrNonceTooAdvace {
-					txnPool.Push(signedTxn)
-					continue
-				}
 				log.Warn().Msgf("%s", err)
+				txnPool.Reject(signedTxn, err)
 				continue
 			}
 			err = blkBuilder.AddTxn(signedTxn)
@@ -113,13 +119,23 @@ out:
 			}
 			txnCount++
 
-			if txnCount == config.MaxTxnsPerBlk {
-				break out
-			}
-
 			// reset timeout if a txn is successfuly append
 			timeout = time.After(duration)
 		}
+		if txnCount == config.MaxTxnsPerBlk {
+			break out
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-timeout:
+			if txnCount > 0 {
+				// if any txn, then produce the block
+				break out
+			}
+		case <-txnPool.Notify():
+		}
 	}
 
 	blkBuilder.SetState(worldState)
@@ -146,51 +162,60 @@ func (m *BlockchainModule) VerifyBlock(ctx context.Context) {
 			log.Info().Msgf("Verifying block %s on height=%d...",
 				block.Hash(), block.Height)
 
+			if m.GetBlock(block.Hash()) != nil {
+				// already received
+				continue
+			}
+
 			result := m.CheckBlockHeight(block)
 			if result == permissioned.BlockCompareAdvance ||
 				result == permissioned.BlockCompareNotInitialize {
 				// block too advance. Syncing
 				log.Info().Msgf("receive advance block on height %d. Syncing...", block.Height)
-				// err := m.sync(block.Miner)
-				// if err != nil {
-				// 	log.Err(err).Send()
-				// }
-				m.blkPool.Add(block)
+				m.syncAdvanceBlk(block)
 				continue
-			} else if result != permissioned.BlockCompareMatched {
+			}
+			if m.GetBlock(block.PrevHash) == nil {
 				log.Error().Msgf("block %s is invalid. Error code: %d",
 					block.Hash(), result)
 				continue
 			}
 
-			// validate consensus
-			expectedMiner := m.cr.getLatestMiner()
-			if expectedMiner != block.Miner {
-				log.Error().Msgf("invalid miner. Expected: %s. Got: %s",
-					expectedMiner, block.Miner)
+			// validate consensus on the branch of the block. The parent
+			// may be far below the tip, its state is replayed if pruned
+			err := m.VerifyMinerSelection(block)
+			if err != nil {
+				log.Err(err).Send()
 				continue
 			}
 
-			// append the block
-			err := m.AppendBlock(block)
+			// add the block
+			reorg, err := m.AddBlock(block)
 			if err != nil {
 				log.Err(err).Send()
 				continue
 			}
+			if reorg == nil {
+				// the block stays on a competing branch
+				log.Warn().Msgf("keep block %s on a competing branch at height=%d",
+					block.Hash(), block.Height)
+				continue
+			}
+			if len(reorg.Removed) > 0 {
+				m.reorganize(reorg)
+				continue
+			}
+
 			log.Info().Msgf("Append Block %s successfully",
 				block.Hash())
-			// notify outside for the received transactions
-			go func() {
-				m.wallet.Sync(block.States)
-
-				config := permissioned.GetConfigFromWorldState(block.States)
-				for _, signedTxn := range block.Transactions {
-					err = m.watchRegistry.Tell(config, &signedTxn.Txn)
-					if err != nil {
-						log.Err(err).Send()
-					}
-				}
-			}()
+			m.includeReceipts(block)
+			// notify outside for the received transactions,
+			// once final if the chain requires votes
+			if m.isFinalityMode(block) {
+				m.voteBlks(block)
+			} else {
+				go m.notifyBlk(block)
+			}
 
 			// select next miner
 			m.selectNextMiner(block)
@@ -0,0 +1,87 @@
+package blockchain
+
+import (
+	"sync"
+
+	"github.com/rs/xid"
+	"github.com/rs/zerolog/log"
+	permissioned "go.dedis.ch/cs438/permissioned-chain"
+)
+
+// EVENT_BUFFER_SIZE is the number of events a subscriber can lag behind
+// before it is closed
+var EVENT_BUFFER_SIZE = 256
+
+// -----------------------------------------------------------------------------
+// EventHub
+
+type subscription struct {
+	filter permissioned.EventFilter
+	events chan permissioned.Event
+}
+
+// EventHub delivers the chain events to the subscribers whose filter
+// matches. Publishing never blocks: a subscriber that does not keep up is
+// closed and must subscribe again
+type EventHub struct {
+	*sync.Mutex
+	subscriptions map[string]*subscription
+}
+
+func NewEventHub() *EventHub {
+	h := EventHub{
+		Mutex:         &sync.Mutex{},
+		subscriptions: map[string]*subscription{},
+	}
+	return &h
+}
+
+// Subscribe returns the ID of the subscription and the channel of its
+// events. The channel is closed on Unsubscribe
+func (h *EventHub) Subscribe(filter permissioned.EventFilter) (string, <-chan permissioned.Event) {
+	h.Lock()
+	defer h.Unlock()
+
+	id := xid.New().String()
+	events := make(chan permissioned.Event, EVENT_BUFFER_SIZE)
+	h.subscriptions[id] = &subscription{filter: filter, events: events}
+	return id, events
+}
+
+// Unsubscribe closes the subscription. It does nothing if it is already
+// closed
+func (h *EventHub) Unsubscribe(id string) {
+	h.Lock()
+	defer h.Unlock()
+
+	sub, ok := h.subscriptions[id]
+	if !ok {
+		return
+	}
+	close(sub.events)
+	delete(h.subscriptions, id)
+}
+
+// Publish sends the events to the matching subscribers
+func (h *EventHub) Publish(events ...permissioned.Event) {
+	h.Lock()
+	defer h.Unlock()
+
+	for id, sub := range h.subscriptions {
+		for _, event := range events {
+			if !sub.filter.Match(event) {
+				continue
+			}
+			select {
+			case sub.events <- event:
+			default:
+				log.Warn().Msgf("close subscription %s lagging behind", id)
+				close(sub.events)
+				delete(h.subscriptions, id)
+			}
+			if _, ok := h.subscriptions[id]; !ok {
+				break
+			}
+		}
+	}
+}
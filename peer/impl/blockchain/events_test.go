@@ -0,0 +1,51 @@
+package blockchain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	permissioned "go.dedis.ch/cs438/permissioned-chain"
+)
+
+func Test_BC_Event_Hub(t *testing.T) {
+	hub := NewEventHub()
+
+	stakeTxn := &permissioned.Transaction{ID: "1", Type: permissioned.TxnTypeStake, From: "a"}
+	cancelTxn := &permissioned.Transaction{ID: "2", Type: permissioned.TxnTypeCancel, From: "b"}
+	events := []permissioned.Event{
+		{Type: permissioned.EventBlock, Height: 1, BlockHash: "hash"},
+		{Type: permissioned.EventTxn, Height: 1, BlockHash: "hash", Txn: stakeTxn},
+		{Type: permissioned.EventTxn, Height: 1, BlockHash: "hash", Txn: cancelTxn},
+	}
+
+	allID, all := hub.Subscribe(permissioned.EventFilter{})
+	_, stakes := hub.Subscribe(permissioned.EventFilter{
+		TxnTypes: []permissioned.TxnType{permissioned.TxnTypeStake},
+	})
+	hub.Publish(events...)
+
+	// > the events are delivered in order to the matching subscribers
+
+	for _, expected := range events {
+		require.Equal(t, expected, <-all)
+	}
+	require.Equal(t, events[1], <-stakes)
+	require.Len(t, stakes, 0)
+
+	// > unsubscribing closes the channel
+
+	hub.Unsubscribe(allID)
+	_, ok := <-all
+	require.False(t, ok)
+	hub.Unsubscribe(allID)
+
+	// > a subscriber lagging behind is closed
+
+	for i := 0; i <= EVENT_BUFFER_SIZE; i++ {
+		hub.Publish(events[1])
+	}
+	require.Len(t, stakes, EVENT_BUFFER_SIZE)
+	for range stakes {
+	}
+	require.Empty(t, hub.subscriptions)
+}
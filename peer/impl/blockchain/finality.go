@@ -0,0 +1,137 @@
+package blockchain
+
+import (
+	"github.com/rs/zerolog/log"
+	permissioned "go.dedis.ch/cs438/permissioned-chain"
+	"go.dedis.ch/cs438/types"
+)
+
+// -----------------------------------------------------------------------------
+// Finality
+
+// isFinalityMode checks if the block needs a quorum of votes to be final
+func (m *BlockchainModule) isFinalityMode(block *permissioned.Block) bool {
+	config := m.blockConfig(block)
+	return config != nil && config.Finality
+}
+
+// blockConfig returns the chain config after the block. The state is read
+// under the chain lock since it may be pruned meanwhile
+func (m *BlockchainModule) blockConfig(block *permissioned.Block) *permissioned.ChainConfig {
+	worldState, err := m.StateAt(block.Hash())
+	if err != nil {
+		log.Err(err).Send()
+		return nil
+	}
+	return permissioned.GetConfigFromWorldState(worldState)
+}
+
+// voteBlks votes for the blocks joining the canonical chain and checks if
+// they are final. Blocks not needing votes are skipped
+func (m *BlockchainModule) voteBlks(blocks ...*permissioned.Block) {
+	for _, block := range blocks {
+		if !m.isFinalityMode(block) {
+			continue
+		}
+
+		m.voteBlk(block)
+		// votes may have arrived before the block
+		m.checkFinality(block.Hash())
+	}
+}
+
+// voteBlk broadcasts the node's vote for a block of the canonical chain.
+// The node votes at most once per height, and only for blocks extending
+// the one it last voted for, see VoteCenter.MarkVoted
+func (m *BlockchainModule) voteBlk(block *permissioned.Block) {
+	config := m.blockConfig(block)
+	if config == nil {
+		return
+	}
+	validators := config.Validators()
+	if _, ok := validators[m.wallet.GetAddress().Hex]; !ok {
+		return
+	}
+	finalBlock := m.GetFinalBlock()
+	if block.Height <= finalBlock.Height || !m.votes.MarkVoted(m.Blockchain, block, validators) {
+		return
+	}
+
+	vote, err := m.wallet.SignBlockVote(block)
+	if err != nil {
+		log.Err(err).Msgf("failed to sign vote for block %s", block.Hash())
+		return
+	}
+
+	participants := make(map[string]struct{})
+	for p := range config.Participants {
+		participants[p] = struct{}{}
+	}
+	err = m.broadcastBCVoteMessage(participants, vote)
+	if err != nil {
+		log.Err(err).Send()
+	}
+}
+
+// checkFinality finalizes the block if a quorum of the participants voted
+// for it. Blocks not on the canonical chain are checked again when they join it
+func (m *BlockchainModule) checkFinality(blockHash string) {
+	block := m.GetBlock(blockHash)
+	if block == nil {
+		return
+	}
+	config := m.blockConfig(block)
+	if config == nil || !config.Finality {
+		return
+	}
+
+	validators := config.Validators()
+	quorum := permissioned.FinalityQuorum(len(validators))
+	if m.votes.Count(blockHash, validators) < quorum {
+		return
+	}
+
+	finalized, err := m.Finalize(blockHash)
+	if err != nil {
+		log.Warn().Msgf("block %s has a quorum but cannot be final: %v", blockHash, err)
+		return
+	}
+	if len(finalized) == 0 {
+		return
+	}
+	m.votes.Prune(block.Height)
+	log.Info().Msgf("Block %s on height=%d is final", blockHash, block.Height)
+
+	// notify outside for the final transactions
+	go func() {
+		for _, block := range finalized {
+			m.notifyBlk(block)
+		}
+	}()
+}
+
+// broadcastBCVoteMessage broadcast a BCVoteMessage in private msg
+func (m *BlockchainModule) broadcastBCVoteMessage(participants map[string]struct{},
+	vote *permissioned.BlockVote) error {
+	voteMsg := types.BCVoteMessage{
+		Origin: m.conf.Socket.GetAddress(),
+		Vote:   *vote,
+	}
+	voteMsgMarshal, err := m.CreateMsg(voteMsg)
+	if err != nil {
+		return err
+	}
+
+	// wrap in private msg
+	privMsg := types.BCPrivateMessage{
+		Recipients: participants,
+		Msg:        &voteMsgMarshal,
+	}
+	privMsgMarshal, err := m.CreateMsg(privMsg)
+	if err != nil {
+		return err
+	}
+
+	// send in rumor
+	return m.Broadcast(privMsgMarshal)
+}
@@ -0,0 +1,117 @@
+package blockchain
+
+import (
+	"crypto/ecdsa"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/require"
+	permissioned "go.dedis.ch/cs438/permissioned-chain"
+)
+
+func Test_BC_Vote_Center(t *testing.T) {
+	center := NewVoteCenter()
+	participants := map[string]string{"a": "", "b": "", "c": ""}
+
+	// > votes are counted once per participant
+	center.Add("block1", 1, "a")
+	center.Add("block1", 1, "a")
+	center.Add("block1", 1, "b")
+	center.Add("block1", 1, "outsider")
+	center.Add("block2", 2, "c")
+	require.Equal(t, 2, center.Count("block1", participants))
+	require.Equal(t, 1, center.Count("block2", participants))
+	require.Equal(t, 0, center.Count("unknown", participants))
+
+	// > the node votes once per height
+	bc, block0, privKey := newVoteChain(t)
+	block1 := appendVoteBlock(t, bc, block0, privKey, 1)
+	otherBlock1 := appendVoteBlock(t, bc, block0, privKey, 2)
+	block2 := appendVoteBlock(t, bc, block1, privKey, 3)
+	require.True(t, center.MarkVoted(bc, block1, participants))
+	require.False(t, center.MarkVoted(bc, otherBlock1, participants))
+	require.True(t, center.MarkVoted(bc, block2, participants))
+
+	// > final heights are forgotten
+	center.Prune(1)
+	require.Equal(t, 0, center.Count("block1", participants))
+	require.Equal(t, 1, center.Count("block2", participants))
+	require.False(t, center.MarkVoted(bc, block2, participants))
+}
+
+func Test_BC_Vote_Center_Lock(t *testing.T) {
+	center := NewVoteCenter()
+	validators := map[string]string{"a": "", "b": "", "c": ""}
+
+	// two branches compete: block0 <- a1 <- a2 and block0 <- b1 <- b2 <- b3
+	bc, block0, privKey := newVoteChain(t)
+	a1 := appendVoteBlock(t, bc, block0, privKey, 1)
+	a2 := appendVoteBlock(t, bc, a1, privKey, 2)
+	b1 := appendVoteBlock(t, bc, block0, privKey, 3)
+	b2 := appendVoteBlock(t, bc, b1, privKey, 4)
+	b3 := appendVoteBlock(t, bc, b2, privKey, 5)
+
+	// > after voting on a branch, the node doesn't vote on the other one,
+	// even at a height it never voted on
+	require.True(t, center.MarkVoted(bc, a1, validators))
+	require.False(t, center.MarkVoted(bc, b2, validators))
+
+	// > votes short of a quorum don't release the lock
+	center.Add(b1.Hash(), b1.Height, "a")
+	center.Add(b1.Hash(), b1.Height, "b")
+	require.False(t, center.MarkVoted(bc, b2, validators))
+
+	// > a quorum at or below the lock doesn't release it
+	center.Add(b1.Hash(), b1.Height, "c")
+	require.False(t, center.MarkVoted(bc, b2, validators))
+
+	// > a quorum for a higher block of the other branch releases it
+	center.Add(b2.Hash(), b2.Height, "a")
+	center.Add(b2.Hash(), b2.Height, "b")
+	center.Add(b2.Hash(), b2.Height, "c")
+	require.True(t, center.MarkVoted(bc, b3, validators))
+
+	// > the node is now locked on the new branch
+	require.False(t, center.MarkVoted(bc, a2, validators))
+
+	// > a final block above the lock releases it as well
+	center = NewVoteCenter()
+	require.True(t, center.MarkVoted(bc, a1, validators))
+	_, err := bc.Finalize(b2.Hash())
+	require.NoError(t, err)
+	require.True(t, center.MarkVoted(bc, b3, validators))
+}
+
+// newVoteChain creates a chain with a single participant mining every block
+func newVoteChain(t *testing.T) (*permissioned.Blockchain, *permissioned.Block, *ecdsa.PrivateKey) {
+	privKey, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	addr := permissioned.NewAddress(&privKey.PublicKey).Hex
+
+	config := *permissioned.NewChainConfig(map[string]string{addr: ""}, 1, "2h", 1, 10)
+	bc := permissioned.NewBlockchain()
+	block0, err := bc.InitGenesisBlock(&config, map[string]permissioned.Amount{addr: 1000})
+	require.NoError(t, err)
+	require.NoError(t, bc.SetGenesisBlock(&block0))
+	return bc, &block0, privKey
+}
+
+// appendVoteBlock appends an empty block on the parent. Blocks on the same
+// parent differ by their timestamp offset
+func appendVoteBlock(t *testing.T, bc *permissioned.Blockchain, parent *permissioned.Block,
+	privKey *ecdsa.PrivateKey, offset int) *permissioned.Block {
+	miner, proof := permissioned.SelectMiner(parent)
+	block := permissioned.NewBlockBuilder().
+		SetPrevHash(parent.Hash()).
+		SetHeight(parent.Height + 1).
+		SetMiner(miner).
+		SetSelectionProof(proof).
+		SetTimestamp(time.Unix(0, parent.Timestamp).Add(time.Duration(offset) * time.Millisecond)).
+		SetState(parent.GetWorldStateCopy()).
+		Build()
+	require.NoError(t, block.Sign(privKey))
+	_, err := bc.AddBlock(block)
+	require.NoError(t, err)
+	return block
+}
@@ -0,0 +1,370 @@
+package blockchain
+
+import (
+	"fmt"
+
+	"github.com/rs/xid"
+	"github.com/rs/zerolog/log"
+	permissioned "go.dedis.ch/cs438/permissioned-chain"
+	"go.dedis.ch/cs438/storage"
+	"go.dedis.ch/cs438/types"
+)
+
+// -----------------------------------------------------------------------------
+// Light Node
+
+// IsLight checks if the node runs as a light node, following the headers only
+func (m *BlockchainModule) IsLight() bool {
+	return m.headers != nil
+}
+
+// processLightBlk keeps the header of a received block. The genesis block
+// is kept in full
+func (m *BlockchainModule) processLightBlk(block *permissioned.Block) error {
+	if block.Height == 0 {
+		err := m.headers.SetGenesisBlock(block)
+		if err != nil {
+			return err
+		}
+
+		log.Info().Msgf("init genesis block successfully")
+		m.readyCond.Broadcast()
+
+		// send SetPubkey Txn
+		if m.conf.DisableAnnonceEnckey {
+			return nil
+		}
+		txnID, err := m.sendRegEnckeyTransaction()
+		if err != nil {
+			return err
+		}
+		log.Info().Msgf("send setPubkey txn %s", txnID)
+
+		return nil
+	}
+
+	if m.headers.GetHeader(block.PrevHash) == nil ||
+		!m.headers.IsValidatorAfter(block.PrevHash, block.Miner) {
+		// header too advance, or mined by a validator the node does not
+		// know yet. Syncing, the sync proves the new validators
+		if m.syncCenter.Start(block) {
+			go func() {
+				err := m.syncLight()
+				if err != nil {
+					log.Err(err).Msgf("failed to sync headers before block %s", block.Hash())
+				}
+			}()
+		}
+		return nil
+	}
+
+	changed, err := m.headers.AddHeader(*block.BlockHeader)
+	if err != nil {
+		return err
+	}
+	if changed {
+		go m.syncLightWallet()
+	}
+	return nil
+}
+
+// syncLight syncs the headers and adds the headers of the blocks received
+// in the meantime
+func (m *BlockchainModule) syncLight() error {
+	err := m.syncHeaders()
+
+	for _, block := range m.syncCenter.Done() {
+		if m.headers.GetHeader(block.Hash()) != nil {
+			continue
+		}
+		_, addErr := m.addLightHeader(*block.BlockHeader)
+		if addErr != nil {
+			log.Warn().Msgf("drop header %s: %v", block.Hash(), addErr)
+		}
+	}
+	return err
+}
+
+// syncHeaders fetches the headers following the tip of the header chain.
+// The genesis block is fetched in full first if missing
+func (m *BlockchainModule) syncHeaders() error {
+	neighbors := m.GetNeighbors(nil)
+	if len(neighbors) == 0 {
+		return fmt.Errorf("no neighbor to sync with")
+	}
+
+	if m.headers.GetGenesisBlock() == nil {
+		candidates := m.fetchHeaders(neighbors, 0, permissioned.DUMMY_PREVHASH)
+		if len(candidates) == 0 {
+			return fmt.Errorf("no neighbor knows the genesis block")
+		}
+		var err error
+		for _, candidate := range candidates {
+			var blocks []*permissioned.Block
+			blocks, err = m.fetchBlocks(candidate.headers[:1], candidate.sources)
+			if err == nil {
+				err = m.processLightBlk(blocks[0])
+			}
+			if err == nil {
+				break
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	for {
+		latest := m.headers.GetLatestHeader()
+		candidates := m.fetchHeaders(neighbors, latest.Height+1, latest.Hash())
+		if len(candidates) == 0 {
+			return nil
+		}
+		// the header chain verifies each header
+		headers := candidates[0].headers
+		for _, header := range headers {
+			if m.headers.GetHeader(header.Hash()) != nil {
+				// added by a concurrent sync
+				continue
+			}
+			_, err := m.addLightHeader(header)
+			if err != nil {
+				return err
+			}
+		}
+
+		if uint(len(headers)) < SYNC_HEADERS_BATCH {
+			return nil
+		}
+	}
+}
+
+// fetchTxnProof asks the neighbors for the proof of a transaction. The
+// first valid proof on the canonical header chain is returned
+func (m *BlockchainModule) fetchTxnProof(txnID string) (*permissioned.TxnProof, error) {
+	for _, proofMsg := range m.askProof(txnID, "", "") {
+		proof := proofMsg.TxnProof
+		if proof == nil {
+			continue
+		}
+		err := proof.Verify()
+		if err == nil {
+			err = m.checkLightHeader(&proof.Header)
+		}
+		if err != nil {
+			log.Warn().Msgf("invalid proof of txn %s from %s: %v", txnID, proofMsg.Origin, err)
+			continue
+		}
+		return proof, nil
+	}
+	return nil, fmt.Errorf("no valid proof of txn %s", txnID)
+}
+
+// fetchStateProof asks the neighbors for the proof of a world state entry.
+// The valid proof on the highest header of the canonical chain is returned.
+// With finality, the entry is proven after the final header
+func (m *BlockchainModule) fetchStateProof(key string) (*permissioned.StateProof, error) {
+	blockHash := ""
+	if m.headers.IsFinalityMode() {
+		blockHash = m.headers.GetFinalHeader().Hash()
+	}
+
+	var best *permissioned.StateProof
+	for _, proofMsg := range m.askProof("", key, blockHash) {
+		proof := proofMsg.StateProof
+		if proof == nil || (best != nil && proof.Header.Height <= best.Header.Height) {
+			continue
+		}
+
+		err := proof.Verify()
+		if err == nil {
+			err = m.checkLightHeader(&proof.Header)
+		}
+		if err != nil {
+			log.Warn().Msgf("invalid proof of entry %s from %s: %v", key, proofMsg.Origin, err)
+			continue
+		}
+		best = proof
+	}
+	if best == nil {
+		return nil, fmt.Errorf("no valid proof of entry %s", key)
+	}
+	return best, nil
+}
+
+// askProof asks all the neighbors for a proof and returns their answers.
+// An entry is proven after the block if its hash is set
+func (m *BlockchainModule) askProof(txnID string, key string, blockHash string) []*types.BCProofMessage {
+	requests := map[string]types.Message{}
+	for _, neighbor := range m.GetNeighbors(nil) {
+		requests[neighbor] = types.BCAskProofMessage{
+			UniqID:    xid.New().String(),
+			Origin:    m.conf.Socket.GetAddress(),
+			TxnID:     txnID,
+			Key:       key,
+			BlockHash: blockHash,
+		}
+	}
+
+	proofs := make([]*types.BCProofMessage, 0, len(requests))
+	for _, reply := range m.request(requests) {
+		proofMsg, ok := reply.(*types.BCProofMessage)
+		if ok {
+			proofs = append(proofs, proofMsg)
+		}
+	}
+	return proofs
+}
+
+// addLightHeader adds a header to the header chain. A miner unknown to the
+// light node means that the validators changed, e.g. on a key rotation: the
+// config after the parent is proven first
+func (m *BlockchainModule) addLightHeader(header permissioned.BlockHeader) (bool, error) {
+	if m.headers.GetHeader(header.PrevHash) != nil &&
+		!m.headers.IsValidatorAfter(header.PrevHash, header.Miner) {
+
+		err := m.proveLightConfig(header.PrevHash)
+		if err != nil {
+			log.Warn().Msgf("failed to update the validators for header %s: %v", header.Hash(), err)
+		}
+	}
+	return m.headers.AddHeader(header)
+}
+
+// proveLightConfig asks the neighbors for the config after a known header
+// and keeps the first valid proof
+func (m *BlockchainModule) proveLightConfig(hash string) error {
+	for _, proofMsg := range m.askProof("", permissioned.STATE_CONFIG_KEY, hash) {
+		proof := proofMsg.StateProof
+		if proof == nil || proof.Header.Hash() != hash {
+			continue
+		}
+		err := m.headers.SetConfig(*proof)
+		if err != nil {
+			log.Warn().Msgf("invalid proof of the config from %s: %v", proofMsg.Origin, err)
+			continue
+		}
+		return nil
+	}
+	return fmt.Errorf("no valid proof of the config after header %s", hash)
+}
+
+// checkLightHeader checks that a proven header is on the canonical header
+// chain. Headers are synced first if the header is not known yet. With
+// finality, the header must be final: a single validator can sign a longer
+// chain, but not gather a quorum of votes
+func (m *BlockchainModule) checkLightHeader(header *permissioned.BlockHeader) error {
+	hash := header.Hash()
+	if m.headers.GetHeader(hash) == nil {
+		err := m.syncHeaders()
+		if err != nil {
+			return err
+		}
+	}
+	if m.headers.IsFinalityMode() {
+		if !m.headers.IsFinal(hash) {
+			return fmt.Errorf("header %s is not final", hash)
+		}
+		return nil
+	}
+	if !m.headers.IsCanonical(hash) {
+		return fmt.Errorf("header %s is not on the canonical chain", hash)
+	}
+	return nil
+}
+
+// getLightAccount returns the proven account of the address. The account
+// is empty if it cannot be proven
+func (m *BlockchainModule) getLightAccount(addr string) *permissioned.Account {
+	proof, err := m.fetchStateProof(addr)
+	if err != nil {
+		log.Warn().Msgf("failed to get account %s: %v", addr, err)
+		return permissioned.NewAccount(*permissioned.NewAddressFromHex(addr))
+	}
+	account, err := proof.GetAccount()
+	if err != nil {
+		log.Warn().Msgf("failed to get account %s: %v", addr, err)
+		return permissioned.NewAccount(*permissioned.NewAddressFromHex(addr))
+	}
+	return account
+}
+
+// getLightConfig returns the proven config of the chain. It falls back on
+// the config of the genesis block if it cannot be proven
+func (m *BlockchainModule) getLightConfig() permissioned.ChainConfig {
+	proof, err := m.fetchStateProof(permissioned.STATE_CONFIG_KEY)
+	if err != nil {
+		log.Warn().Msgf("failed to get chain config: %v", err)
+		return m.headers.GetConfig()
+	}
+	value, err := proof.GetValue()
+	if err != nil {
+		log.Warn().Msgf("failed to get chain config: %v", err)
+		return m.headers.GetConfig()
+	}
+	config, ok := value.(permissioned.ChainConfig)
+	if !ok {
+		log.Warn().Msgf("failed to get chain config: wrong type %T", value)
+		return m.headers.GetConfig()
+	}
+	// the following headers are checked against the proven validators
+	err = m.headers.SetConfig(*proof)
+	if err != nil {
+		log.Warn().Msgf("failed to keep chain config: %v", err)
+	}
+	return config
+}
+
+// getLightAssets returns the proven assets of the participant, nil if
+// they cannot be proven
+func (m *BlockchainModule) getLightAssets(owner string) *permissioned.AssetsRecord {
+	proof, err := m.fetchStateProof(permissioned.AssetsKeyFromUniqID(owner))
+	if err != nil {
+		return nil
+	}
+	value, err := proof.GetValue()
+	if err != nil {
+		return nil
+	}
+	record, ok := value.(permissioned.AssetsRecord)
+	if !ok {
+		return nil
+	}
+	return &record
+}
+
+// syncLightWallet reconciles the wallet with the proven account. A light
+// node does not see the rejections as it does not mine
+func (m *BlockchainModule) syncLightWallet() {
+	if m.wallet == nil {
+		return
+	}
+
+	addr := m.wallet.GetAddress().Hex
+	proof, err := m.fetchStateProof(addr)
+	if err != nil {
+		// the account is not on chain yet
+		return
+	}
+	account, err := proof.GetAccount()
+	if err != nil {
+		log.Warn().Msgf("failed to get account %s: %v", addr, err)
+		return
+	}
+
+	worldState := storage.NewBasicKV()
+	worldState.Put(addr, *account)
+	m.syncWallet(worldState)
+}
+
+// lightBlk returns the block of a header. Only the genesis block has its
+// states and transactions
+func (m *BlockchainModule) lightBlk(header *permissioned.BlockHeader) *permissioned.Block {
+	if header == nil {
+		return nil
+	}
+	if header.Height == 0 {
+		return m.headers.GetGenesisBlock()
+	}
+	return &permissioned.Block{BlockHeader: header}
+}
@@ -22,7 +22,7 @@ func Test_BC_Miner_Create_Blk_Success(t *testing.T) {
 		map[string]string{account.GetAddress().Hex: ""},
 		1, "2h", 1, 10,
 	)
-	initialGain := map[string]float64{
+	initialGain := map[string]permissioned.Amount{
 		account.GetAddress().Hex: 1000,
 	}
 	bc := permissioned.NewBlockchain()
@@ -35,9 +35,9 @@ func Test_BC_Miner_Create_Blk_Success(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	pool := NewTxnPool()
+	pool := NewTxnPool(NewReceiptStore())
 	go pool.Daemon(ctx)
-	txn1, err := permissioned.NewTransactionRegAssets(&account, map[string]float64{
+	txn1, err := permissioned.NewTransactionRegAssets(&account, map[string]permissioned.Amount{
 		"key1": 1,
 	}).Sign(privKey)
 	require.NoError(t, err)
@@ -62,6 +62,8 @@ func Test_BC_Miner_Create_Blk_Success(t *testing.T) {
 	}
 
 	require.NotNil(t, block)
+	err = block.Sign(privKey)
+	require.NoError(t, err)
 	err = bc.AppendBlock(block)
 	require.NoError(t, err)
 }
@@ -78,7 +80,7 @@ func Test_BC_Miner_Create_Blk_Success_Resume(t *testing.T) {
 		map[string]string{account.GetAddress().Hex: ""},
 		1, "2h", 1, 10,
 	)
-	initialGain := map[string]float64{
+	initialGain := map[string]permissioned.Amount{
 		account.GetAddress().Hex: 1000,
 	}
 	bc := permissioned.NewBlockchain()
@@ -91,7 +93,7 @@ func Test_BC_Miner_Create_Blk_Success_Resume(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	pool := NewTxnPool()
+	pool := NewTxnPool(NewReceiptStore())
 	go pool.Daemon(ctx)
 
 	blkDone := make(chan struct{})
@@ -112,7 +114,7 @@ func Test_BC_Miner_Create_Blk_Success_Resume(t *testing.T) {
 	case <-timeout:
 	}
 
-	txn1, err := permissioned.NewTransactionRegAssets(&account, map[string]float64{
+	txn1, err := permissioned.NewTransactionRegAssets(&account, map[string]permissioned.Amount{
 		"key1": 1,
 	}).Sign(privKey)
 	require.NoError(t, err)
@@ -127,6 +129,8 @@ func Test_BC_Miner_Create_Blk_Success_Resume(t *testing.T) {
 	}
 
 	require.NotNil(t, block)
+	err = block.Sign(privKey)
+	require.NoError(t, err)
 	err = bc.AppendBlock(block)
 	require.NoError(t, err)
 }
@@ -143,7 +147,7 @@ func Test_BC_Miner_Create_Blk_Success_Timeout(t *testing.T) {
 		map[string]string{account.GetAddress().Hex: ""},
 		2, "2s", 1, 10,
 	)
-	initialGain := map[string]float64{
+	initialGain := map[string]permissioned.Amount{
 		account.GetAddress().Hex: 1000,
 	}
 	bc := permissioned.NewBlockchain()
@@ -156,9 +160,9 @@ func Test_BC_Miner_Create_Blk_Success_Timeout(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	pool := NewTxnPool()
+	pool := NewTxnPool(NewReceiptStore())
 	go pool.Daemon(ctx)
-	txn1, err := permissioned.NewTransactionRegAssets(&account, map[string]float64{
+	txn1, err := permissioned.NewTransactionRegAssets(&account, map[string]permissioned.Amount{
 		"key1": 1,
 	}).Sign(privKey)
 	require.NoError(t, err)
@@ -193,6 +197,8 @@ func Test_BC_Miner_Create_Blk_Success_Timeout(t *testing.T) {
 
 	require.Equal(t, len(block.Transactions), 1)
 
+	err = block.Sign(privKey)
+	require.NoError(t, err)
 	err = bc.AppendBlock(block)
 	require.NoError(t, err)
 }
@@ -209,7 +215,7 @@ func Test_BC_Miner_Create_Blk_Ctx_Stop(t *testing.T) {
 		map[string]string{account.GetAddress().Hex: ""},
 		2, "2h", 1, 10,
 	)
-	initialGain := map[string]float64{
+	initialGain := map[string]permissioned.Amount{
 		account.GetAddress().Hex: 1000,
 	}
 	bc := permissioned.NewBlockchain()
@@ -221,9 +227,9 @@ func Test_BC_Miner_Create_Blk_Ctx_Stop(t *testing.T) {
 	// init txnPool
 	ctx, cancel := context.WithCancel(context.Background())
 
-	pool := NewTxnPool()
+	pool := NewTxnPool(NewReceiptStore())
 	go pool.Daemon(ctx)
-	txn1, err := permissioned.NewTransactionRegAssets(&account, map[string]float64{
+	txn1, err := permissioned.NewTransactionRegAssets(&account, map[string]permissioned.Amount{
 		"key1": 1,
 	}).Sign(privKey)
 	require.NoError(t, err)
@@ -5,15 +5,16 @@ import (
 	"crypto/ecdsa"
 	"fmt"
 	"math"
+	"os"
 	"sync"
 	"time"
 
 	"github.com/ethereum/go-ethereum/crypto"
-	"github.com/rs/xid"
 	"github.com/rs/zerolog/log"
 	"go.dedis.ch/cs438/peer"
 	"go.dedis.ch/cs438/peer/impl/message"
 	permissioned "go.dedis.ch/cs438/permissioned-chain"
+	"go.dedis.ch/cs438/storage"
 	"go.dedis.ch/cs438/types"
 )
 
@@ -22,12 +23,20 @@ type BlockchainModule struct {
 	conf *peer.Configuration
 
 	wallet *Wallet
+	// key the wallet switches to once its rotation is included
+	nextKey *ecdsa.PrivateKey
 
 	*permissioned.Blockchain
+	// only set on a light node. The Blockchain then stays empty
+	headers       *permissioned.HeaderChain
 	txnPool       *TxnPool
 	blkPool       *BlkPool
 	watchRegistry *WatchRegistry
-	cr            *CreditRecords
+	events        *EventHub
+	slashReports  *SlashReports
+	syncCenter    *SyncCenter
+	votes         *VoteCenter
+	receipts      *ReceiptStore
 
 	// blkChan   chan *permissioned.Block
 	readyCond sync.Cond
@@ -35,24 +44,40 @@ type BlockchainModule struct {
 }
 
 func NewBlockchainModule(conf *peer.Configuration, messageModule *message.MessageModule) *BlockchainModule {
+	receipts := NewReceiptStore()
 	m := BlockchainModule{
 		MessageModule: messageModule,
 		conf:          conf,
 
-		Blockchain:    permissioned.NewBlockchain(),
-		txnPool:       NewTxnPool(),
+		Blockchain:    newBlockchain(conf),
+		txnPool:       NewTxnPool(receipts),
 		blkPool:       NewBlkPool(),
 		watchRegistry: NewWatchRegistry(),
-		cr:            NewCreditRecords(),
+		events:        NewEventHub(),
+		slashReports:  NewSlashReports(),
+		syncCenter:    NewSyncCenter(),
+		votes:         NewVoteCenter(),
+		receipts:      receipts,
 		// blkChan:       make(chan *permissioned.Block, 10),
 		readyCond: *sync.NewCond(&sync.Mutex{}),
 		minerChan: make(chan NextBlkInfo, 5),
 	}
+	if conf.LightChain {
+		m.Blockchain = permissioned.NewBlockchain()
+		m.headers = permissioned.NewHeaderChain()
+	}
 
 	// message registery
 	m.conf.MessageRegistry.RegisterMessageCallback(types.BCPrivateMessage{}, m.ProcessBCPrivateMsg)
 	m.conf.MessageRegistry.RegisterMessageCallback(types.BCBlkMessage{}, m.ProcessBCBlkMsg)
 	m.conf.MessageRegistry.RegisterMessageCallback(types.BCTxnMessag{}, m.ProcessBCTxnMsg)
+	m.conf.MessageRegistry.RegisterMessageCallback(types.BCAskHeadersMessage{}, m.ProcessBCAskHeadersMsg)
+	m.conf.MessageRegistry.RegisterMessageCallback(types.BCHeadersMessage{}, m.ProcessBCHeadersMsg)
+	m.conf.MessageRegistry.RegisterMessageCallback(types.BCAskBlocksMessage{}, m.ProcessBCAskBlocksMsg)
+	m.conf.MessageRegistry.RegisterMessageCallback(types.BCBlocksMessage{}, m.ProcessBCBlocksMsg)
+	m.conf.MessageRegistry.RegisterMessageCallback(types.BCVoteMessage{}, m.ProcessBCVoteMsg)
+	m.conf.MessageRegistry.RegisterMessageCallback(types.BCAskProofMessage{}, m.ProcessBCAskProofMsg)
+	m.conf.MessageRegistry.RegisterMessageCallback(types.BCProofMessage{}, m.ProcessBCProofMsg)
 
 	return &m
 }
@@ -60,9 +85,15 @@ func NewBlockchainModule(conf *peer.Configuration, messageModule *message.Messag
 // -----------------------------------------------------------------------------
 // Feature Functions
 
-// MiningDaemon starts a new minor daemon
+// MiningDaemon starts a new minor daemon. Light nodes do not mine
 func (m *BlockchainModule) MiningDaemon(ctx context.Context) error {
+	if m.IsLight() {
+		log.Info().Msgf("Light node. Only following headers")
+		return nil
+	}
+
 	go m.txnPool.Daemon(ctx)
+	go m.WalletDaemon(ctx)
 	go m.Mine(ctx, m.txnPool)
 	go m.VerifyBlock(ctx)
 
@@ -70,7 +101,7 @@ func (m *BlockchainModule) MiningDaemon(ctx context.Context) error {
 }
 
 // InitBlockchain inits a new blockchain with the given config
-func (m *BlockchainModule) InitBlockchain(config permissioned.ChainConfig, initialGain map[string]float64) error {
+func (m *BlockchainModule) InitBlockchain(config permissioned.ChainConfig, initialGain map[string]permissioned.Amount) error {
 	bc := permissioned.NewBlockchain()
 	blk, err := bc.InitGenesisBlock(&config, initialGain)
 	if err != nil {
@@ -89,11 +120,21 @@ func (m *BlockchainModule) InitBlockchain(config permissioned.ChainConfig, initi
 	return nil
 }
 
+// InitBlockchainFromGenesis inits the blockchain with a verified genesis.
+// It is set locally as if received from the network
+func (m *BlockchainModule) InitBlockchainFromGenesis(genesis *permissioned.Genesis) error {
+	err := genesis.Verify()
+	if err != nil {
+		return err
+	}
+	return m.processBlk(genesis.Block)
+}
+
 // WaitBlock blocks until the blockchain has at lest a block block
 func (m *BlockchainModule) WaitBlock() *permissioned.Block {
 	m.readyCond.L.Lock()
 	for {
-		genesis := m.GetLatestBlock()
+		genesis := m.GetChainLatestBlock()
 		if genesis != nil {
 			m.readyCond.L.Unlock()
 			return genesis
@@ -103,24 +144,173 @@ func (m *BlockchainModule) WaitBlock() *permissioned.Block {
 }
 
 // GetAccountBalance returns the current balance of the node's account
-func (m *BlockchainModule) GetAccountBalance() float64 {
+func (m *BlockchainModule) GetAccountBalance() permissioned.Amount {
 	if m.wallet == nil {
 		return 0
 	}
 
 	addr := m.wallet.GetAddress().Hex
+	if m.IsLight() {
+		return m.getLightAccount(addr).GetBalance()
+	}
 	return m.GetBalance(addr)
 }
 
+// GetChainConfig returns the config of the chain, whether the node is light
+func (m *BlockchainModule) GetChainConfig() permissioned.ChainConfig {
+	if m.IsLight() {
+		return m.getLightConfig()
+	}
+	return m.GetConfig()
+}
+
+// GetChainAssetPrices returns the prices of the assets of every participant.
+// A light node gets them proven by the full nodes
+func (m *BlockchainModule) GetChainAssetPrices() map[string]map[string]permissioned.Amount {
+	if !m.IsLight() {
+		latestBlock := m.GetLatestBlock()
+		if latestBlock == nil {
+			return nil
+		}
+		return permissioned.GetAllAssetsFromWorldState(latestBlock.States)
+	}
+
+	assets := make(map[string]map[string]permissioned.Amount)
+	for participant := range m.GetChainConfig().Participants {
+		record := m.getLightAssets(participant)
+		if record == nil || len(record.Assets) == 0 {
+			continue
+		}
+		assets[participant] = record.Assets
+	}
+	return assets
+}
+
+// GetChainAssetsHolder returns the participant whose node holds the values
+// of the assets of the owner. A light node gets it proven by the full nodes
+func (m *BlockchainModule) GetChainAssetsHolder(owner string) string {
+	if m.IsLight() {
+		record := m.getLightAssets(owner)
+		if record == nil {
+			return owner
+		}
+		return record.GetHolder()
+	}
+
+	latestBlock := m.GetLatestBlock()
+	if latestBlock == nil {
+		return owner
+	}
+	return permissioned.GetAssetsFromWorldState(latestBlock.States, owner).GetHolder()
+}
+
+// AllEncryptKeySet checks if all the participants have registered their
+// encryption key. A light node checks the proven config
+func (m *BlockchainModule) AllEncryptKeySet() bool {
+	if !m.IsLight() {
+		return m.Blockchain.AllEncryptKeySet()
+	}
+
+	config := m.GetChainConfig()
+	if len(config.Participants) == 0 {
+		return false
+	}
+	for _, pubkey := range config.Participants {
+		if len(pubkey) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// GetChainLatestBlock returns the latest block of the chain. A light node
+// only returns its header, except for the genesis block
+func (m *BlockchainModule) GetChainLatestBlock() *permissioned.Block {
+	if m.IsLight() {
+		return m.lightBlk(m.headers.GetLatestHeader())
+	}
+	return m.GetLatestBlock()
+}
+
+// GetChainFinalBlock returns the latest final block. A light node only
+// returns its header, except for the genesis block
+func (m *BlockchainModule) GetChainFinalBlock() *permissioned.Block {
+	if m.IsLight() {
+		return m.lightBlk(m.headers.GetFinalHeader())
+	}
+	return m.GetFinalBlock()
+}
+
+// GetChainBlock returns the requested block. A light node only returns its
+// header, except for the genesis block
+func (m *BlockchainModule) GetChainBlock(blockID string) *permissioned.Block {
+	if m.IsLight() {
+		return m.lightBlk(m.headers.GetHeader(blockID))
+	}
+	return m.GetBlock(blockID)
+}
+
+// GetChainTxn returns the requested transaction of the canonical chain. A
+// light node asks the full nodes for a proof of inclusion
+func (m *BlockchainModule) GetChainTxn(txnID string) *permissioned.SignedTransaction {
+	if !m.IsLight() {
+		return m.GetTxn(txnID)
+	}
+
+	proof, err := m.fetchTxnProof(txnID)
+	if err != nil {
+		return nil
+	}
+	return &proof.Txn
+}
+
+// GetChainTxnProof returns the proof that the transaction is included in
+// the canonical chain. A light node gets it from the full nodes
+func (m *BlockchainModule) GetChainTxnProof(txnID string) (*permissioned.TxnProof, error) {
+	if m.IsLight() {
+		return m.fetchTxnProof(txnID)
+	}
+	return m.GetTxnProof(txnID)
+}
+
+// GetChainStateProof returns the proof of the current value of a world
+// state entry. A light node gets it from the full nodes
+func (m *BlockchainModule) GetChainStateProof(key string) (*permissioned.StateProof, error) {
+	if m.IsLight() {
+		return m.fetchStateProof(key)
+	}
+	return m.GetStateProof(key)
+}
+
+// GetReceipt returns the receipt of a transaction sent by the node. A light
+// node does not see the blocks so it checks pending transactions against
+// the proofs of the full nodes
+func (m *BlockchainModule) GetReceipt(txnID string) (*permissioned.TxnReceipt, error) {
+	receipt, ok := m.receipts.Get(txnID)
+	if !ok {
+		return nil, fmt.Errorf("txn %s was not sent by this node", txnID)
+	}
+
+	if m.IsLight() && receipt.Status == permissioned.TxnStatusPending {
+		proof, err := m.fetchTxnProof(txnID)
+		if err == nil {
+			m.receipts.Include(txnID, proof.Header.Hash(), proof.Header.Height, proof.Index)
+			receipt, _ = m.receipts.Get(txnID)
+		}
+	}
+	return &receipt, nil
+}
+
 // SendTransaction signs and sends a transaction
 func (m *BlockchainModule) SendTransaction(signedTxn *permissioned.SignedTransaction) error {
 	// get config and send private message
-	config := m.GetConfig()
+	config := m.GetChainConfig()
 	participants := make(map[string]struct{})
 	for p := range config.Participants {
 		participants[p] = struct{}{}
 	}
 
+	m.receipts.Track(&signedTxn.Txn)
 	return m.broadcastBCTxnMessage(participants, signedTxn)
 }
 
@@ -147,13 +337,45 @@ func (m *BlockchainModule) GenerateKeyPair(path string) error {
 	return crypto.SaveECDSA(path, privkey)
 }
 
-// SetKeyPair sets an ECDSA key pair to the node
+// SetKeyPair sets an ECDSA key pair to the node. Once set, it can only be
+// replaced by the key the account was rotated or revoked to
 func (m *BlockchainModule) SetKeyPair(privkey ecdsa.PrivateKey) error {
-	// Only assume pne-time initialize of wallet
 	if m.wallet != nil {
-		return fmt.Errorf("wallet already set. Account is not changable")
+		latestBlock := m.GetLatestBlock()
+		newAddr := permissioned.NewAddress(&privkey.PublicKey).Hex
+		if latestBlock == nil || newAddr == m.wallet.GetAddress().Hex ||
+			permissioned.ResolveAddress(latestBlock.States, m.wallet.GetAddress().Hex) != newAddr {
+			return fmt.Errorf("wallet already set. Account is only changed by a key rotation")
+		}
+	}
+	return m.setWallet(privkey)
+}
+
+// setWallet replaces the wallet of the node
+func (m *BlockchainModule) setWallet(privkey ecdsa.PrivateKey) error {
+	wallet, err := newWallet(m.conf, &privkey)
+	if err != nil {
+		log.Err(err).Msgf("failed to restore the wallet")
+	}
+	m.wallet = wallet
+
+	// resend the txns pending before a restart
+	for _, signedTxn := range m.wallet.GetPending() {
+		err := m.SendTransaction(signedTxn)
+		if err != nil {
+			log.Err(err).Msgf("failed to resend txn %s", signedTxn.Txn.ID)
+		}
+	}
+
+	// resume mining on a restored chain
+	latestBlock := m.GetLatestBlock()
+	if latestBlock != nil {
+		m.syncWallet(latestBlock.States)
+
+		nextMiner, _ := permissioned.SelectMiner(latestBlock)
+		m.notifyMiner(NextBlkInfo{latestBlock.Height,
+			nextMiner == m.wallet.GetAddress().Hex})
 	}
-	m.wallet = NewWallet(&privkey)
 
 	return nil
 }
@@ -168,16 +390,83 @@ func (m *BlockchainModule) LoadKeyPair(path string) error {
 	return m.SetKeyPair(*privkey)
 }
 
+// GenerateKeystore generates a new identity for the node and saves it in
+// a keystore file protected by the passphrase. The keys are only set once
+// saved, so that the node never uses an identity it could lose
+func (m *BlockchainModule) GenerateKeystore(path, passphrase string) error {
+	keys, err := permissioned.NewKeystore()
+	if err != nil {
+		return err
+	}
+	err = keys.Save(path, passphrase)
+	if err != nil {
+		return err
+	}
+	return m.SetKeystore(keys)
+}
+
+// LoadKeystore unlocks a keystore file and sets its keys to the node
+func (m *BlockchainModule) LoadKeystore(path, passphrase string) error {
+	keys, err := permissioned.LoadKeystore(path, passphrase)
+	if err != nil {
+		return err
+	}
+	return m.SetKeystore(keys)
+}
+
+// SetKeystore sets the chain key and the message encryption key of the
+// node. The encryption key must be the one registered on chain, if any
+func (m *BlockchainModule) SetKeystore(keys *permissioned.Keystore) error {
+	pubkey, err := message.PubkeyString(&keys.EncKey.PublicKey)
+	if err != nil {
+		return err
+	}
+	latestBlock := m.GetLatestBlock()
+	if latestBlock != nil {
+		config := permissioned.GetConfigFromWorldState(latestBlock.States)
+		registered := config.Participants[keys.Address().Hex]
+		if registered != "" && registered != pubkey {
+			return fmt.Errorf("encryption key of %s differs from the one on chain",
+				keys.Address().Hex)
+		}
+	}
+
+	err = m.SetKeyPair(*keys.ChainKey)
+	if err != nil {
+		return err
+	}
+	m.SetPrivkey((*types.Privkey)(keys.EncKey))
+	return nil
+}
+
 // RegisterTxnCallabck registers a callback function for a specific type of txn
 // it will be called when the txn is in a newly appended block
 func (m *BlockchainModule) RegisterTxnCallabck(txnType permissioned.TxnType, watcher watchCallbck) {
 	m.watchRegistry.Register(txnType, watcher)
 }
 
+// RegisterTxnRevertCallback registers a callback function for a specific type
+// of txn. It is called when the txn leaves the canonical chain on a reorganisation
+func (m *BlockchainModule) RegisterTxnRevertCallback(txnType permissioned.TxnType, watcher watchCallbck) {
+	m.watchRegistry.RegisterRevert(txnType, watcher)
+}
+
+// Subscribe returns the ID of a new subscription to the chain events
+// matching the filter, and the channel they are delivered on
+func (m *BlockchainModule) Subscribe(filter permissioned.EventFilter) (string, <-chan permissioned.Event) {
+	return m.events.Subscribe(filter)
+}
+
+// Unsubscribe closes the subscription and its channel
+func (m *BlockchainModule) Unsubscribe(id string) {
+	m.events.Unsubscribe(id)
+}
+
 // SendPreMPCTransaction generates and sends a preMPC transaction
-func (m *BlockchainModule) SendPreMPCTransaction(expression string, budget float64, prime string) (string, error) {
+func (m *BlockchainModule) SendPreMPCTransaction(expression string, budget permissioned.Amount,
+	prime string, fee permissioned.Amount) (string, error) {
 	fmt.Printf("BENCHMARK, Time: %d. In function: SendPreMPCTransaction\n", time.Now().UnixNano())
-	signedTxn, err := m.wallet.PreMPCTxn(expression, budget, prime)
+	signedTxn, err := m.wallet.PreMPCTxn(expression, budget, prime, fee)
 	if err != nil {
 		return "", err
 	}
@@ -195,36 +484,209 @@ func (m *BlockchainModule) SendPostMPCTransaction(id string, result float64) (st
 }
 
 // SendRegAssetsTransaction generates and sends a regAssets transaction
-func (m *BlockchainModule) SendRegAssetsTransaction(assets map[string]float64) (string, error) {
-	signedTxn, err := m.wallet.RegAssets(assets)
+func (m *BlockchainModule) SendRegAssetsTransaction(registration permissioned.AssetsRegistration) (string, error) {
+	signedTxn, err := m.wallet.RegAssets(registration)
+	if err != nil {
+		return "", err
+	}
+	return signedTxn.Txn.ID, m.SendTransaction(signedTxn)
+}
+
+// SendUpdateAssetsTransaction generates and sends an updateAssets transaction
+func (m *BlockchainModule) SendUpdateAssetsTransaction(prices map[string]permissioned.Amount) (string, error) {
+	signedTxn, err := m.wallet.UpdateAssetsTxn(prices)
+	if err != nil {
+		return "", err
+	}
+	return signedTxn.Txn.ID, m.SendTransaction(signedTxn)
+}
+
+// SendRemoveAssetsTransaction generates and sends a removeAssets transaction
+func (m *BlockchainModule) SendRemoveAssetsTransaction(keys []string) (string, error) {
+	signedTxn, err := m.wallet.RemoveAssetsTxn(keys)
+	if err != nil {
+		return "", err
+	}
+	return signedTxn.Txn.ID, m.SendTransaction(signedTxn)
+}
+
+// SendTransferAssetTransaction generates and sends a transferAsset transaction
+func (m *BlockchainModule) SendTransferAssetTransaction(transfer permissioned.AssetTransfer) (string, error) {
+	signedTxn, err := m.wallet.TransferAssetTxn(transfer)
+	if err != nil {
+		return "", err
+	}
+	return signedTxn.Txn.ID, m.SendTransaction(signedTxn)
+}
+
+// SendRegPricingTransaction generates and sends a regPricing transaction
+func (m *BlockchainModule) SendRegPricingTransaction(schedules map[string]permissioned.PriceSchedule) (string, error) {
+	signedTxn, err := m.wallet.RegPricing(schedules)
+	if err != nil {
+		return "", err
+	}
+	return signedTxn.Txn.ID, m.SendTransaction(signedTxn)
+}
+
+// BumpTransaction resends a pending transaction of the node with a higher
+// tip. It returns the ID of the replacing transaction
+func (m *BlockchainModule) BumpTransaction(txnID string, tip permissioned.Amount) (string, error) {
+	txn, err := m.getPendingTxn(txnID, tip)
+	if err != nil {
+		return "", err
+	}
+	signedTxn, err := m.wallet.BumpTxn(txn, tip)
+	if err != nil {
+		return "", err
+	}
+	return signedTxn.Txn.ID, m.SendTransaction(signedTxn)
+}
+
+// CancelTransaction replaces a pending transaction of the node by a
+// transaction doing nothing with a higher tip. It returns the ID of the
+// replacing transaction
+func (m *BlockchainModule) CancelTransaction(txnID string, tip permissioned.Amount) (string, error) {
+	txn, err := m.getPendingTxn(txnID, tip)
+	if err != nil {
+		return "", err
+	}
+	signedTxn, err := m.wallet.CancelTxn(txn.Nonce, tip)
+	if err != nil {
+		return "", err
+	}
+	return signedTxn.Txn.ID, m.SendTransaction(signedTxn)
+}
+
+// SendStakeTransaction generates and sends a stake transaction
+func (m *BlockchainModule) SendStakeTransaction(amount permissioned.Amount) (string, error) {
+	signedTxn, err := m.wallet.StakeTxn(amount)
+	if err != nil {
+		return "", err
+	}
+	return signedTxn.Txn.ID, m.SendTransaction(signedTxn)
+}
+
+// SendUnstakeTransaction generates and sends an unstake transaction
+func (m *BlockchainModule) SendUnstakeTransaction(amount permissioned.Amount) (string, error) {
+	signedTxn, err := m.wallet.UnstakeTxn(amount)
 	if err != nil {
 		return "", err
 	}
 	return signedTxn.Txn.ID, m.SendTransaction(signedTxn)
 }
 
+// SendSlashTransaction generates and sends a slash transaction
+func (m *BlockchainModule) SendSlashTransaction(evidence permissioned.SlashEvidence) (string, error) {
+	signedTxn, err := m.wallet.SlashTxn(evidence)
+	if err != nil {
+		return "", err
+	}
+	return signedTxn.Txn.ID, m.SendTransaction(signedTxn)
+}
+
+// SendRegMultisigTransaction generates and sends a regMultisig transaction.
+// It returns the address of the new multisig account
+func (m *BlockchainModule) SendRegMultisigTransaction(policy permissioned.MultisigPolicy,
+	deposit permissioned.Amount) (permissioned.Address, error) {
+	signedTxn, err := m.wallet.RegMultisigTxn(policy, deposit)
+	if err != nil {
+		return permissioned.Address{}, err
+	}
+	return *permissioned.NewAddressFromHex(signedTxn.Txn.To), m.SendTransaction(signedTxn)
+}
+
+// CosignTransaction adds the node's signature to a transaction of a
+// multisig account
+func (m *BlockchainModule) CosignTransaction(signedTxn *permissioned.SignedTransaction) error {
+	if m.wallet == nil {
+		return fmt.Errorf("node %s does not have an address yet",
+			m.conf.Socket.GetAddress())
+	}
+	return m.wallet.Cosign(signedTxn)
+}
+
+// RotateKey sends a rotateKey transaction moving the node's account to the
+// new key. The node switches to the new key once it is included
+func (m *BlockchainModule) RotateKey(newKey ecdsa.PrivateKey) (string, error) {
+	if m.wallet == nil {
+		return "", fmt.Errorf("node %s does not have an address yet",
+			m.conf.Socket.GetAddress())
+	}
+	signedTxn, err := m.wallet.RotateKeyTxn(&newKey)
+	if err != nil {
+		return "", err
+	}
+	m.nextKey = &newKey
+	return signedTxn.Txn.ID, m.SendTransaction(signedTxn)
+}
+
+// SendRevokeKeyTransaction generates and sends a revokeKey transaction
+func (m *BlockchainModule) SendRevokeKeyTransaction(revocation permissioned.KeyRevocation) (string, error) {
+	signedTxn, err := m.wallet.RevokeKeyTxn(revocation)
+	if err != nil {
+		return "", err
+	}
+	return signedTxn.Txn.ID, m.SendTransaction(signedTxn)
+}
+
+// SignMPCShare signs a MPC share with the node's account
+func (m *BlockchainModule) SignMPCShare(uniqID string, key string, value string) (*permissioned.MPCShareProof, error) {
+	if m.wallet == nil {
+		return nil, fmt.Errorf("node %s does not have an address yet",
+			m.conf.Socket.GetAddress())
+	}
+	return m.wallet.SignMPCShare(uniqID, key, value)
+}
+
+// GetAccountStake returns the current stake of the node's account
+func (m *BlockchainModule) GetAccountStake() permissioned.Amount {
+	if m.wallet == nil {
+		return 0
+	}
+
+	addr := m.wallet.GetAddress().Hex
+	if m.IsLight() {
+		return m.getLightAccount(addr).GetStake()
+	}
+	return m.GetStake(addr)
+}
+
 // SprintBlockchain returns a description of the chain
 func (m *BlockchainModule) SprintBlockchain() string {
+	if m.IsLight() {
+		return m.headers.Sprint()
+	}
 	return m.Sprint()
 }
 
+// ExportChain writes the canonical chain in the file, with the world state
+// snapshots if asked. It can be imported into a fresh node and audited
+func (m *BlockchainModule) ExportChain(path string, snapshots bool) error {
+	if m.IsLight() {
+		return fmt.Errorf("a light node only has the block headers")
+	}
+	buf, err := m.Export(snapshots)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf, 0600)
+}
+
 // GetBlockTimeout returns the maximum timeout of a block
 func (m *BlockchainModule) GetMaxBlockTime() time.Duration {
-	config := m.GetConfig()
+	config := m.GetChainConfig()
 	return getBlockTimeout(&config) * time.Duration(config.MaxTxnsPerBlk)
 }
 
 // -----------------------------------------------------------------------------
 // Private Helpfer Functions
 
-// sync syncs blochain with the target node
-func (m *BlockchainModule) sync(to string) error {
-	id := xid.New().String()
-	return m.sendBCAskSyncMessage(id, to)
-}
-
 // processBlk process a received block
 func (m *BlockchainModule) processBlk(block *permissioned.Block) error {
+	if m.IsLight() {
+		return m.processLightBlk(block)
+	}
+
 	// if is genesis block. Directly set
 	if block.Height == 0 {
 		err := m.SetGenesisBlock(block)
@@ -255,16 +717,239 @@ func (m *BlockchainModule) processBlk(block *permissioned.Block) error {
 	return nil
 }
 
+// newWallet creates the wallet persisted in the node's storage and
+// restores its pending txns if the node was restarted
+func newWallet(conf *peer.Configuration, privkey *ecdsa.PrivateKey) (*Wallet, error) {
+	if conf.Storage == nil {
+		return NewWallet(privkey), nil
+	}
+	return NewWalletWithStore(privkey, conf.Storage.GetBlockchainStore())
+}
+
+// newBlockchain creates the blockchain persisted in the node's storage
+// and restores it if the node was restarted
+func newBlockchain(conf *peer.Configuration) *permissioned.Blockchain {
+	if conf.Storage == nil {
+		return permissioned.NewBlockchain()
+	}
+
+	bc, err := permissioned.NewBlockchainWithStore(conf.Storage.GetBlockchainStore())
+	if err != nil {
+		log.Err(err).Msgf("failed to restore the blockchain")
+	}
+	return bc
+}
+
+// notifyBlk notifies outside for the transactions of a block
+// joining the canonical chain
+func (m *BlockchainModule) notifyBlk(block *permissioned.Block) {
+	// the state is read under the chain lock since it may be pruned meanwhile
+	worldState, err := m.StateAt(block.Hash())
+	if err != nil {
+		log.Err(err).Msgf("failed to notify block %s", block.Hash())
+		return
+	}
+	m.followRotation(worldState)
+	m.syncWallet(worldState)
+
+	config := permissioned.GetConfigFromWorldState(worldState)
+	for _, signedTxn := range block.Transactions {
+		err := m.watchRegistry.Tell(config, &signedTxn.Txn)
+		if err != nil {
+			log.Err(err).Send()
+		}
+	}
+	m.events.Publish(permissioned.NewBlockEvents(block, worldState)...)
+
+	m.reportLateEndorsers(worldState)
+}
+
+// getPendingTxn returns a pending transaction of the node that can be
+// replaced with the tip
+func (m *BlockchainModule) getPendingTxn(txnID string, tip permissioned.Amount) (*permissioned.Transaction, error) {
+	if m.wallet == nil {
+		return nil, fmt.Errorf("node %s does not have an address yet",
+			m.conf.Socket.GetAddress())
+	}
+	receipt, err := m.GetReceipt(txnID)
+	if err != nil {
+		return nil, err
+	}
+	if receipt.Status != permissioned.TxnStatusPending {
+		return nil, fmt.Errorf("txn %s is not pending anymore: %s", txnID, receipt.Status)
+	}
+	txn, _ := m.receipts.GetTxn(txnID)
+	if tip <= txn.Tip {
+		return nil, fmt.Errorf("tip must be higher than the current one: %s", txn.Tip)
+	}
+	return &txn, nil
+}
+
+// WalletDaemon reconciles the wallet when a sent txn is rejected, so that
+// the following txns do not wait for the next block to be unblocked
+func (m *BlockchainModule) WalletDaemon(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-m.receipts.Rejections():
+			latestBlock := m.GetLatestBlock()
+			if m.wallet == nil || latestBlock == nil {
+				continue
+			}
+			m.syncWallet(latestBlock.States)
+		}
+	}
+}
+
+// followRotation switches the wallet to the next key once the world state
+// moved the account to it
+func (m *BlockchainModule) followRotation(worldState storage.KVStore) {
+	if m.wallet == nil || m.nextKey == nil {
+		return
+	}
+	newAddr := permissioned.NewAddress(&m.nextKey.PublicKey).Hex
+	if permissioned.ResolveAddress(worldState, m.wallet.GetAddress().Hex) != newAddr {
+		return
+	}
+
+	err := m.setWallet(*m.nextKey)
+	if err != nil {
+		log.Err(err).Msgf("failed to switch to key %s", newAddr)
+		return
+	}
+	m.nextKey = nil
+	log.Info().Msgf("account rotated to %s", newAddr)
+}
+
+// syncWallet reconciles the wallet with the world state and sends the txns
+// it re-signed. The pending txns the world state moved past are looked up
+// on the chain first, so that included ones are not taken as lost
+func (m *BlockchainModule) syncWallet(worldState storage.KVStore) {
+	if m.wallet == nil {
+		return
+	}
+
+	account := permissioned.GetAccountFromWorldState(worldState, m.wallet.GetAddress().Hex)
+	for _, signedTxn := range m.wallet.GetPending() {
+		if signedTxn.Txn.Nonce >= account.GetNonce() {
+			break
+		}
+		receipt, _ := m.receipts.Get(signedTxn.Txn.ID)
+		if receipt.Status != permissioned.TxnStatusPending {
+			continue
+		}
+		proof, err := m.GetChainTxnProof(signedTxn.Txn.ID)
+		if err == nil {
+			m.receipts.Include(signedTxn.Txn.ID, proof.Header.Hash(), proof.Header.Height, proof.Index)
+		}
+	}
+
+	for _, signedTxn := range m.wallet.Sync(worldState, m.receipts) {
+		err := m.SendTransaction(signedTxn)
+		if err != nil {
+			log.Err(err).Msgf("failed to send txn %s", signedTxn.Txn.ID)
+		}
+	}
+}
+
+// includeReceipts marks the tracked transactions of the blocks joining the
+// canonical chain as included
+func (m *BlockchainModule) includeReceipts(blocks ...*permissioned.Block) {
+	for _, block := range blocks {
+		for i, signedTxn := range block.Transactions {
+			m.receipts.Include(signedTxn.Txn.ID, block.Hash(), block.Height, i)
+		}
+	}
+}
+
+// reorganize switches the node to the new canonical chain. Transactions
+// only in the removed blocks go back to the pool, the removed ones are
+// reverted and the added ones notified as if freshly appended
+func (m *BlockchainModule) reorganize(reorg *permissioned.Reorg) {
+	tip := reorg.Added[len(reorg.Added)-1]
+	log.Warn().Msgf("reorganise the chain on block %s at height=%d: %d blocks removed, %d added",
+		tip.Hash(), tip.Height, len(reorg.Removed), len(reorg.Added))
+
+	included := map[string]struct{}{}
+	for _, block := range reorg.Added {
+		for _, signedTxn := range block.Transactions {
+			included[signedTxn.Txn.ID] = struct{}{}
+		}
+	}
+	for _, block := range reorg.Removed {
+		for _, signedTxn := range block.Transactions {
+			m.receipts.Revert(signedTxn.Txn.ID)
+			if _, ok := included[signedTxn.Txn.ID]; ok ||
+				signedTxn.Txn.From == permissioned.ZeroAddress.Hex {
+				continue
+			}
+			txn := signedTxn
+			err := m.txnPool.Push(&txn)
+			if err != nil {
+				log.Warn().Msgf("drop txn %s of a removed block: %v", txn.Txn.ID, err)
+			}
+		}
+	}
+
+	go func() {
+		// revert from the old tip backward. Blocks needing votes were
+		// not final, hence not notified
+		for _, block := range reorg.Removed {
+			config := m.blockConfig(block)
+			if config == nil || config.Finality {
+				continue
+			}
+			for i := len(block.Transactions) - 1; i >= 0; i-- {
+				err := m.watchRegistry.TellRevert(config, &block.Transactions[i].Txn)
+				if err != nil {
+					log.Err(err).Send()
+				}
+			}
+			m.events.Publish(permissioned.NewRevertEvents(block)...)
+		}
+		for _, block := range reorg.Added {
+			if !m.isFinalityMode(block) {
+				m.notifyBlk(block)
+			}
+		}
+	}()
+	m.includeReceipts(reorg.Added...)
+	m.voteBlks(reorg.Added...)
+
+	// select next miner on the new tip
+	nextMiner, _ := permissioned.SelectMiner(tip)
+	m.notifyMiner(NextBlkInfo{tip.Height, nextMiner == m.wallet.GetAddress().Hex})
+}
+
 // selectNextMiner selects the next Miner
 // it notifies the minning daemon if the miner is us
 func (m *BlockchainModule) selectNextMiner(block *permissioned.Block) {
 	// select next miner
-	nextMiner := m.cr.advanceAndSelect(block)
+	nextMiner, _ := permissioned.SelectMiner(block)
 	log.Info().Msgf("Next miner on height %d is %s",
 		block.Height+1, nextMiner)
 
 	// notify miner to start if the next miner is myself
-	m.minerChan <- NextBlkInfo{block.Height, nextMiner == m.wallet.GetAddress().Hex}
+	m.notifyMiner(NextBlkInfo{block.Height, nextMiner == m.wallet.GetAddress().Hex})
+}
+
+// notifyMiner notifies the mining daemon without blocking. The miner only
+// cares about the latest height so the oldest notifications are dropped
+// when blocks are appended faster than mined, e.g. while syncing
+func (m *BlockchainModule) notifyMiner(info NextBlkInfo) {
+	for {
+		select {
+		case m.minerChan <- info:
+			return
+		default:
+		}
+
+		select {
+		case <-m.minerChan:
+		default:
+		}
+	}
 }
 
 // getBlockTimeout returns the maxTimeout configured by chain config
@@ -278,6 +963,31 @@ func getBlockTimeout(config *permissioned.ChainConfig) time.Duration {
 	return duration
 }
 
+// reportLateEndorsers sends slash transactions for committee members that
+// missed the endorsement deadline of MPCs initiated by the node, given the
+// world state after the last block
+func (m *BlockchainModule) reportLateEndorsers(worldState storage.KVStore) {
+	lateEndorsers := permissioned.GetLateEndorsers(worldState, m.wallet.GetAddress().Hex)
+	for uniqID, offenders := range lateEndorsers {
+		for _, offender := range offenders {
+			// only report once. Pending reports will be included later
+			if !m.slashReports.Mark(uniqID, offender) {
+				continue
+			}
+			txnID, err := m.SendSlashTransaction(permissioned.SlashEvidence{
+				UniqID:   uniqID,
+				Offender: offender,
+				Reason:   permissioned.SlashReasonTimeout,
+			})
+			if err != nil {
+				log.Err(err).Send()
+				continue
+			}
+			log.Info().Msgf("send slash txn %s against %s for MPC %s", txnID, offender, uniqID)
+		}
+	}
+}
+
 func (m *BlockchainModule) sendRegEnckeyTransaction() (string, error) {
 	pubkey, err := m.GetPubkeyString()
 	if err != nil {
@@ -344,54 +1054,3 @@ func (m *BlockchainModule) broadcastBCBlkMessage(participants map[string]struct{
 	// send in rumor
 	return m.Broadcast(privMsgMarshal)
 }
-
-// sendBCAskSyncMessage sends a BCAskSyncMessage in private msg
-func (m *BlockchainModule) sendBCAskSyncMessage(id string,
-	to string) error {
-	askMsg := types.BCAskSyncMessage{
-		UniqID:       id,
-		Origin:       m.conf.Socket.GetAddress(),
-		LatestHeight: m.GetLatestBlock().Height,
-	}
-	askMsgMarshal, err := m.CreateMsg(askMsg)
-	if err != nil {
-		return err
-	}
-
-	// wrap in private msg
-	privMsg := types.BCPrivateMessage{
-		Recipients: map[string]struct{}{to: {}},
-		Msg:        &askMsgMarshal,
-	}
-	privMsgMarshal, err := m.CreateMsg(privMsg)
-	if err != nil {
-		return err
-	}
-	return m.Broadcast(privMsgMarshal)
-}
-
-// sendBCSyncMessage sends a BCSyncMessage in private msg
-func (m *BlockchainModule) sendBCSyncMessage(id string,
-	blocks []permissioned.Block, to string) error {
-	syncMsg := types.BCSyncMessage{
-		UniqID: id,
-		Origin: m.conf.Socket.GetAddress(),
-		Blocks: blocks,
-	}
-	syncMsgMarshal, err := m.CreateMsg(syncMsg)
-	if err != nil {
-		return err
-	}
-
-	// wrap in private msg
-	privMsg := types.BCPrivateMessage{
-		Recipients: map[string]struct{}{to: {}},
-		Msg:        &syncMsgMarshal,
-	}
-	privMsgMarshal, err := m.CreateMsg(privMsg)
-	if err != nil {
-		return err
-	}
-
-	return m.Broadcast(privMsgMarshal)
-}
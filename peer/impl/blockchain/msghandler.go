@@ -39,18 +39,17 @@ func (m *BlockchainModule) ProcessBCTxnMsg(msg types.Message, pkt transport.Pack
 		return fmt.Errorf("wrong type: %T", msg)
 	}
 
+	if m.IsLight() {
+		// light nodes do not mine
+		return nil
+	}
+
 	if txnMsg.Txn.Txn.From == permissioned.ZeroAddress.Hex {
 		return fmt.Errorf("cannot receive transaction created by zeroAddress")
 	}
 
-	err := txnMsg.Txn.Txn.Unmarshal()
-	if err != nil {
-		return err
-	}
 	txn := txnMsg.Txn
-	m.txnPool.Push(&txn)
-
-	return nil
+	return m.txnPool.Push(&txn)
 }
 
 // ProcessBCBlkMsg is a callback function to handle the received BCBlkMessage
@@ -60,65 +59,184 @@ func (m *BlockchainModule) ProcessBCBlkMsg(msg types.Message, pkt transport.Pack
 		return fmt.Errorf("wrong type: %T", msg)
 	}
 
-	// rebuild the block
-	txns := make([]permissioned.SignedTransaction, 0, len(blkMsg.Txns))
-	for _, txn := range blkMsg.Txns {
-		err := txn.Txn.Unmarshal()
-		if err != nil {
-			return err
-		}
-		txns = append(txns, txn)
+	block := rebuildBlk(blkMsg.BlkHeader, blkMsg.Txns)
+
+	return m.processBlk(block)
+}
+
+// ProcessBCAskHeadersMsg is a callback function to handle the received BCAskHeadersMessage
+func (m *BlockchainModule) ProcessBCAskHeadersMsg(msg types.Message, pkt transport.Packet) error {
+	askMsg, ok := msg.(*types.BCAskHeadersMessage)
+	if !ok {
+		return fmt.Errorf("wrong type: %T", msg)
+	}
+
+	count := askMsg.Count
+	if count > SYNC_HEADERS_BATCH {
+		count = SYNC_HEADERS_BATCH
 	}
-	block := permissioned.Block{
-		BlockHeader:  &blkMsg.BlkHeader,
-		Transactions: txns,
+
+	// the height of a block is its index in the chain
+	headers := make([]permissioned.BlockHeader, 0)
+	blocks := m.GetBlocksFromGenesis()
+	for h := askMsg.FromHeight; h < uint(len(blocks)) && h < askMsg.FromHeight+count; h++ {
+		headers = append(headers, *blocks[h].BlockHeader)
 	}
 
-	return m.processBlk(&block)
+	headersMsg := types.BCHeadersMessage{
+		UniqID:  askMsg.UniqID,
+		Origin:  m.conf.Socket.GetAddress(),
+		Headers: headers,
+	}
+	return m.sendSyncMsg(pkt.Header.Source, headersMsg)
 }
 
-// ProcessBCAskSyncMsg is a callback function to handle the received BCAskSyncMessage
-func (m *BlockchainModule) ProcessBCAskSyncMsg(msg types.Message, pkt transport.Packet) error {
-	askMsg, ok := msg.(*types.BCAskSyncMessage)
+// ProcessBCHeadersMsg is a callback function to handle the received BCHeadersMessage
+func (m *BlockchainModule) ProcessBCHeadersMsg(msg types.Message, pkt transport.Packet) error {
+	headersMsg, ok := msg.(*types.BCHeadersMessage)
 	if !ok {
 		return fmt.Errorf("wrong type: %T", msg)
 	}
 
-	// fetch blocks until the latest height
-	fullBlks := m.GetBlockUntil(askMsg.LatestHeight)
-	blocks := make([]permissioned.Block, 0, len(fullBlks))
-	for i := len(fullBlks) - 1; i > -1; i-- {
-		block := permissioned.Block{
-			BlockHeader:  fullBlks[i].BlockHeader,
-			Transactions: fullBlks[i].Transactions,
-		}
-		blocks = append(blocks, block)
+	m.syncCenter.Notify(headersMsg.UniqID, headersMsg)
+	return nil
+}
+
+// ProcessBCAskBlocksMsg is a callback function to handle the received BCAskBlocksMessage
+func (m *BlockchainModule) ProcessBCAskBlocksMsg(msg types.Message, pkt transport.Packet) error {
+	askMsg, ok := msg.(*types.BCAskBlocksMessage)
+	if !ok {
+		return fmt.Errorf("wrong type: %T", msg)
 	}
 
-	// send sync
-	err := m.sendBCSyncMessage(askMsg.UniqID, blocks, askMsg.Origin)
+	blocks := make([]permissioned.Block, 0, len(askMsg.Hashes))
+	for _, hash := range askMsg.Hashes {
+		block := m.GetBlock(hash)
+		if block == nil {
+			continue
+		}
+		blocks = append(blocks, permissioned.Block{
+			BlockHeader:  block.BlockHeader,
+			Transactions: block.Transactions,
+		})
+	}
 
-	return err
+	blocksMsg := types.BCBlocksMessage{
+		UniqID: askMsg.UniqID,
+		Origin: m.conf.Socket.GetAddress(),
+		Blocks: blocks,
+	}
+	return m.sendSyncMsg(pkt.Header.Source, blocksMsg)
 }
 
-// ProcessBCSyncMsg is a callback function to handle the received BCSyncMessage
-func (m *BlockchainModule) ProcessBCSyncMsg(msg types.Message, pkt transport.Packet) error {
-	synMsg, ok := msg.(*types.BCSyncMessage)
+// ProcessBCBlocksMsg is a callback function to handle the received BCBlocksMessage
+func (m *BlockchainModule) ProcessBCBlocksMsg(msg types.Message, pkt transport.Packet) error {
+	blocksMsg, ok := msg.(*types.BCBlocksMessage)
 	if !ok {
 		return fmt.Errorf("wrong type: %T", msg)
 	}
 
-	// process sync
-	latestBlk := m.GetLatestBlock()
-	for _, block := range synMsg.Blocks {
-		if block.Height <= latestBlk.Height {
+	// rebuild the blocks
+	blocks := make([]permissioned.Block, 0, len(blocksMsg.Blocks))
+	for _, blk := range blocksMsg.Blocks {
+		if blk.BlockHeader == nil {
 			continue
 		}
-		err := m.processBlk(&block)
+		blocks = append(blocks, *rebuildBlk(*blk.BlockHeader, blk.Transactions))
+	}
+	blocksMsg.Blocks = blocks
+
+	m.syncCenter.Notify(blocksMsg.UniqID, blocksMsg)
+	return nil
+}
+
+// ProcessBCVoteMsg is a callback function to handle the received BCVoteMessage
+func (m *BlockchainModule) ProcessBCVoteMsg(msg types.Message, pkt transport.Packet) error {
+	voteMsg, ok := msg.(*types.BCVoteMessage)
+	if !ok {
+		return fmt.Errorf("wrong type: %T", msg)
+	}
+
+	if m.IsLight() {
+		// light nodes only trust the values proven on final headers
+		final, err := m.headers.AddVote(voteMsg.Vote)
 		if err != nil {
 			return err
 		}
+		if final {
+			go m.syncLightWallet()
+		}
+		return nil
+	}
+
+	finalBlock := m.GetFinalBlock()
+	if finalBlock != nil && voteMsg.Vote.Height <= finalBlock.Height {
+		// already final
+		return nil
+	}
+
+	// only the signature is checked here. Whether the voter is a
+	// participant is checked against the config of the voted block
+	voter, err := voteMsg.Vote.Signer()
+	if err != nil {
+		return err
 	}
+	m.votes.Add(voteMsg.Vote.BlockHash, voteMsg.Vote.Height, voter)
 
+	m.checkFinality(voteMsg.Vote.BlockHash)
 	return nil
 }
+
+// ProcessBCAskProofMsg is a callback function to handle the received BCAskProofMessage
+func (m *BlockchainModule) ProcessBCAskProofMsg(msg types.Message, pkt transport.Packet) error {
+	askMsg, ok := msg.(*types.BCAskProofMessage)
+	if !ok {
+		return fmt.Errorf("wrong type: %T", msg)
+	}
+
+	proofMsg := types.BCProofMessage{
+		UniqID: askMsg.UniqID,
+		Origin: m.conf.Socket.GetAddress(),
+	}
+	// a light node has nothing to prove. It answers without proof
+	if !m.IsLight() && askMsg.TxnID != "" {
+		proof, err := m.GetTxnProof(askMsg.TxnID)
+		if err == nil {
+			proofMsg.TxnProof = proof
+		}
+	}
+	if !m.IsLight() && askMsg.Key != "" {
+		var proof *permissioned.StateProof
+		var err error
+		if askMsg.BlockHash != "" {
+			proof, err = m.GetStateProofAt(askMsg.BlockHash, askMsg.Key)
+		} else {
+			proof, err = m.GetStateProof(askMsg.Key)
+		}
+		if err == nil {
+			proofMsg.StateProof = proof
+		}
+	}
+	return m.sendSyncMsg(pkt.Header.Source, proofMsg)
+}
+
+// ProcessBCProofMsg is a callback function to handle the received BCProofMessage
+func (m *BlockchainModule) ProcessBCProofMsg(msg types.Message, pkt transport.Packet) error {
+	proofMsg, ok := msg.(*types.BCProofMessage)
+	if !ok {
+		return fmt.Errorf("wrong type: %T", msg)
+	}
+
+	m.syncCenter.Notify(proofMsg.UniqID, proofMsg)
+	return nil
+}
+
+// rebuildBlk rebuilds a block received without its states
+func rebuildBlk(header permissioned.BlockHeader,
+	signedTxns []permissioned.SignedTransaction) *permissioned.Block {
+
+	return &permissioned.Block{
+		BlockHeader:  &header,
+		Transactions: signedTxns,
+	}
+}
@@ -0,0 +1,418 @@
+package blockchain
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/rs/xid"
+	"github.com/rs/zerolog/log"
+	permissioned "go.dedis.ch/cs438/permissioned-chain"
+	"go.dedis.ch/cs438/types"
+)
+
+// maximal number of headers sent in a single BCHeadersMessage
+const SYNC_HEADERS_BATCH uint = 50
+
+// maximal number of blocks asked to a peer in a single BCAskBlocksMessage
+const SYNC_BLOCKS_BATCH = 10
+
+// SYNC_TIMEOUT is the time to wait for the answers of a sync request
+var SYNC_TIMEOUT = time.Second * 2
+
+// -----------------------------------------------------------------------------
+// Block Sync
+
+// Sync catches up with the longest chain known by the neighbors.
+// It returns once the fetched blocks are appended to the chain. A light
+// node only fetches the headers
+func (m *BlockchainModule) Sync() error {
+	if m.IsLight() {
+		if !m.syncCenter.Start() {
+			return fmt.Errorf("a sync is already running")
+		}
+		return m.syncLight()
+	}
+
+	if m.wallet == nil {
+		return fmt.Errorf("node %s does not have an address yet",
+			m.conf.Socket.GetAddress())
+	}
+	if !m.syncCenter.Start() {
+		return fmt.Errorf("a sync is already running")
+	}
+
+	target, err := m.sync()
+	if err != nil {
+		return err
+	}
+	if target == nil {
+		return nil
+	}
+
+	// wait for the verifying daemon to append the fetched blocks
+	timeout := time.After(SYNC_TIMEOUT)
+	height := uint(0)
+	for {
+		latestBlock := m.GetLatestBlock()
+		if latestBlock != nil && latestBlock.Height >= target.Height {
+			return nil
+		}
+		if latestBlock != nil && latestBlock.Height > height {
+			height = latestBlock.Height
+			timeout = time.After(SYNC_TIMEOUT)
+		}
+
+		select {
+		case <-timeout:
+			return fmt.Errorf("fetched blocks until height %d but stuck on height %d",
+				target.Height, height)
+		case <-time.After(time.Millisecond * 10):
+		}
+	}
+}
+
+// syncAdvanceBlk starts a sync in background to fill the gap before an
+// advanced block. The block is processed again once the sync is done
+func (m *BlockchainModule) syncAdvanceBlk(block *permissioned.Block) {
+	if !m.syncCenter.Start(block) {
+		// a sync is already running
+		return
+	}
+
+	go func() {
+		_, err := m.sync()
+		if err != nil {
+			log.Err(err).Msgf("failed to sync before block %s", block.Hash())
+		}
+	}()
+}
+
+// sync fetches the blocks missing from the chain and queues them in the
+// block pool. It returns the header of the last fetched block, nil if the
+// chain is up to date. Deferred blocks are queued back if any progress is made
+func (m *BlockchainModule) sync() (*permissioned.BlockHeader, error) {
+	target, err := m.fetchMissingBlks()
+
+	pending := m.syncCenter.Done()
+	if target == nil {
+		if len(pending) > 0 {
+			log.Warn().Msgf("no block can be synced. Drop %d advanced blocks", len(pending))
+		}
+		return nil, err
+	}
+	for _, block := range pending {
+		m.blkPool.Add(block)
+	}
+
+	return target, err
+}
+
+// fetchMissingBlks fetches the missing blocks batch by batch: it asks all
+// neighbors for headers and fetches the bodies of the longest valid chain
+// from the neighbors having it. If they fail to provide the blocks, the
+// next longest chain is tried
+func (m *BlockchainModule) fetchMissingBlks() (*permissioned.BlockHeader, error) {
+	neighbors := m.GetNeighbors(nil)
+	if len(neighbors) == 0 {
+		return nil, fmt.Errorf("no neighbor to sync with")
+	}
+
+	prevHash := permissioned.DUMMY_PREVHASH
+	from := uint(0)
+	if latestBlock := m.GetLatestBlock(); latestBlock != nil {
+		prevHash = latestBlock.Hash()
+		from = latestBlock.Height + 1
+	}
+
+	var target *permissioned.BlockHeader
+	for {
+		candidates := m.fetchHeaders(neighbors, from, prevHash)
+		if len(candidates) == 0 {
+			return target, nil
+		}
+
+		var headers []permissioned.BlockHeader
+		var blocks []*permissioned.Block
+		var err error
+		for _, candidate := range candidates {
+			log.Info().Msgf("syncing blocks from height %d to %d with %v", from,
+				candidate.headers[len(candidate.headers)-1].Height, candidate.sources)
+
+			blocks, err = m.fetchBlocks(candidate.headers, candidate.sources)
+			if err == nil {
+				headers = candidate.headers
+				break
+			}
+			log.Warn().Err(err).Msgf("failed to sync with %v", candidate.sources)
+		}
+		if err != nil {
+			return target, err
+		}
+		for _, block := range blocks {
+			err = m.processBlk(block)
+			if err != nil {
+				return target, err
+			}
+		}
+
+		target = &headers[len(headers)-1]
+		prevHash = target.Hash()
+		from = target.Height + 1
+
+		if uint(len(headers)) < SYNC_HEADERS_BATCH {
+			return target, nil
+		}
+	}
+}
+
+// syncCandidate is a chain of headers announced during a sync and the peers
+// having it
+type syncCandidate struct {
+	headers []permissioned.BlockHeader
+	sources []string
+}
+
+// fetchHeaders asks the neighbors for the headers following prevHash. It
+// returns the valid chains they announced, the longest first
+func (m *BlockchainModule) fetchHeaders(neighbors []string, from uint,
+	prevHash string) []syncCandidate {
+
+	requests := map[string]types.Message{}
+	for _, neighbor := range neighbors {
+		requests[neighbor] = types.BCAskHeadersMessage{
+			UniqID:     xid.New().String(),
+			Origin:     m.conf.Socket.GetAddress(),
+			FromHeight: from,
+			Count:      SYNC_HEADERS_BATCH,
+		}
+	}
+	replies := m.request(requests)
+
+	valid := map[string][]permissioned.BlockHeader{}
+	for peer, reply := range replies {
+		headersMsg, ok := reply.(*types.BCHeadersMessage)
+		if !ok {
+			continue
+		}
+		headers := headersMsg.Headers[:verifyHeaders(headersMsg.Headers, from, prevHash)]
+		if len(headers) > 0 {
+			valid[peer] = headers
+		}
+	}
+
+	return syncCandidates(valid)
+}
+
+// syncCandidates groups the valid headers announced by each peer into
+// candidate chains, the longest first. Any peer whose headers contain a
+// chain can serve its blocks, the peers that announced it come first
+func syncCandidates(valid map[string][]permissioned.BlockHeader) []syncCandidate {
+	peers := make([]string, 0, len(valid))
+	for peer := range valid {
+		peers = append(peers, peer)
+	}
+	sort.Slice(peers, func(i, j int) bool {
+		if len(valid[peers[i]]) != len(valid[peers[j]]) {
+			return len(valid[peers[i]]) > len(valid[peers[j]])
+		}
+		return peers[i] < peers[j]
+	})
+
+	candidates := make([]syncCandidate, 0)
+	seen := map[string]struct{}{}
+	for _, peer := range peers {
+		headers := valid[peer]
+		last := headers[len(headers)-1].Hash()
+		if _, ok := seen[last]; ok {
+			continue
+		}
+		seen[last] = struct{}{}
+
+		sources := []string{}
+		others := []string{}
+		for _, other := range peers {
+			otherHeaders := valid[other]
+			if len(otherHeaders) < len(headers) ||
+				otherHeaders[len(headers)-1].Hash() != last {
+				continue
+			}
+			if len(otherHeaders) == len(headers) {
+				sources = append(sources, other)
+			} else {
+				others = append(others, other)
+			}
+		}
+		candidates = append(candidates, syncCandidate{
+			headers: headers,
+			sources: append(sources, others...),
+		})
+	}
+	return candidates
+}
+
+// fetchBlocks fetches the bodies of the headers from the sources. Blocks a
+// source failed to provide are asked again to each source in turn
+func (m *BlockchainModule) fetchBlocks(headers []permissioned.BlockHeader,
+	sources []string) ([]*permissioned.Block, error) {
+
+	wanted := map[string]struct{}{}
+	hashes := make([]string, 0, len(headers))
+	for _, header := range headers {
+		hash := header.Hash()
+		wanted[hash] = struct{}{}
+		hashes = append(hashes, hash)
+	}
+
+	fetched := map[string]*permissioned.Block{}
+	collect := func(replies map[string]types.Message) {
+		for _, reply := range replies {
+			blocksMsg, ok := reply.(*types.BCBlocksMessage)
+			if !ok {
+				continue
+			}
+			for i := range blocksMsg.Blocks {
+				block := blocksMsg.Blocks[i]
+				hash := block.Hash()
+				if _, ok := wanted[hash]; ok {
+					fetched[hash] = &block
+				}
+			}
+		}
+	}
+	missing := func() []string {
+		res := make([]string, 0)
+		for _, hash := range hashes {
+			if _, ok := fetched[hash]; !ok {
+				res = append(res, hash)
+			}
+		}
+		return res
+	}
+
+	// spread the batches on the sources
+	for start := 0; start < len(hashes); {
+		requests := map[string]types.Message{}
+		for _, source := range sources {
+			if start >= len(hashes) {
+				break
+			}
+			end := start + SYNC_BLOCKS_BATCH
+			if end > len(hashes) {
+				end = len(hashes)
+			}
+			requests[source] = types.BCAskBlocksMessage{
+				UniqID: xid.New().String(),
+				Origin: m.conf.Socket.GetAddress(),
+				Hashes: hashes[start:end],
+			}
+			start = end
+		}
+		collect(m.request(requests))
+	}
+
+	// retry the missing ones on each source until they are all fetched
+	for _, source := range sources {
+		hashes := missing()
+		if len(hashes) == 0 {
+			break
+		}
+		collect(m.request(map[string]types.Message{
+			source: types.BCAskBlocksMessage{
+				UniqID: xid.New().String(),
+				Origin: m.conf.Socket.GetAddress(),
+				Hashes: hashes,
+			},
+		}))
+	}
+
+	if hashes := missing(); len(hashes) > 0 {
+		return nil, fmt.Errorf("failed to fetch %d blocks, first one is %s",
+			len(hashes), hashes[0])
+	}
+	blocks := make([]*permissioned.Block, 0, len(hashes))
+	for _, hash := range hashes {
+		blocks = append(blocks, fetched[hash])
+	}
+	return blocks, nil
+}
+
+// request sends the sync requests to the peers and waits for their answers
+// until SYNC_TIMEOUT. The answers are indexed by peer
+func (m *BlockchainModule) request(requests map[string]types.Message) map[string]types.Message {
+	channel := make(chan types.Message, len(requests))
+	ids := map[string]string{}
+	for peer, request := range requests {
+		id := syncRequestID(request)
+		m.syncCenter.Register(id, channel)
+		ids[id] = peer
+
+		err := m.sendSyncMsg(peer, request)
+		if err != nil {
+			log.Err(err).Msgf("failed to send sync request to %s", peer)
+		}
+	}
+	defer func() {
+		for id := range ids {
+			m.syncCenter.Unregister(id)
+		}
+	}()
+
+	replies := map[string]types.Message{}
+	timeout := time.After(SYNC_TIMEOUT)
+	for len(replies) < len(requests) {
+		select {
+		case reply := <-channel:
+			if peer, ok := ids[syncRequestID(reply)]; ok {
+				replies[peer] = reply
+			}
+		case <-timeout:
+			return replies
+		}
+	}
+	return replies
+}
+
+// sendSyncMsg sends a sync message directly to a neighbor
+func (m *BlockchainModule) sendSyncMsg(dest string, msg types.Message) error {
+	transpMsg, err := m.CreateMsg(msg)
+	if err != nil {
+		return err
+	}
+	return m.SendToNeighbor(dest, transpMsg)
+}
+
+// syncRequestID returns the ID of a sync request or answer
+func syncRequestID(msg types.Message) string {
+	switch vv := msg.(type) {
+	case types.BCAskHeadersMessage:
+		return vv.UniqID
+	case types.BCAskBlocksMessage:
+		return vv.UniqID
+	case *types.BCHeadersMessage:
+		return vv.UniqID
+	case *types.BCBlocksMessage:
+		return vv.UniqID
+	case types.BCAskProofMessage:
+		return vv.UniqID
+	case *types.BCProofMessage:
+		return vv.UniqID
+	}
+	return ""
+}
+
+// verifyHeaders returns the number of headers forming a valid chain
+// starting at the given height after the block prevHash. Each header must be
+// signed by its miner
+func verifyHeaders(headers []permissioned.BlockHeader, from uint, prevHash string) int {
+	for i, header := range headers {
+		if header.Height != from+uint(i) || header.PrevHash != prevHash {
+			return i
+		}
+		if header.VerifySignature() != nil {
+			return i
+		}
+		prevHash = header.Hash()
+	}
+	return len(headers)
+}
@@ -0,0 +1,113 @@
+package blockchain
+
+import (
+	"crypto/ecdsa"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/stretchr/testify/require"
+	permissioned "go.dedis.ch/cs438/permissioned-chain"
+)
+
+func Test_BC_Sync_Verify_Headers(t *testing.T) {
+	privKey, err := crypto.GenerateKey()
+	require.NoError(t, err)
+
+	headers := newSyncHeaders(t, privKey, 5, 0, permissioned.DUMMY_PREVHASH)
+
+	// > a linked chain is valid
+	require.Equal(t, 5, verifyHeaders(headers, 0, permissioned.DUMMY_PREVHASH))
+	require.Equal(t, 3, verifyHeaders(headers[2:], 2, headers[1].Hash()))
+	require.Equal(t, 0, verifyHeaders(nil, 0, permissioned.DUMMY_PREVHASH))
+
+	// > headers not following our latest block are invalid
+	require.Equal(t, 0, verifyHeaders(headers[2:], 2, headers[0].Hash()))
+	require.Equal(t, 0, verifyHeaders(headers[2:], 3, headers[1].Hash()))
+
+	// > only the prefix before a broken link is valid
+	broken := append([]permissioned.BlockHeader{}, headers...)
+	broken[3].Timestamp++
+	require.NoError(t, broken[3].Sign(privKey))
+	require.Equal(t, 4, verifyHeaders(broken, 0, permissioned.DUMMY_PREVHASH))
+
+	// > only the prefix before a header not signed by its miner is valid
+	forged := append([]permissioned.BlockHeader{}, headers...)
+	forged[2].Signature = nil
+	require.Equal(t, 2, verifyHeaders(forged, 0, permissioned.DUMMY_PREVHASH))
+
+	other, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	forged = append([]permissioned.BlockHeader{}, headers...)
+	require.NoError(t, forged[1].Sign(other))
+	require.Equal(t, 1, verifyHeaders(forged, 0, permissioned.DUMMY_PREVHASH))
+}
+
+func Test_BC_Sync_Candidates(t *testing.T) {
+	privKey, err := crypto.GenerateKey()
+	require.NoError(t, err)
+
+	other, err := crypto.GenerateKey()
+	require.NoError(t, err)
+
+	chain := newSyncHeaders(t, privKey, 4, 0, permissioned.DUMMY_PREVHASH)
+	fork := newSyncHeaders(t, other, 2, 1, chain[0].Hash())
+	valid := map[string][]permissioned.BlockHeader{
+		"long":  chain,
+		"short": chain[:2],
+		"fork":  append([]permissioned.BlockHeader{chain[0]}, fork...),
+		"long2": chain,
+	}
+
+	candidates := syncCandidates(valid)
+	require.Len(t, candidates, 3)
+
+	// > the longest chain comes first, any peer having it can serve it
+	require.Equal(t, chain, candidates[0].headers)
+	require.Equal(t, []string{"long", "long2"}, candidates[0].sources)
+	require.Equal(t, 3, len(candidates[1].headers))
+	require.Equal(t, []string{"fork"}, candidates[1].sources)
+
+	// > peers with a longer chain can serve a shorter one, after the peers
+	// that announced it
+	require.Equal(t, chain[:2], candidates[2].headers)
+	require.Equal(t, []string{"short", "long", "long2"}, candidates[2].sources)
+
+	require.Empty(t, syncCandidates(nil))
+}
+
+func Test_BC_Sync_Center_Pending(t *testing.T) {
+	center := NewSyncCenter()
+	block1 := &permissioned.Block{BlockHeader: &permissioned.BlockHeader{Height: 1}}
+	block2 := &permissioned.Block{BlockHeader: &permissioned.BlockHeader{Height: 2}}
+
+	// > only one sync runs at a time. Blocks are kept for the running one
+	require.True(t, center.Start(block1))
+	require.False(t, center.Start(block2))
+	require.False(t, center.Start())
+
+	pending := center.Done()
+	require.Equal(t, []*permissioned.Block{block1, block2}, pending)
+
+	require.True(t, center.Start())
+	require.Len(t, center.Done(), 0)
+}
+
+// newSyncHeaders returns a chain of headers signed by the miner
+func newSyncHeaders(t *testing.T, privKey *ecdsa.PrivateKey, count int, from uint,
+	prevHash string) []permissioned.BlockHeader {
+
+	headers := make([]permissioned.BlockHeader, 0, count)
+	for i := uint(0); i < uint(count); i++ {
+		header := permissioned.BlockHeader{
+			PrevHash:  prevHash,
+			Height:    from + i,
+			Miner:     permissioned.NewAddress(&privKey.PublicKey).Hex,
+			Timestamp: int64(from + i),
+		}
+		require.NoError(t, header.Sign(privKey))
+		headers = append(headers, header)
+		prevHash = header.Hash()
+	}
+	return headers
+}
@@ -3,11 +3,16 @@ package blockchain
 import (
 	"context"
 	"crypto/ecdsa"
+	"encoding/json"
+	"fmt"
 	"sort"
 	"sync"
+	"time"
 
+	"github.com/rs/zerolog/log"
 	permissioned "go.dedis.ch/cs438/permissioned-chain"
 	"go.dedis.ch/cs438/storage"
+	"go.dedis.ch/cs438/types"
 )
 
 // -----------------------------------------------------------------------------
@@ -82,124 +87,269 @@ func (p *BlkPool) Get(ctx context.Context) *permissioned.Block {
 // -----------------------------------------------------------------------------
 // TxnPool
 
-const POOL_CHAN_BUFFER_SIZE = 10
+// maximal number of transactions waiting in the pool
+var TXN_POOL_MAX_SIZE = 1024
 
+// time after which a transaction that could not be included is dropped,
+// e.g. because of a nonce gap
+var TXN_POOL_TTL = time.Minute * 10
+
+var ErrTxnPoolFull = fmt.Errorf("txn pool is full")
+var ErrTxnUnderpriced = fmt.Errorf("a txn with the same nonce and a higher or equal tip is pending")
+var ErrTxnExpired = fmt.Errorf("txn expired in the pool")
+
+type pooledTxn struct {
+	txn   *permissioned.SignedTransaction
+	added time.Time
+}
+
+// TxnPool keeps the pending transactions per account, ordered by nonce.
+// A transaction is ready once it has the next nonce of its account. The
+// ready transactions are served by tip, oldest first. A transaction with
+// the nonce of a pending one replaces it if its tip is higher, so that the
+// sender can bump or cancel it
 type TxnPool struct {
 	*sync.Mutex
-	channel       chan *permissioned.SignedTransaction
-	queue         []*permissioned.SignedTransaction
+	// sender -> nonce -> txn
+	accounts      map[string]map[uint]*pooledTxn
+	size          int
+	receipts      *ReceiptStore
 	newTxnChannel chan struct{}
 }
 
-func NewTxnPool() *TxnPool {
-	lock := sync.Mutex{}
+func NewTxnPool(receipts *ReceiptStore) *TxnPool {
 	return &TxnPool{
-		Mutex: &lock,
-		channel: make(chan *permissioned.SignedTransaction,
-			POOL_CHAN_BUFFER_SIZE),
-		queue:         make([]*permissioned.SignedTransaction, 0),
+		Mutex:         &sync.Mutex{},
+		accounts:      map[string]map[uint]*pooledTxn{},
+		receipts:      receipts,
 		newTxnChannel: make(chan struct{}, 1),
 	}
 }
 
+// Daemon regularly drops the expired transactions
 func (p *TxnPool) Daemon(ctx context.Context) {
+	ticker := time.NewTicker(TXN_POOL_TTL / 10)
+	defer ticker.Stop()
+
 	for {
 		select {
 		case <-ctx.Done():
 			return
-		case <-p.newTxnChannel:
-			p.Lock()
-			for _, txn := range p.queue {
-				p.Unlock()
-				p.channel <- txn
-				p.Lock()
-			}
-			p.queue = make([]*permissioned.SignedTransaction, 0)
-			p.Unlock()
+		case now := <-ticker.C:
+			p.Expire(now)
 		}
 	}
 }
 
-func (p *TxnPool) sortedInsert(txn *permissioned.SignedTransaction) {
-	i := sort.Search(len(p.queue), func(i int) bool {
-		return p.queue[i].Txn.Nonce > txn.Txn.Nonce
-	})
-	if i == len(p.queue) {
-		// Insert at end is the easy case.
-		p.queue = append(p.queue, txn)
-	} else {
-		// Make space for the inserted element by shifting values
-		p.queue = append(p.queue[:i+1], p.queue[i:]...)
-		// Insert the new element.
-		p.queue[i] = txn
+// Push adds a transaction to the pool. A pending transaction with the same
+// nonce is replaced if the new tip is higher. If the pool is full, the
+// transaction with the lowest tip is evicted if it is lower than the new one
+func (p *TxnPool) Push(txn *permissioned.SignedTransaction) error {
+	p.Lock()
+	defer p.Unlock()
+
+	return p.push(txn, time.Now())
+}
+
+// PushBackSeveral puts back transactions taken from the pool but not
+// included, e.g. by a node that was not selected as miner
+func (p *TxnPool) PushBackSeveral(txns []permissioned.SignedTransaction) {
+	p.Lock()
+	defer p.Unlock()
+
+	now := time.Now()
+	for i := range txns {
+		// replaced or evicted in the meantime otherwise
+		_ = p.push(&txns[i], now)
 	}
 }
 
-func (p *TxnPool) Push(txn *permissioned.SignedTransaction) {
+// Pop removes and returns the ready transaction with the highest tip. The
+// nonce function returns the next nonce of an account. Transactions with
+// a lower nonce are already included and dropped. It returns nil if no
+// transaction is ready
+func (p *TxnPool) Pop(nonce func(addr string) uint) *permissioned.SignedTransaction {
 	p.Lock()
 	defer p.Unlock()
 
-	p.sortedInsert(txn)
+	var best *pooledTxn
+	for from, pending := range p.accounts {
+		next := nonce(from)
+		for n := range pending {
+			if n < next {
+				p.remove(from, n)
+			}
+		}
 
-	if len(p.newTxnChannel) == 0 {
-		p.newTxnChannel <- struct{}{}
+		entry, ok := pending[next]
+		if !ok {
+			continue
+		}
+		if best == nil || entry.txn.Txn.Tip > best.txn.Txn.Tip ||
+			(entry.txn.Txn.Tip == best.txn.Txn.Tip && entry.added.Before(best.added)) {
+			best = entry
+		}
 	}
+	if best == nil {
+		return nil
+	}
+
+	p.remove(best.txn.Txn.From, best.txn.Txn.Nonce)
+	return best.txn
 }
 
-func (p *TxnPool) PushBackSeveral(txns []permissioned.SignedTransaction) {
+// Reject drops a transaction that failed its verification
+func (p *TxnPool) Reject(txn *permissioned.SignedTransaction, err error) {
 	p.Lock()
 	defer p.Unlock()
 
-	for _, txn := range txns {
-		p.sortedInsert(&txn)
+	entry, ok := p.accounts[txn.Txn.From][txn.Txn.Nonce]
+	if ok && entry.txn.Txn.ID == txn.Txn.ID {
+		p.remove(txn.Txn.From, txn.Txn.Nonce)
 	}
+	p.receipts.Reject(txn.Txn.ID, err)
+}
+
+// Expire drops the transactions added before the TTL
+func (p *TxnPool) Expire(now time.Time) {
+	p.Lock()
+	defer p.Unlock()
 
-	if len(p.newTxnChannel) == 0 {
-		p.newTxnChannel <- struct{}{}
+	for from, pending := range p.accounts {
+		for n, entry := range pending {
+			if now.Sub(entry.added) > TXN_POOL_TTL {
+				p.remove(from, n)
+				p.receipts.Reject(entry.txn.Txn.ID, ErrTxnExpired)
+			}
+		}
 	}
 }
 
-// func (p *TxnPool) Pull() <-chan *permissioned.SignedTransaction {
-// 	p.Lock()
-// 	defer p.Unlock()
+// Len returns the number of pending transactions
+func (p *TxnPool) Len() int {
+	p.Lock()
+	defer p.Unlock()
+
+	return p.size
+}
+
+// Notify returns the channel notified when transactions are added
+func (p *TxnPool) Notify() <-chan struct{} {
+	return p.newTxnChannel
+}
 
-// 	if len(p.channel) > 0 {
-// 		return p.channel
-// 	}
+func (p *TxnPool) push(txn *permissioned.SignedTransaction, now time.Time) error {
+	from, nonce := txn.Txn.From, txn.Txn.Nonce
 
-// 	i := 0
-// 	queueLen := len(p.queue)
-// 	for ; i < POOL_CHAN_BUFFER_SIZE && i < queueLen; i++ {
-// 		p.channel <- p.queue[i]
-// 	}
-// 	p.queue = p.queue[i:]
+	old, ok := p.accounts[from][nonce]
+	if ok {
+		if old.txn.Txn.ID == txn.Txn.ID {
+			return nil
+		}
+		if txn.Txn.Tip <= old.txn.Txn.Tip {
+			return ErrTxnUnderpriced
+		}
+		p.remove(from, nonce)
+		p.receipts.Reject(old.txn.Txn.ID,
+			fmt.Errorf("replaced by txn %s", txn.Txn.ID))
+	}
 
-// 	return p.channel
-// }
+	if p.size >= TXN_POOL_MAX_SIZE {
+		victim := p.lowestTip()
+		if victim == nil || victim.txn.Txn.Tip >= txn.Txn.Tip {
+			return ErrTxnPoolFull
+		}
+		p.remove(victim.txn.Txn.From, victim.txn.Txn.Nonce)
+		p.receipts.Reject(victim.txn.Txn.ID, ErrTxnPoolFull)
+	}
+
+	pending, ok := p.accounts[from]
+	if !ok {
+		pending = map[uint]*pooledTxn{}
+		p.accounts[from] = pending
+	}
+	pending[nonce] = &pooledTxn{txn: txn, added: now}
+	p.size++
+
+	select {
+	case p.newTxnChannel <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// lowestTip returns the eviction candidate: the last transaction of an
+// account, so that no nonce gap is created, with the lowest tip. The
+// newest one is evicted on ties
+func (p *TxnPool) lowestTip() *pooledTxn {
+	var victim *pooledTxn
+	for _, pending := range p.accounts {
+		var last *pooledTxn
+		for _, entry := range pending {
+			if last == nil || entry.txn.Txn.Nonce > last.txn.Txn.Nonce {
+				last = entry
+			}
+		}
+		if last == nil {
+			continue
+		}
+		if victim == nil || last.txn.Txn.Tip < victim.txn.Txn.Tip ||
+			(last.txn.Txn.Tip == victim.txn.Txn.Tip && last.added.After(victim.added)) {
+			victim = last
+		}
+	}
+	return victim
+}
+
+func (p *TxnPool) remove(from string, nonce uint) {
+	pending := p.accounts[from]
+	if _, ok := pending[nonce]; !ok {
+		return
+	}
+	delete(pending, nonce)
+	p.size--
+	if len(pending) == 0 {
+		delete(p.accounts, from)
+	}
+}
 
 // -----------------------------------------------------------------------------
 // SyncCenter
 
+// SyncCenter dispatches the answers of sync requests to the waiting requester
 type SyncCenter struct {
 	*sync.Mutex
-	store map[string]chan error
+	store map[string]chan types.Message
+
+	// only one sync runs at a time
+	syncing bool
+	// advanced blocks received while syncing
+	pending []*permissioned.Block
 }
 
 func NewSyncCenter() *SyncCenter {
 	return &SyncCenter{
-		Mutex: &sync.Mutex{},
-		store: map[string]chan error{},
+		Mutex:   &sync.Mutex{},
+		store:   map[string]chan types.Message{},
+		pending: make([]*permissioned.Block, 0),
 	}
 }
 
-func (c *SyncCenter) Register(id string, channel chan error) {
+func (c *SyncCenter) Register(id string, channel chan types.Message) {
 	c.Lock()
 	defer c.Unlock()
 
 	c.store[id] = channel
 }
 
-func (c *SyncCenter) Notify(id string, err error) {
+func (c *SyncCenter) Unregister(id string) {
+	c.Lock()
+	defer c.Unlock()
+
+	delete(c.store, id)
+}
+
+func (c *SyncCenter) Notify(id string, msg types.Message) {
 	c.Lock()
 	defer c.Unlock()
 
@@ -208,10 +358,294 @@ func (c *SyncCenter) Notify(id string, err error) {
 		return
 	}
 
-	channel <- err
+	channel <- msg
 	delete(c.store, id)
 }
 
+// Start marks a sync as running with the blocks to process once it is done.
+// If a sync is already running, the blocks are added to it and false is returned
+func (c *SyncCenter) Start(pending ...*permissioned.Block) bool {
+	c.Lock()
+	defer c.Unlock()
+
+	c.pending = append(c.pending, pending...)
+	if c.syncing {
+		return false
+	}
+	c.syncing = true
+	return true
+}
+
+// Done marks the running sync as finished and returns the blocks put aside
+// during the sync
+func (c *SyncCenter) Done() []*permissioned.Block {
+	c.Lock()
+	defer c.Unlock()
+
+	pending := c.pending
+	c.syncing = false
+	c.pending = make([]*permissioned.Block, 0)
+	return pending
+}
+
+// -----------------------------------------------------------------------------
+// SlashReports
+
+type SlashReports struct {
+	*sync.Mutex
+	reported map[string]struct{}
+}
+
+func NewSlashReports() *SlashReports {
+	return &SlashReports{
+		Mutex:    &sync.Mutex{},
+		reported: map[string]struct{}{},
+	}
+}
+
+// Mark marks the offence as reported. It returns false if it was already reported
+func (r *SlashReports) Mark(uniqID string, offender string) bool {
+	r.Lock()
+	defer r.Unlock()
+
+	key := uniqID + "|" + offender
+	if _, ok := r.reported[key]; ok {
+		return false
+	}
+	r.reported[key] = struct{}{}
+	return true
+}
+
+// -----------------------------------------------------------------------------
+// VoteCenter
+
+// VoteCenter collects the votes of the participants for blocks, the
+// heights the node already voted on and the block it last voted for
+type VoteCenter struct {
+	*sync.Mutex
+	// block hash -> voters
+	votes map[string]map[string]struct{}
+	// block hash -> height
+	heights map[string]uint
+	voted   map[uint]struct{}
+	// the node only votes for blocks extending this one
+	lockHash   string
+	lockHeight uint
+}
+
+func NewVoteCenter() *VoteCenter {
+	return &VoteCenter{
+		Mutex:   &sync.Mutex{},
+		votes:   map[string]map[string]struct{}{},
+		heights: map[string]uint{},
+		voted:   map[uint]struct{}{},
+	}
+}
+
+// Add records the vote of the voter for the block
+func (c *VoteCenter) Add(blockHash string, height uint, voter string) {
+	c.Lock()
+	defer c.Unlock()
+
+	voters, ok := c.votes[blockHash]
+	if !ok {
+		voters = map[string]struct{}{}
+		c.votes[blockHash] = voters
+		c.heights[blockHash] = height
+	}
+	voters[voter] = struct{}{}
+}
+
+// Count returns the number of participants that voted for the block
+func (c *VoteCenter) Count(blockHash string, participants map[string]string) int {
+	c.Lock()
+	defer c.Unlock()
+
+	return c.count(blockHash, participants)
+}
+
+// count is the unlocked version of Count
+func (c *VoteCenter) count(blockHash string, participants map[string]string) int {
+	count := 0
+	for voter := range c.votes[blockHash] {
+		if _, ok := participants[voter]; ok {
+			count++
+		}
+	}
+	return count
+}
+
+// MarkVoted marks that the node votes for the block. It returns false if
+// the node already voted on its height, or if the block does not extend the
+// block the node last voted for: voting on two branches, even at different
+// heights, could make both final
+func (c *VoteCenter) MarkVoted(chain *permissioned.Blockchain, block *permissioned.Block,
+	validators map[string]string) bool {
+	c.Lock()
+	defer c.Unlock()
+
+	if _, ok := c.voted[block.Height]; ok {
+		return false
+	}
+	if c.lockHash != "" && !chain.IsAncestor(c.lockHash, block.Hash()) &&
+		!c.unlocked(chain, block, validators) {
+		return false
+	}
+	c.voted[block.Height] = struct{}{}
+	c.lockHash, c.lockHeight = block.Hash(), block.Height
+	return true
+}
+
+// unlocked checks if the block extends a block above the lock that a quorum
+// of validators voted for, or a final one. The branch of the lock can then
+// never be final anymore
+func (c *VoteCenter) unlocked(chain *permissioned.Blockchain, block *permissioned.Block,
+	validators map[string]string) bool {
+	final := chain.GetFinalBlock()
+	if final != nil && final.Height >= c.lockHeight && chain.IsAncestor(final.Hash(), block.Hash()) {
+		return true
+	}
+
+	quorum := permissioned.FinalityQuorum(len(validators))
+	for blockHash, height := range c.heights {
+		if height <= c.lockHeight || c.count(blockHash, validators) < quorum {
+			continue
+		}
+		if chain.IsAncestor(blockHash, block.Hash()) {
+			return true
+		}
+	}
+	return false
+}
+
+// Prune forgets the votes for the blocks up to the final height
+func (c *VoteCenter) Prune(height uint) {
+	c.Lock()
+	defer c.Unlock()
+
+	for blockHash, h := range c.heights {
+		if h <= height {
+			delete(c.votes, blockHash)
+			delete(c.heights, blockHash)
+		}
+	}
+	for h := range c.voted {
+		if h <= height {
+			delete(c.voted, h)
+		}
+	}
+}
+
+// -----------------------------------------------------------------------------
+// ReceiptStore
+
+// ReceiptStore keeps the receipts of the transactions sent by the node
+type ReceiptStore struct {
+	*sync.RWMutex
+	receipts map[string]*permissioned.TxnReceipt
+	// sent txns, to bump or cancel them
+	txns map[string]permissioned.Transaction
+	// notified when a sent txn is rejected
+	rejections chan struct{}
+}
+
+func NewReceiptStore() *ReceiptStore {
+	return &ReceiptStore{
+		RWMutex:    &sync.RWMutex{},
+		receipts:   map[string]*permissioned.TxnReceipt{},
+		txns:       map[string]permissioned.Transaction{},
+		rejections: make(chan struct{}, 1),
+	}
+}
+
+// Track starts tracking a transaction sent by the node as pending
+func (s *ReceiptStore) Track(txn *permissioned.Transaction) {
+	s.Lock()
+	defer s.Unlock()
+
+	if _, ok := s.receipts[txn.ID]; ok {
+		return
+	}
+	s.receipts[txn.ID] = permissioned.NewTxnReceipt(txn)
+	s.txns[txn.ID] = *txn
+}
+
+// GetTxn returns a transaction sent by the node
+func (s *ReceiptStore) GetTxn(txnID string) (permissioned.Transaction, bool) {
+	s.RLock()
+	defer s.RUnlock()
+
+	txn, ok := s.txns[txnID]
+	return txn, ok
+}
+
+// Include marks a tracked transaction as included in the block. Another
+// miner may include a transaction the node rejected, so it wins over rejection
+func (s *ReceiptStore) Include(txnID string, blockHash string, height uint, index int) {
+	s.Lock()
+	defer s.Unlock()
+
+	receipt, ok := s.receipts[txnID]
+	if !ok {
+		return
+	}
+	receipt.Status = permissioned.TxnStatusIncluded
+	receipt.Error = ""
+	receipt.BlockHash = blockHash
+	receipt.Height = height
+	receipt.Index = index
+}
+
+// Reject marks a pending tracked transaction as rejected with the reason
+func (s *ReceiptStore) Reject(txnID string, err error) {
+	s.Lock()
+	defer s.Unlock()
+
+	receipt, ok := s.receipts[txnID]
+	if !ok || receipt.Status != permissioned.TxnStatusPending {
+		return
+	}
+	receipt.Status = permissioned.TxnStatusRejected
+	receipt.Error = err.Error()
+
+	select {
+	case s.rejections <- struct{}{}:
+	default:
+	}
+}
+
+// Rejections returns the channel notified when a sent txn is rejected
+func (s *ReceiptStore) Rejections() <-chan struct{} {
+	return s.rejections
+}
+
+// Revert marks a tracked transaction leaving the canonical chain as pending
+func (s *ReceiptStore) Revert(txnID string) {
+	s.Lock()
+	defer s.Unlock()
+
+	receipt, ok := s.receipts[txnID]
+	if !ok || receipt.Status != permissioned.TxnStatusIncluded {
+		return
+	}
+	receipt.Status = permissioned.TxnStatusPending
+	receipt.BlockHash = ""
+	receipt.Height = 0
+	receipt.Index = 0
+}
+
+// Get returns a copy of the receipt of the transaction
+func (s *ReceiptStore) Get(txnID string) (permissioned.TxnReceipt, bool) {
+	s.RLock()
+	defer s.RUnlock()
+
+	receipt, ok := s.receipts[txnID]
+	if !ok {
+		return permissioned.TxnReceipt{}, false
+	}
+	return *receipt, true
+}
+
 // -----------------------------------------------------------------------------
 // WatchRegistry
 
@@ -221,12 +655,15 @@ type watchCallbck func(config *permissioned.ChainConfig,
 type WatchRegistry struct {
 	*sync.RWMutex
 	store map[permissioned.TxnType]watchCallbck
+	// callbacks for the txns leaving the canonical chain
+	reverts map[permissioned.TxnType]watchCallbck
 }
 
 func NewWatchRegistry() *WatchRegistry {
 	r := WatchRegistry{
 		RWMutex: &sync.RWMutex{},
 		store:   map[permissioned.TxnType]watchCallbck{},
+		reverts: map[permissioned.TxnType]watchCallbck{},
 	}
 	return &r
 }
@@ -239,6 +676,14 @@ func (r *WatchRegistry) Register(txnType permissioned.TxnType,
 	r.store[txnType] = watcher
 }
 
+func (r *WatchRegistry) RegisterRevert(txnType permissioned.TxnType,
+	watcher watchCallbck) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.reverts[txnType] = watcher
+}
+
 func (r *WatchRegistry) Tell(config *permissioned.ChainConfig, txn *permissioned.Transaction) error {
 	r.RLock()
 	defer r.RUnlock()
@@ -251,14 +696,38 @@ func (r *WatchRegistry) Tell(config *permissioned.ChainConfig, txn *permissioned
 	return watcher(config, txn)
 }
 
+func (r *WatchRegistry) TellRevert(config *permissioned.ChainConfig, txn *permissioned.Transaction) error {
+	r.RLock()
+	defer r.RUnlock()
+
+	watcher, ok := r.reverts[txn.Type]
+	if !ok {
+		return nil
+	}
+
+	return watcher(config, txn)
+}
+
 // -----------------------------------------------------------------------------
 // Wallet
 
+var STORE_WALLET_PREFIX = "Wallet|"
+
+// walletRecord is the stored form of the pending state of a wallet
+type walletRecord struct {
+	Nonce   uint
+	Pending []permissioned.SignedTransaction
+}
+
 type Wallet struct {
 	*sync.RWMutex
 	account *permissioned.Account
 	privKey *ecdsa.PrivateKey
 	addr    *permissioned.Address
+	// signed txns not included yet, by nonce
+	pending map[uint]*permissioned.SignedTransaction
+	// nil if the wallet is not persisted
+	store storage.Store
 }
 
 func NewWallet(privkey *ecdsa.PrivateKey) *Wallet {
@@ -269,10 +738,35 @@ func NewWallet(privkey *ecdsa.PrivateKey) *Wallet {
 		privKey: privkey,
 		account: account,
 		addr:    address,
+		pending: map[uint]*permissioned.SignedTransaction{},
 	}
 	return &r
 }
 
+// NewWalletWithStore creates a wallet persisting its nonce and pending txns
+// in the store, and restores them if the node was restarted
+func NewWalletWithStore(privkey *ecdsa.PrivateKey, store storage.Store) (*Wallet, error) {
+	w := NewWallet(privkey)
+	w.store = store
+
+	buf := store.Get(STORE_WALLET_PREFIX + w.addr.Hex)
+	if buf == nil {
+		return w, nil
+	}
+	var record walletRecord
+	err := json.Unmarshal(buf, &record)
+	if err != nil {
+		return w, err
+	}
+	for i := range record.Pending {
+		signedTxn := record.Pending[i]
+		w.pending[signedTxn.Txn.Nonce] = &signedTxn
+	}
+	w.account.SetNonce(record.Nonce)
+
+	return w, nil
+}
+
 func (w *Wallet) GetAddress() permissioned.Address {
 	// Assume addr never change
 	if w.addr == nil {
@@ -281,22 +775,87 @@ func (w *Wallet) GetAddress() permissioned.Address {
 	return *w.addr
 }
 
-func (w *Wallet) Sync(worldState storage.KVStore) {
-	// w.Lock()
-	// defer w.Unlock()
+// GetNonce returns the nonce of the next signed txn
+func (w *Wallet) GetNonce() uint {
+	w.RLock()
+	defer w.RUnlock()
+
+	return w.account.GetNonce()
+}
+
+// GetPending returns the signed txns not included yet, ordered by nonce
+func (w *Wallet) GetPending() []*permissioned.SignedTransaction {
+	w.RLock()
+	defer w.RUnlock()
+
+	return w.sortedPending()
+}
+
+// Sync reconciles the wallet with the world state of a block of the
+// canonical chain. The nonce never decreases. Included txns are forgotten.
+// A txn whose nonce was used by another txn, e.g. signed by another node
+// sharing the key, is re-signed with a fresh nonce. The nonce of a
+// rejected txn is filled with a cancel txn so that the following txns stay
+// valid: re-signing them could execute them twice. It returns the txns to send
+func (w *Wallet) Sync(worldState storage.KVStore, receipts *ReceiptStore) []*permissioned.SignedTransaction {
+	w.Lock()
+	defer w.Unlock()
+
+	account := permissioned.GetAccountFromWorldState(worldState, w.addr.Hex)
+	chainNonce := account.GetNonce()
+	if w.account.GetNonce() < chainNonce {
+		w.account.SetNonce(chainNonce)
+	}
+
+	toSend := make([]*permissioned.SignedTransaction, 0)
+	lost := make([]*permissioned.SignedTransaction, 0)
+	for _, signedTxn := range w.sortedPending() {
+		nonce := signedTxn.Txn.Nonce
+		receipt, _ := receipts.Get(signedTxn.Txn.ID)
+
+		if nonce < chainNonce {
+			delete(w.pending, nonce)
+			// a lost cancel txn has nothing to redo
+			if receipt.Status == permissioned.TxnStatusPending &&
+				signedTxn.Txn.Type != permissioned.TxnTypeCancel {
+				lost = append(lost, signedTxn)
+			}
+			continue
+		}
+		if receipt.Status != permissioned.TxnStatusRejected {
+			continue
+		}
 
-	// account := permissioned.GetAccountFromWorldState(worldState, w.addr.Hex)
+		cancel, err := permissioned.NewTransactionCancel(w.account, nonce, 0).Sign(w.privKey)
+		if err != nil {
+			log.Err(err).Msgf("failed to fill nonce %d", nonce)
+			continue
+		}
+		w.pending[nonce] = cancel
+		toSend = append(toSend, cancel)
+	}
 
-	// if w.account.GetAddress().Hex != account.GetAddress().Hex {
-	// 	return
-	// }
+	for _, signedTxn := range lost {
+		txn := signedTxn.Txn
+		txn.Nonce = w.account.GetNonce()
+		txn.ID = txn.Hash()
+		resigned, err := txn.Sign(w.privKey)
+		if err != nil {
+			log.Err(err).Msgf("failed to re-sign txn %s", signedTxn.Txn.ID)
+			continue
+		}
+		receipts.Reject(signedTxn.Txn.ID, fmt.Errorf("nonce %d used by another txn. Re-signed as %s",
+			signedTxn.Txn.Nonce, resigned.Txn.ID))
+		w.addPending(resigned)
+		toSend = append(toSend, resigned)
+	}
 
-	// if w.account.GetNonce() != account.GetNonce() {
-	// 	w.account = account
-	// }
+	w.persist()
+	return toSend
 }
 
-func (w *Wallet) PreMPCTxn(expression string, budget float64, prime string) (*permissioned.SignedTransaction, error) {
+func (w *Wallet) PreMPCTxn(expression string, budget permissioned.Amount, prime string,
+	fee permissioned.Amount) (*permissioned.SignedTransaction, error) {
 	w.Lock()
 	defer w.Unlock()
 
@@ -305,13 +864,14 @@ func (w *Wallet) PreMPCTxn(expression string, budget float64, prime string) (*pe
 		Budget:     budget,
 		Expression: expression,
 		Prime:      prime,
+		Fee:        fee,
 	}
 	txn := permissioned.NewTransactionPreMPC(w.account, propose)
 	signedTxn, err := txn.Sign(w.privKey)
 	if err != nil {
 		return nil, err
 	}
-	w.account.IncreaseNonce()
+	w.addPending(signedTxn)
 
 	return signedTxn, err
 }
@@ -331,21 +891,77 @@ func (w *Wallet) PostMPCTxn(id string, result float64) (*permissioned.SignedTran
 	if err != nil {
 		return nil, err
 	}
-	w.account.IncreaseNonce()
+	w.addPending(signedTxn)
 
 	return signedTxn, err
 }
 
-func (w *Wallet) RegAssets(assets map[string]float64) (*permissioned.SignedTransaction, error) {
+func (w *Wallet) RegAssets(registration permissioned.AssetsRegistration) (*permissioned.SignedTransaction, error) {
 	w.Lock()
 	defer w.Unlock()
 
-	txn := permissioned.NewTransactionRegAssets(w.account, assets)
+	txn := permissioned.NewTransactionRegAssetsWithDetails(w.account, registration)
 	signedTxn, err := txn.Sign(w.privKey)
 	if err != nil {
 		return nil, err
 	}
-	w.account.IncreaseNonce()
+	w.addPending(signedTxn)
+
+	return signedTxn, err
+}
+
+func (w *Wallet) UpdateAssetsTxn(prices map[string]permissioned.Amount) (*permissioned.SignedTransaction, error) {
+	w.Lock()
+	defer w.Unlock()
+
+	txn := permissioned.NewTransactionUpdateAssets(w.account, prices)
+	signedTxn, err := txn.Sign(w.privKey)
+	if err != nil {
+		return nil, err
+	}
+	w.addPending(signedTxn)
+
+	return signedTxn, err
+}
+
+func (w *Wallet) RemoveAssetsTxn(keys []string) (*permissioned.SignedTransaction, error) {
+	w.Lock()
+	defer w.Unlock()
+
+	txn := permissioned.NewTransactionRemoveAssets(w.account, keys)
+	signedTxn, err := txn.Sign(w.privKey)
+	if err != nil {
+		return nil, err
+	}
+	w.addPending(signedTxn)
+
+	return signedTxn, err
+}
+
+func (w *Wallet) TransferAssetTxn(transfer permissioned.AssetTransfer) (*permissioned.SignedTransaction, error) {
+	w.Lock()
+	defer w.Unlock()
+
+	txn := permissioned.NewTransactionTransferAsset(w.account, transfer)
+	signedTxn, err := txn.Sign(w.privKey)
+	if err != nil {
+		return nil, err
+	}
+	w.addPending(signedTxn)
+
+	return signedTxn, err
+}
+
+func (w *Wallet) RegPricing(schedules map[string]permissioned.PriceSchedule) (*permissioned.SignedTransaction, error) {
+	w.Lock()
+	defer w.Unlock()
+
+	txn := permissioned.NewTransactionRegPricing(w.account, schedules)
+	signedTxn, err := txn.Sign(w.privKey)
+	if err != nil {
+		return nil, err
+	}
+	w.addPending(signedTxn)
 
 	return signedTxn, err
 }
@@ -359,7 +975,205 @@ func (w *Wallet) RegEnckeyTxn(pubkey string) (*permissioned.SignedTransaction, e
 	if err != nil {
 		return nil, err
 	}
-	w.account.IncreaseNonce()
+	w.addPending(signedTxn)
+
+	return signedTxn, err
+}
+
+func (w *Wallet) StakeTxn(amount permissioned.Amount) (*permissioned.SignedTransaction, error) {
+	w.Lock()
+	defer w.Unlock()
+
+	txn := permissioned.NewTransactionStake(w.account, amount)
+	signedTxn, err := txn.Sign(w.privKey)
+	if err != nil {
+		return nil, err
+	}
+	w.addPending(signedTxn)
+
+	return signedTxn, err
+}
+
+func (w *Wallet) UnstakeTxn(amount permissioned.Amount) (*permissioned.SignedTransaction, error) {
+	w.Lock()
+	defer w.Unlock()
+
+	txn := permissioned.NewTransactionUnstake(w.account, amount)
+	signedTxn, err := txn.Sign(w.privKey)
+	if err != nil {
+		return nil, err
+	}
+	w.addPending(signedTxn)
+
+	return signedTxn, err
+}
+
+func (w *Wallet) SlashTxn(evidence permissioned.SlashEvidence) (*permissioned.SignedTransaction, error) {
+	w.Lock()
+	defer w.Unlock()
+
+	txn := permissioned.NewTransactionSlash(w.account, evidence)
+	signedTxn, err := txn.Sign(w.privKey)
+	if err != nil {
+		return nil, err
+	}
+	w.addPending(signedTxn)
+
+	return signedTxn, err
+}
+
+// BumpTxn signs a copy of a pending transaction with a higher tip. The
+// nonce is reused so that the copy replaces the pending transaction
+func (w *Wallet) BumpTxn(txn *permissioned.Transaction, tip permissioned.Amount) (*permissioned.SignedTransaction, error) {
+	w.Lock()
+	defer w.Unlock()
+
+	signedTxn, err := txn.Bump(tip).Sign(w.privKey)
+	if err != nil {
+		return nil, err
+	}
+	w.replacePending(signedTxn)
+
+	return signedTxn, nil
+}
+
+// CancelTxn signs a transaction doing nothing with the nonce of a pending
+// transaction, so that it replaces it if the tip is higher
+func (w *Wallet) CancelTxn(nonce uint, tip permissioned.Amount) (*permissioned.SignedTransaction, error) {
+	w.Lock()
+	defer w.Unlock()
+
+	txn := permissioned.NewTransactionCancel(w.account, nonce, tip)
+	signedTxn, err := txn.Sign(w.privKey)
+	if err != nil {
+		return nil, err
+	}
+	w.replacePending(signedTxn)
+
+	return signedTxn, nil
+}
+
+// RegMultisigTxn creates a multisig account. The address of the account is
+// the To of the transaction
+func (w *Wallet) RegMultisigTxn(policy permissioned.MultisigPolicy,
+	deposit permissioned.Amount) (*permissioned.SignedTransaction, error) {
+	w.Lock()
+	defer w.Unlock()
+
+	txn := permissioned.NewTransactionRegMultisig(w.account, policy, deposit)
+	signedTxn, err := txn.Sign(w.privKey)
+	if err != nil {
+		return nil, err
+	}
+	w.addPending(signedTxn)
+
+	return signedTxn, err
+}
+
+// Cosign adds the signature of the wallet to a transaction of a multisig
+// account it owns
+func (w *Wallet) Cosign(signedTxn *permissioned.SignedTransaction) error {
+	w.RLock()
+	defer w.RUnlock()
+
+	return signedTxn.Cosign(w.privKey)
+}
+
+// RotateKeyTxn moves the account of the wallet to the address of the new
+// key. The wallet must be replaced once the transaction is included
+func (w *Wallet) RotateKeyTxn(newKey *ecdsa.PrivateKey) (*permissioned.SignedTransaction, error) {
+	w.Lock()
+	defer w.Unlock()
+
+	txn, err := permissioned.NewTransactionRotateKey(w.account, newKey)
+	if err != nil {
+		return nil, err
+	}
+	signedTxn, err := txn.Sign(w.privKey)
+	if err != nil {
+		return nil, err
+	}
+	w.addPending(signedTxn)
 
 	return signedTxn, err
 }
+
+func (w *Wallet) RevokeKeyTxn(revocation permissioned.KeyRevocation) (*permissioned.SignedTransaction, error) {
+	w.Lock()
+	defer w.Unlock()
+
+	txn := permissioned.NewTransactionRevokeKey(w.account, revocation)
+	signedTxn, err := txn.Sign(w.privKey)
+	if err != nil {
+		return nil, err
+	}
+	w.addPending(signedTxn)
+
+	return signedTxn, err
+}
+
+func (w *Wallet) SignBlock(block *permissioned.Block) error {
+	w.RLock()
+	defer w.RUnlock()
+
+	return block.Sign(w.privKey)
+}
+
+func (w *Wallet) SignBlockVote(block *permissioned.Block) (*permissioned.BlockVote, error) {
+	w.RLock()
+	defer w.RUnlock()
+
+	return permissioned.SignBlockVote(w.privKey, block)
+}
+
+func (w *Wallet) SignMPCShare(uniqID string, key string, value string) (*permissioned.MPCShareProof, error) {
+	w.RLock()
+	defer w.RUnlock()
+
+	return permissioned.SignMPCShare(w.privKey, uniqID, key, value)
+}
+
+// addPending records a freshly signed txn and moves to the next nonce
+func (w *Wallet) addPending(signedTxn *permissioned.SignedTransaction) {
+	w.pending[signedTxn.Txn.Nonce] = signedTxn
+	w.account.IncreaseNonce()
+	w.persist()
+}
+
+// replacePending records a txn replacing the pending one with its nonce
+func (w *Wallet) replacePending(signedTxn *permissioned.SignedTransaction) {
+	w.pending[signedTxn.Txn.Nonce] = signedTxn
+	w.persist()
+}
+
+func (w *Wallet) sortedPending() []*permissioned.SignedTransaction {
+	pending := make([]*permissioned.SignedTransaction, 0, len(w.pending))
+	for _, signedTxn := range w.pending {
+		pending = append(pending, signedTxn)
+	}
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].Txn.Nonce < pending[j].Txn.Nonce
+	})
+	return pending
+}
+
+// persist writes the nonce and the pending txns if the wallet has a store
+func (w *Wallet) persist() {
+	if w.store == nil {
+		return
+	}
+
+	record := walletRecord{
+		Nonce:   w.account.GetNonce(),
+		Pending: make([]permissioned.SignedTransaction, 0, len(w.pending)),
+	}
+	for _, signedTxn := range w.sortedPending() {
+		record.Pending = append(record.Pending, *signedTxn)
+	}
+	buf, err := json.Marshal(record)
+	if err != nil {
+		log.Err(err).Msgf("failed to persist wallet %s", w.addr.Hex)
+		return
+	}
+	w.store.Set(STORE_WALLET_PREFIX+w.addr.Hex, buf)
+}
@@ -0,0 +1,283 @@
+package blockchain
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/require"
+	permissioned "go.dedis.ch/cs438/permissioned-chain"
+	"go.dedis.ch/cs438/storage"
+	"go.dedis.ch/cs438/storage/inmemory"
+)
+
+func Test_BC_Watch_Registry_Revert(t *testing.T) {
+	registry := NewWatchRegistry()
+
+	reverted := make([]string, 0)
+	registry.RegisterRevert("type", func(config *permissioned.ChainConfig,
+		txn *permissioned.Transaction) error {
+		reverted = append(reverted, txn.ID)
+		return nil
+	})
+
+	err := registry.TellRevert(nil, &permissioned.Transaction{ID: "1", Type: "type"})
+	require.NoError(t, err)
+	err = registry.TellRevert(nil, &permissioned.Transaction{ID: "2", Type: "other"})
+	require.NoError(t, err)
+	err = registry.Tell(nil, &permissioned.Transaction{ID: "3", Type: "type"})
+	require.NoError(t, err)
+
+	require.Equal(t, []string{"1"}, reverted)
+}
+
+func Test_BC_Receipt_Store(t *testing.T) {
+	store := NewReceiptStore()
+
+	txn := &permissioned.Transaction{ID: "1", Type: permissioned.TxnTypeStake}
+	store.Track(txn)
+	store.Include("2", "hash", 1, 0)
+
+	receipt, ok := store.Get("1")
+	require.True(t, ok)
+	require.Equal(t, permissioned.TxnStatusPending, receipt.Status)
+	require.Equal(t, permissioned.TxnTypeStake, receipt.Type)
+	_, ok = store.Get("2")
+	require.False(t, ok)
+
+	// > rejected while pending
+	store.Reject("1", fmt.Errorf("insufficient balance"))
+	receipt, _ = store.Get("1")
+	require.Equal(t, permissioned.TxnStatusRejected, receipt.Status)
+	require.Equal(t, "insufficient balance", receipt.Error)
+
+	// > included by another miner wins over the rejection
+	store.Include("1", "hash", 3, 2)
+	receipt, _ = store.Get("1")
+	require.Equal(t, permissioned.TxnStatusIncluded, receipt.Status)
+	require.Empty(t, receipt.Error)
+	require.Equal(t, "hash", receipt.BlockHash)
+	require.Equal(t, uint(3), receipt.Height)
+	require.Equal(t, 2, receipt.Index)
+
+	// > an included txn is not rejected anymore
+	store.Reject("1", fmt.Errorf("nonce too low"))
+	receipt, _ = store.Get("1")
+	require.Equal(t, permissioned.TxnStatusIncluded, receipt.Status)
+
+	// > reverted to pending on reorganisation
+	store.Revert("1")
+	receipt, _ = store.Get("1")
+	require.Equal(t, permissioned.TxnStatusPending, receipt.Status)
+	require.Empty(t, receipt.BlockHash)
+}
+
+func newPoolTxn(from string, nonce uint, tip permissioned.Amount) *permissioned.SignedTransaction {
+	txn := permissioned.Transaction{
+		Nonce: nonce,
+		From:  from,
+		Type:  permissioned.TxnTypeStake,
+		Value: 1,
+		Tip:   tip,
+	}
+	txn.ID = txn.Hash()
+	return &permissioned.SignedTransaction{Txn: txn}
+}
+
+func Test_BC_Txn_Pool_Priority(t *testing.T) {
+	pool := NewTxnPool(NewReceiptStore())
+	nonces := map[string]uint{"a": 0, "b": 0}
+	nonce := func(addr string) uint { return nonces[addr] }
+
+	a0, a1 := newPoolTxn("a", 0, 1), newPoolTxn("a", 1, 5)
+	b0, b2 := newPoolTxn("b", 0, 2), newPoolTxn("b", 2, 10)
+	for _, txn := range []*permissioned.SignedTransaction{a1, a0, b2, b0} {
+		require.NoError(t, pool.Push(txn))
+	}
+	require.Equal(t, 4, pool.Len())
+
+	// > ready txns by tip, following the nonces
+	require.Equal(t, b0.Txn.ID, pool.Pop(nonce).Txn.ID)
+	nonces["b"]++
+	require.Equal(t, a0.Txn.ID, pool.Pop(nonce).Txn.ID)
+	nonces["a"]++
+	require.Equal(t, a1.Txn.ID, pool.Pop(nonce).Txn.ID)
+	nonces["a"]++
+
+	// > b has a nonce gap: nothing is ready
+	require.Nil(t, pool.Pop(nonce))
+	require.Equal(t, 1, pool.Len())
+
+	// > stale txns are dropped
+	nonces["b"] = 3
+	require.Nil(t, pool.Pop(nonce))
+	require.Equal(t, 0, pool.Len())
+}
+
+func Test_BC_Txn_Pool_Replace(t *testing.T) {
+	receipts := NewReceiptStore()
+	pool := NewTxnPool(receipts)
+	nonce := func(addr string) uint { return 0 }
+
+	txn := newPoolTxn("a", 0, 2)
+	receipts.Track(&txn.Txn)
+	require.NoError(t, pool.Push(txn))
+	require.NoError(t, pool.Push(txn))
+	require.Equal(t, 1, pool.Len())
+
+	// > a lower or equal tip does not replace
+	underpriced, err := txn.Txn.Bump(1).Sign(nil)
+	require.NoError(t, err)
+	require.ErrorIs(t, pool.Push(underpriced), ErrTxnUnderpriced)
+
+	// > a higher tip replaces
+	bumped, err := txn.Txn.Bump(3).Sign(nil)
+	require.NoError(t, err)
+	require.NoError(t, pool.Push(bumped))
+	require.Equal(t, 1, pool.Len())
+	require.Equal(t, bumped.Txn.ID, pool.Pop(nonce).Txn.ID)
+
+	receipt, _ := receipts.Get(txn.Txn.ID)
+	require.Equal(t, permissioned.TxnStatusRejected, receipt.Status)
+	require.Contains(t, receipt.Error, bumped.Txn.ID)
+}
+
+func Test_BC_Txn_Pool_Eviction(t *testing.T) {
+	defer func(size int) { TXN_POOL_MAX_SIZE = size }(TXN_POOL_MAX_SIZE)
+	TXN_POOL_MAX_SIZE = 3
+
+	receipts := NewReceiptStore()
+	pool := NewTxnPool(receipts)
+
+	a0, a1, b0 := newPoolTxn("a", 0, 1), newPoolTxn("a", 1, 1), newPoolTxn("b", 0, 3)
+	for _, txn := range []*permissioned.SignedTransaction{a0, a1, b0} {
+		receipts.Track(&txn.Txn)
+		require.NoError(t, pool.Push(txn))
+	}
+
+	// > not better than the lowest tip
+	require.ErrorIs(t, pool.Push(newPoolTxn("c", 0, 1)), ErrTxnPoolFull)
+
+	// > the last txn of the account with the lowest tip is evicted
+	require.NoError(t, pool.Push(newPoolTxn("c", 0, 2)))
+	require.Equal(t, 3, pool.Len())
+
+	receipt, _ := receipts.Get(a1.Txn.ID)
+	require.Equal(t, permissioned.TxnStatusRejected, receipt.Status)
+	receipt, _ = receipts.Get(a0.Txn.ID)
+	require.Equal(t, permissioned.TxnStatusPending, receipt.Status)
+}
+
+func Test_BC_Txn_Pool_Expire(t *testing.T) {
+	receipts := NewReceiptStore()
+	pool := NewTxnPool(receipts)
+
+	txn := newPoolTxn("a", 1, 0)
+	receipts.Track(&txn.Txn)
+	require.NoError(t, pool.Push(txn))
+
+	pool.Expire(time.Now())
+	require.Equal(t, 1, pool.Len())
+
+	pool.Expire(time.Now().Add(TXN_POOL_TTL + time.Second))
+	require.Equal(t, 0, pool.Len())
+	receipt, _ := receipts.Get(txn.Txn.ID)
+	require.Equal(t, permissioned.TxnStatusRejected, receipt.Status)
+	require.Equal(t, ErrTxnExpired.Error(), receipt.Error)
+}
+
+func Test_BC_Wallet_Sync(t *testing.T) {
+	privKey, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	receipts := NewReceiptStore()
+	wallet := NewWallet(privKey)
+	addr := wallet.GetAddress().Hex
+
+	send := func(signedTxn *permissioned.SignedTransaction, err error) *permissioned.SignedTransaction {
+		require.NoError(t, err)
+		receipts.Track(&signedTxn.Txn)
+		return signedTxn
+	}
+	stateWithNonce := func(nonce uint) storage.KVStore {
+		account := permissioned.NewAccount(wallet.GetAddress())
+		account.SetNonce(nonce)
+		worldState := storage.NewBasicKV()
+		worldState.Put(addr, *account)
+		return worldState
+	}
+
+	included := send(wallet.StakeTxn(1))
+	lost := send(wallet.StakeTxn(2))
+	rejected := send(wallet.StakeTxn(3))
+	next := send(wallet.StakeTxn(4))
+	require.Equal(t, uint(4), wallet.GetNonce())
+	require.Len(t, wallet.GetPending(), 4)
+
+	// > nothing to do while the txns are pending
+	require.Len(t, wallet.Sync(stateWithNonce(0), receipts), 0)
+	require.Len(t, wallet.GetPending(), 4)
+
+	// > the included txn is forgotten, the one whose nonce was used by
+	// another txn is re-signed and the rejected one is replaced by a cancel
+	receipts.Include(included.Txn.ID, "hash", 1, 0)
+	receipts.Reject(rejected.Txn.ID, fmt.Errorf("insufficient balance"))
+
+	toSend := wallet.Sync(stateWithNonce(2), receipts)
+	require.Len(t, toSend, 2)
+
+	cancel := toSend[0]
+	require.Equal(t, permissioned.TxnTypeCancel, cancel.Txn.Type)
+	require.Equal(t, rejected.Txn.Nonce, cancel.Txn.Nonce)
+
+	resigned := toSend[1]
+	require.Equal(t, permissioned.TxnTypeStake, resigned.Txn.Type)
+	require.Equal(t, lost.Txn.Value, resigned.Txn.Value)
+	require.Equal(t, uint(4), resigned.Txn.Nonce)
+	receipt, _ := receipts.Get(lost.Txn.ID)
+	require.Equal(t, permissioned.TxnStatusRejected, receipt.Status)
+	require.Contains(t, receipt.Error, resigned.Txn.ID)
+
+	pending := wallet.GetPending()
+	require.Len(t, pending, 3)
+	require.Equal(t, cancel.Txn.ID, pending[0].Txn.ID)
+	require.Equal(t, next.Txn.ID, pending[1].Txn.ID)
+	require.Equal(t, resigned.Txn.ID, pending[2].Txn.ID)
+	require.Equal(t, uint(5), wallet.GetNonce())
+
+	// > the nonce follows the chain, e.g. used by another node
+	receipts.Include(next.Txn.ID, "hash", 2, 0)
+	receipts.Include(resigned.Txn.ID, "hash", 3, 0)
+	require.Len(t, wallet.Sync(stateWithNonce(10), receipts), 0)
+	require.Len(t, wallet.GetPending(), 0)
+	require.Equal(t, uint(10), wallet.GetNonce())
+
+	// > the nonce never decreases
+	wallet.Sync(stateWithNonce(0), receipts)
+	require.Equal(t, uint(10), wallet.GetNonce())
+}
+
+func Test_BC_Wallet_Restore(t *testing.T) {
+	privKey, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	store := inmemory.NewPersistency().GetBlockchainStore()
+
+	wallet, err := NewWalletWithStore(privKey, store)
+	require.NoError(t, err)
+	_, err = wallet.StakeTxn(1)
+	require.NoError(t, err)
+	signedTxn, err := wallet.RegAssets(permissioned.AssetsRegistration{
+		Assets: map[string]permissioned.Amount{"key1": 1},
+	})
+	require.NoError(t, err)
+
+	// > a restarted wallet resumes its nonce and pending txns
+	restored, err := NewWalletWithStore(privKey, store)
+	require.NoError(t, err)
+	require.Equal(t, uint(2), restored.GetNonce())
+	pending := restored.GetPending()
+	require.Len(t, pending, 2)
+	require.Equal(t, signedTxn.Txn.ID, pending[1].Txn.ID)
+	require.Equal(t, signedTxn.Txn.Data.(permissioned.AssetsRegistration).Assets,
+		pending[1].Txn.Data.(permissioned.AssetsRegistration).Assets)
+}
@@ -49,11 +49,14 @@ func NewEncryptionModule(conf *peer.Configuration, messageModue *MessageModule)
 
 // GetPubkeyString returns a string to descript the pubkey
 func (m *EncryptionModule) GetPubkeyString() (string, error) {
-	pubBytes, err := x509.MarshalPKIXPublicKey((*rsa.PublicKey)(&m.privkey.PublicKey))
-	if err != nil {
-		return "", err
-	}
-	return hex.EncodeToString(pubBytes), nil
+	return PubkeyString(&m.privkey.PublicKey)
+}
+
+// SetPrivkey replaces the key used to decrypt messages, e.g. by the one
+// unlocked from a keystore
+func (m *EncryptionModule) SetPrivkey(privkey *types.Privkey) {
+	m.privkey = privkey
+	m.pubkeyStore.set(m.conf.Socket.GetAddress(), (*types.Pubkey)(&privkey.PublicKey))
 }
 
 // SendEncryptedMessage broadcast an encrypted message in private msg
@@ -158,6 +161,15 @@ func (m *EncryptionModule) GetPubkeyStore() peer.PubkeyStore {
 	return m.pubkeyStore.getAll()
 }
 
+// PubkeyString returns the string registered on chain for the pubkey
+func PubkeyString(pubkey *rsa.PublicKey) (string, error) {
+	pubBytes, err := x509.MarshalPKIXPublicKey(pubkey)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(pubBytes), nil
+}
+
 /** Private Helpfer Functions **/
 
 // generateKeyPair generates privkey-pubkey pair
@@ -154,6 +154,11 @@ func (t *PubkeyController) add(peer string, pubkey *types.Pubkey) {
 		t.table[peer] = *pubkey
 	}
 }
+func (t *PubkeyController) set(peer string, pubkey *types.Pubkey) {
+	t.Lock()
+	defer t.Unlock()
+	t.table[peer] = *pubkey
+}
 func (t *PubkeyController) remove(key string) {
 	t.Lock()
 	defer t.Unlock()
@@ -200,30 +200,86 @@ func (n *node) ComputeExpression(uniqID string, expr string, prime string) (int,
 }
 
 // GetPubkeyStore implements peer.SetValueDBAsset
-func (n *node) SetValueDBAsset(key string, value int, price float64) error {
+func (n *node) SetValueDBAsset(key string, value int, price permissioned.Amount) error {
 	return n.mpc.SetValueDBAsset(key, value, price)
 }
 
+// SetValueDBAssetWithSchema implements peer.SetValueDBAssetWithSchema
+func (n *node) SetValueDBAssetWithSchema(key string, value int, price permissioned.Amount,
+	schema *permissioned.AssetSchema) error {
+	return n.mpc.SetValueDBAssetWithSchema(key, value, price, schema)
+}
+
 // ShowAllPeerAssets implements peer.ShowAllPeerAssets
-func (n *node) GetAllPeerAssetPrices() map[string]map[string]float64 {
+func (n *node) GetAllPeerAssetPrices() map[string]map[string]permissioned.Amount {
 	return n.mpc.GetPeerAssetPrices()
 }
 
 // Calculate implements peer.Calculate
-func (n *node) Calculate(expression string, budget float64) (int, error) {
+func (n *node) Calculate(expression string, budget permissioned.Amount) (int, error) {
 	return n.mpc.Calculate(expression, budget)
 }
 
+// CalculateWithFee implements peer.CalculateWithFee
+func (n *node) CalculateWithFee(expression string, budget permissioned.Amount, fee permissioned.Amount) (int, error) {
+	return n.mpc.CalculateWithFee(expression, budget, fee)
+}
+
+// UpdateAssetPrice implements peer.UpdateAssetPrice
+func (n *node) UpdateAssetPrice(key string, price permissioned.Amount) error {
+	return n.mpc.UpdateAssetPrice(key, price)
+}
+
+// RemoveValueDBAsset implements peer.RemoveValueDBAsset
+func (n *node) RemoveValueDBAsset(key string) error {
+	return n.mpc.RemoveValueDBAsset(key)
+}
+
+// TransferAsset implements peer.TransferAsset
+func (n *node) TransferAsset(key string, to string) error {
+	return n.mpc.TransferAsset(key, to)
+}
+
+// HoldValueDBAsset implements peer.HoldValueDBAsset
+func (n *node) HoldValueDBAsset(key string, value int) error {
+	return n.mpc.HoldValueDBAsset(key, value)
+}
+
+// NewAssetTransfer implements peer.NewAssetTransfer
+func (n *node) NewAssetTransfer(key string, to string) (permissioned.AssetTransfer, error) {
+	return n.mpc.NewAssetTransfer(key, to)
+}
+
+// SetAssetPolicy implements peer.SetAssetPolicy
+func (n *node) SetAssetPolicy(key string, policy permissioned.AssetPolicy) error {
+	return n.mpc.SetAssetPolicy(key, policy)
+}
+
+// SetAssetPricing implements peer.SetAssetPricing
+func (n *node) SetAssetPricing(key string, schedule permissioned.PriceSchedule) error {
+	return n.mpc.SetAssetPricing(key, schedule)
+}
+
 // InitBlockchain implements peer.InitBlockchain
-func (n *node) InitBlockchain(config permissioned.ChainConfig, initialGain map[string]float64) error {
+func (n *node) InitBlockchain(config permissioned.ChainConfig, initialGain map[string]permissioned.Amount) error {
 	return n.blockchain.InitBlockchain(config, initialGain)
 }
 
+// InitBlockchainFromGenesis implements peer.InitBlockchainFromGenesis
+func (n *node) InitBlockchainFromGenesis(genesis *permissioned.Genesis) error {
+	return n.blockchain.InitBlockchainFromGenesis(genesis)
+}
+
 // BCWaitBlock implements peer.BCWaitBlock
 func (n *node) BCWaitBlock() *permissioned.Block {
 	return n.blockchain.WaitBlock()
 }
 
+// BCSync implements peer.BCSync
+func (n *node) BCSync() error {
+	return n.blockchain.Sync()
+}
+
 // BCSendTransaction implements peer.BCSendTransaction
 func (n *node) BCSendTransaction(txn *permissioned.SignedTransaction) error {
 	return n.blockchain.SendTransaction(txn)
@@ -231,22 +287,42 @@ func (n *node) BCSendTransaction(txn *permissioned.SignedTransaction) error {
 
 // BCHasTransaction implements peer.BCHasTransaction
 func (n *node) BCHasTransaction(txnID string) bool {
-	return n.blockchain.GetTxn(txnID) != nil
+	return n.blockchain.GetChainTxn(txnID) != nil
 }
 
 // BCGetTransaction implements peer.BCGetTransaction
 func (n *node) BCGetTransaction(txnID string) *permissioned.SignedTransaction {
-	return n.blockchain.GetTxn(txnID)
+	return n.blockchain.GetChainTxn(txnID)
+}
+
+// BCGetReceipt implements peer.BCGetReceipt
+func (n *node) BCGetReceipt(txnID string) (*permissioned.TxnReceipt, error) {
+	return n.blockchain.GetReceipt(txnID)
+}
+
+// BCBumpTransaction implements peer.BCBumpTransaction
+func (n *node) BCBumpTransaction(txnID string, tip permissioned.Amount) (string, error) {
+	return n.blockchain.BumpTransaction(txnID, tip)
+}
+
+// BCCancelTransaction implements peer.BCCancelTransaction
+func (n *node) BCCancelTransaction(txnID string, tip permissioned.Amount) (string, error) {
+	return n.blockchain.CancelTransaction(txnID, tip)
 }
 
 // BCGetLatestBlock implements peer.BCGetLatestBlock
 func (n *node) BCGetLatestBlock() *permissioned.Block {
-	return n.blockchain.GetLatestBlock()
+	return n.blockchain.GetChainLatestBlock()
+}
+
+// BCGetFinalBlock implements peer.BCGetFinalBlock
+func (n *node) BCGetFinalBlock() *permissioned.Block {
+	return n.blockchain.GetChainFinalBlock()
 }
 
 // BCGetBlock implements peer.BCGetBlock
 func (n *node) BCGetBlock(blockID string) *permissioned.Block {
-	return n.blockchain.GetBlock(blockID)
+	return n.blockchain.GetChainBlock(blockID)
 }
 
 // BCGetAddress implements peer.BCGetAddress
@@ -255,10 +331,76 @@ func (n *node) BCGetAddress() (permissioned.Address, error) {
 }
 
 // BCGetBalance implements peer.BCGetBalance
-func (n *node) BCGetBalance() float64 {
+func (n *node) BCGetBalance() permissioned.Amount {
 	return n.blockchain.GetAccountBalance()
 }
 
+// BCStake implements peer.BCStake
+func (n *node) BCStake(amount permissioned.Amount) (string, error) {
+	return n.blockchain.SendStakeTransaction(amount)
+}
+
+// BCRegMultisig implements peer.BCRegMultisig
+func (n *node) BCRegMultisig(policy permissioned.MultisigPolicy,
+	deposit permissioned.Amount) (permissioned.Address, error) {
+	return n.blockchain.SendRegMultisigTransaction(policy, deposit)
+}
+
+// BCCosignTransaction implements peer.BCCosignTransaction
+func (n *node) BCCosignTransaction(txn *permissioned.SignedTransaction) error {
+	return n.blockchain.CosignTransaction(txn)
+}
+
+// BCRotateKey implements peer.BCRotateKey
+func (n *node) BCRotateKey(newKey ecdsa.PrivateKey) (string, error) {
+	return n.blockchain.RotateKey(newKey)
+}
+
+// BCRevokeKey implements peer.BCRevokeKey
+func (n *node) BCRevokeKey(revocation permissioned.KeyRevocation) (string, error) {
+	return n.blockchain.SendRevokeKeyTransaction(revocation)
+}
+
+// BCUnstake implements peer.BCUnstake
+func (n *node) BCUnstake(amount permissioned.Amount) (string, error) {
+	return n.blockchain.SendUnstakeTransaction(amount)
+}
+
+// BCGetStake implements peer.BCGetStake
+func (n *node) BCGetStake() permissioned.Amount {
+	return n.blockchain.GetAccountStake()
+}
+
+// BCSearchAssets implements peer.BCSearchAssets
+func (n *node) BCSearchAssets(query string) []permissioned.AssetInfo {
+	return n.blockchain.SearchAssets(query)
+}
+
+// BCGetTxnProof implements peer.BCGetTxnProof
+func (n *node) BCGetTxnProof(txnID string) (*permissioned.TxnProof, error) {
+	return n.blockchain.GetChainTxnProof(txnID)
+}
+
+// BCGetAccountProof implements peer.BCGetAccountProof
+func (n *node) BCGetAccountProof(addr string) (*permissioned.StateProof, error) {
+	return n.blockchain.GetChainStateProof(addr)
+}
+
+// BCGetAssetsProof implements peer.BCGetAssetsProof
+func (n *node) BCGetAssetsProof(owner string) (*permissioned.StateProof, error) {
+	return n.blockchain.GetChainStateProof(permissioned.AssetsKeyFromUniqID(owner))
+}
+
+// BCSubscribe implements peer.BCSubscribe
+func (n *node) BCSubscribe(filter permissioned.EventFilter) (string, <-chan permissioned.Event) {
+	return n.blockchain.Subscribe(filter)
+}
+
+// BCUnsubscribe implements peer.BCUnsubscribe
+func (n *node) BCUnsubscribe(id string) {
+	n.blockchain.Unsubscribe(id)
+}
+
 // BCGenerateKeyPair implements peer.BCGenerateKeyPair
 func (n *node) BCGenerateKeyPair(path string) error {
 	return n.blockchain.GenerateKeyPair(path)
@@ -274,6 +416,16 @@ func (n *node) BCLoadKeyPair(path string) error {
 	return n.blockchain.LoadKeyPair(path)
 }
 
+// BCGenerateKeystore implements peer.BCGenerateKeystore
+func (n *node) BCGenerateKeystore(path, passphrase string) error {
+	return n.blockchain.GenerateKeystore(path, passphrase)
+}
+
+// BCLoadKeystore implements peer.BCLoadKeystore
+func (n *node) BCLoadKeystore(path, passphrase string) error {
+	return n.blockchain.LoadKeystore(path, passphrase)
+}
+
 // BCAllEncryptKeySet implements peer.BCAllEncryptKeySet
 func (n *node) BCAllEncryptKeySet() bool {
 	return n.blockchain.AllEncryptKeySet()
@@ -283,3 +435,8 @@ func (n *node) BCAllEncryptKeySet() bool {
 func (n *node) BCSprintBlockchain() string {
 	return n.blockchain.SprintBlockchain()
 }
+
+// BCExportChain implements peer.BCExportChain
+func (n *node) BCExportChain(path string, snapshots bool) error {
+	return n.blockchain.ExportChain(path, snapshots)
+}
@@ -3,6 +3,7 @@ package mpc
 import (
 	"fmt"
 	"math/big"
+	"strconv"
 
 	"github.com/rs/zerolog/log"
 	"go.dedis.ch/cs438/permissioned-chain"
@@ -23,6 +24,16 @@ func (m *MPCModule) PreMPCTxnCallback(config *permissioned.ChainConfig, txn *per
 			permissioned.TxnTypePreMPC, txn.Type)
 	}
 
+	// only the MPC committee recorded on chain takes part in the MPC
+	endorsement, err := m.bcModule.GetMPCEndorsement(txn.ID)
+	if err != nil {
+		return err
+	}
+	if _, ok := endorsement.Peers[m.getIdentifyKey()]; !ok {
+		log.Info().Msgf("not in the committee of MPC %s. Skip", txn.ID)
+		return nil
+	}
+
 	if m.conf.DisableMPC {
 		mpc := NewMPC(txn.ID, big.Int{}, "", "")
 		m.mpcCenter.RegisterMPC(mpc.id, mpc)
@@ -44,7 +55,7 @@ func (m *MPCModule) PreMPCTxnCallback(config *permissioned.ChainConfig, txn *per
 	log.Info().Msgf("PreMPC Txn %s is confirmed. Start MPC {%s}...", txn.ID, propose.Expression)
 
 	// add addr -> pubkey map
-	err := m.pubkeyStore.Add(config.Participants)
+	err = m.pubkeyStore.Add(endorsement.Peers)
 	if err != nil {
 		err = m.mpcCenter.InformMPCComplete(txn.ID, MPCResult{result: 0, err: err})
 		return err
@@ -52,7 +63,7 @@ func (m *MPCModule) PreMPCTxnCallback(config *permissioned.ChainConfig, txn *per
 
 	// init MPC
 	// fmt.Printf("BENCHMARK, Time: %d. In function: InitMPC\n", time.Now().UnixNano())
-	err = m.initMPCWithBlockchain(txn.ID, config, &propose)
+	err = m.initMPCWithBlockchain(txn.ID, endorsement.Peers, &propose)
 	m.mpcCenter.InformMPCStart(txn.ID)
 	if err != nil {
 		err = m.mpcCenter.InformMPCComplete(txn.ID, MPCResult{result: 0, err: err})
@@ -80,3 +91,59 @@ func (m *MPCModule) PreMPCTxnCallback(config *permissioned.ChainConfig, txn *per
 
 	return nil
 }
+
+// RemoveAssetsTxnCallback deletes the removed assets from the ValueDB of the node
+// holding them. The chain rejects the removal while an MPC the holder has not
+// endorsed uses them
+func (m *MPCModule) RemoveAssetsTxnCallback(config *permissioned.ChainConfig, txn *permissioned.Transaction) error {
+	if txn.Type != permissioned.TxnTypeRemoveAssets {
+		return fmt.Errorf("invalid txn type. Expected: %s. Got: %s",
+			permissioned.TxnTypeRemoveAssets, txn.Type)
+	}
+	if m.bcModule.GetChainAssetsHolder(txn.From) != m.getIdentifyKey() {
+		return nil
+	}
+
+	removal := txn.Data.(permissioned.AssetsRemoval)
+	for _, key := range removal.Keys {
+		m.valueDB.removeAsset(key)
+		log.Info().Msgf("asset %s removed", key)
+	}
+	return nil
+}
+
+// TransferAssetTxnCallback moves the transferred asset from the ValueDB of the
+// node holding the values of the old owner to the ValueDB of the node holding
+// the values of the new owner. The chain rejects the transfer while an MPC the
+// old holder has not endorsed uses it
+func (m *MPCModule) TransferAssetTxnCallback(config *permissioned.ChainConfig, txn *permissioned.Transaction) error {
+	if txn.Type != permissioned.TxnTypeTransferAsset {
+		return fmt.Errorf("invalid txn type. Expected: %s. Got: %s",
+			permissioned.TxnTypeTransferAsset, txn.Type)
+	}
+
+	transfer := txn.Data.(permissioned.AssetTransfer)
+	fromHolder := m.bcModule.GetChainAssetsHolder(txn.From)
+	toHolder := m.bcModule.GetChainAssetsHolder(transfer.To)
+	if fromHolder == toHolder {
+		// the value stays on the same node
+		return nil
+	}
+	switch m.getIdentifyKey() {
+	case fromHolder:
+		m.valueDB.removeAsset(transfer.Key)
+		log.Info().Msgf("asset %s transferred to %s", transfer.Key, transfer.To)
+	case toHolder:
+		valueBytes, err := m.DecryptAsymetric(transfer.EncryptedValue)
+		if err != nil {
+			return fmt.Errorf("fail to decrypt transferred asset %s: %s", transfer.Key, err)
+		}
+		value, err := strconv.Atoi(string(valueBytes))
+		if err != nil {
+			return fmt.Errorf("invalid transferred asset %s: %s", transfer.Key, err)
+		}
+		m.valueDB.addAsset(transfer.Key, value)
+		log.Info().Msgf("asset %s received from %s", transfer.Key, txn.From)
+	}
+	return nil
+}
@@ -25,14 +25,16 @@ func newMPCModuleWithBlockchain(conf *peer.Configuration, messageModule *message
 	// register txn callback
 	bcModule.RegisterTxnCallabck(permissioned.TxnTypePreMPC, m.PreMPCTxnCallback)
 	bcModule.RegisterTxnCallabck(permissioned.TxnTypePostMPC, m.PostMPCTxnCallback)
+	bcModule.RegisterTxnCallabck(permissioned.TxnTypeRemoveAssets, m.RemoveAssetsTxnCallback)
+	bcModule.RegisterTxnCallabck(permissioned.TxnTypeTransferAsset, m.TransferAssetTxnCallback)
 
 	return m
 }
 
 // CalculateBlockchain sends a PreMPC txn to the blockchain
 // It will initiate a paxos to start the MPC once it notice the txn is included in the chain
-func (m *MPCModule) CalculateBlockchain(expression string, budget float64) (int, error) {
-	id, err := m.bcModule.SendPreMPCTransaction(expression, budget, "1000000009")
+func (m *MPCModule) CalculateBlockchain(expression string, budget permissioned.Amount, fee permissioned.Amount) (int, error) {
+	id, err := m.bcModule.SendPreMPCTransaction(expression, budget, "1000000009", fee)
 	if err != nil {
 		return 0, err
 	}
@@ -46,7 +48,7 @@ func (m *MPCModule) CalculateBlockchain(expression string, budget float64) (int,
 // -----------------------------------------------------------------------------
 // Private Helpfer Functions
 
-func (m *MPCModule) initMPCWithBlockchain(uniqID string, config *permissioned.ChainConfig,
+func (m *MPCModule) initMPCWithBlockchain(uniqID string, committee map[string]string,
 	propose *permissioned.MPCPropose) error {
 	mpcPrime, ok := new(big.Int).SetString(propose.Prime, 10)
 	if !ok {
@@ -54,9 +56,9 @@ func (m *MPCModule) initMPCWithBlockchain(uniqID string, config *permissioned.Ch
 	}
 	mpc := NewMPC(uniqID, *mpcPrime, propose.Initiator, propose.Expression)
 
-	// Use chain participants as MPC participants
-	participants := make([]string, 0, len(config.Participants))
-	for peer := range config.Participants {
+	// Use MPC committee as MPC participants
+	participants := make([]string, 0, len(committee))
+	for peer := range committee {
 		participants = append(participants, peer)
 	}
 	sort.Strings(participants)
@@ -101,14 +103,53 @@ func (m *MPCModule) boardcastInterpolationResultBlockchain(result big.Int, mpc *
 	return m.Broadcast(privMsgMarshal)
 }
 
+// reportInvalidShare sends a slash transaction if the share is signed by its
+// owner and is not a valid element of the MPC field
+func (m *MPCModule) reportInvalidShare(uniqID string, value types.MPCSecretValue) bool {
+	if m.consensusType != peer.MPCConsensusBC || len(value.Signature) == 0 {
+		return false
+	}
+	endorsement, err := m.bcModule.GetMPCEndorsement(uniqID)
+	if err != nil {
+		return false
+	}
+	if permissioned.IsValidShare(value.Value, endorsement.Prime) {
+		return false
+	}
+
+	txnID, err := m.bcModule.SendSlashTransaction(permissioned.SlashEvidence{
+		UniqID:   uniqID,
+		Offender: value.Owner,
+		Reason:   permissioned.SlashReasonInvalidShare,
+		Share: &permissioned.MPCShareProof{
+			UniqID:    uniqID,
+			Key:       value.Key,
+			Value:     value.Value,
+			Signature: value.Signature,
+		},
+	})
+	if err != nil {
+		log.Err(err).Send()
+	} else {
+		log.Info().Msgf("send slash txn %s against %s for MPC %s", txnID, value.Owner, uniqID)
+	}
+	return true
+}
+
 // sendShareMessagePaxos sends the share secret in encrypted message
 func (m *MPCModule) sendShareMessageBlockchain(uniqID string, peer string, id int, key string, value big.Int) error {
+	// sign the share so that the receiver can prove an invalid share on chain
+	proof, err := m.bcModule.SignMPCShare(uniqID, key, value.Text(10))
+	if err != nil {
+		return err
+	}
 	shareMsg := types.MPCShareMessage{
 		ReqID: uniqID,
 		Value: types.MPCSecretValue{
-			Owner: m.getIdentifyKey(),
-			Key:   key,
-			Value: value.Text(10),
+			Owner:     m.getIdentifyKey(),
+			Key:       key,
+			Value:     value.Text(10),
+			Signature: proof.Signature,
 		},
 	}
 	shareMsgMarshal, err := m.CreateMsg(shareMsg)
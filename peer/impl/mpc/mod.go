@@ -59,18 +59,42 @@ func newMPCModule(conf *peer.Configuration, messageModule *message.MessageModule
 
 /** Feature Functions **/
 
-func (m *MPCModule) Calculate(expression string, budget float64) (int, error) {
+func (m *MPCModule) Calculate(expression string, budget permissioned.Amount) (int, error) {
+	return m.CalculateWithFee(expression, budget, 0)
+}
+
+// CalculateWithFee offers the given participation fee to each committee member.
+// The fee is only used with the blockchain consensus
+func (m *MPCModule) CalculateWithFee(expression string, budget permissioned.Amount, fee permissioned.Amount) (int, error) {
 	// fmt.Printf("BENCHMARK, Time: %d. In function: Calculate Start\n", time.Now().UnixNano())
 	switch m.consensusType {
 	case peer.MPCConsensusPaxos:
 		return m.CalculatePaxos(expression, budget)
 	case peer.MPCConsensusBC:
-		return m.CalculateBlockchain(expression, budget)
+		return m.CalculateBlockchain(expression, budget, fee)
 	}
 	panic("invalid MPC type")
 }
 
-func (m *MPCModule) SetValueDBAsset(key string, value int, price float64) error {
+func (m *MPCModule) SetValueDBAsset(key string, value int, price permissioned.Amount) error {
+	return m.SetValueDBAssetWithSchema(key, value, price, nil)
+}
+
+// SetValueDBAssetWithSchema sets the asset and registers its schema on chain.
+// The value must be inside the range of the schema
+func (m *MPCModule) SetValueDBAssetWithSchema(key string, value int, price permissioned.Amount,
+	schema *permissioned.AssetSchema) error {
+	if schema != nil {
+		err := schema.Validate()
+		if err != nil {
+			return err
+		}
+		if !schema.InRange(value) {
+			return fmt.Errorf("add Assets failed. value %d out of range [%d, %d]",
+				value, schema.Min, schema.Max)
+		}
+	}
+
 	myIdentity := m.getIdentifyKey()
 	assetsMap := m.GetPeerAssetPrices()
 
@@ -93,7 +117,13 @@ func (m *MPCModule) SetValueDBAsset(key string, value int, price float64) error
 		return nil
 	}
 
-	id, err := m.bcModule.SendRegAssetsTransaction(map[string]float64{key: price})
+	registration := permissioned.AssetsRegistration{
+		Assets: map[string]permissioned.Amount{key: price},
+	}
+	if schema != nil {
+		registration.Schemas = map[string]permissioned.AssetSchema{key: *schema}
+	}
+	id, err := m.bcModule.SendRegAssetsTransaction(registration)
 	if err != nil {
 		return err
 	}
@@ -102,15 +132,157 @@ func (m *MPCModule) SetValueDBAsset(key string, value int, price float64) error
 	return nil
 }
 
-// GetPeerAssetPrices returns the assets inside the network with the keys and prices
-func (m *MPCModule) GetPeerAssetPrices() map[string]map[string]float64 {
+// UpdateAssetPrice changes the price of an asset owned by the peer.
+// Ongoing MPCs keep the price of the time they started
+func (m *MPCModule) UpdateAssetPrice(key string, price permissioned.Amount) error {
+	if m.consensusType != peer.MPCConsensusBC {
+		return fmt.Errorf("asset price needs blockchain consensus")
+	}
+
+	id, err := m.bcModule.SendUpdateAssetsTransaction(map[string]permissioned.Amount{key: price})
+	if err != nil {
+		return err
+	}
+	log.Info().Msgf("send updateAssets txn %s for Assets %s", id, key)
+
+	return nil
+}
+
+// RemoveValueDBAsset withdraws an asset of the peer. With blockchain consensus,
+// the value is removed from the ValueDB once the removal is on chain
+func (m *MPCModule) RemoveValueDBAsset(key string) error {
+	if _, ok := m.valueDB.getAsset(key); !ok {
+		return fmt.Errorf("remove Assets failed. key %s not found", key)
+	}
+
 	if m.consensusType != peer.MPCConsensusBC {
+		m.valueDB.removeAsset(key)
 		return nil
 	}
 
-	states := m.bcModule.GetLatestBlock().States
+	id, err := m.bcModule.SendRemoveAssetsTransaction([]string{key})
+	if err != nil {
+		return err
+	}
+	log.Info().Msgf("send removeAssets txn %s for Assets %s", id, key)
+
+	return nil
+}
+
+// TransferAsset gives an asset of the peer to another participant or to a
+// multisig account
+func (m *MPCModule) TransferAsset(key string, to string) error {
+	transfer, err := m.NewAssetTransfer(key, to)
+	if err != nil {
+		return err
+	}
+
+	id, err := m.bcModule.SendTransferAssetTransaction(transfer)
+	if err != nil {
+		return err
+	}
+	log.Info().Msgf("send transferAsset txn %s for Assets %s to %s", id, key, to)
+
+	return nil
+}
+
+// HoldValueDBAsset sets the value of an asset of a multisig account the peer
+// holds the values of. The owners register the asset from the account, naming
+// the peer as holder
+func (m *MPCModule) HoldValueDBAsset(key string, value int) error {
+	if m.consensusType != peer.MPCConsensusBC {
+		return fmt.Errorf("multisig assets need blockchain consensus")
+	}
+
+	myIdentity := m.getIdentifyKey()
+	for owner, assets := range m.GetPeerAssetPrices() {
+		if _, found := assets[key]; found && m.bcModule.GetChainAssetsHolder(owner) != myIdentity {
+			return fmt.Errorf("add Assets failed. key %s duplicate, %s already have the same assets", key, owner)
+		}
+	}
+
+	ok := m.valueDB.addAsset(key, value)
+	if !ok {
+		return fmt.Errorf("add Assets failed")
+	}
+	return nil
+}
+
+// NewAssetTransfer prepares the transfer of an asset whose value the peer
+// holds. The value is encrypted with the encryption key of the node holding
+// the values of the new owner, as registered on chain
+func (m *MPCModule) NewAssetTransfer(key string, to string) (permissioned.AssetTransfer, error) {
+	if m.consensusType != peer.MPCConsensusBC {
+		return permissioned.AssetTransfer{}, fmt.Errorf("asset transfer needs blockchain consensus")
+	}
+
+	value, ok := m.valueDB.getAsset(key)
+	if !ok {
+		return permissioned.AssetTransfer{}, fmt.Errorf("transfer Assets failed. key %s not found", key)
+	}
+
+	holder := m.bcModule.GetChainAssetsHolder(to)
+	config := m.bcModule.GetChainConfig()
+	pubkeys := NewPubkeyStore()
+	err := pubkeys.Add(map[string]string{holder: config.Participants[holder]})
+	if err != nil {
+		return permissioned.AssetTransfer{}, err
+	}
+	pubkey, ok := pubkeys.Get(holder)
+	if !ok {
+		return permissioned.AssetTransfer{}, fmt.Errorf("encryption key of %s not found", holder)
+	}
+	encryptedValue, err := m.EncryptAsymetric([]byte(strconv.Itoa(value)), *pubkey)
+	if err != nil {
+		return permissioned.AssetTransfer{}, err
+	}
+
+	return permissioned.AssetTransfer{
+		Key:            key,
+		To:             to,
+		EncryptedValue: encryptedValue,
+	}, nil
+}
+
+// SetAssetPolicy restricts the usage of an asset owned by the peer
+func (m *MPCModule) SetAssetPolicy(key string, policy permissioned.AssetPolicy) error {
+	if m.consensusType != peer.MPCConsensusBC {
+		return fmt.Errorf("asset policy needs blockchain consensus")
+	}
+
+	id, err := m.bcModule.SendRegAssetsTransaction(permissioned.AssetsRegistration{
+		Policies: map[string]permissioned.AssetPolicy{key: policy},
+	})
+	if err != nil {
+		return err
+	}
+	log.Info().Msgf("send regAssets txn %s for policy of Assets %s", id, key)
+
+	return nil
+}
+
+// SetAssetPricing publishes a price schedule for an asset owned by the peer
+func (m *MPCModule) SetAssetPricing(key string, schedule permissioned.PriceSchedule) error {
+	if m.consensusType != peer.MPCConsensusBC {
+		return fmt.Errorf("asset pricing needs blockchain consensus")
+	}
+
+	id, err := m.bcModule.SendRegPricingTransaction(map[string]permissioned.PriceSchedule{key: schedule})
+	if err != nil {
+		return err
+	}
+	log.Info().Msgf("send regPricing txn %s for Assets %s", id, key)
+
+	return nil
+}
+
+// GetPeerAssetPrices returns the assets inside the network with the keys and prices
+func (m *MPCModule) GetPeerAssetPrices() map[string]map[string]permissioned.Amount {
+	if m.consensusType != peer.MPCConsensusBC {
+		return nil
+	}
 
-	return permissioned.GetAllAssetsFromWorldState(states)
+	return m.bcModule.GetChainAssetPrices()
 }
 
 func (m *MPCModule) ComputeExpression(uniqID string, expr string, prime string) (int, error) {
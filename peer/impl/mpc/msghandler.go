@@ -22,6 +22,10 @@ func (m *MPCModule) ProcessMPCShareMsg(msg types.Message, pkt transport.Packet)
 	log.Debug().Msgf("%s: mpc value for req %s, %s:%s",
 		m.conf.Socket.GetAddress(), secretMsg.ReqID, secretMsg.Value.Key, secretMsg.Value.Value)
 
+	if m.reportInvalidShare(secretMsg.ReqID, secretMsg.Value) {
+		return fmt.Errorf("invalid share %s from %s", secretMsg.Value.Key, secretMsg.Value.Owner)
+	}
+
 	// MPC operation
 	valueBig, ok := new(big.Int).SetString(secretMsg.Value.Value, 10)
 	if !ok {
@@ -5,11 +5,12 @@ import (
 	"math/big"
 
 	"github.com/rs/zerolog/log"
+	"go.dedis.ch/cs438/permissioned-chain"
 	"go.dedis.ch/cs438/types"
 )
 
 // initMPCConcensus inits a paxos to consensus on mpc value
-func (m *MPCModule) initMPCConcensus(uniqID string, budget float64, expression string, prime string) (err error) {
+func (m *MPCModule) initMPCConcensus(uniqID string, budget permissioned.Amount, expression string, prime string) (err error) {
 	if m.paxos.Type != types.PaxosTypeMPC {
 		return fmt.Errorf("invalid operation")
 	}
@@ -23,7 +24,7 @@ func (m *MPCModule) initMPCConcensus(uniqID string, budget float64, expression s
 /** Private Helpfer Functions **/
 
 // proposeMPC starts a new paxos starting from phase one
-func (m *MPCModule) proposeMPC(uniqID string, budget float64, expression string, prime string, step uint) error {
+func (m *MPCModule) proposeMPC(uniqID string, budget permissioned.Amount, expression string, prime string, step uint) error {
 	// TODO: use public key hash?
 	initiator := m.GetPubkey().N.String()
 	proposeValContent := types.PaxosMPCValue{
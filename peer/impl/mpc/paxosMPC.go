@@ -13,6 +13,7 @@ import (
 	"go.dedis.ch/cs438/peer"
 	"go.dedis.ch/cs438/peer/impl/message"
 	"go.dedis.ch/cs438/peer/impl/paxos"
+	"go.dedis.ch/cs438/permissioned-chain"
 	"go.dedis.ch/cs438/storage"
 	"go.dedis.ch/cs438/types"
 )
@@ -37,7 +38,7 @@ func newMPCModuleWithPaxos(conf *peer.Configuration, messageModule *message.Mess
 
 // CalculatePaxos start a new MPC from making consensus on budget and expression.
 // It will then initiate the MPC automatically
-func (m *MPCModule) CalculatePaxos(expression string, budget float64) (int, error) {
+func (m *MPCModule) CalculatePaxos(expression string, budget permissioned.Amount) (int, error) {
 	if m.conf.TotalPeers == 1 {
 		log.Println("No MPC. Direct calculate the result.")
 		return 0, nil
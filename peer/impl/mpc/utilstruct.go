@@ -29,6 +29,11 @@ func (db *ValueDB) addAsset(key string, value int) bool {
 	db.asset[key] = value
 	return true
 }
+func (db *ValueDB) removeAsset(key string) {
+	db.Lock()
+	defer db.Unlock()
+	delete(db.asset, key)
+}
 func (db *ValueDB) getAsset(key string) (int, bool) {
 	db.RLock()
 	defer db.RUnlock()
@@ -102,6 +102,13 @@ type Configuration struct {
 	// for the PreMPC Txn to be on chain
 	// Default: 2
 	MPCMaxWaitBlock int
+
+	// LightChain runs the node as a light node of the permissioned chain:
+	// it only syncs the block headers and queries full nodes for proven
+	// state values. It never mines, so its address must be listed in the
+	// LightNodes of the chain config
+	// Default: false
+	LightChain bool
 }
 
 // Backoff describes parameters for a backoff algorithm. The initial time must
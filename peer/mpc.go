@@ -1,9 +1,17 @@
 package peer
 
+import (
+	permissioned "go.dedis.ch/cs438/permissioned-chain"
+)
+
 type MPC interface {
 	// starts MPC with given expression and budget to pay
 	// and returns the result
-	Calculate(expression string, budget float64) (int, error)
+	Calculate(expression string, budget permissioned.Amount) (int, error)
+
+	// CalculateWithFee is Calculate with a participation fee offered to
+	// each MPC committee member. 0 uses the chain default
+	CalculateWithFee(expression string, budget permissioned.Amount, fee permissioned.Amount) (int, error)
 
 	// start MPC with given expression and return the result
 	// ComputeExpression(expression MPCExpression) (int, error)
@@ -11,10 +19,39 @@ type MPC interface {
 
 	// SetValueDBAsset set the asset of the peers. Overwrites it if the entry
 	// already exists.
-	SetValueDBAsset(key string, value int, price float64) error
+	SetValueDBAsset(key string, value int, price permissioned.Amount) error
+
+	// SetValueDBAssetWithSchema is SetValueDBAsset that also registers
+	// the schema of the asset on chain
+	SetValueDBAssetWithSchema(key string, value int, price permissioned.Amount,
+		schema *permissioned.AssetSchema) error
 
 	// GetAllPeerAssetPrices returns the assets inside the network with the keys and prices
-	GetAllPeerAssetPrices() map[string]map[string]float64
+	GetAllPeerAssetPrices() map[string]map[string]permissioned.Amount
+
+	// UpdateAssetPrice changes the price of an asset of the peer
+	UpdateAssetPrice(key string, price permissioned.Amount) error
+
+	// RemoveValueDBAsset withdraws an asset of the peer
+	RemoveValueDBAsset(key string) error
+
+	// TransferAsset gives an asset of the peer to another participant or to
+	// a multisig account
+	TransferAsset(key string, to string) error
+
+	// HoldValueDBAsset sets the value of an asset of a multisig account whose
+	// values the peer holds. The owners register the asset from the account
+	HoldValueDBAsset(key string, value int) error
+
+	// NewAssetTransfer encrypts the value of an asset the peer holds for the
+	// new owner. The owners of a multisig account cosign the transfer
+	NewAssetTransfer(key string, to string) (permissioned.AssetTransfer, error)
+
+	// SetAssetPolicy publishes the access-control policy of an asset of the peer
+	SetAssetPolicy(key string, policy permissioned.AssetPolicy) error
+
+	// SetAssetPricing publishes the price schedule of an asset of the peer
+	SetAssetPricing(key string, schedule permissioned.PriceSchedule) error
 
 	// InitMPC inits a MPC instance for mpc before computation.
 	InitMPC(uniqID string, prime string, initiator string, expression string) error
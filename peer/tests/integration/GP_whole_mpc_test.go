@@ -61,7 +61,7 @@ func Test_GP_MPC_Paxos_Add(t *testing.T) {
 func Test_GP_MPC_Pure_BC_Single(t *testing.T) {
 	zerolog.SetGlobalLevel(zerolog.ErrorLevel)
 	// zerolog.SetGlobalLevel(zerolog.WarnLevel)
-	nodes, addrs := tests.Setup_n_peers_bc(t, 3, 3, "2s", []float64{100}, true, true)
+	nodes, addrs := tests.Setup_n_peers_bc(t, 3, 3, "2s", []permissioned.Amount{100}, true, true)
 	nodeA := nodes[0]
 	nodeB := nodes[1]
 	nodeC := nodes[2]
@@ -124,26 +124,26 @@ func Test_GP_MPC_Pure_BC_Single(t *testing.T) {
 	// > verify balance are correct at last
 	worldstate := block2a.GetWorldStateCopy()
 	accountA := permissioned.GetAccountFromWorldState(worldstate, addrs[0])
-	require.Equal(t, float64(92), accountA.GetBalance())
+	require.Equal(t, permissioned.Amount(92), accountA.GetBalance())
 	accountB := permissioned.GetAccountFromWorldState(worldstate, addrs[1])
-	require.Equal(t, float64(7), accountB.GetBalance())
+	require.Equal(t, permissioned.Amount(7), accountB.GetBalance())
 	accountC := permissioned.GetAccountFromWorldState(worldstate, addrs[2])
-	require.Equal(t, float64(1), accountC.GetBalance())
+	require.Equal(t, permissioned.Amount(1), accountC.GetBalance())
 
 	// > verify balance are correct before MPC
 	worldstate = block1a.GetWorldStateCopy()
 	accountA = permissioned.GetAccountFromWorldState(worldstate, addrs[0])
-	require.Equal(t, float64(90), accountA.GetBalance())
+	require.Equal(t, permissioned.Amount(90), accountA.GetBalance())
 	accountB = permissioned.GetAccountFromWorldState(worldstate, addrs[1])
-	require.Equal(t, float64(0), accountB.GetBalance())
+	require.Equal(t, permissioned.Amount(0), accountB.GetBalance())
 	accountC = permissioned.GetAccountFromWorldState(worldstate, addrs[2])
-	require.Equal(t, float64(0), accountC.GetBalance())
+	require.Equal(t, permissioned.Amount(0), accountC.GetBalance())
 }
 
 func Test_GP_MPC_Pure_BC_Multiple(t *testing.T) {
 	zerolog.SetGlobalLevel(zerolog.ErrorLevel)
 	// zerolog.SetGlobalLevel(zerolog.WarnLevel)
-	nodes, addrs := tests.Setup_n_peers_bc(t, 3, 3, "2h", []float64{100}, true, true)
+	nodes, addrs := tests.Setup_n_peers_bc(t, 3, 3, "2h", []permissioned.Amount{100}, true, true)
 	nodeA := nodes[0]
 	nodeB := nodes[1]
 	nodeC := nodes[2]
@@ -238,17 +238,17 @@ func Test_GP_MPC_Pure_BC_Multiple(t *testing.T) {
 	// > verify balance are correct at last
 	worldstate := blockA.GetWorldStateCopy()
 	accountA := permissioned.GetAccountFromWorldState(worldstate, addrs[0])
-	require.Equal(t, float64(84), accountA.GetBalance())
+	require.Equal(t, permissioned.Amount(84), accountA.GetBalance())
 	accountB := permissioned.GetAccountFromWorldState(worldstate, addrs[1])
-	require.Equal(t, float64(7), accountB.GetBalance())
+	require.Equal(t, permissioned.Amount(7), accountB.GetBalance())
 	accountC := permissioned.GetAccountFromWorldState(worldstate, addrs[2])
-	require.Equal(t, float64(9), accountC.GetBalance())
+	require.Equal(t, permissioned.Amount(9), accountC.GetBalance())
 }
 
 func Test_GP_MPC_Pure_BC_Double_Spend(t *testing.T) {
 	zerolog.SetGlobalLevel(zerolog.ErrorLevel)
 	// zerolog.SetGlobalLevel(zerolog.WarnLevel)
-	nodes, addrs := tests.Setup_n_peers_bc(t, 3, 3, "2s", []float64{7}, true, true)
+	nodes, addrs := tests.Setup_n_peers_bc(t, 3, 3, "2s", []permissioned.Amount{7}, true, true)
 	nodeA := nodes[0]
 	nodeB := nodes[1]
 	nodeC := nodes[2]
@@ -322,18 +322,18 @@ func Test_GP_MPC_Pure_BC_Double_Spend(t *testing.T) {
 	// > verify balance are correct at last
 	worldstate := blockA.GetWorldStateCopy()
 	accountA := permissioned.GetAccountFromWorldState(worldstate, addrs[0])
-	require.Equal(t, float64(3), accountA.GetBalance())
+	require.Equal(t, permissioned.Amount(3), accountA.GetBalance())
 	accountB := permissioned.GetAccountFromWorldState(worldstate, addrs[1])
-	require.Equal(t, float64(3), accountB.GetBalance())
+	require.Equal(t, permissioned.Amount(3), accountB.GetBalance())
 	accountC := permissioned.GetAccountFromWorldState(worldstate, addrs[2])
-	require.Equal(t, float64(1), accountC.GetBalance())
+	require.Equal(t, permissioned.Amount(1), accountC.GetBalance())
 
 }
 
 func Test_GP_MPC_BC_ADD_Simple(t *testing.T) {
 	zerolog.SetGlobalLevel(zerolog.ErrorLevel)
 	// zerolog.SetGlobalLevel(zerolog.WarnLevel)
-	nodes, _ := tests.Setup_n_peers_bc(t, 3, 1, "2s", []float64{100}, false, true)
+	nodes, _ := tests.Setup_n_peers_bc(t, 3, 1, "2s", []permissioned.Amount{100}, false, true)
 	nodeA := nodes[0]
 	nodeB := nodes[1]
 	nodeC := nodes[2]
@@ -381,7 +381,7 @@ func Test_GP_MPC_BC_ADD_Simple(t *testing.T) {
 func Test_GP_MPC_BC_MULT_Simple(t *testing.T) {
 	// zerolog.SetGlobalLevel(zerolog.WarnLevel)
 	zerolog.SetGlobalLevel(zerolog.ErrorLevel)
-	nodes, _ := tests.Setup_n_peers_bc(t, 3, 1, "2s", []float64{100}, false, true)
+	nodes, _ := tests.Setup_n_peers_bc(t, 3, 1, "2s", []permissioned.Amount{100}, false, true)
 	nodeA := nodes[0]
 	nodeB := nodes[1]
 	nodeC := nodes[2]
@@ -429,7 +429,7 @@ func Test_GP_MPC_BC_MULT_Simple(t *testing.T) {
 func Test_GP_MPC_BC_COMPLEX(t *testing.T) {
 	zerolog.SetGlobalLevel(zerolog.ErrorLevel)
 	// zerolog.SetGlobalLevel(zerolog.WarnLevel)
-	nodes, _ := tests.Setup_n_peers_bc(t, 3, 1, "2s", []float64{0, 0, 100}, false, true)
+	nodes, _ := tests.Setup_n_peers_bc(t, 3, 1, "2s", []permissioned.Amount{0, 0, 100}, false, true)
 	nodeA := nodes[0]
 	nodeB := nodes[1]
 	nodeC := nodes[2]
@@ -484,7 +484,7 @@ func Test_GP_MPC_BC_COMPLEX(t *testing.T) {
 func Test_GP_MPC_BC_Multiple(t *testing.T) {
 	zerolog.SetGlobalLevel(zerolog.ErrorLevel)
 	// zerolog.SetGlobalLevel(zerolog.InfoLevel)
-	nodes, addrs := tests.Setup_n_peers_bc(t, 3, 1, "5h", []float64{200}, false, true)
+	nodes, addrs := tests.Setup_n_peers_bc(t, 3, 1, "5h", []permissioned.Amount{200}, false, true)
 	nodeA := nodes[0]
 	nodeB := nodes[1]
 	nodeC := nodes[2]
@@ -573,17 +573,17 @@ func Test_GP_MPC_BC_Multiple(t *testing.T) {
 	// > verify balance are correct at last
 	worldstate := blockA.GetWorldStateCopy()
 	accountA := permissioned.GetAccountFromWorldState(worldstate, addrs[0])
-	require.Equal(t, float64(194), accountA.GetBalance())
+	require.Equal(t, permissioned.Amount(194), accountA.GetBalance())
 	accountB := permissioned.GetAccountFromWorldState(worldstate, addrs[1])
-	require.Equal(t, float64(3), accountB.GetBalance())
+	require.Equal(t, permissioned.Amount(3), accountB.GetBalance())
 	accountC := permissioned.GetAccountFromWorldState(worldstate, addrs[2])
-	require.Equal(t, float64(3), accountC.GetBalance())
+	require.Equal(t, permissioned.Amount(3), accountC.GetBalance())
 }
 
 func Test_GP_MPC_BC_MULT_Simple_With_Pubkey_Txn(t *testing.T) {
 	zerolog.SetGlobalLevel(zerolog.ErrorLevel)
 	// zerolog.SetGlobalLevel(zerolog.WarnLevel)
-	nodes, _ := tests.Setup_n_peers_bc(t, 3, 1, "2s", []float64{100}, false, true)
+	nodes, _ := tests.Setup_n_peers_bc(t, 3, 1, "2s", []permissioned.Amount{100}, false, true)
 	nodeA := nodes[0]
 	nodeB := nodes[1]
 	nodeC := nodes[2]
@@ -629,7 +629,7 @@ func Test_GP_MPC_BC_MULT_Simple_With_Pubkey_Txn(t *testing.T) {
 func Test_GP_MPC_BC_Stress_Multiple(t *testing.T) {
 	// zerolog.SetGlobalLevel(zerolog.InfoLevel)
 	zerolog.SetGlobalLevel(zerolog.ErrorLevel)
-	nodes, addrs := tests.Setup_n_peers_bc(t, 3, 3, "5s", []float64{200}, false, true)
+	nodes, addrs := tests.Setup_n_peers_bc(t, 3, 3, "5s", []permissioned.Amount{200}, false, true)
 	nodeA := nodes[0]
 	nodeB := nodes[1]
 	nodeC := nodes[2]
@@ -716,11 +716,11 @@ func Test_GP_MPC_BC_Stress_Multiple(t *testing.T) {
 	// > verify balance are correct at last
 	worldstate := blockA.GetWorldStateCopy()
 	accountA := permissioned.GetAccountFromWorldState(worldstate, addrs[0])
-	require.Equal(t, float64(194), accountA.GetBalance())
+	require.Equal(t, permissioned.Amount(194), accountA.GetBalance())
 	accountB := permissioned.GetAccountFromWorldState(worldstate, addrs[1])
-	require.Equal(t, float64(3), accountB.GetBalance())
+	require.Equal(t, permissioned.Amount(3), accountB.GetBalance())
 	accountC := permissioned.GetAccountFromWorldState(worldstate, addrs[2])
-	require.Equal(t, float64(3), accountC.GetBalance())
+	require.Equal(t, permissioned.Amount(3), accountC.GetBalance())
 }
 
 // -----------------------------------------------------------------------------
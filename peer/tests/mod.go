@@ -23,7 +23,7 @@ import (
 var peerFac peer.Factory = impl.NewPeer
 
 func Setup_n_peers_bc_perf(t *testing.T, transp transport.Transport, n int, maxTxn int,
-	timeout string, gains []float64, waitTime time.Duration,
+	timeout string, gains []permissioned.Amount, waitTime time.Duration,
 	disableMPC bool, disablePubkeyTxn bool) ([]*z.TestNode, []string) {
 	nodes := make([]*z.TestNode, n)
 
@@ -93,7 +93,7 @@ func Setup_n_peers_bc_perf(t *testing.T, transp transport.Transport, n int, maxT
 		participants,
 		maxTxn, timeout, 1, 1,
 	)
-	initialGain := make(map[string]float64)
+	initialGain := make(map[string]permissioned.Amount)
 	for i, gain := range gains {
 		initialGain[addrs[i]] = gain
 	}
@@ -113,7 +113,7 @@ func Setup_n_peers_bc_perf(t *testing.T, transp transport.Transport, n int, maxT
 }
 
 func Setup_n_peers_bc(t *testing.T, n int, maxTxn int,
-	timeout string, gains []float64, disableMPC bool, disablePubkeyTxn bool) ([]*z.TestNode, []string) {
+	timeout string, gains []permissioned.Amount, disableMPC bool, disablePubkeyTxn bool) ([]*z.TestNode, []string) {
 	transp := channel.NewTransport()
 	nodes := make([]*z.TestNode, n)
 
@@ -181,7 +181,7 @@ func Setup_n_peers_bc(t *testing.T, n int, maxTxn int,
 		participants,
 		maxTxn, timeout, 1, 1,
 	)
-	initialGain := make(map[string]float64)
+	initialGain := make(map[string]permissioned.Amount)
 	for i, gain := range gains {
 		initialGain[addrs[i]] = gain
 	}
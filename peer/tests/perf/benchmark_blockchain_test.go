@@ -15,7 +15,7 @@ import (
 var getTest = func(n int, maxTxn int, blkTimeout string, initSleepTime, sleepTime time.Duration) func(*testing.T) {
 	return func(t *testing.T) {
 		nodes, addrs := tests.Setup_n_peers_bc_perf(t, channel.NewTransport(), n, maxTxn, blkTimeout,
-			[]float64{10000}, initSleepTime, true, true)
+			[]permissioned.Amount{10000}, initSleepTime, true, true)
 		fmt.Println("----------set up correctly----------")
 		nodeA := nodes[0]
 		addrA := addrs[0]
@@ -25,7 +25,7 @@ var getTest = func(n int, maxTxn int, blkTimeout string, initSleepTime, sleepTim
 		start := time.Now()
 
 		for j := 0; j < total; j++ {
-			err := nodeA.SetValueDBAsset("a", 1, float64(j+1))
+			err := nodeA.SetValueDBAsset("a", 1, permissioned.Amount(j+1))
 			require.NoError(t, err)
 
 			if j%50 == 0 {
@@ -39,7 +39,7 @@ var getTest = func(n int, maxTxn int, blkTimeout string, initSleepTime, sleepTim
 			record := permissioned.GetAssetsFromWorldState(block.States, addrA)
 			commit := record.Assets["a"]
 
-			if commit == float64(total) {
+			if commit == permissioned.Amount(total) {
 				break
 			}
 		}
@@ -9,6 +9,7 @@ import (
 	"github.com/stretchr/testify/require"
 	z "go.dedis.ch/cs438/internal/testing"
 	"go.dedis.ch/cs438/peer/tests"
+	"go.dedis.ch/cs438/permissioned-chain"
 	"go.dedis.ch/cs438/transport/channel"
 )
 
@@ -20,8 +21,8 @@ import (
 // ##################################################
 
 func setup(t *testing.T, n int, maxTxn int,
-	timeout string, gains []float64, initSleepTime time.Duration) ([]*z.TestNode, []string) {
-	return tests.Setup_n_peers_bc_perf(t, channel.NewTransport(), n, maxTxn, timeout, []float64{10000}, initSleepTime, false, true)
+	timeout string, gains []permissioned.Amount, initSleepTime time.Duration) ([]*z.TestNode, []string) {
+	return tests.Setup_n_peers_bc_perf(t, channel.NewTransport(), n, maxTxn, timeout, []permissioned.Amount{10000}, initSleepTime, false, true)
 	// return tests.Setup_n_peers_bc(t, n, maxTxn, timeout, gains, false, true)
 }
 
@@ -31,7 +32,7 @@ func Test_Throughput_Simple_Add_3_nodes(t *testing.T) {
 	//zerolog.SetGlobalLevel(zerolog.WarnLevel)
 
 	const iniBalanceA = 300
-	nodes, _ := setup(t, 3, 1, "2s", []float64{float64(iniBalanceA)}, time.Millisecond*500)
+	nodes, _ := setup(t, 3, 1, "2s", []permissioned.Amount{permissioned.Amount(iniBalanceA)}, time.Millisecond*500)
 	nodeA, nodeB, nodeC := nodes[0], nodes[1], nodes[2]
 	defer nodeA.Stop()
 	defer nodeB.Stop()
@@ -39,9 +40,9 @@ func Test_Throughput_Simple_Add_3_nodes(t *testing.T) {
 
 	//start := time.Now()
 
-	err := nodeA.SetValueDBAsset("a", 1, 0.1)
+	err := nodeA.SetValueDBAsset("a", 1, 1)
 	require.NoError(t, err)
-	err = nodeB.SetValueDBAsset("b", 1, 0.1)
+	err = nodeB.SetValueDBAsset("b", 1, 1)
 	require.NoError(t, err)
 
 	//timeTrack(start, "'set assets value'")
@@ -58,7 +59,7 @@ func Test_Throughput_Simple_Add_3_nodes(t *testing.T) {
 
 			//start := time.Now()
 
-			_, err = nodeA.Calculate("a+b", 3.2)
+			_, err = nodeA.Calculate("a+b", 5)
 			require.NoError(t, err)
 
 			// try to minimize
@@ -88,7 +89,7 @@ func Test_Throughput_Simple_Add_4_nodes(t *testing.T) {
 	//zerolog.SetGlobalLevel(zerolog.WarnLevel)
 
 	const iniBalanceA = 300
-	nodes, _ := setup(t, 4, 1, "2s", []float64{float64(iniBalanceA)}, time.Second)
+	nodes, _ := setup(t, 4, 1, "2s", []permissioned.Amount{permissioned.Amount(iniBalanceA)}, time.Second)
 	nodeA, nodeB, nodeC, nodeD := nodes[0], nodes[1], nodes[2], nodes[3]
 	defer nodeA.Stop()
 	defer nodeB.Stop()
@@ -97,9 +98,9 @@ func Test_Throughput_Simple_Add_4_nodes(t *testing.T) {
 
 	//start := time.Now()
 
-	err := nodeA.SetValueDBAsset("a", 1, 0.1)
+	err := nodeA.SetValueDBAsset("a", 1, 1)
 	require.NoError(t, err)
-	err = nodeB.SetValueDBAsset("b", 1, 0.1)
+	err = nodeB.SetValueDBAsset("b", 1, 1)
 	require.NoError(t, err)
 
 	//timeTrack(start, "'set assets value'")
@@ -116,7 +117,7 @@ func Test_Throughput_Simple_Add_4_nodes(t *testing.T) {
 
 			//start := time.Now()
 
-			_, err = nodeA.Calculate("a+b", 4.2)
+			_, err = nodeA.Calculate("a+b", 6)
 			require.NoError(t, err)
 
 			//timer(start, "MPC")
@@ -144,7 +145,7 @@ func Test_Throughput_Simple_Add_5_nodes(t *testing.T) {
 	// zerolog.SetGlobalLevel(zerolog.InfoLevel)
 
 	const iniBalanceA = 300
-	nodes, _ := setup(t, 5, 1, "10s", []float64{float64(iniBalanceA)}, time.Second*3)
+	nodes, _ := setup(t, 5, 1, "10s", []permissioned.Amount{permissioned.Amount(iniBalanceA)}, time.Second*3)
 	nodeA, nodeB, nodeC, nodeD, nodeE := nodes[0], nodes[1], nodes[2], nodes[3], nodes[4]
 	defer nodeA.Stop()
 	defer nodeB.Stop()
@@ -202,7 +203,7 @@ func Test_Throughput_Simple_Mul_3_nodes(t *testing.T) {
 	//zerolog.SetGlobalLevel(zerolog.WarnLevel)
 
 	const iniBalanceA = 300
-	nodes, _ := setup(t, 3, 1, "2s", []float64{float64(iniBalanceA)}, time.Millisecond*200)
+	nodes, _ := setup(t, 3, 1, "2s", []permissioned.Amount{permissioned.Amount(iniBalanceA)}, time.Millisecond*200)
 	nodeA, nodeB, nodeC := nodes[0], nodes[1], nodes[2]
 	defer nodeA.Stop()
 	defer nodeB.Stop()
@@ -259,7 +260,7 @@ func Test_Throughput_Simple_Mul_4_nodes(t *testing.T) {
 	//zerolog.SetGlobalLevel(zerolog.WarnLevel)
 
 	const iniBalanceA = 300
-	nodes, _ := setup(t, 4, 1, "2s", []float64{float64(iniBalanceA)}, time.Second)
+	nodes, _ := setup(t, 4, 1, "2s", []permissioned.Amount{permissioned.Amount(iniBalanceA)}, time.Second)
 	nodeA, nodeB, nodeC, nodeD := nodes[0], nodes[1], nodes[2], nodes[3]
 	defer nodeA.Stop()
 	defer nodeB.Stop()
@@ -317,7 +318,7 @@ func Test_Throughput_Simple_Mul_5_nodes(t *testing.T) {
 	//zerolog.SetGlobalLevel(zerolog.WarnLevel)
 
 	const iniBalanceA = 300
-	nodes, _ := setup(t, 5, 1, "2s", []float64{float64(iniBalanceA)}, time.Second*3)
+	nodes, _ := setup(t, 5, 1, "2s", []permissioned.Amount{permissioned.Amount(iniBalanceA)}, time.Second*3)
 	nodeA, nodeB, nodeC, nodeD, nodeE := nodes[0], nodes[1], nodes[2], nodes[3], nodes[4]
 	defer nodeA.Stop()
 	defer nodeB.Stop()
@@ -377,7 +378,7 @@ func Test_Throughput_Complex_3_nodes(t *testing.T) {
 	//zerolog.SetGlobalLevel(zerolog.WarnLevel)
 
 	const iniBalanceA = 300
-	nodes, _ := setup(t, 3, 1, "2s", []float64{float64(iniBalanceA)}, time.Millisecond*200)
+	nodes, _ := setup(t, 3, 1, "2s", []permissioned.Amount{permissioned.Amount(iniBalanceA)}, time.Millisecond*200)
 	nodeA, nodeB, nodeC := nodes[0], nodes[1], nodes[2]
 	defer nodeA.Stop()
 	defer nodeB.Stop()
@@ -436,7 +437,7 @@ func Test_Throughput_Complex_4_nodes(t *testing.T) {
 	//zerolog.SetGlobalLevel(zerolog.WarnLevel)
 
 	const iniBalanceA = 500
-	nodes, _ := setup(t, 4, 1, "5s", []float64{float64(iniBalanceA)}, time.Second)
+	nodes, _ := setup(t, 4, 1, "5s", []permissioned.Amount{permissioned.Amount(iniBalanceA)}, time.Second)
 	nodeA, nodeB, nodeC, nodeD := nodes[0], nodes[1], nodes[2], nodes[3]
 	defer nodeA.Stop()
 	defer nodeB.Stop()
@@ -499,7 +500,7 @@ func Test_Throughput_maxTxn_3_nodes(t *testing.T) {
 	//zerolog.SetGlobalLevel(zerolog.WarnLevel)
 
 	const iniBalanceA = 300
-	nodes, _ := setup(t, 3, 7, "2s", []float64{float64(iniBalanceA)}, time.Second*3)
+	nodes, _ := setup(t, 3, 7, "2s", []permissioned.Amount{permissioned.Amount(iniBalanceA)}, time.Second*3)
 	nodeA, nodeB, nodeC := nodes[0], nodes[1], nodes[2]
 	defer nodeA.Stop()
 	defer nodeB.Stop()
@@ -559,7 +560,7 @@ func Test_Throughput_timeout_3_nodes(t *testing.T) {
 	//zerolog.SetGlobalLevel(zerolog.WarnLevel)
 
 	const iniBalanceA = 300
-	nodes, _ := setup(t, 3, 5, "5s", []float64{float64(iniBalanceA)}, time.Millisecond*200)
+	nodes, _ := setup(t, 3, 5, "5s", []permissioned.Amount{permissioned.Amount(iniBalanceA)}, time.Millisecond*200)
 	nodeA, nodeB, nodeC := nodes[0], nodes[1], nodes[2]
 	defer nodeA.Stop()
 	defer nodeB.Stop()
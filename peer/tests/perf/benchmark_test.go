@@ -18,7 +18,7 @@ func Test_Benchmark_Throughput_Simple_Add_3_nodes(t *testing.T) {
 	//zerolog.SetGlobalLevel(zerolog.WarnLevel)
 
 	const iniBalanceA = 200
-	nodes, addrs := tests.Setup_n_peers_bc(t, 3, 1, "2s", []float64{float64(iniBalanceA)}, false, true)
+	nodes, addrs := tests.Setup_n_peers_bc(t, 3, 1, "2s", []permissioned.Amount{permissioned.Amount(iniBalanceA)}, false, true)
 	nodeA := nodes[0]
 	nodeB := nodes[1]
 	nodeC := nodes[2]
@@ -63,11 +63,11 @@ func Test_Benchmark_Throughput_Simple_Add_3_nodes(t *testing.T) {
 			require.NotNil(t, block2a)
 			worldstate := block2a.GetWorldStateCopy()
 			accountA := permissioned.GetAccountFromWorldState(worldstate, addrs[0])
-			require.Equal(t, float64(iniBalanceA-i*3), accountA.GetBalance())
+			require.Equal(t, permissioned.Amount(iniBalanceA-i*3), accountA.GetBalance())
 			accountB := permissioned.GetAccountFromWorldState(worldstate, addrs[1])
-			require.Equal(t, float64(i*2), accountB.GetBalance())
+			require.Equal(t, permissioned.Amount(i*2), accountB.GetBalance())
 			accountC := permissioned.GetAccountFromWorldState(worldstate, addrs[2])
-			require.Equal(t, float64(i), accountC.GetBalance())
+			require.Equal(t, permissioned.Amount(i), accountC.GetBalance())
 			//timeTrack(start, "'verification'")
 
 			fmt.Println()
@@ -92,7 +92,7 @@ func Test_Benchmark_Throughput_Simple_Add_4_nodes(t *testing.T) {
 	//zerolog.SetGlobalLevel(zerolog.WarnLevel)
 
 	const iniBalanceA = 100
-	nodes, addrs := tests.Setup_n_peers_bc(t, 4, 1, "5s", []float64{float64(iniBalanceA)}, false, true)
+	nodes, addrs := tests.Setup_n_peers_bc(t, 4, 1, "5s", []permissioned.Amount{permissioned.Amount(iniBalanceA)}, false, true)
 	nodeA := nodes[0]
 	nodeB := nodes[1]
 	nodeC := nodes[2]
@@ -140,13 +140,13 @@ func Test_Benchmark_Throughput_Simple_Add_4_nodes(t *testing.T) {
 
 			worldstate := block2a.GetWorldStateCopy()
 			accountA := permissioned.GetAccountFromWorldState(worldstate, addrs[0])
-			require.Equal(t, float64(iniBalanceA-i*4), accountA.GetBalance())
+			require.Equal(t, permissioned.Amount(iniBalanceA-i*4), accountA.GetBalance())
 			accountB := permissioned.GetAccountFromWorldState(worldstate, addrs[1])
-			require.Equal(t, float64(i*2), accountB.GetBalance())
+			require.Equal(t, permissioned.Amount(i*2), accountB.GetBalance())
 			accountC := permissioned.GetAccountFromWorldState(worldstate, addrs[2])
-			require.Equal(t, float64(i), accountC.GetBalance())
+			require.Equal(t, permissioned.Amount(i), accountC.GetBalance())
 			accountD := permissioned.GetAccountFromWorldState(worldstate, addrs[3])
-			require.Equal(t, float64(i), accountD.GetBalance())
+			require.Equal(t, permissioned.Amount(i), accountD.GetBalance())
 			//timeTrack(start, "'verification'")
 
 			fmt.Println()
@@ -1,14 +1,18 @@
 package unit
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/ethereum/go-ethereum/accounts/keystore"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/require"
 	z "go.dedis.ch/cs438/internal/testing"
 	"go.dedis.ch/cs438/permissioned-chain"
+	"go.dedis.ch/cs438/storage/inmemory"
 	"go.dedis.ch/cs438/transport/channel"
 )
 
@@ -138,7 +142,7 @@ func Test_GP_BC_Mine_Block_Simple(t *testing.T) {
 		},
 		1, "2h", 1, 1,
 	)
-	initialGain := map[string]float64{
+	initialGain := map[string]permissioned.Amount{
 		addr1.Hex: 10000,
 	}
 	require.Len(t, config.Participants, 2)
@@ -154,7 +158,7 @@ func Test_GP_BC_Mine_Block_Simple(t *testing.T) {
 
 	// > send Tx to node1. A new block need to be mined
 
-	txn1 := permissioned.NewTransactionRegAssets(account1, map[string]float64{
+	txn1 := permissioned.NewTransactionRegAssets(account1, map[string]permissioned.Amount{
 		"key1": 1,
 	})
 	require.Equal(t, addr1.Hex, txn1.From)
@@ -264,7 +268,7 @@ func Test_GP_BC_Mine_Block(t *testing.T) {
 		},
 		1, "2h", 1, 1,
 	)
-	initialGain := map[string]float64{
+	initialGain := map[string]permissioned.Amount{
 		addr1.Hex: 22,
 		addr2.Hex: 15,
 	}
@@ -284,7 +288,7 @@ func Test_GP_BC_Mine_Block(t *testing.T) {
 
 	// > send Tx to nodeA. need to succeed
 
-	txn1 := permissioned.NewTransactionRegAssets(account1, map[string]float64{
+	txn1 := permissioned.NewTransactionRegAssets(account1, map[string]permissioned.Amount{
 		"key1": 1,
 	})
 	require.Equal(t, addr1.Hex, txn1.From)
@@ -301,8 +305,8 @@ func Test_GP_BC_Mine_Block(t *testing.T) {
 
 	time.Sleep(time.Second * 1)
 
-	// > A new block need to be mined by nodeA.
-	// Both should append the new block
+	// > A new block need to be mined by the miner selected after
+	// the genesis block. Both should append the new block
 
 	block1a := nodeA.BCGetLatestBlock()
 	require.NotNil(t, block1a)
@@ -311,12 +315,30 @@ func Test_GP_BC_Mine_Block(t *testing.T) {
 	require.Equal(t, block1a.Hash(), block1b.Hash())
 	require.Equal(t, uint(1), block1a.Height)
 	require.Equal(t, block0a.Hash(), block1a.PrevHash)
-	require.Equal(t, addr1.Hex, block1a.Miner)
+	miner1, proof1 := permissioned.SelectMiner(block0a)
+	require.Equal(t, miner1, block1a.Miner)
+	require.Equal(t, proof1, block1a.SelectionProof)
+	signer1, err := block1a.Signer()
+	require.NoError(t, err)
+	require.Equal(t, miner1, signer1)
+	require.False(t, block1a.GetTime().Before(block0a.GetTime()))
 	require.NotNil(t, block1a.GetTxn(txn1.ID))
 
+	// > nodeB proves the inclusion of the txn in block 1
+
+	txnProof, err := nodeB.BCGetTxnProof(txn1.ID)
+	require.NoError(t, err)
+	require.NoError(t, txnProof.Verify())
+	require.Equal(t, block1a.Hash(), txnProof.Header.Hash())
+
+	accountProof, err := nodeB.BCGetAccountProof(addr1.Hex)
+	require.NoError(t, err)
+	require.NoError(t, accountProof.Verify())
+	require.Equal(t, block1a.Hash(), accountProof.Header.Hash())
+
 	// > send Tx to nodeA. need to succeed
 
-	txn2 := permissioned.NewTransactionRegAssets(account1, map[string]float64{
+	txn2 := permissioned.NewTransactionRegAssets(account1, map[string]permissioned.Amount{
 		"key1": 1,
 	})
 	require.Equal(t, addr1.Hex, txn2.From)
@@ -333,8 +355,8 @@ func Test_GP_BC_Mine_Block(t *testing.T) {
 
 	time.Sleep(time.Second * 2)
 
-	// > A new block need to be mined by nodeB.
-	// Both should append the new block
+	// > A new block need to be mined by the miner selected after
+	// block 1. Both should append the new block
 
 	block2a := nodeA.BCGetLatestBlock()
 	require.NotNil(t, block2a)
@@ -343,7 +365,8 @@ func Test_GP_BC_Mine_Block(t *testing.T) {
 	require.Equal(t, block2a.Hash(), block2b.Hash())
 	require.Equal(t, uint(2), block2a.Height)
 	require.Equal(t, block1a.Hash(), block2a.PrevHash)
-	require.Equal(t, addr2.Hex, block2a.Miner)
+	miner2, _ := permissioned.SelectMiner(block1a)
+	require.Equal(t, miner2, block2a.Miner)
 	require.NotNil(t, block2a.GetTxn(txn2.ID))
 }
 
@@ -382,7 +405,7 @@ func Test_GP_BC_Late_Joing(t *testing.T) {
 		},
 		1, "2h", 1, 1,
 	)
-	initialGain := map[string]float64{
+	initialGain := map[string]permissioned.Amount{
 		addr1.Hex: 100,
 		addr2.Hex: 0,
 	}
@@ -400,7 +423,7 @@ func Test_GP_BC_Late_Joing(t *testing.T) {
 
 	// > send Tx to nodeA. need to succeed
 
-	txn1 := permissioned.NewTransactionRegAssets(account1, map[string]float64{
+	txn1 := permissioned.NewTransactionRegAssets(account1, map[string]permissioned.Amount{
 		"key1": 1,
 	})
 	require.Equal(t, addr1.Hex, txn1.From)
@@ -438,7 +461,7 @@ func Test_GP_BC_Late_Joing(t *testing.T) {
 
 	// > send Tx to nodeA. need to succeed
 
-	txn2 := permissioned.NewTransactionRegAssets(account1, map[string]float64{
+	txn2 := permissioned.NewTransactionRegAssets(account1, map[string]permissioned.Amount{
 		"key1": 1,
 	})
 	require.Equal(t, addr1.Hex, txn2.From)
@@ -525,7 +548,7 @@ func Test_GP_BC_Consensus_Equal_Credit(t *testing.T) {
 			addrC.Hex: "",
 		}, 1, "2s", 1, 1,
 	)
-	initialGain := map[string]float64{
+	initialGain := map[string]permissioned.Amount{
 		addrA.Hex: 100,
 		addrB.Hex: 100,
 		addrC.Hex: 100,
@@ -551,7 +574,7 @@ func Test_GP_BC_Consensus_Equal_Credit(t *testing.T) {
 
 	accountA := permissioned.NewAccount(addrA)
 	txn1 := permissioned.NewTransactionRegAssets(accountA,
-		map[string]float64{
+		map[string]permissioned.Amount{
 			"key1": 1,
 		})
 	require.Equal(t, addrA.Hex, txn1.From)
@@ -726,19 +749,1356 @@ func Test_GP_BC_Set_Get_Assets(t *testing.T) {
 	price1, ok := priceMap[addr1.Hex]
 	require.True(t, ok)
 	require.Len(t, price1, 1)
-	require.Equal(t, float64(1), price1["a"])
+	require.Equal(t, permissioned.Amount(1), price1["a"])
 	price2, ok := priceMap[addr2.Hex]
 	require.True(t, ok)
 	require.Len(t, price2, 1)
-	require.Equal(t, float64(2), price2["b"])
+	require.Equal(t, permissioned.Amount(2), price2["b"])
 
 	priceMap = node2.GetAllPeerAssetPrices()
 	price1, ok = priceMap[addr1.Hex]
 	require.True(t, ok)
 	require.Len(t, price1, 1)
-	require.Equal(t, float64(1), price1["a"])
+	require.Equal(t, permissioned.Amount(1), price1["a"])
 	price2, ok = priceMap[addr2.Hex]
 	require.True(t, ok)
 	require.Len(t, price2, 1)
-	require.Equal(t, float64(2), price2["b"])
+	require.Equal(t, permissioned.Amount(2), price2["b"])
+}
+
+func Test_GP_BC_Update_Remove_Transfer_Assets(t *testing.T) {
+	zerolog.SetGlobalLevel(zerolog.ErrorLevel)
+
+	transp := channel.NewTransport()
+
+	node1 := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0")
+	defer node1.Stop()
+
+	node2 := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0")
+	defer node2.Stop()
+
+	node1.AddPeer(node2.GetAddr())
+
+	// generate key pairs
+
+	privkey1, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	node1.BCSetKeyPair(*privkey1)
+	addr1, err := node1.BCGetAddress()
+	require.NoError(t, err)
+
+	privkey2, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	node2.BCSetKeyPair(*privkey2)
+	addr2, err := node2.BCGetAddress()
+	require.NoError(t, err)
+
+	// > init blockchain on node1, encryption keys are announced in block 1
+
+	config := permissioned.NewChainConfig(
+		map[string]string{
+			addr1.Hex: "",
+			addr2.Hex: "",
+		},
+		2, "2h", 1, 1,
+	)
+	err = node1.InitBlockchain(*config, nil)
+	require.NoError(t, err)
+
+	time.Sleep(time.Millisecond * 200)
+	require.True(t, node1.BCAllEncryptKeySet())
+
+	// set asssets
+
+	err = node1.SetValueDBAsset("a", 1, 1)
+	require.NoError(t, err)
+	err = node2.SetValueDBAsset("b", 2, 2)
+	require.NoError(t, err)
+
+	time.Sleep(time.Millisecond * 500)
+
+	// > transfer a to node2 and update price of b
+
+	err = node1.TransferAsset("a", addr2.Hex)
+	require.NoError(t, err)
+	err = node2.UpdateAssetPrice("b", 5)
+	require.NoError(t, err)
+
+	time.Sleep(time.Millisecond * 500)
+
+	blk1 := node1.BCGetLatestBlock()
+	require.Equal(t, uint(3), blk1.Height)
+	require.Equal(t, blk1.Hash(), node2.BCGetLatestBlock().Hash())
+
+	priceMap := node1.GetAllPeerAssetPrices()
+	require.NotContains(t, priceMap, addr1.Hex)
+	require.Equal(t, map[string]permissioned.Amount{"a": 1, "b": 5}, priceMap[addr2.Hex])
+
+	// > the value of a moved from node1 to node2
+
+	err = node1.RemoveValueDBAsset("a")
+	require.Error(t, err)
+
+	err = node2.RemoveValueDBAsset("a")
+	require.NoError(t, err)
+	err = node2.UpdateAssetPrice("b", 6)
+	require.NoError(t, err)
+
+	time.Sleep(time.Millisecond * 500)
+
+	// > a is removed from chain and from the ValueDB of node2
+
+	priceMap = node2.GetAllPeerAssetPrices()
+	require.Equal(t, map[string]permissioned.Amount{"b": 6}, priceMap[addr2.Hex])
+	err = node2.RemoveValueDBAsset("a")
+	require.Error(t, err)
+}
+
+func Test_GP_BC_Search_Assets(t *testing.T) {
+	zerolog.SetGlobalLevel(zerolog.ErrorLevel)
+
+	transp := channel.NewTransport()
+
+	node1 := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0", z.WithDisableAnnonceEnckey())
+	defer node1.Stop()
+
+	node2 := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0", z.WithDisableAnnonceEnckey())
+	defer node2.Stop()
+
+	node1.AddPeer(node2.GetAddr())
+
+	// generate key pairs
+
+	privkey1, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	node1.BCSetKeyPair(*privkey1)
+	addr1, err := node1.BCGetAddress()
+	require.NoError(t, err)
+
+	privkey2, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	node2.BCSetKeyPair(*privkey2)
+	addr2, err := node2.BCGetAddress()
+	require.NoError(t, err)
+
+	config := permissioned.NewChainConfig(
+		map[string]string{
+			addr1.Hex: "",
+			addr2.Hex: "",
+		},
+		2, "2h", 1, 1,
+	)
+	err = node1.InitBlockchain(*config, nil)
+	require.NoError(t, err)
+
+	time.Sleep(time.Millisecond * 200)
+
+	// > value out of the schema range should fail
+
+	schema := permissioned.AssetSchema{
+		Description: "Body temperature",
+		Unit:        "celsius",
+		Bounded:     true,
+		Min:         30,
+		Max:         45,
+		Tags:        []string{"health"},
+	}
+	err = node1.SetValueDBAssetWithSchema("temp", 50, 1, &schema)
+	require.Error(t, err)
+
+	// set asssets
+
+	err = node1.SetValueDBAssetWithSchema("temp", 37, 1, &schema)
+	require.NoError(t, err)
+	err = node2.SetValueDBAsset("b", 2, 2)
+	require.NoError(t, err)
+
+	time.Sleep(time.Millisecond * 500)
+
+	// > both nodes can search the schema
+
+	for _, node := range []*z.TestNode{&node1, &node2} {
+		require.Len(t, node.BCSearchAssets(""), 2)
+
+		infos := node.BCSearchAssets("health")
+		require.Len(t, infos, 1)
+		require.Equal(t, "temp", infos[0].Key)
+		require.Equal(t, addr1.Hex, infos[0].Owner)
+		require.Equal(t, permissioned.Amount(1), infos[0].Price)
+		require.Equal(t, "celsius", infos[0].Schema.Unit)
+	}
+}
+
+func Test_GP_BC_Restart_Recovery(t *testing.T) {
+	zerolog.SetGlobalLevel(zerolog.ErrorLevel)
+
+	transp := channel.NewTransport()
+
+	node1 := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0", z.WithDisableAnnonceEnckey())
+	defer node1.Stop()
+
+	storage2 := inmemory.NewPersistency()
+	node2 := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0", z.WithDisableAnnonceEnckey(),
+		z.WithStorage(storage2))
+
+	node1.AddPeer(node2.GetAddr())
+
+	// generate key pairs
+
+	privkey1, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	node1.BCSetKeyPair(*privkey1)
+	addr1, err := node1.BCGetAddress()
+	require.NoError(t, err)
+
+	privkey2, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	node2.BCSetKeyPair(*privkey2)
+	addr2, err := node2.BCGetAddress()
+	require.NoError(t, err)
+
+	config := permissioned.NewChainConfig(
+		map[string]string{
+			addr1.Hex: "",
+			addr2.Hex: "",
+		},
+		2, "2h", 1, 1,
+	)
+	err = node1.InitBlockchain(*config, map[string]permissioned.Amount{
+		addr1.Hex: 100,
+		addr2.Hex: 50,
+	})
+	require.NoError(t, err)
+
+	time.Sleep(time.Millisecond * 200)
+
+	err = node1.SetValueDBAsset("temp", 37, 1)
+	require.NoError(t, err)
+	err = node1.SetValueDBAsset("pulse", 60, 2)
+	require.NoError(t, err)
+
+	time.Sleep(time.Millisecond * 500)
+
+	latestBlock := node2.BCGetLatestBlock()
+	require.Equal(t, uint(1), latestBlock.Height)
+	require.NoError(t, node2.Stop())
+
+	// > the restarted node resumes from its storage
+
+	node2 = z.NewTestNode(t, peerFac, transp, "127.0.0.1:0", z.WithDisableAnnonceEnckey(),
+		z.WithStorage(storage2))
+	defer node2.Stop()
+	node2.BCSetKeyPair(*privkey2)
+
+	restoredBlock := node2.BCGetLatestBlock()
+	require.NotNil(t, restoredBlock)
+	require.Equal(t, latestBlock.Hash(), restoredBlock.Hash())
+	require.Equal(t, permissioned.Amount(50), node2.BCGetBalance())
+
+	infos := node2.BCSearchAssets("temp")
+	require.Len(t, infos, 1)
+	require.Equal(t, addr1.Hex, infos[0].Owner)
+}
+
+func Test_GP_BC_Sync_Late_Node(t *testing.T) {
+	zerolog.SetGlobalLevel(zerolog.ErrorLevel)
+
+	transp := channel.NewTransport()
+
+	node1 := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0", z.WithDisableAnnonceEnckey())
+	defer node1.Stop()
+
+	node2 := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0", z.WithDisableAnnonceEnckey())
+	defer node2.Stop()
+
+	node3 := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0", z.WithDisableAnnonceEnckey())
+	defer node3.Stop()
+
+	node1.AddPeer(node2.GetAddr())
+	node2.AddPeer(node1.GetAddr())
+
+	// generate key pairs
+
+	privkey1, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	node1.BCSetKeyPair(*privkey1)
+	addr1, err := node1.BCGetAddress()
+	require.NoError(t, err)
+
+	privkey2, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	node2.BCSetKeyPair(*privkey2)
+	addr2, err := node2.BCGetAddress()
+	require.NoError(t, err)
+
+	privkey3, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	node3.BCSetKeyPair(*privkey3)
+	addr3, err := node3.BCGetAddress()
+	require.NoError(t, err)
+
+	config := permissioned.NewChainConfig(
+		map[string]string{
+			addr1.Hex: "",
+			addr2.Hex: "",
+			addr3.Hex: "",
+		},
+		1, "2h", 1, 1,
+	)
+	err = node1.InitBlockchain(*config, map[string]permissioned.Amount{
+		addr1.Hex: 1000,
+		addr2.Hex: 500,
+	})
+	require.NoError(t, err)
+
+	time.Sleep(time.Millisecond * 200)
+
+	// > node1 and node2 build 100 blocks without node3
+
+	for i := uint(1); i <= 100; i++ {
+		_, err = node1.BCStake(1)
+		require.NoError(t, err)
+		waitHeight(t, &node1, i)
+		waitHeight(t, &node2, i)
+	}
+	require.Nil(t, node3.BCGetLatestBlock())
+
+	// > node3 syncs from both nodes
+
+	node3.AddPeer(node1.GetAddr(), node2.GetAddr())
+
+	err = node3.BCSync()
+	require.NoError(t, err)
+
+	block1 := node1.BCGetLatestBlock()
+	block3 := node3.BCGetLatestBlock()
+	require.NotNil(t, block3)
+	require.Equal(t, block1.Hash(), block3.Hash())
+	require.Equal(t, block1.States.Hash(), block3.States.Hash())
+
+	// > node3 follows the chain once synced
+
+	node1.AddPeer(node3.GetAddr())
+	node2.AddPeer(node3.GetAddr())
+
+	_, err = node1.BCStake(1)
+	require.NoError(t, err)
+	waitHeight(t, &node3, 101)
+
+	block1 = node1.BCGetLatestBlock()
+	block3 = node3.BCGetLatestBlock()
+	require.Equal(t, block1.Hash(), block3.Hash())
+
+	// > a synced node has nothing to fetch
+
+	err = node3.BCSync()
+	require.NoError(t, err)
+	require.Equal(t, block1.Hash(), node3.BCGetLatestBlock().Hash())
+}
+
+func Test_GP_BC_Finality_Quorum(t *testing.T) {
+	zerolog.SetGlobalLevel(zerolog.ErrorLevel)
+
+	transp := channel.NewTransport()
+
+	node1 := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0", z.WithDisableAnnonceEnckey())
+	defer node1.Stop()
+
+	node2 := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0", z.WithDisableAnnonceEnckey())
+	defer node2.Stop()
+
+	node3 := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0", z.WithDisableAnnonceEnckey())
+	defer node3.Stop()
+
+	node1.AddPeer(node2.GetAddr(), node3.GetAddr())
+	node2.AddPeer(node1.GetAddr(), node3.GetAddr())
+	node3.AddPeer(node1.GetAddr(), node2.GetAddr())
+
+	// generate key pairs. The 4th participant never comes online
+
+	nodes := []*z.TestNode{&node1, &node2, &node3}
+	participants := map[string]string{}
+	for _, node := range nodes {
+		privkey, err := crypto.GenerateKey()
+		require.NoError(t, err)
+		(*node).BCSetKeyPair(*privkey)
+		addr, err := (*node).BCGetAddress()
+		require.NoError(t, err)
+		participants[addr.Hex] = ""
+	}
+	privkey4, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	participants[permissioned.NewAddress(&privkey4.PublicKey).Hex] = ""
+
+	addr1, err := node1.BCGetAddress()
+	require.NoError(t, err)
+
+	config := permissioned.NewChainConfig(participants, 1, "2h", 1, 1)
+	config.Finality = true
+	err = node1.InitBlockchain(*config, map[string]permissioned.Amount{
+		addr1.Hex: 1000,
+	})
+	require.NoError(t, err)
+
+	time.Sleep(time.Millisecond * 200)
+
+	for _, node := range nodes {
+		genesis := (*node).BCGetLatestBlock()
+		require.NotNil(t, genesis)
+		require.Equal(t, genesis.Hash(), (*node).BCGetFinalBlock().Hash())
+	}
+
+	// > 3 votes out of 4 participants finalize the blocks
+
+	for i := uint(1); i <= 3; i++ {
+		_, err = node1.BCStake(1)
+		require.NoError(t, err)
+		for _, node := range nodes {
+			waitHeight(t, node, i)
+		}
+	}
+
+	timeout := time.After(time.Second * 5)
+	for _, node := range nodes {
+		for (*node).BCGetFinalBlock().Height < 3 {
+			select {
+			case <-timeout:
+				t.Fatalf("node %s did not finalize height 3", (*node).GetAddr())
+			case <-time.After(time.Millisecond * 20):
+			}
+		}
+		require.Equal(t, node1.BCGetLatestBlock().Hash(), (*node).BCGetFinalBlock().Hash())
+	}
+}
+
+// waitHeight waits until the node's chain reaches the height
+func waitHeight(t *testing.T, node *z.TestNode, height uint) {
+	timeout := time.After(time.Second * 20)
+	for {
+		block := node.BCGetLatestBlock()
+		if block != nil && block.Height >= height {
+			return
+		}
+
+		select {
+		case <-timeout:
+			if block == nil {
+				t.Fatalf("node %s did not reach height %d: no block", node.GetAddr(), height)
+			}
+			t.Fatalf("node %s did not reach height %d: %v", node.GetAddr(), height, block.Height)
+		case <-time.After(time.Millisecond * 20):
+		}
+	}
+}
+
+func Test_GP_BC_Light_Node(t *testing.T) {
+	zerolog.SetGlobalLevel(zerolog.ErrorLevel)
+
+	transp := channel.NewTransport()
+
+	nodeA := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0", z.WithDisableAnnonceEnckey())
+	defer nodeA.Stop()
+
+	nodeL := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0", z.WithDisableAnnonceEnckey(),
+		z.WithLightChain())
+	defer nodeL.Stop()
+
+	nodeA.AddPeer(nodeL.GetAddr())
+	nodeL.AddPeer(nodeA.GetAddr())
+
+	privkeyA, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	nodeA.BCSetKeyPair(*privkeyA)
+	addrA, err := nodeA.BCGetAddress()
+	require.NoError(t, err)
+
+	privkeyL, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	nodeL.BCSetKeyPair(*privkeyL)
+	addrL, err := nodeL.BCGetAddress()
+	require.NoError(t, err)
+
+	// > init blockchain on nodeA with nodeL as light node
+
+	config := permissioned.NewChainConfig(
+		map[string]string{
+			addrA.Hex: "",
+			addrL.Hex: "",
+		},
+		1, "2h", 1, 1,
+	)
+	config.LightNodes = []string{addrL.Hex}
+	err = nodeA.InitBlockchain(*config, map[string]permissioned.Amount{
+		addrA.Hex: 10,
+		addrL.Hex: 100,
+	})
+	require.NoError(t, err)
+
+	time.Sleep(time.Millisecond * 500)
+
+	block0 := nodeA.BCGetLatestBlock()
+	require.NotNil(t, block0)
+	require.Equal(t, block0.Hash(), nodeL.BCWaitBlock().Hash())
+
+	// > the light node sends a txn, mined by the full node although
+	// the light node holds more
+
+	txnID, err := nodeL.BCStake(30)
+	require.NoError(t, err)
+
+	time.Sleep(time.Second * 1)
+
+	block1 := nodeA.BCGetLatestBlock()
+	require.Equal(t, uint(1), block1.Height)
+	require.Equal(t, addrA.Hex, block1.Miner)
+	require.NotNil(t, block1.GetTxn(txnID))
+
+	// > the light node only has the header
+
+	header1 := nodeL.BCGetLatestBlock()
+	require.NotNil(t, header1)
+	require.Equal(t, block1.Hash(), header1.Hash())
+	require.Nil(t, header1.States)
+	require.Len(t, header1.Transactions, 0)
+	require.NotNil(t, nodeL.BCGetBlock(block0.Hash()).States)
+
+	// > values are proven by the full node
+
+	require.True(t, nodeL.BCHasTransaction(txnID))
+	require.False(t, nodeL.BCHasTransaction("unknown"))
+	require.Equal(t, permissioned.Amount(70), nodeL.BCGetBalance())
+	require.Equal(t, permissioned.Amount(30), nodeL.BCGetStake())
+
+	txnProof, err := nodeL.BCGetTxnProof(txnID)
+	require.NoError(t, err)
+	require.Equal(t, block1.Hash(), txnProof.Header.Hash())
+
+	accountProof, err := nodeL.BCGetAccountProof(addrA.Hex)
+	require.NoError(t, err)
+	account, err := accountProof.GetAccount()
+	require.NoError(t, err)
+	require.Equal(t, permissioned.Amount(10), account.GetBalance())
+
+	// > a new light node syncs the headers only
+
+	nodeM := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0", z.WithDisableAnnonceEnckey(),
+		z.WithLightChain())
+	defer nodeM.Stop()
+
+	nodeM.AddPeer(nodeA.GetAddr())
+	nodeA.AddPeer(nodeM.GetAddr())
+
+	err = nodeM.BCSync()
+	require.NoError(t, err)
+	require.Equal(t, block1.Hash(), nodeM.BCGetLatestBlock().Hash())
+	require.True(t, nodeM.BCHasTransaction(txnID))
+}
+
+// with finality, a light node only trusts the values proven on the headers
+// the validators voted for
+func Test_GP_BC_Light_Node_Finality(t *testing.T) {
+	zerolog.SetGlobalLevel(zerolog.ErrorLevel)
+
+	transp := channel.NewTransport()
+
+	nodeA := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0", z.WithDisableAnnonceEnckey())
+	defer nodeA.Stop()
+
+	nodeL := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0", z.WithDisableAnnonceEnckey(),
+		z.WithLightChain())
+	defer nodeL.Stop()
+
+	nodeA.AddPeer(nodeL.GetAddr())
+	nodeL.AddPeer(nodeA.GetAddr())
+
+	privkeyA, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	nodeA.BCSetKeyPair(*privkeyA)
+	addrA, err := nodeA.BCGetAddress()
+	require.NoError(t, err)
+
+	privkeyL, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	nodeL.BCSetKeyPair(*privkeyL)
+	addrL, err := nodeL.BCGetAddress()
+	require.NoError(t, err)
+
+	config := permissioned.NewChainConfig(
+		map[string]string{
+			addrA.Hex: "",
+			addrL.Hex: "",
+		},
+		1, "2h", 1, 1,
+	)
+	config.LightNodes = []string{addrL.Hex}
+	config.Finality = true
+	err = nodeA.InitBlockchain(*config, map[string]permissioned.Amount{
+		addrA.Hex: 10,
+		addrL.Hex: 100,
+	})
+	require.NoError(t, err)
+
+	time.Sleep(time.Millisecond * 500)
+	require.NotNil(t, nodeL.BCWaitBlock())
+
+	// > the vote of the only validator makes the header final on the
+	// light node, the values are proven after it
+
+	txnID, err := nodeL.BCStake(30)
+	require.NoError(t, err)
+
+	time.Sleep(time.Second * 1)
+
+	block1 := nodeA.BCGetLatestBlock()
+	require.Equal(t, uint(1), block1.Height)
+	require.Equal(t, block1.Hash(), nodeA.BCGetFinalBlock().Hash())
+	require.Equal(t, block1.Hash(), nodeL.BCGetFinalBlock().Hash())
+	require.True(t, nodeL.BCHasTransaction(txnID))
+	require.Equal(t, permissioned.Amount(70), nodeL.BCGetBalance())
+
+	accountProof, err := nodeL.BCGetAccountProof(addrL.Hex)
+	require.NoError(t, err)
+	require.Equal(t, block1.Hash(), accountProof.Header.Hash())
+}
+
+// a light node follows the chain after the only validator rotates its key
+func Test_GP_BC_Light_Node_Key_Rotation(t *testing.T) {
+	zerolog.SetGlobalLevel(zerolog.ErrorLevel)
+
+	transp := channel.NewTransport()
+
+	nodeA := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0", z.WithDisableAnnonceEnckey())
+	defer nodeA.Stop()
+
+	nodeL := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0", z.WithDisableAnnonceEnckey(),
+		z.WithLightChain())
+	defer nodeL.Stop()
+
+	nodeA.AddPeer(nodeL.GetAddr())
+	nodeL.AddPeer(nodeA.GetAddr())
+
+	privkeyA, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	nodeA.BCSetKeyPair(*privkeyA)
+	addrA, err := nodeA.BCGetAddress()
+	require.NoError(t, err)
+
+	privkeyL, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	nodeL.BCSetKeyPair(*privkeyL)
+	addrL, err := nodeL.BCGetAddress()
+	require.NoError(t, err)
+
+	config := permissioned.NewChainConfig(
+		map[string]string{
+			addrA.Hex: "",
+			addrL.Hex: "",
+		},
+		1, "2h", 1, 1,
+	)
+	config.LightNodes = []string{addrL.Hex}
+	err = nodeA.InitBlockchain(*config, map[string]permissioned.Amount{
+		addrA.Hex: 100,
+	})
+	require.NoError(t, err)
+
+	time.Sleep(time.Millisecond * 500)
+
+	// > the validator rotates its key, then mines with the new one
+
+	rotatedKey, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	rotatedAddr := permissioned.NewAddress(&rotatedKey.PublicKey).Hex
+	_, err = nodeA.BCRotateKey(*rotatedKey)
+	require.NoError(t, err)
+	waitHeight(t, &nodeA, 1)
+
+	time.Sleep(time.Millisecond * 500)
+
+	txnID, err := nodeA.BCStake(10)
+	require.NoError(t, err)
+	waitHeight(t, &nodeA, 2)
+
+	block2 := nodeA.BCGetLatestBlock()
+	require.Equal(t, rotatedAddr, block2.Miner)
+	require.NotNil(t, block2.GetTxn(txnID))
+
+	// > the light node proves the new validator and keeps following
+
+	timeout := time.After(time.Second * 5)
+	for nodeL.BCGetLatestBlock().Hash() != block2.Hash() {
+		select {
+		case <-timeout:
+			t.Fatalf("light node did not follow the rotated validator")
+		case <-time.After(time.Millisecond * 20):
+		}
+	}
+	require.True(t, nodeL.BCHasTransaction(txnID))
+}
+
+func Test_GP_BC_Txn_Receipts(t *testing.T) {
+	zerolog.SetGlobalLevel(zerolog.ErrorLevel)
+
+	transp := channel.NewTransport()
+
+	node1 := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0", z.WithDisableAnnonceEnckey())
+	defer node1.Stop()
+
+	privkey1, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	node1.BCSetKeyPair(*privkey1)
+	addr1, err := node1.BCGetAddress()
+	require.NoError(t, err)
+
+	privkey2, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	addr2 := permissioned.NewAddress(&privkey2.PublicKey)
+
+	config := permissioned.NewChainConfig(
+		map[string]string{
+			addr1.Hex: "",
+			addr2.Hex: "",
+		},
+		1, "2h", 1, 1,
+	)
+	err = node1.InitBlockchain(*config, map[string]permissioned.Amount{
+		addr1.Hex: 100,
+	})
+	require.NoError(t, err)
+
+	time.Sleep(time.Millisecond * 500)
+
+	// > a valid txn is pending then included
+
+	txnID, err := node1.BCStake(10)
+	require.NoError(t, err)
+
+	receipt, err := node1.BCGetReceipt(txnID)
+	require.NoError(t, err)
+	require.Equal(t, txnID, receipt.TxnID)
+	require.Equal(t, permissioned.TxnTypeStake, receipt.Type)
+
+	time.Sleep(time.Second * 1)
+
+	block1 := node1.BCGetLatestBlock()
+	require.Equal(t, uint(1), block1.Height)
+
+	receipt, err = node1.BCGetReceipt(txnID)
+	require.NoError(t, err)
+	require.Equal(t, permissioned.TxnStatusIncluded, receipt.Status)
+	require.Equal(t, block1.Hash(), receipt.BlockHash)
+	require.Equal(t, uint(1), receipt.Height)
+	require.Equal(t, 0, receipt.Index)
+	require.Empty(t, receipt.Error)
+
+	// > an invalid txn is rejected with the reason
+
+	txnID, err = node1.BCStake(1000)
+	require.NoError(t, err)
+
+	time.Sleep(time.Second * 1)
+
+	// the wallet fills the nonce of the rejected txn with a cancel txn
+	require.NotEqual(t, block1.Hash(), node1.BCGetLatestBlock().Hash())
+	require.False(t, node1.BCHasTransaction(txnID))
+
+	receipt, err = node1.BCGetReceipt(txnID)
+	require.NoError(t, err)
+	require.Equal(t, permissioned.TxnStatusRejected, receipt.Status)
+	require.NotEmpty(t, receipt.Error)
+	require.Empty(t, receipt.BlockHash)
+
+	// > only the txns sent by the node have a receipt
+
+	_, err = node1.BCGetReceipt("unknown")
+	require.Error(t, err)
+}
+
+func Test_GP_BC_Wallet_Nonce_Gap(t *testing.T) {
+	zerolog.SetGlobalLevel(zerolog.ErrorLevel)
+
+	transp := channel.NewTransport()
+
+	node1 := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0", z.WithDisableAnnonceEnckey())
+	defer node1.Stop()
+
+	privkey1, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	node1.BCSetKeyPair(*privkey1)
+	addr1, err := node1.BCGetAddress()
+	require.NoError(t, err)
+
+	config := permissioned.NewChainConfig(
+		map[string]string{
+			addr1.Hex: "",
+		},
+		1, "2h", 1, 1,
+	)
+	err = node1.InitBlockchain(*config, map[string]permissioned.Amount{
+		addr1.Hex: 100,
+	})
+	require.NoError(t, err)
+
+	time.Sleep(time.Millisecond * 500)
+
+	// > the first txn is rejected. The second one must not wait forever
+	// for its nonce
+
+	rejectedID, err := node1.BCStake(1000)
+	require.NoError(t, err)
+	txnID, err := node1.BCStake(10)
+	require.NoError(t, err)
+
+	time.Sleep(time.Second * 2)
+
+	receipt, err := node1.BCGetReceipt(rejectedID)
+	require.NoError(t, err)
+	require.Equal(t, permissioned.TxnStatusRejected, receipt.Status)
+
+	receipt, err = node1.BCGetReceipt(txnID)
+	require.NoError(t, err)
+	require.Equal(t, permissioned.TxnStatusIncluded, receipt.Status)
+	require.Equal(t, uint(2), receipt.Height)
+	require.Equal(t, permissioned.Amount(10), node1.BCGetStake())
+
+	// > the nonce of the rejected txn is used by a cancel txn
+	block1 := node1.BCGetBlock(node1.BCGetLatestBlock().PrevHash)
+	require.Len(t, block1.Transactions, 1)
+	require.Equal(t, permissioned.TxnTypeCancel, block1.Transactions[0].Txn.Type)
+}
+
+func Test_GP_BC_Multisig(t *testing.T) {
+	zerolog.SetGlobalLevel(zerolog.ErrorLevel)
+
+	transp := channel.NewTransport()
+
+	node1 := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0")
+	defer node1.Stop()
+
+	node2 := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0")
+	defer node2.Stop()
+
+	node1.AddPeer(node2.GetAddr())
+
+	// generate key pairs
+
+	privkey1, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	node1.BCSetKeyPair(*privkey1)
+	addr1, err := node1.BCGetAddress()
+	require.NoError(t, err)
+
+	privkey2, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	node2.BCSetKeyPair(*privkey2)
+	addr2, err := node2.BCGetAddress()
+	require.NoError(t, err)
+
+	config := permissioned.NewChainConfig(
+		map[string]string{
+			addr1.Hex: "",
+			addr2.Hex: "",
+		},
+		1, "2h", 1, 1,
+	)
+	err = node1.InitBlockchain(*config, map[string]permissioned.Amount{
+		addr1.Hex: 100,
+	})
+	require.NoError(t, err)
+
+	time.Sleep(time.Millisecond * 200)
+
+	// > node1 creates a 2-of-2 account with node2 and funds it
+
+	multisigAddr, err := node1.BCRegMultisig(permissioned.MultisigPolicy{
+		Owners:    []string{addr1.Hex, addr2.Hex},
+		Threshold: 2,
+	}, 20)
+	require.NoError(t, err)
+
+	time.Sleep(time.Second)
+
+	worldState := node2.BCGetLatestBlock().States
+	require.True(t, permissioned.IsMultisig(worldState, multisigAddr.Hex))
+	account := permissioned.GetAccountFromWorldState(worldState, multisigAddr.Hex)
+	require.Equal(t, permissioned.Amount(20), account.GetBalance())
+	require.Equal(t, permissioned.Amount(80), node1.BCGetBalance())
+
+	// > the account pays an MPC on the assets of the owners. They sign
+	// off-chain, then node2 sends it
+
+	err = node1.SetValueDBAsset("a", 3, 1)
+	require.NoError(t, err)
+	err = node2.SetValueDBAsset("b", 4, 2)
+	require.NoError(t, err)
+
+	time.Sleep(time.Second)
+
+	signedTxn, err := permissioned.NewTransactionPreMPC(account, permissioned.MPCPropose{
+		Initiator:  multisigAddr.Hex,
+		Budget:     5,
+		Expression: "a+b",
+		Prime:      "1000000009",
+	}).Sign(nil)
+	require.NoError(t, err)
+	require.NoError(t, node1.BCCosignTransaction(signedTxn))
+	require.NoError(t, node2.BCCosignTransaction(signedTxn))
+	require.NoError(t, node2.BCSendTransaction(signedTxn))
+
+	// > the committee computes it and both members are paid from the
+	// locked budget
+
+	timeout := time.After(time.Second * 10)
+	for {
+		worldState = node1.BCGetLatestBlock().States
+		account = permissioned.GetAccountFromWorldState(worldState, multisigAddr.Hex)
+		if node1.BCHasTransaction(signedTxn.Txn.ID) && account.GetLockedBalance() == 0 {
+			break
+		}
+
+		select {
+		case <-timeout:
+			t.Fatalf("MPC %s of the multisig account was not endorsed", signedTxn.Txn.ID)
+		case <-time.After(time.Millisecond * 100):
+		}
+	}
+	require.Equal(t, permissioned.Amount(15), account.GetBalance())
+	require.Equal(t, permissioned.Amount(0), account.GetLockedBalance())
+
+	// > the account registers an asset whose value node2 holds
+
+	require.NoError(t, node2.HoldValueDBAsset("org", 7))
+	signedTxn, err = permissioned.NewTransactionRegAssetsWithDetails(account,
+		permissioned.AssetsRegistration{
+			Assets: map[string]permissioned.Amount{"org": 3},
+			Holder: addr2.Hex,
+		}).Sign(nil)
+	require.NoError(t, err)
+	require.NoError(t, node1.BCCosignTransaction(signedTxn))
+	require.NoError(t, node2.BCCosignTransaction(signedTxn))
+	require.NoError(t, node2.BCSendTransaction(signedTxn))
+
+	time.Sleep(time.Second)
+
+	require.True(t, node1.BCHasTransaction(signedTxn.Txn.ID))
+	require.Equal(t, permissioned.Amount(3), node1.GetAllPeerAssetPrices()[multisigAddr.Hex]["org"])
+
+	// > node2 inputs the value in MPCs and the price goes to the account
+
+	result, err := node1.Calculate("a+org", 10)
+	require.NoError(t, err)
+	require.Equal(t, 10, result)
+
+	time.Sleep(time.Second)
+
+	worldState = node1.BCGetLatestBlock().States
+	account = permissioned.GetAccountFromWorldState(worldState, multisigAddr.Hex)
+	require.Equal(t, permissioned.Amount(18), account.GetBalance())
+
+	// > the account transfers the asset to node1. node2 encrypts the value
+	// for node1, the owners cosign the transfer
+
+	transfer, err := node2.NewAssetTransfer("org", addr1.Hex)
+	require.NoError(t, err)
+	signedTxn, err = permissioned.NewTransactionTransferAsset(account, transfer).Sign(nil)
+	require.NoError(t, err)
+	require.NoError(t, node1.BCCosignTransaction(signedTxn))
+	require.NoError(t, node2.BCCosignTransaction(signedTxn))
+	require.NoError(t, node1.BCSendTransaction(signedTxn))
+
+	time.Sleep(time.Second)
+
+	require.True(t, node1.BCHasTransaction(signedTxn.Txn.ID))
+	result, err = node1.Calculate("org", 10)
+	require.NoError(t, err)
+	require.Equal(t, 7, result)
+}
+
+func Test_GP_BC_Rotate_Revoke_Key(t *testing.T) {
+	zerolog.SetGlobalLevel(zerolog.ErrorLevel)
+
+	transp := channel.NewTransport()
+
+	node1 := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0")
+	defer node1.Stop()
+
+	node2 := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0")
+	defer node2.Stop()
+
+	node1.AddPeer(node2.GetAddr())
+
+	// generate key pairs
+
+	privkey1, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	node1.BCSetKeyPair(*privkey1)
+	addr1, err := node1.BCGetAddress()
+	require.NoError(t, err)
+
+	privkey2, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	node2.BCSetKeyPair(*privkey2)
+	addr2, err := node2.BCGetAddress()
+	require.NoError(t, err)
+
+	config := permissioned.NewChainConfig(
+		map[string]string{
+			addr1.Hex: "",
+			addr2.Hex: "",
+		},
+		1, "2h", 1, 1,
+	)
+	err = node1.InitBlockchain(*config, map[string]permissioned.Amount{
+		addr1.Hex: 100,
+	})
+	require.NoError(t, err)
+
+	time.Sleep(time.Millisecond * 500)
+
+	// > the key cannot be replaced by another one
+
+	rotatedKey, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	require.Error(t, node1.BCSetKeyPair(*rotatedKey))
+
+	// > node1 rotates its key and keeps its balance and participant slot
+
+	_, err = node1.BCRotateKey(*rotatedKey)
+	require.NoError(t, err)
+
+	time.Sleep(time.Second)
+
+	rotatedAddr, err := node1.BCGetAddress()
+	require.NoError(t, err)
+	require.Equal(t, permissioned.NewAddress(&rotatedKey.PublicKey).Hex, rotatedAddr.Hex)
+	require.Equal(t, permissioned.Amount(100), node1.BCGetBalance())
+	participants := node2.BCGetLatestBlock().GetConfig().Participants
+	require.Contains(t, participants, rotatedAddr.Hex)
+	require.NotContains(t, participants, addr1.Hex)
+
+	// > the rotated account keeps sending txns
+
+	txnID, err := node1.BCStake(10)
+	require.NoError(t, err)
+
+	time.Sleep(time.Second)
+
+	require.True(t, node2.BCHasTransaction(txnID))
+	require.Equal(t, permissioned.Amount(10), node1.BCGetStake())
+
+	// > node2 revokes the rotated key, node1 then sets the new one
+
+	revokedKey, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	revokedAddr := permissioned.NewAddress(&revokedKey.PublicKey).Hex
+	_, err = node2.BCRevokeKey(permissioned.KeyRevocation{
+		Address:    rotatedAddr.Hex,
+		NewAddress: revokedAddr,
+	})
+	require.NoError(t, err)
+
+	time.Sleep(time.Second)
+
+	require.NoError(t, node1.BCSetKeyPair(*revokedKey))
+	addr, err := node1.BCGetAddress()
+	require.NoError(t, err)
+	require.Equal(t, revokedAddr, addr.Hex)
+	require.Equal(t, permissioned.Amount(90), node1.BCGetBalance())
+	require.Equal(t, permissioned.Amount(10), node1.BCGetStake())
+}
+
+func Test_GP_BC_Keystore(t *testing.T) {
+	zerolog.SetGlobalLevel(zerolog.ErrorLevel)
+
+	transp := channel.NewTransport()
+
+	node1 := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0")
+	defer node1.Stop()
+
+	node2 := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0")
+	defer node2.Stop()
+
+	node1.AddPeer(node2.GetAddr())
+
+	// node1 unlocks its identity from a keystore
+
+	keys, err := permissioned.NewKeystore()
+	require.NoError(t, err)
+	data, err := keys.Encrypt("passphrase", keystore.LightScryptN, keystore.LightScryptP)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "keystore.json")
+	require.NoError(t, os.WriteFile(path, data, 0600))
+
+	require.Error(t, node1.BCLoadKeystore(path, "wrong"))
+	require.NoError(t, node1.BCLoadKeystore(path, "passphrase"))
+	addr1, err := node1.BCGetAddress()
+	require.NoError(t, err)
+	require.Equal(t, keys.Address().Hex, addr1.Hex)
+
+	privkey2, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	node2.BCSetKeyPair(*privkey2)
+	addr2, err := node2.BCGetAddress()
+	require.NoError(t, err)
+
+	config := permissioned.NewChainConfig(
+		map[string]string{
+			addr1.Hex: "",
+			addr2.Hex: "",
+		},
+		1, "2h", 1, 1,
+	)
+	err = node1.InitBlockchain(*config, map[string]permissioned.Amount{})
+	require.NoError(t, err)
+
+	time.Sleep(time.Second)
+
+	// > the encryption key of the keystore is registered on chain
+
+	enckey, err := node1.GetPubkeyString()
+	require.NoError(t, err)
+	config = permissioned.GetConfigFromWorldState(node2.BCGetLatestBlock().States)
+	require.Equal(t, enckey, config.Participants[addr1.Hex])
+
+	// > another keystore of the account cannot replace the registered key
+
+	other, err := permissioned.NewKeystoreFromKey(keys.ChainKey)
+	require.NoError(t, err)
+	data, err = other.Encrypt("passphrase", keystore.LightScryptN, keystore.LightScryptP)
+	require.NoError(t, err)
+	path = filepath.Join(t.TempDir(), "other.json")
+	require.NoError(t, os.WriteFile(path, data, 0600))
+	require.Error(t, node1.BCLoadKeystore(path, "passphrase"))
+}
+
+func Test_GP_BC_Subscribe(t *testing.T) {
+	zerolog.SetGlobalLevel(zerolog.ErrorLevel)
+
+	transp := channel.NewTransport()
+
+	node1 := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0", z.WithDisableAnnonceEnckey())
+	defer node1.Stop()
+
+	node2 := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0", z.WithDisableAnnonceEnckey())
+	defer node2.Stop()
+
+	node1.AddPeer(node2.GetAddr())
+
+	// generate key pairs
+
+	privkey1, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	node1.BCSetKeyPair(*privkey1)
+	addr1, err := node1.BCGetAddress()
+	require.NoError(t, err)
+
+	privkey2, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	node2.BCSetKeyPair(*privkey2)
+	addr2, err := node2.BCGetAddress()
+	require.NoError(t, err)
+
+	// > node2 follows the stakes of node1 and all the blocks
+
+	_, stakes := node2.BCSubscribe(permissioned.EventFilter{
+		TxnTypes: []permissioned.TxnType{permissioned.TxnTypeStake},
+		Accounts: []string{addr1.Hex},
+	})
+	blocksID, blocks := node2.BCSubscribe(permissioned.EventFilter{
+		Types: []permissioned.EventType{permissioned.EventBlock},
+	})
+
+	config := permissioned.NewChainConfig(
+		map[string]string{
+			addr1.Hex: "",
+			addr2.Hex: "",
+		},
+		1, "2h", 1, 1,
+	)
+	err = node1.InitBlockchain(*config, map[string]permissioned.Amount{
+		addr1.Hex: 100,
+		addr2.Hex: 100,
+	})
+	require.NoError(t, err)
+
+	time.Sleep(time.Millisecond * 500)
+
+	_, err = node2.BCStake(10)
+	require.NoError(t, err)
+	txnID, err := node1.BCStake(20)
+	require.NoError(t, err)
+
+	time.Sleep(time.Second)
+
+	select {
+	case event := <-stakes:
+		require.Equal(t, permissioned.EventTxn, event.Type)
+		require.Equal(t, txnID, event.Txn.ID)
+		require.Greater(t, event.Height, uint(0))
+		require.NotEmpty(t, event.BlockHash)
+	default:
+		t.Fatal("no stake event")
+	}
+	require.Len(t, stakes, 0)
+
+	// > one event per block after the genesis, then closed
+
+	require.Len(t, blocks, 2)
+	event := <-blocks
+	require.Nil(t, event.Txn)
+	node2.BCUnsubscribe(blocksID)
+	<-blocks
+	_, ok := <-blocks
+	require.False(t, ok)
+}
+
+func Test_GP_BC_Export_Import_Audit(t *testing.T) {
+	zerolog.SetGlobalLevel(zerolog.ErrorLevel)
+
+	transp := channel.NewTransport()
+
+	node1 := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0", z.WithDisableAnnonceEnckey())
+	defer node1.Stop()
+
+	node2 := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0", z.WithDisableAnnonceEnckey())
+	defer node2.Stop()
+
+	node1.AddPeer(node2.GetAddr())
+
+	// generate key pairs
+
+	privkey1, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	node1.BCSetKeyPair(*privkey1)
+	addr1, err := node1.BCGetAddress()
+	require.NoError(t, err)
+
+	privkey2, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	node2.BCSetKeyPair(*privkey2)
+	addr2, err := node2.BCGetAddress()
+	require.NoError(t, err)
+
+	config := permissioned.NewChainConfig(
+		map[string]string{
+			addr1.Hex: "",
+			addr2.Hex: "",
+		},
+		1, "2h", 1, 1,
+	)
+	err = node1.InitBlockchain(*config, map[string]permissioned.Amount{
+		addr1.Hex: 100,
+		addr2.Hex: 100,
+	})
+	require.NoError(t, err)
+
+	time.Sleep(time.Millisecond * 500)
+
+	err = node1.SetValueDBAsset("a", 1, 1)
+	require.NoError(t, err)
+	_, err = node2.BCStake(30)
+	require.NoError(t, err)
+
+	time.Sleep(time.Second)
+
+	// > the chain leaves the node with its snapshots
+
+	path := filepath.Join(t.TempDir(), "chain")
+	err = node2.BCExportChain(path, true)
+	require.NoError(t, err)
+	buf, err := os.ReadFile(path)
+	require.NoError(t, err)
+	export, err := permissioned.DecodeExport(buf)
+	require.NoError(t, err)
+
+	latestBlock := node2.BCGetLatestBlock()
+	require.Equal(t, uint(2), latestBlock.Height)
+	require.Len(t, export.Blocks, 3)
+
+	// > the audit replays it
+
+	report := permissioned.Audit(export)
+	require.Empty(t, report.Anomalies)
+	require.Equal(t, latestBlock.Height, report.Height)
+	require.Equal(t, permissioned.Amount(200), report.Supply)
+	require.Equal(t, permissioned.Amount(30), report.Staked)
+
+	// > a fresh chain imports it
+
+	imported := permissioned.NewBlockchain()
+	err = imported.Import(export)
+	require.NoError(t, err)
+	require.Equal(t, latestBlock.Hash(), imported.GetLatestBlock().Hash())
+}
+
+func Test_GP_BC_Genesis_File(t *testing.T) {
+	zerolog.SetGlobalLevel(zerolog.ErrorLevel)
+
+	transp := channel.NewTransport()
+
+	node1 := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0", z.WithDisableAnnonceEnckey())
+	defer node1.Stop()
+
+	node2 := z.NewTestNode(t, peerFac, transp, "127.0.0.1:0", z.WithDisableAnnonceEnckey())
+	defer node2.Stop()
+
+	// the genesis is not broadcast, nodes must know each other
+	node1.AddPeer(node2.GetAddr())
+	node2.AddPeer(node1.GetAddr())
+
+	// generate key pairs
+
+	privkey1, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	node1.BCSetKeyPair(*privkey1)
+	addr1, err := node1.BCGetAddress()
+	require.NoError(t, err)
+
+	privkey2, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	node2.BCSetKeyPair(*privkey2)
+	addr2, err := node2.BCGetAddress()
+	require.NoError(t, err)
+
+	// > node1 builds the genesis file of the members
+
+	config := permissioned.NewChainConfig(nil, 1, "2h", 1, 1)
+	genesis, err := permissioned.NewGenesis(*config, []permissioned.GenesisMember{
+		{Address: addr1.Hex, Balance: 100},
+		{Address: addr2.Hex, Balance: 100},
+	}, privkey1)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "genesis.json")
+	err = genesis.Save(path)
+	require.NoError(t, err)
+
+	// > every node loads it and starts with the same block
+
+	for _, node := range []z.TestNode{node1, node2} {
+		loaded, err := permissioned.LoadGenesis(path)
+		require.NoError(t, err)
+		err = node.InitBlockchainFromGenesis(loaded)
+		require.NoError(t, err)
+	}
+
+	time.Sleep(time.Millisecond * 500)
+
+	require.Equal(t, genesis.Block.Hash(), node1.BCGetLatestBlock().Hash())
+	require.Equal(t, genesis.Block.Hash(), node2.BCGetLatestBlock().Hash())
+
+	// > the chain grows on both nodes
+
+	_, err = node2.BCStake(30)
+	require.NoError(t, err)
+
+	time.Sleep(time.Second)
+
+	require.Equal(t, uint(1), node1.BCGetLatestBlock().Height)
+	require.Equal(t, node1.BCGetLatestBlock().Hash(), node2.BCGetLatestBlock().Hash())
+	require.Equal(t, permissioned.Amount(70), node2.BCGetBalance())
+
+	// > a node cannot load a genesis twice
+
+	err = node1.InitBlockchainFromGenesis(genesis)
+	require.Error(t, err)
 }
@@ -12,6 +12,7 @@ import (
 	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/require"
 	z "go.dedis.ch/cs438/internal/testing"
+	"go.dedis.ch/cs438/permissioned-chain"
 	"go.dedis.ch/cs438/storage"
 	"go.dedis.ch/cs438/transport"
 	"go.dedis.ch/cs438/transport/channel"
@@ -189,7 +190,7 @@ func Test_GP_Paxos_Acceptor_Propose_Wrong_Step(t *testing.T) {
 
 	// sending a propose with a wrong step
 
-	budget := float64(10)
+	budget := permissioned.Amount(10)
 	expression := "a.v1+b.v1+c.v1"
 	proposeVal, err := types.CreatePaxosValue(types.PaxosMPCValue{
 		UniqID:     "xxx",
@@ -243,7 +244,7 @@ func Test_GP_Paxos_Acceptor_Propose_Wrong_ID(t *testing.T) {
 
 	// sending a propose with a wrong ID
 
-	budget := float64(10)
+	budget := permissioned.Amount(10)
 	expression := "a.v1+b.v1+c.v1"
 	proposeVal, err := types.CreatePaxosValue(types.PaxosMPCValue{
 		UniqID:     "xxx",
@@ -320,7 +321,7 @@ func Test_GP_Paxos_Acceptor_Prepare_Already_Promised(t *testing.T) {
 
 	// sending a propose, will make the proposer set its MaxID
 
-	budget := float64(10)
+	budget := permissioned.Amount(10)
 	expression := "a.v1+b.v1+c.v1"
 	proposeVal, err := types.CreatePaxosValue(types.PaxosMPCValue{
 		UniqID:     "xxx",
@@ -435,7 +436,7 @@ func Test_GP_Paxos_Acceptor_Propose_Correct(t *testing.T) {
 
 	acceptor.AddPeer(proposer.GetAddress())
 
-	budget := float64(10)
+	budget := permissioned.Amount(10)
 	expression := "a.v1+b.v1+c.v1"
 	proposeVal, err := types.CreatePaxosValue(types.PaxosMPCValue{
 		UniqID:     "xxx",
@@ -659,7 +660,7 @@ func Test_GP_Paxos_Proposer_Prepare_Propose_Correct(t *testing.T) {
 	require.True(t, ok)
 
 	require.Equal(t, "a.v1+b.v1+c.v1", mpcvalue.Expression)
-	require.Equal(t, float64(10), mpcvalue.Budget)
+	require.Equal(t, permissioned.Amount(10), mpcvalue.Budget)
 }
 
 // Check that a peer can differentiate paxos message from different paxos instance
@@ -766,7 +767,7 @@ func Test_GP_TLC_Move_Step_Not_Enough(t *testing.T) {
 	blockHash := "9efc06df7e54b580ebb0e7d7e52cdf05773cf5165c2a2d1a52cdc9ab6fd442e0"
 	previousHash := [32]byte{}
 
-	budget := float64(10)
+	budget := permissioned.Amount(10)
 	expression := "a.v1+b.v1+c.v1"
 	tlcVal, err := types.CreatePaxosValue(types.PaxosMPCValue{
 		UniqID:     "xxx",
@@ -847,7 +848,7 @@ func Test_GP_TLC_Move_Step_OK(t *testing.T) {
 	blockHash := "9efc06df7e54b580ebb0e7d7e52cdf05773cf5165c2a2d1a52cdc9ab6fd442e0"
 	previousHash := [32]byte{}
 
-	budget := float64(10)
+	budget := permissioned.Amount(10)
 	expression := "a.v1+b.v1+c.v1"
 	tlcVal, err := types.CreatePaxosValue(types.PaxosMPCValue{
 		UniqID:     "xxx",
@@ -940,7 +941,7 @@ func Test_GP_MPC_Paxos_Simple_Consensus(t *testing.T) {
 	node1.AddPeer(node2.GetAddr())
 
 	expression := "a.v1+b.v1+c.v1"
-	budget := float64(10)
+	budget := permissioned.Amount(10)
 	_, err := node1.Calculate(expression, budget)
 	require.NoError(t, err)
 
@@ -1303,7 +1304,7 @@ func Test_GP_MPC_Paxos_Eventual_Consensus(t *testing.T) {
 	MPCDone := make(chan struct{})
 
 	expression := "a.v1+b.v1+c.v1"
-	budget := float64(10)
+	budget := permissioned.Amount(10)
 	go func() {
 		_, err := node1.Calculate(expression, budget)
 		require.NoError(t, err)
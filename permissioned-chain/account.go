@@ -3,6 +3,7 @@ package permissioned
 import (
 	"crypto/ecdsa"
 	"fmt"
+	"reflect"
 
 	"github.com/ethereum/go-ethereum/crypto"
 	"go.dedis.ch/cs438/storage"
@@ -33,8 +34,9 @@ func NewAddressFromHex(pubkeyhex string) *Address {
 type Account struct {
 	// *sync.RWMutex
 	addr          Address
-	balance       float64
-	lockedBalance float64
+	balance       Amount
+	lockedBalance Amount
+	stake         Amount
 	nonce         uint
 }
 
@@ -44,6 +46,7 @@ func NewAccount(addr Address) *Account {
 		addr:          addr,
 		balance:       0,
 		lockedBalance: 0,
+		stake:         0,
 		nonce:         0,
 	}
 }
@@ -54,29 +57,76 @@ func (ac Account) Copy() storage.Copyable {
 		addr:          *NewAddressFromHex(ac.addr.Hex),
 		balance:       ac.balance,
 		lockedBalance: ac.lockedBalance,
+		stake:         ac.stake,
 		nonce:         ac.nonce,
 	}
 	return account
 }
 
+// Hash implements Hashable.Hash
+func (ac Account) Hash() string {
+	return hashCanonical(ac)
+}
+
+// encodeCanonical implements canonicalMarshaler through the stored form of
+// the account
+func (ac Account) encodeCanonical(e *encoder) error {
+	return e.value(reflect.ValueOf(accountRecord{
+		Addr:          ac.addr.Hex,
+		Balance:       ac.balance,
+		LockedBalance: ac.lockedBalance,
+		Stake:         ac.stake,
+		Nonce:         ac.nonce,
+	}))
+}
+
+// decodeCanonical implements canonicalUnmarshaler
+func (ac *Account) decodeCanonical(d *decoder) error {
+	var record accountRecord
+	err := d.value(reflect.ValueOf(&record).Elem())
+	if err != nil {
+		return err
+	}
+	*ac = Account{
+		addr:          *NewAddressFromHex(record.Addr),
+		balance:       record.Balance,
+		lockedBalance: record.LockedBalance,
+		stake:         record.Stake,
+		nonce:         record.Nonce,
+	}
+	return nil
+}
+
 // String implements Describable.String()
 func (ac Account) String() string {
-	return fmt.Sprintf("Addr: %s, Balance: %f, Locked: %f, Nonce: %d\n",
-		ac.addr.Hex, ac.balance, ac.lockedBalance, ac.nonce)
+	return fmt.Sprintf("Addr: %s, Balance: %s, Locked: %s, Stake: %s, Nonce: %d\n",
+		ac.addr.Hex, ac.balance, ac.lockedBalance, ac.stake, ac.nonce)
 }
 
 func (ac *Account) GetAddress() Address {
 	return ac.addr
 }
 
-func (ac *Account) GetBalance() float64 {
+func (ac *Account) GetBalance() Amount {
 	return ac.balance
 }
 
+func (ac *Account) GetStake() Amount {
+	return ac.stake
+}
+
+func (ac *Account) GetLockedBalance() Amount {
+	return ac.lockedBalance
+}
+
 func (ac *Account) IncreaseNonce() {
 	ac.nonce++
 }
 
-func (ac *Account) GetNonce() float64 {
-	return ac.balance
+func (ac *Account) SetNonce(nonce uint) {
+	ac.nonce = nonce
+}
+
+func (ac *Account) GetNonce() uint {
+	return ac.nonce
 }
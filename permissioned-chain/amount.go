@@ -0,0 +1,169 @@
+package permissioned
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// -----------------------------------------------------------------------------
+// Amount
+
+// AMOUNT_DECIMALS is the number of decimals of a coin
+const AMOUNT_DECIMALS = 6
+
+// AMOUNT_UNIT is the number of base units in a coin
+const AMOUNT_UNIT Amount = 1000000
+
+// MAX_AMOUNT is the largest amount a transaction can carry, a billion coins.
+// Sums of amounts are still checked, see Add and Mul
+const MAX_AMOUNT Amount = 1000000000 * AMOUNT_UNIT
+
+// Amount is an amount of coins counted in integer base units, so that
+// balances, prices and their hashes are exact.
+//
+// Rounding rule: amounts are only ever scaled through MulRatio, which rounds
+// down to the base unit. Whatever is cut off stays with the account the
+// amount is taken from, e.g. the offender's stake or the asset's price
+type Amount int64
+
+// Coins returns the amount of n whole coins
+func Coins(n int64) Amount {
+	return Amount(n) * AMOUNT_UNIT
+}
+
+// ParseAmount parses a decimal number of coins, e.g. "12.5". More than
+// AMOUNT_DECIMALS decimals is an error rather than a silent rounding
+func ParseAmount(s string) (Amount, error) {
+	s = strings.TrimSpace(s)
+	negative := strings.HasPrefix(s, "-")
+	digits := strings.TrimPrefix(strings.TrimPrefix(s, "-"), "+")
+
+	whole, frac, _ := strings.Cut(digits, ".")
+	if whole == "" && frac == "" {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+	if len(frac) > AMOUNT_DECIMALS {
+		return 0, fmt.Errorf("amount %q has more than %d decimals", s, AMOUNT_DECIMALS)
+	}
+	frac += strings.Repeat("0", AMOUNT_DECIMALS-len(frac))
+	if whole == "" {
+		whole = "0"
+	}
+	for _, part := range []string{whole, frac} {
+		if strings.Trim(part, "0123456789") != "" {
+			return 0, fmt.Errorf("invalid amount %q", s)
+		}
+	}
+
+	units, err := strconv.ParseInt(whole+frac, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q: %w", s, err)
+	}
+	if negative {
+		units = -units
+	}
+	return Amount(units), nil
+}
+
+// String displays the amount as a decimal number of coins without
+// trailing zeros, e.g. "12.5"
+func (a Amount) String() string {
+	sign := ""
+	units := uint64(a)
+	if a < 0 {
+		sign = "-"
+		units = uint64(-a)
+	}
+	whole := units / uint64(AMOUNT_UNIT)
+	frac := units % uint64(AMOUNT_UNIT)
+	if frac == 0 {
+		return fmt.Sprintf("%s%d", sign, whole)
+	}
+	decimals := strings.TrimRight(fmt.Sprintf("%0*d", AMOUNT_DECIMALS, frac), "0")
+	return fmt.Sprintf("%s%d.%s", sign, whole, decimals)
+}
+
+// Validate checks that the amount can be carried by a transaction: it is
+// not negative and at most MAX_AMOUNT
+func (a Amount) Validate() error {
+	if a < 0 {
+		return fmt.Errorf("negative amount %s", a)
+	}
+	if a > MAX_AMOUNT {
+		return fmt.Errorf("amount %s larger than the maximum %s", a, MAX_AMOUNT)
+	}
+	return nil
+}
+
+// Add returns the sum of the amounts, or an error if it overflows
+func (a Amount) Add(b Amount) (Amount, error) {
+	sum := a + b
+	if (b > 0 && sum < a) || (b < 0 && sum > a) {
+		return 0, fmt.Errorf("amount overflow: %s + %s", a, b)
+	}
+	return sum, nil
+}
+
+// Mul returns the amount n times, or an error if it overflows
+func (a Amount) Mul(n int) (Amount, error) {
+	product := new(big.Int).Mul(big.NewInt(int64(a)), big.NewInt(int64(n)))
+	if !product.IsInt64() {
+		return 0, fmt.Errorf("amount overflow: %s * %d", a, n)
+	}
+	return Amount(product.Int64()), nil
+}
+
+// MulRatio returns the fraction of the amount given by the ratio, rounded
+// down to the base unit. The ratio is taken as the shortest decimal of the
+// float, e.g. 0.3 rather than 0.29999..., and the computation is exact so
+// that all nodes agree
+func (a Amount) MulRatio(ratio float64) Amount {
+	if math.IsNaN(ratio) || math.IsInf(ratio, 0) {
+		return 0
+	}
+	r, _ := new(big.Rat).SetString(strconv.FormatFloat(ratio, 'g', -1, 64))
+	r.Mul(r, new(big.Rat).SetInt64(int64(a)))
+	// Quo truncates toward zero
+	q := new(big.Int).Quo(r.Num(), r.Denom())
+	if r.Sign() < 0 && !r.IsInt() {
+		q.Sub(q, big.NewInt(1))
+	}
+	return Amount(q.Int64())
+}
+
+// MarshalJSON implements json.Marshaler. The amount is a JSON number of
+// coins, so that it stays readable for HTTP clients
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler. Both numbers and strings of
+// coins are accepted. They are parsed without going through a float
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(string(data), `"`)
+	if s == "null" {
+		return nil
+	}
+	amount, err := ParseAmount(s)
+	if err != nil {
+		return err
+	}
+	*a = amount
+	return nil
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler, so that config files give
+// amounts in coins
+func (a *Amount) UnmarshalYAML(value *yaml.Node) error {
+	amount, err := ParseAmount(value.Value)
+	if err != nil {
+		return err
+	}
+	*a = amount
+	return nil
+}
@@ -0,0 +1,119 @@
+package permissioned
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func Test_Amount_Parse_String(t *testing.T) {
+	amount, err := ParseAmount("12.5")
+	require.NoError(t, err)
+	require.Equal(t, Coins(12)+AMOUNT_UNIT/2, amount)
+	require.Equal(t, "12.5", amount.String())
+
+	amount, err = ParseAmount("0.000001")
+	require.NoError(t, err)
+	require.Equal(t, Amount(1), amount)
+	require.Equal(t, "0.000001", amount.String())
+
+	amount, err = ParseAmount("-3")
+	require.NoError(t, err)
+	require.Equal(t, Coins(-3), amount)
+	require.Equal(t, "-3", amount.String())
+
+	amount, err = ParseAmount(".25")
+	require.NoError(t, err)
+	require.Equal(t, "0.25", amount.String())
+
+	// > too many decimals are not rounded silently
+
+	_, err = ParseAmount("0.0000001")
+	require.Error(t, err)
+	_, err = ParseAmount("1e3")
+	require.Error(t, err)
+	_, err = ParseAmount(".")
+	require.Error(t, err)
+	_, err = ParseAmount("")
+	require.Error(t, err)
+}
+
+func Test_Amount_MulRatio(t *testing.T) {
+	require.Equal(t, Amount(50), Amount(100).MulRatio(0.5))
+	require.Equal(t, Amount(33), Amount(100).MulRatio(1.0/3))
+	require.Equal(t, Amount(0), Amount(1).MulRatio(0.2))
+	require.Equal(t, Amount(-34), Amount(-100).MulRatio(1.0/3))
+
+	// > exact on amounts a float cannot represent
+	require.Equal(t, Amount(9007199254740993), Amount(9007199254740993).MulRatio(1))
+	require.Equal(t, Amount(300000), Coins(1).MulRatio(0.3))
+}
+
+func Test_Amount_Checked(t *testing.T) {
+	sum, err := Coins(1).Add(Coins(2))
+	require.NoError(t, err)
+	require.Equal(t, Coins(3), sum)
+	product, err := Coins(2).Mul(3)
+	require.NoError(t, err)
+	require.Equal(t, Coins(6), product)
+
+	// > overflows are errors rather than wrapping
+
+	_, err = Amount(math.MaxInt64).Add(1)
+	require.Error(t, err)
+	_, err = Amount(math.MinInt64).Add(-1)
+	require.Error(t, err)
+	_, err = Amount(math.MaxInt64 - 1000000000).Mul(2)
+	require.Error(t, err)
+	_, err = Amount(math.MinInt64).Mul(-1)
+	require.Error(t, err)
+
+	// > transactions carry amounts in [0, MAX_AMOUNT]
+
+	require.NoError(t, Amount(0).Validate())
+	require.NoError(t, MAX_AMOUNT.Validate())
+	require.Error(t, Amount(-1).Validate())
+	require.Error(t, (MAX_AMOUNT + 1).Validate())
+}
+
+func Test_Amount_JSON(t *testing.T) {
+	record := struct {
+		Balance Amount
+		Prices  map[string]Amount
+	}{
+		Balance: Coins(1) + 1,
+		Prices:  map[string]Amount{"a": AMOUNT_UNIT / 10},
+	}
+
+	buf, err := json.Marshal(record)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"Balance":1.000001,"Prices":{"a":0.1}}`, string(buf))
+
+	var decoded struct {
+		Balance Amount
+		Prices  map[string]Amount
+	}
+	err = json.Unmarshal(buf, &decoded)
+	require.NoError(t, err)
+	require.Equal(t, record.Balance, decoded.Balance)
+	require.Equal(t, record.Prices, decoded.Prices)
+
+	// > strings are accepted as well
+	err = json.Unmarshal([]byte(`{"Balance":"2.5"}`), &decoded)
+	require.NoError(t, err)
+	require.Equal(t, Coins(2)+AMOUNT_UNIT/2, decoded.Balance)
+}
+
+func Test_Amount_YAML(t *testing.T) {
+	var config ChainConfig
+	err := yaml.Unmarshal([]byte("mpcparticipationgain: 1.5\nminstake: 10\n"), &config)
+	require.NoError(t, err)
+	require.Equal(t, Coins(1)+AMOUNT_UNIT/2, config.MPCParticipationGain)
+	require.Equal(t, Coins(10), config.MinStake)
+
+	err = yaml.Unmarshal([]byte("minstake: ten\n"), &config)
+	require.Error(t, err)
+}
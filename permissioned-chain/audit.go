@@ -0,0 +1,188 @@
+package permissioned
+
+import (
+	"bytes"
+	"fmt"
+
+	"go.dedis.ch/cs438/storage"
+)
+
+// -----------------------------------------------------------------------------
+// Utilities - Audit
+
+// AuditReport is the result of replaying an exported chain offline
+type AuditReport struct {
+	// height of the last block replayed successfully
+	Height       uint
+	Blocks       int
+	Transactions int
+
+	// coins created by coinbase txns and destroyed by slashing
+	Minted Amount
+	Burnt  Amount
+
+	// totals of all accounts after the last replayed block
+	Supply   Amount
+	Unlocked Amount
+	Locked   Amount
+	Staked   Amount
+
+	Anomalies []AuditAnomaly
+}
+
+// AuditAnomaly is an inconsistency found in a block
+type AuditAnomaly struct {
+	Height    uint
+	BlockHash string
+	Message   string
+}
+
+// String implements Describable.String()
+func (a AuditAnomaly) String() string {
+	return fmt.Sprintf("height %d, block %s: %s", a.Height, a.BlockHash, a.Message)
+}
+
+// String implements Describable.String()
+func (r AuditReport) String() string {
+	description := fmt.Sprintf("Replayed %d blocks and %d txns up to height %d\n",
+		r.Blocks, r.Transactions, r.Height)
+	description += fmt.Sprintf("Supply: %s (unlocked: %s, locked: %s, staked: %s)\n",
+		r.Supply, r.Unlocked, r.Locked, r.Staked)
+	description += fmt.Sprintf("Minted: %s, Burnt: %s\n", r.Minted, r.Burnt)
+	description += fmt.Sprintf("%d anomalies\n", len(r.Anomalies))
+	for _, anomaly := range r.Anomalies {
+		description += fmt.Sprintf("\t%s\n", anomaly)
+	}
+	return description
+}
+
+// Audit replays the exported chain from the genesis block with Block.Verify,
+// checks that each block was mined by the selected miner and checks the
+// balance invariants after each block:
+//   - the supply only grows by the coinbase txns
+//   - it only shrinks in blocks slashing stakes
+//   - no amount is negative
+//   - locked balances belong to the initiators of ongoing MPCs
+//
+// The replay stops at the first block that cannot be verified. The exported
+// snapshots must match the replayed states
+func Audit(export *ChainExport) *AuditReport {
+	report := &AuditReport{Anomalies: []AuditAnomaly{}}
+
+	var prev *Block
+	for _, exported := range export.Blocks {
+		block := &Block{
+			BlockHeader:  exported.BlockHeader,
+			Transactions: exported.Transactions,
+		}
+		hash := block.Hash()
+		anomaly := func(format string, a ...interface{}) {
+			report.Anomalies = append(report.Anomalies, AuditAnomaly{
+				Height:    block.Height,
+				BlockHash: hash,
+				Message:   fmt.Sprintf(format, a...),
+			})
+		}
+
+		// chain links
+		var worldState storage.KVStore = storage.NewBasicKV()
+		if prev == nil {
+			if block.Height != 0 || block.PrevHash != DUMMY_PREVHASH {
+				anomaly("first block is not a genesis block")
+				return report
+			}
+		} else {
+			if block.Height != prev.Height+1 || block.PrevHash != prev.Hash() {
+				anomaly("block does not extend block %s at height %d", prev.Hash(), prev.Height)
+				return report
+			}
+			if block.Timestamp < prev.Timestamp {
+				anomaly("block is older than its parent")
+			}
+			err := VerifyMinerSelection(prev, block)
+			if err != nil {
+				anomaly("miner was not selected: %v", err)
+			}
+			worldState = prev.GetWorldStateCopy()
+		}
+
+		err := block.Verify(worldState)
+		if err != nil {
+			anomaly("replay failed: %v", err)
+			return report
+		}
+		report.Height = block.Height
+		report.Blocks++
+		report.Transactions += len(block.Transactions)
+
+		snapshot, ok := export.Snapshots[hash]
+		if ok && !bytes.Equal(snapshot.Hash(), block.States.Hash()) {
+			anomaly("exported snapshot differs from the replayed state")
+		}
+
+		// balance invariants
+		supply := report.Supply
+		auditBalances(block.States, report, anomaly)
+
+		var minted Amount = 0
+		slashing := false
+		for _, signedTxn := range block.Transactions {
+			switch signedTxn.Txn.Type {
+			case TxnTypeCoinbase:
+				minted += signedTxn.Txn.Value
+			case TxnTypeSlash:
+				slashing = true
+			}
+		}
+		report.Minted += minted
+
+		delta := report.Supply - supply
+		if delta > minted {
+			anomaly("supply grew by %s but %s was minted", delta, minted)
+		} else if delta < minted {
+			if !slashing {
+				anomaly("supply shrank by %s without slashing", minted-delta)
+			}
+			report.Burnt += minted - delta
+		}
+
+		prev = block
+	}
+
+	return report
+}
+
+// auditBalances sums the amounts of all accounts of the world state in the
+// report and checks each of them
+func auditBalances(worldState storage.KVStore, report *AuditReport,
+	anomaly func(format string, a ...interface{})) {
+	initiators := map[string]struct{}{}
+	worldState.For(func(key string, value interface{}) error {
+		endorsement, ok := value.(MPCEndorsement)
+		if ok {
+			initiators[endorsement.Initiator] = struct{}{}
+		}
+		return nil
+	})
+
+	report.Supply, report.Unlocked, report.Locked, report.Staked = 0, 0, 0, 0
+	worldState.For(func(key string, value interface{}) error {
+		account, ok := value.(Account)
+		if !ok {
+			return nil
+		}
+		if account.balance < 0 || account.lockedBalance < 0 || account.stake < 0 {
+			anomaly("account %s has a negative amount: balance %s, locked %s, stake %s",
+				key, account.balance, account.lockedBalance, account.stake)
+		}
+		if _, ok := initiators[key]; account.lockedBalance > 0 && !ok {
+			anomaly("account %s has %s locked without an ongoing MPC", key, account.lockedBalance)
+		}
+
+		report.Unlocked += account.balance
+		report.Locked += account.lockedBalance
+		report.Staked += account.stake
+		return nil
+	})
+	report.Supply = report.Unlocked + report.Locked + report.Staked
+}
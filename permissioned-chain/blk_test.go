@@ -1,10 +1,10 @@
 package permissioned
 
 import (
-	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/stretchr/testify/require"
@@ -26,24 +26,27 @@ func Test_Block_Build(t *testing.T) {
 	prevHash := "fffffff"
 	var height uint = 1
 	miner := "miner1"
+	timestamp := time.Now()
 	expectedBlock := Block{
 		BlockHeader: &BlockHeader{
-			PrevHash: prevHash,
-			Height:   height,
-			Miner:    miner,
+			PrevHash:  prevHash,
+			Height:    height,
+			Miner:     miner,
+			Timestamp: timestamp.UnixNano(),
 		},
 		States:       storage.NewBasicKV(),
 		Transactions: transactions,
 	}
 	expectedBlock.StateHash = hex.EncodeToString(expectedBlock.States.Hash())
-	h := sha256.New()
+	leaves := make([][]byte, 0, len(transactions))
 	for _, txn := range transactions {
-		h.Write(txn.HashBytes())
+		leaves = append(leaves, txn.HashBytes())
 	}
-	expectedBlock.TransationHash = hex.EncodeToString(h.Sum(nil))
+	expectedBlock.TransationHash = hex.EncodeToString(storage.MerkleRoot(leaves))
 
 	bb := NewBlockBuilder()
-	bb.SetPrevHash(prevHash).SetHeight(height).SetMiner(miner).SetState(storage.NewBasicKV())
+	bb.SetPrevHash(prevHash).SetHeight(height).SetMiner(miner).
+		SetTimestamp(timestamp).SetState(storage.NewBasicKV())
 	for _, txn := range transactions {
 		err := bb.AddTxn(&txn)
 		require.NoError(t, err)
@@ -75,10 +78,10 @@ func Test_Block_Verify_Correct(t *testing.T) {
 	worldState.Put(STATE_CONFIG_KEY, config)
 	worldState.Put(account.addr.Hex, account)
 	asset := NewAssetsRecord(account.addr.Hex)
-	var budgetA float64 = 10
-	asset.Add(map[string]float64{"a": budgetA})
-	var budgetB float64 = 5
-	asset.Add(map[string]float64{"b": budgetB})
+	var budgetA Amount = 10
+	asset.Add(map[string]Amount{"a": budgetA})
+	var budgetB Amount = 5
+	asset.Add(map[string]Amount{"b": budgetB})
 	worldState.Put(AssetsKeyFromUniqID(account.addr.Hex), *asset)
 	stateCopy := worldState.Copy()
 
@@ -112,6 +115,8 @@ func Test_Block_Verify_Correct(t *testing.T) {
 
 	block := bb.Build()
 
+	err = block.Sign(privKey)
+	require.NoError(t, err)
 	err = block.Verify(stateCopy)
 	require.NoError(t, err)
 }
@@ -133,10 +138,10 @@ func Test_Block_Verify_Invalid_Miner(t *testing.T) {
 	worldState.Put(STATE_CONFIG_KEY, config)
 	worldState.Put(account.addr.Hex, account)
 	asset := NewAssetsRecord(account.addr.Hex)
-	var budgetA float64 = 10
-	asset.Add(map[string]float64{"a": budgetA})
-	var budgetB float64 = 5
-	asset.Add(map[string]float64{"b": budgetB})
+	var budgetA Amount = 10
+	asset.Add(map[string]Amount{"a": budgetA})
+	var budgetB Amount = 5
+	asset.Add(map[string]Amount{"b": budgetB})
 	worldState.Put(AssetsKeyFromUniqID(account.addr.Hex), *asset)
 	stateCopy := worldState.Copy()
 
@@ -192,10 +197,10 @@ func Test_Block_Verify_Invalid_TXN_Hash(t *testing.T) {
 	worldState.Put(STATE_CONFIG_KEY, config)
 	worldState.Put(account.addr.Hex, account)
 	asset := NewAssetsRecord(account.addr.Hex)
-	var budgetA float64 = 10
-	asset.Add(map[string]float64{"a": budgetA})
-	var budgetB float64 = 5
-	asset.Add(map[string]float64{"b": budgetB})
+	var budgetA Amount = 10
+	asset.Add(map[string]Amount{"a": budgetA})
+	var budgetB Amount = 5
+	asset.Add(map[string]Amount{"b": budgetB})
 	worldState.Put(AssetsKeyFromUniqID(account.addr.Hex), *asset)
 	stateCopy := worldState.Copy()
 
@@ -231,6 +236,8 @@ func Test_Block_Verify_Invalid_TXN_Hash(t *testing.T) {
 	block := bb.Build()
 	block.TransationHash = "1234566789"
 
+	err = block.Sign(privKey)
+	require.NoError(t, err)
 	err = block.Verify(stateCopy)
 	require.Error(t, err)
 }
@@ -252,10 +259,10 @@ func Test_Block_Verify_Invalid_TXN(t *testing.T) {
 	worldState.Put(STATE_CONFIG_KEY, config)
 	worldState.Put(account.addr.Hex, account)
 	asset := NewAssetsRecord(account.addr.Hex)
-	var budgetA float64 = 10
-	asset.Add(map[string]float64{"a": budgetA})
-	var budgetB float64 = 10
-	asset.Add(map[string]float64{"b": budgetB})
+	var budgetA Amount = 10
+	asset.Add(map[string]Amount{"a": budgetA})
+	var budgetB Amount = 10
+	asset.Add(map[string]Amount{"b": budgetB})
 	worldState.Put(AssetsKeyFromUniqID(account.addr.Hex), *asset)
 	stateCopy := worldState.Copy()
 
@@ -290,6 +297,8 @@ func Test_Block_Verify_Invalid_TXN(t *testing.T) {
 
 	block := bb.Build()
 
+	err = block.Sign(privKey)
+	require.NoError(t, err)
 	err = block.Verify(stateCopy)
 	require.Error(t, err)
 }
@@ -311,10 +320,10 @@ func Test_Block_Verify_Inconsistent_State(t *testing.T) {
 	worldState.Put(STATE_CONFIG_KEY, config)
 	worldState.Put(account.addr.Hex, account)
 	asset := NewAssetsRecord(account.addr.Hex)
-	var budgetA float64 = 10
-	asset.Add(map[string]float64{"a": budgetA})
-	var budgetB float64 = 5
-	asset.Add(map[string]float64{"b": budgetB})
+	var budgetA Amount = 10
+	asset.Add(map[string]Amount{"a": budgetA})
+	var budgetB Amount = 5
+	asset.Add(map[string]Amount{"b": budgetB})
 	worldState.Put(AssetsKeyFromUniqID(account.addr.Hex), *asset)
 	stateCopy := worldState.Copy()
 
@@ -347,6 +356,57 @@ func Test_Block_Verify_Inconsistent_State(t *testing.T) {
 
 	block := bb.Build()
 
+	err = block.Sign(privKey)
+	require.NoError(t, err)
 	err = block.Verify(stateCopy)
 	require.Error(t, err)
 }
+
+func Test_Block_Verify_Signature(t *testing.T) {
+	privKey, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	account := *NewAccount(*NewAddress(&privKey.PublicKey))
+	otherKey, err := crypto.GenerateKey()
+	require.NoError(t, err)
+
+	worldState := storage.NewBasicKV()
+	config := *NewChainConfig(
+		map[string]string{account.addr.Hex: ""},
+		10, "2h", 0, 10,
+	)
+	worldState.Put(STATE_CONFIG_KEY, config)
+	worldState.Put(account.addr.Hex, account)
+
+	bb := NewBlockBuilder()
+	bb.SetPrevHash(DUMMY_PREVHASH).SetHeight(1).SetMiner(account.addr.Hex).
+		SetState(worldState.Copy())
+	block := bb.Build()
+
+	// > unsigned
+	err = block.Verify(worldState.Copy())
+	require.Error(t, err)
+
+	// > signed by someone else
+	err = block.Sign(otherKey)
+	require.NoError(t, err)
+	err = block.Verify(worldState.Copy())
+	require.Error(t, err)
+
+	// > the signature does not change the hash
+	hash := block.Hash()
+	err = block.Sign(privKey)
+	require.NoError(t, err)
+	require.Equal(t, hash, block.Hash())
+	signer, err := block.Signer()
+	require.NoError(t, err)
+	require.Equal(t, account.addr.Hex, signer)
+
+	// > the signature covers the timestamp
+	block.Timestamp++
+	err = block.Verify(worldState.Copy())
+	require.Error(t, err)
+	block.Timestamp--
+
+	err = block.Verify(worldState.Copy())
+	require.NoError(t, err)
+}
@@ -2,6 +2,7 @@ package permissioned
 
 import (
 	"testing"
+	"time"
 
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/stretchr/testify/require"
@@ -16,7 +17,7 @@ func Test_BC_Init_Genesis(t *testing.T) {
 		},
 		10, "2h", 0, 10,
 	)
-	initialGain := map[string]float64{
+	initialGain := map[string]Amount{
 		"addr2": 100,
 		"addr3": 10,
 		"addr4": 100000,
@@ -35,13 +36,13 @@ func Test_BC_Init_Genesis(t *testing.T) {
 	newConfig := GetConfigFromWorldState(worldState)
 	require.Equal(t, config.Hash(), newConfig.Hash())
 	account1 := GetAccountFromWorldState(worldState, "addr1")
-	require.Equal(t, float64(0), account1.balance)
+	require.Equal(t, Amount(0), account1.balance)
 	account2 := GetAccountFromWorldState(worldState, "addr2")
-	require.Equal(t, float64(100), account2.balance)
+	require.Equal(t, Amount(100), account2.balance)
 	account3 := GetAccountFromWorldState(worldState, "addr3")
-	require.Equal(t, float64(10), account3.balance)
+	require.Equal(t, Amount(10), account3.balance)
 	account4 := GetAccountFromWorldState(worldState, "addr4")
-	require.Equal(t, float64(0), account4.balance)
+	require.Equal(t, Amount(0), account4.balance)
 }
 
 func Test_BC_Append_Correct(t *testing.T) {
@@ -55,7 +56,7 @@ func Test_BC_Append_Correct(t *testing.T) {
 		map[string]string{account.addr.Hex: ""},
 		10, "2h", 0, 10,
 	)
-	initialGain := map[string]float64{
+	initialGain := map[string]Amount{
 		account.addr.Hex: 1000,
 	}
 	bc := NewBlockchain()
@@ -68,7 +69,7 @@ func Test_BC_Append_Correct(t *testing.T) {
 
 	worldstate := block0.GetWorldStateCopy()
 
-	txn1, err := NewTransactionRegAssets(&account, map[string]float64{
+	txn1, err := NewTransactionRegAssets(&account, map[string]Amount{
 		"key1": 1,
 	}).Sign(privKey)
 	require.NoError(t, err)
@@ -80,6 +81,8 @@ func Test_BC_Append_Correct(t *testing.T) {
 		SetMiner(account.addr.Hex).SetState(worldstate)
 	bb.AddTxn(txn1)
 	block1 := bb.Build()
+	err = block1.Sign(privKey)
+	require.NoError(t, err)
 
 	result := bc.CheckBlockHeight(block1)
 	require.Equal(t, BlockCompareMatched, result)
@@ -102,7 +105,7 @@ func Test_BC_Append_Check_Fail(t *testing.T) {
 		map[string]string{account.addr.Hex: ""},
 		10, "2h", 0, 10,
 	)
-	initialGain := map[string]float64{
+	initialGain := map[string]Amount{
 		account.addr.Hex: 1000,
 	}
 	bc := NewBlockchain()
@@ -115,7 +118,7 @@ func Test_BC_Append_Check_Fail(t *testing.T) {
 
 	worldstate := block0.GetWorldStateCopy()
 
-	txn1, err := NewTransactionRegAssets(&account, map[string]float64{
+	txn1, err := NewTransactionRegAssets(&account, map[string]Amount{
 		"key1": 1,
 	}).Sign(privKey)
 	require.NoError(t, err)
@@ -167,7 +170,7 @@ func Test_BC_Append_Verify_Fail(t *testing.T) {
 		map[string]string{account.addr.Hex: ""},
 		10, "2h", 0, 10,
 	)
-	initialGain := map[string]float64{
+	initialGain := map[string]Amount{
 		account.addr.Hex: 1000,
 	}
 	bc := NewBlockchain()
@@ -194,6 +197,8 @@ func Test_BC_Append_Verify_Fail(t *testing.T) {
 		SetMiner(account.addr.Hex).SetState(worldstate)
 	bb.AddTxn(txn1)
 	block1 := bb.Build()
+	err = block1.Sign(privKey)
+	require.NoError(t, err)
 
 	result := bc.CheckBlockHeight(block1)
 	require.Equal(t, BlockCompareMatched, result)
@@ -216,7 +221,7 @@ func Test_BC_Has_Txn(t *testing.T) {
 		map[string]string{account.addr.Hex: ""},
 		10, "2h", 0, 10,
 	)
-	initialGain := map[string]float64{
+	initialGain := map[string]Amount{
 		account.addr.Hex: 1000,
 	}
 	bc := NewBlockchain()
@@ -227,7 +232,7 @@ func Test_BC_Has_Txn(t *testing.T) {
 
 	// first block has txn1 and txn2
 	worldstate := block0.GetWorldStateCopy()
-	txn1, err := NewTransactionRegAssets(&account, map[string]float64{
+	txn1, err := NewTransactionRegAssets(&account, map[string]Amount{
 		"b": 1,
 	}).Sign(privKey)
 	require.NoError(t, err)
@@ -251,6 +256,8 @@ func Test_BC_Has_Txn(t *testing.T) {
 	bb.AddTxn(txn1)
 	bb.AddTxn(txn2)
 	block1 := bb.Build()
+	err = block1.Sign(privKey)
+	require.NoError(t, err)
 
 	err = bc.AppendBlock(block1)
 	require.NoError(t, err)
@@ -271,6 +278,8 @@ func Test_BC_Has_Txn(t *testing.T) {
 		SetMiner(account.addr.Hex).SetState(worldstate)
 	bb.AddTxn(txn3)
 	block2 := bb.Build()
+	err = block2.Sign(privKey)
+	require.NoError(t, err)
 
 	err = bc.AppendBlock(block2)
 	require.NoError(t, err)
@@ -305,7 +314,7 @@ func Test_BC_Append_Genesis(t *testing.T) {
 		map[string]string{account.addr.Hex: ""},
 		10, "2h", 0, 10,
 	)
-	initialGain := map[string]float64{
+	initialGain := map[string]Amount{
 		account.addr.Hex: 1000,
 	}
 	bc := NewBlockchain()
@@ -326,3 +335,52 @@ func Test_BC_Append_Genesis(t *testing.T) {
 	require.Equal(t, bc.GetLatestBlock().Hash(),
 		newBC.GetLatestBlock().Hash())
 }
+
+func Test_BC_Append_Timestamp(t *testing.T) {
+	privKey, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	account := *NewAccount(*NewAddress(&privKey.PublicKey))
+
+	config := *NewChainConfig(
+		map[string]string{account.addr.Hex: ""},
+		10, "2h", 0, 10,
+	)
+	bc := NewBlockchain()
+	block0, err := bc.InitGenesisBlock(&config, map[string]Amount{
+		account.addr.Hex: 1000,
+	})
+	require.NoError(t, err)
+	err = bc.SetGenesisBlock(&block0)
+	require.NoError(t, err)
+
+	buildBlock := func(timestamp time.Time) *Block {
+		worldState := block0.GetWorldStateCopy()
+		txn, err := NewTransactionStake(&account, 1).Sign(privKey)
+		require.NoError(t, err)
+		err = txn.Verify(worldState)
+		require.NoError(t, err)
+
+		bb := NewBlockBuilder()
+		bb.SetPrevHash(block0.Hash()).SetHeight(1).SetMiner(account.addr.Hex).
+			SetTimestamp(timestamp).SetState(worldState)
+		bb.AddTxn(txn)
+		block := bb.Build()
+		err = block.Sign(privKey)
+		require.NoError(t, err)
+		return block
+	}
+
+	// > older than the parent
+	err = bc.AppendBlock(buildBlock(block0.GetTime().Add(-time.Second)))
+	require.Error(t, err)
+
+	// > too far in the future
+	err = bc.AppendBlock(buildBlock(time.Now().Add(MAX_CLOCK_DRIFT + time.Minute)))
+	require.Error(t, err)
+
+	// > within the drift
+	block1 := buildBlock(time.Now().Add(MAX_CLOCK_DRIFT / 2))
+	err = bc.AppendBlock(block1)
+	require.NoError(t, err)
+	require.Equal(t, block1.Hash(), bc.GetLatestBlock().Hash())
+}
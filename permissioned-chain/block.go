@@ -1,11 +1,12 @@
 package permissioned
 
 import (
-	"crypto/sha256"
+	"crypto/ecdsa"
 	"encoding/hex"
 	"fmt"
-	"strconv"
+	"time"
 
+	"github.com/ethereum/go-ethereum/crypto"
 	"go.dedis.ch/cs438/storage"
 )
 
@@ -17,22 +18,93 @@ type BlockHeader struct {
 	PrevHash string
 	Height   uint
 	Miner    string
+	// seed that selected the miner, see SelectMiner
+	SelectionProof string
 
 	StateHash      string
 	TransationHash string
+
+	// time the block was mined, in unix nanoseconds
+	Timestamp int64
+	// signature of the miner on the block hash. Not part of the hash
+	Signature []byte
 }
 
 // Hash computes the block hash based on data in block header
 func (bh *BlockHeader) Hash() string {
-	h := sha256.New()
-	h.Write([]byte(bh.PrevHash))
-	h.Write([]byte(strconv.Itoa(int(bh.Height))))
-	h.Write([]byte(bh.Miner))
+	return hex.EncodeToString(bh.HashBytes())
+}
+
+// HashBytes computes the digest signed by the miner. It is the hash of the
+// canonical encoding of the header without the signature
+func (bh *BlockHeader) HashBytes() []byte {
+	unsigned := *bh
+	unsigned.Signature = nil
+	return hashCanonicalBytes(unsigned)
+}
+
+// MarshalJSON implements json.Marshaler. Headers are gossiped and stored in
+// their canonical encoding
+func (bh BlockHeader) MarshalJSON() ([]byte, error) {
+	return marshalCanonicalJSON(bh)
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (bh *BlockHeader) UnmarshalJSON(data []byte) error {
+	return unmarshalCanonicalJSON(data, bh)
+}
 
-	h.Write([]byte(bh.StateHash))
-	h.Write([]byte(bh.TransationHash))
+// GetTime returns the time the block was mined
+func (bh *BlockHeader) GetTime() time.Time {
+	return time.Unix(0, bh.Timestamp)
+}
 
-	return hex.EncodeToString(h.Sum(nil))
+// Sign signs the header with the miner's private key
+func (bh *BlockHeader) Sign(privateKey *ecdsa.PrivateKey) error {
+	signature, err := crypto.Sign(bh.HashBytes(), privateKey)
+	if err != nil {
+		return err
+	}
+	bh.Signature = signature
+	return nil
+}
+
+// Signer returns the address that signed the header
+func (bh *BlockHeader) Signer() (string, error) {
+	if len(bh.Signature) != crypto.SignatureLength {
+		return "", fmt.Errorf("invalid block signature length: %d", len(bh.Signature))
+	}
+
+	digestHash := bh.HashBytes()
+	publicKey, err := crypto.SigToPub(digestHash, bh.Signature)
+	if err != nil {
+		return "", err
+	}
+	// verify sig input needs to be in [R || S] format
+	sigValid := crypto.VerifySignature(crypto.FromECDSAPub(publicKey), digestHash,
+		bh.Signature[:len(bh.Signature)-1])
+	if !sigValid {
+		return "", fmt.Errorf("block %s has invalid signature", bh.Hash())
+	}
+
+	return NewAddress(publicKey).Hex, nil
+}
+
+// VerifySignature checks the miner signed the header. Only the genesis
+// block has no miner
+func (bh *BlockHeader) VerifySignature() error {
+	if bh.Height == 0 && bh.Miner == ZeroAddress.Hex {
+		return nil
+	}
+	signer, err := bh.Signer()
+	if err != nil {
+		return err
+	}
+	if signer != bh.Miner {
+		return fmt.Errorf("block %s is signed by %s instead of miner %s",
+			bh.Hash(), signer, bh.Miner)
+	}
+	return nil
 }
 
 // -----------------------------------------------------------------------------
@@ -45,9 +117,37 @@ type Block struct {
 	Transactions []SignedTransaction
 }
 
-// GetWorldStateCopy returns a copy of block's world state
+// MarshalJSON implements json.Marshaler. States are not included, as for
+// storage
+func (b Block) MarshalJSON() ([]byte, error) {
+	if b.BlockHeader == nil {
+		return []byte("null"), nil
+	}
+	return marshalCanonicalJSON(persistedBlock{
+		Header:       *b.BlockHeader,
+		Transactions: b.Transactions,
+	})
+}
+
+// UnmarshalJSON implements json.Unmarshaler. States are left empty
+func (b *Block) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	var stored persistedBlock
+	err := unmarshalCanonicalJSON(data, &stored)
+	if err != nil {
+		return err
+	}
+	b.BlockHeader = &stored.Header
+	b.Transactions = stored.Transactions
+	return nil
+}
+
+// GetWorldStateCopy returns a copy of block's world state. The copy only
+// records its own changes on top of the block's state
 func (b *Block) GetWorldStateCopy() storage.KVStore {
-	return b.States.Copy()
+	return storage.NewLayeredKV(b.States)
 }
 
 // GetConfig returns a copy of blockchain's config
@@ -73,6 +173,11 @@ func (b *Block) Verify(worldState storage.KVStore) error {
 	if !CheckPariticipation(worldState, config, b.Miner) {
 		return fmt.Errorf("miner %s is not a participant of the permissined chain", b.Miner)
 	}
+	// check the miner signed the block
+	err := b.VerifySignature()
+	if err != nil {
+		return err
+	}
 
 	// check # transaction not exceeds the limit (except for genesis)
 	if b.Height > 0 && len(b.Transactions) > config.MaxTxnsPerBlk {
@@ -81,12 +186,7 @@ func (b *Block) Verify(worldState storage.KVStore) error {
 	}
 
 	// check hash
-	h := sha256.New()
-	for _, txn := range b.Transactions {
-		h.Write(txn.HashBytes())
-	}
-	hash := hex.EncodeToString(h.Sum(nil))
-	if b.TransationHash != hash {
+	if b.TransationHash != TxnRoot(b.Transactions) {
 		return fmt.Errorf("block %s has inconsistent transaction hash", b.Hash())
 	}
 
@@ -94,19 +194,39 @@ func (b *Block) Verify(worldState storage.KVStore) error {
 	for _, txn := range b.Transactions {
 		err := txn.Verify(worldState)
 		if err != nil {
-			return fmt.Errorf("block %s has invalid transaction: %t", b.Hash(), err)
+			return fmt.Errorf("block %s has invalid transaction: %v", b.Hash(), err)
 		}
 	}
+	err = payTips(worldState, b.Miner, b.Transactions)
+	if err != nil {
+		return fmt.Errorf("block %s can't pay its tips: %v", b.Hash(), err)
+	}
 	if hex.EncodeToString(worldState.Hash()) != b.StateHash {
 		fmt.Println(worldState)
 		return fmt.Errorf("block %s has different execution result from expected", b.Hash())
 	}
 	// set state
-	b.States = worldState
+	// the height is recorded after the state hash check so that
+	// transactions of the next block can refer to the chain height
+	worldState.Put(STATE_HEIGHT_KEY, b.Height)
+	b.States = checkpointState(b.Height, worldState)
 
 	return nil
 }
 
+// TxnRoot returns the root of the Merkle tree of the transactions, in order
+func TxnRoot(txns []SignedTransaction) string {
+	return hex.EncodeToString(storage.MerkleRoot(txnLeaves(txns)))
+}
+
+func txnLeaves(txns []SignedTransaction) [][]byte {
+	leaves := make([][]byte, len(txns))
+	for i, txn := range txns {
+		leaves[i] = txn.HashBytes()
+	}
+	return leaves
+}
+
 // DescribeTransactions return a string to describe txn info
 func (b *Block) DescribeTransactions() string {
 	description := ""
@@ -116,6 +236,21 @@ func (b *Block) DescribeTransactions() string {
 	return fmt.Sprintf("%d Txns: [%s]", len(b.Transactions), description)
 }
 
+// -----------------------------------------------------------------------------
+// Height
+
+var STATE_HEIGHT_KEY = "PermissionedChain-Height"
+
+// GetHeightFromWorldState returns the height of the last block applied
+// to the world state
+func GetHeightFromWorldState(worldState storage.KVStore) uint {
+	object, ok := worldState.Get(STATE_HEIGHT_KEY)
+	if !ok {
+		return 0
+	}
+	return object.(uint)
+}
+
 // -----------------------------------------------------------------------------
 // BlockBuilder
 
@@ -123,6 +258,8 @@ type BlockBuilder struct {
 	prevHash     string
 	height       uint
 	miner        string
+	proof        string
+	timestamp    time.Time
 	states       storage.KVStore
 	transactions []SignedTransaction
 }
@@ -153,24 +290,41 @@ func (bb *BlockBuilder) SetMiner(miner string) *BlockBuilder {
 	return bb
 }
 
+func (bb *BlockBuilder) SetSelectionProof(proof string) *BlockBuilder {
+	bb.proof = proof
+	return bb
+}
+
+func (bb *BlockBuilder) SetTimestamp(timestamp time.Time) *BlockBuilder {
+	bb.timestamp = timestamp
+	return bb
+}
+
 func (bb *BlockBuilder) SetState(state storage.KVStore) *BlockBuilder {
 	bb.states = state
 	return bb
 }
 
+// Build pays the tips to the miner and creates the block. It returns nil if
+// the tips can't be paid, since no node would accept the block
 func (bb *BlockBuilder) Build() *Block {
-	h := sha256.New()
-	for _, txn := range bb.transactions {
-		h.Write(txn.HashBytes())
+	timestamp := bb.timestamp
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+	err := payTips(bb.states, bb.miner, bb.transactions)
+	if err != nil {
+		return nil
 	}
-	txnHash := h.Sum(nil)
 
 	header := BlockHeader{
 		PrevHash:       bb.prevHash,
 		Height:         bb.height,
 		Miner:          bb.miner,
+		SelectionProof: bb.proof,
 		StateHash:      hex.EncodeToString(bb.states.Hash()),
-		TransationHash: hex.EncodeToString(txnHash),
+		TransationHash: TxnRoot(bb.transactions),
+		Timestamp:      timestamp.UnixNano(),
 	}
 
 	return &Block{
@@ -3,17 +3,35 @@ package permissioned
 import (
 	"encoding/hex"
 	"fmt"
+	"sort"
 	"sync"
+	"time"
 
 	"go.dedis.ch/cs438/storage"
 )
 
 var DUMMY_PREVHASH = hex.EncodeToString(make([]byte, 32))
 
+// MAX_CLOCK_DRIFT is how far ahead of the local clock the timestamp of a
+// received block can be
+var MAX_CLOCK_DRIFT = time.Second * 15
+
 type Blockchain struct {
 	*sync.RWMutex
 	blocksStore map[string]*Block
-	latestBlock *Block // only allow one level of inconsistency
+	latestBlock *Block // tip of the canonical chain
+	finalBlock  *Block // latest block that can no longer be reverted
+
+	// world states of the blocks below this height are dropped, except the
+	// genesis one. See prune
+	prunedHeight uint
+
+	// cumulative selection weight of the miners from the genesis to each block,
+	// used by the fork-choice rule
+	weights map[string]Amount
+
+	// optional persistent store. Blocks are kept in memory only if nil
+	store storage.Store
 }
 
 func NewBlockchain() *Blockchain {
@@ -21,13 +39,28 @@ func NewBlockchain() *Blockchain {
 		RWMutex:     &sync.RWMutex{},
 		blocksStore: map[string]*Block{},
 		latestBlock: nil,
+		weights:     map[string]Amount{},
 	}
 	return &bm
 }
 
+// NewBlockchainWithStore creates a blockchain persisted in the store and
+// restores the chain already saved in it. If the saved chain cannot be
+// restored, an empty blockchain bound to the store is returned with the error
+func NewBlockchainWithStore(store storage.Store) (*Blockchain, error) {
+	bc := NewBlockchain()
+	bc.store = store
+
+	err := bc.load()
+	if err != nil {
+		return bc, err
+	}
+	return bc, nil
+}
+
 // InitGenesisBlock inits a new blockchain with the given config
 func (bc *Blockchain) InitGenesisBlock(config *ChainConfig,
-	initialGain map[string]float64) (Block, error) {
+	initialGain map[string]Amount) (Block, error) {
 	bb := NewBlockBuilder()
 
 	worldState := storage.NewBasicKV()
@@ -79,13 +112,27 @@ func (bc *Blockchain) SetGenesisBlock(block *Block) error {
 		return fmt.Errorf("chain already initialized")
 	}
 
-	err := block.Verify(storage.NewBasicKV())
+	err := checkTimestamp(nil, block)
+	if err != nil {
+		return err
+	}
+	err = block.Verify(storage.NewBasicKV())
 	if err != nil {
 		return err
 	}
 
+	if bc.store != nil {
+		err = persistBlock(bc.store, block)
+		if err != nil {
+			return err
+		}
+		persistTip(bc.store, block)
+	}
+
 	bc.blocksStore[block.Hash()] = block
+	bc.weights[block.Hash()] = 0
 	bc.latestBlock = block
+	bc.finalBlock = block
 	return nil
 }
 
@@ -134,6 +181,22 @@ func (bc *Blockchain) GetBlockUntil(height uint) []*Block {
 	return blocks
 }
 
+// GetBlocksFromGenesis returns the whole chain, starting with the genesis block
+func (bc *Blockchain) GetBlocksFromGenesis() []*Block {
+	bc.RLock()
+	defer bc.RUnlock()
+
+	blocks := make([]*Block, 0)
+	for curr := bc.latestBlock; curr != nil; curr = bc.blocksStore[curr.PrevHash] {
+		blocks = append(blocks, curr)
+	}
+	for i, j := 0, len(blocks)-1; i < j; i, j = i+1, j-1 {
+		blocks[i], blocks[j] = blocks[j], blocks[i]
+	}
+
+	return blocks
+}
+
 // GetLatestBlock returns the latest block
 func (bc *Blockchain) GetLatestBlock() *Block {
 	bc.RLock()
@@ -161,7 +224,7 @@ const (
 )
 
 // GetBalance returns the current account balance of the address
-func (bc *Blockchain) GetBalance(addr string) float64 {
+func (bc *Blockchain) GetBalance(addr string) Amount {
 	bc.RLock()
 	defer bc.RUnlock()
 
@@ -173,6 +236,31 @@ func (bc *Blockchain) GetBalance(addr string) float64 {
 	return account.balance
 }
 
+// GetStake returns the current stake of the address
+func (bc *Blockchain) GetStake(addr string) Amount {
+	bc.RLock()
+	defer bc.RUnlock()
+
+	if bc.latestBlock == nil {
+		return 0
+	}
+
+	account := GetAccountFromWorldState(bc.latestBlock.States, addr)
+	return account.stake
+}
+
+// SearchAssets returns the registered assets matching the query
+func (bc *Blockchain) SearchAssets(query string) []AssetInfo {
+	bc.RLock()
+	defer bc.RUnlock()
+
+	if bc.latestBlock == nil {
+		return []AssetInfo{}
+	}
+
+	return SearchAssets(bc.latestBlock.States, query)
+}
+
 // GetTxn checks if the target transaction is in blockchain
 func (bc *Blockchain) GetTxn(txnID string) *SignedTransaction {
 	bc.RLock()
@@ -193,6 +281,27 @@ func (bc *Blockchain) GetTxn(txnID string) *SignedTransaction {
 	return nil
 }
 
+// GetMPCEndorsement returns the endorsement record created by the PreMPC
+// transaction, as it was in the block including the transaction
+func (bc *Blockchain) GetMPCEndorsement(uniqID string) (*MPCEndorsement, error) {
+	bc.RLock()
+	defer bc.RUnlock()
+
+	curBlock := bc.latestBlock
+	ok := curBlock != nil
+	for ok {
+		if txn := curBlock.GetTxn(uniqID); txn != nil {
+			worldState, err := bc.stateAt(curBlock)
+			if err != nil {
+				return nil, err
+			}
+			return GetMPCEndorsementFromWorldState(worldState, mpcKeyFromUniqID(uniqID))
+		}
+		curBlock, ok = bc.blocksStore[curBlock.PrevHash]
+	}
+	return nil, fmt.Errorf("MPC %s not found in blockchain", uniqID)
+}
+
 // CheckBlockHeight checks if a block can be appended to the end of chain
 func (bc *Blockchain) CheckBlockHeight(block *Block) BlockHeightCompareResult {
 	// block can only be the latest height+1
@@ -211,11 +320,13 @@ func (bc *Blockchain) Sprint() string {
 	description := ""
 	for block != nil {
 		description += fmt.Sprintf("-------------Block %d-------------\n", block.Height)
+		description += fmt.Sprintf("Mined by %s at %s\n",
+			block.Miner, block.GetTime().Format(time.RFC3339Nano))
 		for idx, txn := range block.Transactions {
 			description += fmt.Sprintf("Txn %d [%s] (%s): \n", idx, txn.Txn.Type, txn.Txn.ID)
 			description += fmt.Sprintf("\tFrom: %s\n", txn.Txn.From)
 			description += fmt.Sprintf("\tTo: %s\n", txn.Txn.To)
-			description += fmt.Sprintf("\tValue: %f\n", txn.Txn.Value)
+			description += fmt.Sprintf("\tValue: %s\n", txn.Txn.Value)
 			if txn.Txn.Data == nil {
 				continue
 			}
@@ -253,16 +364,187 @@ func (bc *Blockchain) AppendBlock(block *Block) error {
 			bc.latestBlock.Hash(), block.PrevHash)
 	}
 
+	_, err := bc.addBlock(block)
+	return err
+}
+
+// -----------------------------------------------------------------------------
+// Utilities - Forks
+
+// Reorg describes how the canonical chain changed when a block was added
+type Reorg struct {
+	// blocks leaving the canonical chain, from the old tip backward
+	Removed []*Block
+	// blocks joining the canonical chain, from the fork point forward
+	Added []*Block
+}
+
+// AddBlock adds a block extending any known block, not only the latest one.
+// Blocks of competing branches are kept and the canonical chain switches
+// to the branch preferred by the fork-choice rule. It returns how the
+// canonical chain changed, nil if it did not
+func (bc *Blockchain) AddBlock(block *Block) (*Reorg, error) {
+	bc.Lock()
+	defer bc.Unlock()
+
+	if bc.latestBlock == nil {
+		return nil, fmt.Errorf("need to set genesis block first")
+	}
+
+	return bc.addBlock(block)
+}
+
+// GetBranchTips returns the tips of all known branches, the canonical one first
+func (bc *Blockchain) GetBranchTips() []*Block {
+	bc.RLock()
+	defer bc.RUnlock()
+
+	if bc.latestBlock == nil {
+		return []*Block{}
+	}
+
+	parents := map[string]struct{}{}
+	for _, block := range bc.blocksStore {
+		parents[block.PrevHash] = struct{}{}
+	}
+	others := make([]*Block, 0)
+	for hash, block := range bc.blocksStore {
+		if _, ok := parents[hash]; !ok && block != bc.latestBlock {
+			others = append(others, block)
+		}
+	}
+	sort.Slice(others, func(i, j int) bool {
+		return bc.preferred(others[i], others[j])
+	})
+	return append([]*Block{bc.latestBlock}, others...)
+}
+
+// addBlock is the unlocked version of AddBlock
+func (bc *Blockchain) addBlock(block *Block) (*Reorg, error) {
+	hash := block.Hash()
+	if _, ok := bc.blocksStore[hash]; ok {
+		return nil, fmt.Errorf("block %s already in the chain", hash)
+	}
+	parent, ok := bc.blocksStore[block.PrevHash]
+	if !ok {
+		return nil, fmt.Errorf("unknown parent block %s", block.PrevHash)
+	}
+	if block.Height != parent.Height+1 {
+		return nil, fmt.Errorf("invalid block height. Expected: %d, Got: %d",
+			parent.Height+1, block.Height)
+	}
+	// final blocks are never reverted
+	if parent != bc.latestBlock && !bc.isAncestor(bc.finalBlock, parent) {
+		return nil, fmt.Errorf("block %s does not extend the final block %s",
+			hash, bc.finalBlock.Hash())
+	}
+
 	// verify block
-	err := block.Verify(bc.latestBlock.GetWorldStateCopy())
+	err := checkTimestamp(parent, block)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	// the parent's state may have been pruned on a long reorganisation
+	parentState, err := bc.stateAt(parent)
+	if err != nil {
+		return nil, err
+	}
+	err = block.Verify(storage.NewLayeredKV(parentState))
+	if err != nil {
+		return nil, err
 	}
 
-	// extends the blockchain
-	bc.blocksStore[block.Hash()] = block
+	if bc.store != nil {
+		err = persistBlock(bc.store, block)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// extends the branch
+	bc.blocksStore[hash] = block
+	bc.weights[hash] = bc.weights[parent.Hash()] + minerWeight(parentState, block)
+	if !bc.preferred(block, bc.latestBlock) {
+		return nil, nil
+	}
+
+	reorg := bc.reorgTo(block)
 	bc.latestBlock = block
+	if bc.store != nil {
+		persistTip(bc.store, block)
+	}
+	// the blocks leaving and joining the canonical chain keep their state
+	bc.prune(reorg.Added[0].Height - 1)
+	return reorg, nil
+}
 
+// preferred implements the fork-choice rule. It returns true if the branch
+// ending with a is preferred over the one ending with b:
+//   - the longest branch wins
+//   - then the branch whose miners had the most weight, i.e. balance plus
+//     stake, when they mined. This is the branch SelectMiner favours
+//   - then the tip with the smallest hash
+func (bc *Blockchain) preferred(a *Block, b *Block) bool {
+	if a.Height != b.Height {
+		return a.Height > b.Height
+	}
+
+	weightA, weightB := bc.weights[a.Hash()], bc.weights[b.Hash()]
+	if weightA != weightB {
+		return weightA > weightB
+	}
+	return a.Hash() < b.Hash()
+}
+
+// reorgTo computes the blocks leaving and joining the canonical chain
+// when the tip becomes the given block
+func (bc *Blockchain) reorgTo(tip *Block) *Reorg {
+	reorg := Reorg{
+		Removed: make([]*Block, 0),
+		Added:   make([]*Block, 0),
+	}
+
+	oldBlock, newBlock := bc.latestBlock, tip
+	for newBlock.Height > oldBlock.Height {
+		reorg.Added = append(reorg.Added, newBlock)
+		newBlock = bc.blocksStore[newBlock.PrevHash]
+	}
+	for oldBlock.Height > newBlock.Height {
+		reorg.Removed = append(reorg.Removed, oldBlock)
+		oldBlock = bc.blocksStore[oldBlock.PrevHash]
+	}
+	for oldBlock.Hash() != newBlock.Hash() {
+		reorg.Removed = append(reorg.Removed, oldBlock)
+		reorg.Added = append(reorg.Added, newBlock)
+		oldBlock = bc.blocksStore[oldBlock.PrevHash]
+		newBlock = bc.blocksStore[newBlock.PrevHash]
+	}
+
+	// from the fork point forward
+	for i, j := 0, len(reorg.Added)-1; i < j; i, j = i+1, j-1 {
+		reorg.Added[i], reorg.Added[j] = reorg.Added[j], reorg.Added[i]
+	}
+	return &reorg
+}
+
+// minerWeight returns the selection weight of the block's miner in the
+// state of its parent
+func minerWeight(parentState storage.KVStore, block *Block) Amount {
+	account := GetAccountFromWorldState(parentState, block.Miner)
+	return account.balance + account.stake
+}
+
+// checkTimestamp checks that the block is not older than its parent and
+// not too far ahead of the local clock
+func checkTimestamp(parent *Block, block *Block) error {
+	if parent != nil && block.Timestamp < parent.Timestamp {
+		return fmt.Errorf("block %s is older than its parent. Parent: %s, Got: %s",
+			block.Hash(), parent.GetTime(), block.GetTime())
+	}
+	if limit := time.Now().Add(MAX_CLOCK_DRIFT); block.GetTime().After(limit) {
+		return fmt.Errorf("block %s is too far in the future. Max: %s, Got: %s",
+			block.Hash(), limit, block.GetTime())
+	}
 	return nil
 }
 
@@ -272,18 +554,9 @@ func (bc *Blockchain) checkBlockHeight(block *Block) BlockHeightCompareResult {
 		return BlockCompareNotInitialize
 	}
 
-	// block can only be the latest height+1
+	// block can only be the latest height+1.
+	// Competing blocks are handled by AddBlock
 	lastHeight := bc.latestBlock.Height
-	// lastPrevBlck := bc.latestBlocks.PrevHash
-	// if height equal, then it is a fork
-	// if lastHeight == block.Height {
-	// 	if block.PrevHash != lastPrevBlck {
-	// 		return fmt.Errorf("block with invalid prev hash. Expected: %s, Got: %s", lastPrevBlck, block.PrevHash)
-	// 	}
-	// 	bc.latestBlocks = append(bc.latestBlocks, &block)
-	// 	return nil
-	// }
-	// if height != lastHeight, then invalid block, too new or too old
 	if lastHeight+1 > block.Height {
 		return BlockCompareStale
 	}
@@ -295,3 +568,78 @@ func (bc *Blockchain) checkBlockHeight(block *Block) BlockHeightCompareResult {
 	}
 	return BlockCompareMatched
 }
+
+// load restores the chain saved in the store. Blocks get their world state
+// from their snapshot if any, otherwise by replaying them on the parent state
+func (bc *Blockchain) load() error {
+	lastHash := bc.store.Get(STORE_LAST_BLOCK_KEY)
+	if lastHash == nil {
+		return nil
+	}
+
+	// walk back to the genesis block
+	chain := make([]*Block, 0)
+	hash := string(lastHash)
+	for {
+		block, err := loadBlock(bc.store, hash)
+		if err != nil {
+			return err
+		}
+		chain = append(chain, block)
+
+		if block.Height == 0 {
+			if block.PrevHash != DUMMY_PREVHASH {
+				return fmt.Errorf("stored genesis block %s has prevHash %s",
+					hash, block.PrevHash)
+			}
+			break
+		}
+		hash = block.PrevHash
+	}
+
+	// replay forward
+	blocksStore := map[string]*Block{}
+	weights := map[string]Amount{}
+	var prev *Block
+	for i := len(chain) - 1; i >= 0; i-- {
+		block := chain[i]
+		if prev != nil && block.Height != prev.Height+1 {
+			return fmt.Errorf("stored block %s has height %d after height %d",
+				block.Hash(), block.Height, prev.Height)
+		}
+
+		if states := loadSnapshot(bc.store, block); states != nil {
+			block.States = states
+		} else {
+			var worldState storage.KVStore = storage.NewBasicKV()
+			if prev != nil {
+				worldState = prev.GetWorldStateCopy()
+			}
+			err := block.Verify(worldState)
+			if err != nil {
+				return fmt.Errorf("failed to replay stored block %s: %v", block.Hash(), err)
+			}
+		}
+
+		blocksStore[block.Hash()] = block
+		weights[block.Hash()] = 0
+		if prev != nil {
+			weights[block.Hash()] = weights[prev.Hash()] + minerWeight(prev.States, block)
+		}
+		prev = block
+	}
+
+	bc.blocksStore = blocksStore
+	bc.weights = weights
+	bc.latestBlock = prev
+
+	// blocks are final up to the stored final block, or the genesis block
+	bc.finalBlock = chain[len(chain)-1]
+	if finalHash := bc.store.Get(STORE_FINAL_BLOCK_KEY); finalHash != nil {
+		if block, ok := blocksStore[string(finalHash)]; ok {
+			bc.finalBlock = block
+		}
+	}
+	bc.prune(bc.latestBlock.Height)
+	return nil
+}
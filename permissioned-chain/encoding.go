@@ -0,0 +1,451 @@
+package permissioned
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+)
+
+// -----------------------------------------------------------------------------
+// Canonical Encoding
+
+// ENCODING_VERSION prefixes every canonical encoding, so that a node
+// rejects what it cannot read instead of decoding it wrongly
+const ENCODING_VERSION byte = 1
+
+// The canonical encoding is a deterministic binary format derived from the
+// Go types. It is used for hashing, signing, gossip and storage:
+//   - bool: one byte, 0 or 1
+//   - signed integers: zigzag varint. Unsigned integers: uvarint
+//   - floats: IEEE 754 bits, 8 bytes big endian
+//   - strings, byte slices: uvarint length followed by the bytes
+//   - slices, arrays: uvarint length followed by the elements
+//   - maps: uvarint length followed by the entries sorted by encoded key
+//   - structs: exported fields in declaration order
+//   - pointers: one byte telling if set, followed by the element
+//
+// Types with unexported fields or polymorphic data, e.g. Account and
+// Transaction, implement canonicalMarshaler and canonicalUnmarshaler
+
+type canonicalMarshaler interface {
+	encodeCanonical(e *encoder) error
+}
+
+type canonicalUnmarshaler interface {
+	decodeCanonical(d *decoder) error
+}
+
+var (
+	marshalerType   = reflect.TypeOf((*canonicalMarshaler)(nil)).Elem()
+	unmarshalerType = reflect.TypeOf((*canonicalUnmarshaler)(nil)).Elem()
+)
+
+// Encode returns the canonical encoding of the value
+func Encode(value interface{}) ([]byte, error) {
+	e := encoder{buf: []byte{ENCODING_VERSION}}
+	err := e.value(reflect.ValueOf(value))
+	if err != nil {
+		return nil, err
+	}
+	return e.buf, nil
+}
+
+// Decode decodes a canonical encoding into the value, which must be a
+// pointer. The whole buffer must be consumed
+func Decode(buf []byte, value interface{}) error {
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Pointer || v.IsNil() {
+		return fmt.Errorf("decode needs a non-nil pointer. Got: %T", value)
+	}
+	if len(buf) == 0 {
+		return fmt.Errorf("empty encoding")
+	}
+	if buf[0] != ENCODING_VERSION {
+		return fmt.Errorf("unsupported encoding version %d. Expected: %d", buf[0], ENCODING_VERSION)
+	}
+
+	d := decoder{buf: buf[1:]}
+	err := d.value(v.Elem())
+	if err != nil {
+		return err
+	}
+	if len(d.buf) > 0 {
+		return fmt.Errorf("%d trailing bytes after %T", len(d.buf), value)
+	}
+
+	// a value has a single encoding, e.g. no overlong varints or
+	// unsorted map keys
+	check, err := Encode(v.Elem().Interface())
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(check, buf) {
+		return fmt.Errorf("non-canonical encoding of %T", value)
+	}
+	return nil
+}
+
+// hashCanonical returns the hex-encoded sha256 of the canonical encoding.
+// It panics if the value cannot be encoded, like storage.Hash
+func hashCanonical(value interface{}) string {
+	return hex.EncodeToString(hashCanonicalBytes(value))
+}
+
+func hashCanonicalBytes(value interface{}) []byte {
+	buf, err := Encode(value)
+	if err != nil {
+		panic(err)
+	}
+	h := sha256.Sum256(buf)
+	return h[:]
+}
+
+// marshalCanonicalJSON carries the canonical encoding inside JSON, so that
+// gossip and stores built on JSON keep the exact bytes
+func marshalCanonicalJSON(value interface{}) ([]byte, error) {
+	buf, err := Encode(value)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(buf)
+}
+
+func unmarshalCanonicalJSON(data []byte, value interface{}) error {
+	var buf []byte
+	err := json.Unmarshal(data, &buf)
+	if err != nil {
+		return err
+	}
+	return Decode(buf, value)
+}
+
+// -----------------------------------------------------------------------------
+// Encoder
+
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) uvarint(v uint64) {
+	e.buf = binary.AppendUvarint(e.buf, v)
+}
+
+func (e *encoder) varint(v int64) {
+	e.buf = binary.AppendVarint(e.buf, v)
+}
+
+func (e *encoder) bytes(b []byte) {
+	e.uvarint(uint64(len(b)))
+	e.buf = append(e.buf, b...)
+}
+
+func (e *encoder) string(s string) {
+	e.uvarint(uint64(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+func (e *encoder) bool(b bool) {
+	if b {
+		e.buf = append(e.buf, 1)
+	} else {
+		e.buf = append(e.buf, 0)
+	}
+}
+
+func (e *encoder) value(v reflect.Value) error {
+	if !v.IsValid() {
+		return fmt.Errorf("cannot encode nil value")
+	}
+	if v.Kind() != reflect.Pointer && v.Type().Implements(marshalerType) {
+		return v.Interface().(canonicalMarshaler).encodeCanonical(e)
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		e.bool(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		e.varint(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		e.uvarint(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		e.buf = binary.BigEndian.AppendUint64(e.buf, math.Float64bits(v.Float()))
+	case reflect.String:
+		e.string(v.String())
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			e.bytes(v.Bytes())
+			return nil
+		}
+		return e.list(v)
+	case reflect.Array:
+		return e.list(v)
+	case reflect.Map:
+		return e.dict(v)
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if !v.Type().Field(i).IsExported() {
+				continue
+			}
+			err := e.value(v.Field(i))
+			if err != nil {
+				return err
+			}
+		}
+	case reflect.Pointer:
+		e.bool(!v.IsNil())
+		if !v.IsNil() {
+			return e.value(v.Elem())
+		}
+	default:
+		return fmt.Errorf("cannot encode %s", v.Type())
+	}
+	return nil
+}
+
+func (e *encoder) list(v reflect.Value) error {
+	e.uvarint(uint64(v.Len()))
+	for i := 0; i < v.Len(); i++ {
+		err := e.value(v.Index(i))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *encoder) dict(v reflect.Value) error {
+	type entry struct {
+		key   []byte
+		value reflect.Value
+	}
+	entries := make([]entry, 0, v.Len())
+	iter := v.MapRange()
+	for iter.Next() {
+		keyEncoder := encoder{}
+		err := keyEncoder.value(iter.Key())
+		if err != nil {
+			return err
+		}
+		entries = append(entries, entry{key: keyEncoder.buf, value: iter.Value()})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return bytes.Compare(entries[i].key, entries[j].key) < 0
+	})
+
+	e.uvarint(uint64(len(entries)))
+	for _, entry := range entries {
+		e.buf = append(e.buf, entry.key...)
+		err := e.value(entry.value)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// Decoder
+
+type decoder struct {
+	buf []byte
+}
+
+func (d *decoder) uvarint() (uint64, error) {
+	v, n := binary.Uvarint(d.buf)
+	if n <= 0 {
+		return 0, fmt.Errorf("invalid uvarint")
+	}
+	d.buf = d.buf[n:]
+	return v, nil
+}
+
+func (d *decoder) varint() (int64, error) {
+	v, n := binary.Varint(d.buf)
+	if n <= 0 {
+		return 0, fmt.Errorf("invalid varint")
+	}
+	d.buf = d.buf[n:]
+	return v, nil
+}
+
+// length reads a length prefix that cannot exceed the remaining bytes
+func (d *decoder) length() (int, error) {
+	n, err := d.uvarint()
+	if err != nil {
+		return 0, err
+	}
+	if n > uint64(len(d.buf)) {
+		return 0, fmt.Errorf("length %d exceeds the remaining %d bytes", n, len(d.buf))
+	}
+	return int(n), nil
+}
+
+func (d *decoder) bytes() ([]byte, error) {
+	n, err := d.length()
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, nil
+	}
+	b := make([]byte, n)
+	copy(b, d.buf[:n])
+	d.buf = d.buf[n:]
+	return b, nil
+}
+
+func (d *decoder) string() (string, error) {
+	b, err := d.bytes()
+	return string(b), err
+}
+
+func (d *decoder) bool() (bool, error) {
+	if len(d.buf) == 0 {
+		return false, fmt.Errorf("unexpected end of encoding")
+	}
+	b := d.buf[0]
+	d.buf = d.buf[1:]
+	if b > 1 {
+		return false, fmt.Errorf("invalid bool %d", b)
+	}
+	return b == 1, nil
+}
+
+func (d *decoder) value(v reflect.Value) error {
+	if v.CanAddr() && v.Addr().Type().Implements(unmarshalerType) {
+		return v.Addr().Interface().(canonicalUnmarshaler).decodeCanonical(d)
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		b, err := d.bool()
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := d.varint()
+		if err != nil {
+			return err
+		}
+		if v.OverflowInt(i) {
+			return fmt.Errorf("%d overflows %s", i, v.Type())
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := d.uvarint()
+		if err != nil {
+			return err
+		}
+		if v.OverflowUint(u) {
+			return fmt.Errorf("%d overflows %s", u, v.Type())
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		if len(d.buf) < 8 {
+			return fmt.Errorf("unexpected end of encoding")
+		}
+		v.SetFloat(math.Float64frombits(binary.BigEndian.Uint64(d.buf[:8])))
+		d.buf = d.buf[8:]
+	case reflect.String:
+		s, err := d.string()
+		if err != nil {
+			return err
+		}
+		v.SetString(s)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			b, err := d.bytes()
+			if err != nil {
+				return err
+			}
+			v.SetBytes(b)
+			return nil
+		}
+		n, err := d.length()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+		v.Set(reflect.MakeSlice(v.Type(), n, n))
+		return d.list(v)
+	case reflect.Array:
+		n, err := d.length()
+		if err != nil {
+			return err
+		}
+		if n != v.Len() {
+			return fmt.Errorf("array of %d elements for %s", n, v.Type())
+		}
+		return d.list(v)
+	case reflect.Map:
+		return d.dict(v)
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if !v.Type().Field(i).IsExported() {
+				continue
+			}
+			err := d.value(v.Field(i))
+			if err != nil {
+				return err
+			}
+		}
+	case reflect.Pointer:
+		set, err := d.bool()
+		if err != nil {
+			return err
+		}
+		if !set {
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+		v.Set(reflect.New(v.Type().Elem()))
+		return d.value(v.Elem())
+	default:
+		return fmt.Errorf("cannot decode %s", v.Type())
+	}
+	return nil
+}
+
+func (d *decoder) list(v reflect.Value) error {
+	for i := 0; i < v.Len(); i++ {
+		err := d.value(v.Index(i))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dict decodes a map. Maps are never nil once decoded
+func (d *decoder) dict(v reflect.Value) error {
+	n, err := d.length()
+	if err != nil {
+		return err
+	}
+
+	v.Set(reflect.MakeMapWithSize(v.Type(), n))
+	for i := 0; i < n; i++ {
+		key := reflect.New(v.Type().Key()).Elem()
+		err := d.value(key)
+		if err != nil {
+			return err
+		}
+		value := reflect.New(v.Type().Elem()).Elem()
+		err = d.value(value)
+		if err != nil {
+			return err
+		}
+		v.SetMapIndex(key, value)
+	}
+	return nil
+}
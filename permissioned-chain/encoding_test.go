@@ -0,0 +1,117 @@
+package permissioned
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/cs438/storage"
+)
+
+func Test_Encoding_Txn_RoundTrip(t *testing.T) {
+	privKey, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	account := NewAccount(*NewAddress(&privKey.PublicKey))
+
+	txn := NewTransactionRegAssetsWithDetails(account, AssetsRegistration{
+		Assets: map[string]Amount{"b": 2, "a": 1},
+		Policies: map[string]AssetPolicy{
+			"a": {AllowedInitiators: []string{"x"}},
+		},
+	})
+	signedTxn, err := txn.Sign(privKey)
+	require.NoError(t, err)
+
+	buf, err := Encode(*signedTxn)
+	require.NoError(t, err)
+	require.Equal(t, ENCODING_VERSION, buf[0])
+
+	var decoded SignedTransaction
+	err = Decode(buf, &decoded)
+	require.NoError(t, err)
+	require.Equal(t, signedTxn.Txn.ID, decoded.Txn.ID)
+	require.Equal(t, signedTxn.Hash(), decoded.Hash())
+	require.Equal(t, txn.Data.(AssetsRegistration).Assets,
+		decoded.Txn.Data.(AssetsRegistration).Assets)
+
+	// > the encoding does not depend on the map iteration order
+	for i := 0; i < 10; i++ {
+		again, err := Encode(*signedTxn)
+		require.NoError(t, err)
+		require.Equal(t, buf, again)
+	}
+}
+
+func Test_Encoding_Txn_WrongData(t *testing.T) {
+	txn := Transaction{Type: TxnTypeStake, Data: "data"}
+	_, err := Encode(txn)
+	require.Error(t, err)
+
+	txn = Transaction{Type: TxnTypeRegEnckey, Data: 1}
+	_, err = Encode(txn)
+	require.Error(t, err)
+
+	// > unknown types are not decoded
+	buf, err := Encode(Transaction{Type: "unknown"})
+	require.NoError(t, err)
+	err = Decode(buf, &Transaction{})
+	require.Error(t, err)
+}
+
+func Test_Encoding_Rejects_NonCanonical(t *testing.T) {
+	buf, err := Encode(map[string]uint{"a": 1, "b": 2})
+	require.NoError(t, err)
+
+	var decoded map[string]uint
+	err = Decode(buf, &decoded)
+	require.NoError(t, err)
+	require.Equal(t, map[string]uint{"a": 1, "b": 2}, decoded)
+
+	// > entries out of order
+	swapped := []byte{ENCODING_VERSION, 2, 1, 'b', 2, 1, 'a', 1}
+	err = Decode(swapped, &decoded)
+	require.Error(t, err)
+
+	// > overlong varint
+	err = Decode([]byte{ENCODING_VERSION, 0x81, 0x00}, new(uint))
+	require.Error(t, err)
+
+	// > trailing bytes
+	err = Decode(append(buf, 0), &decoded)
+	require.Error(t, err)
+
+	// > unknown version
+	buf[0] = ENCODING_VERSION + 1
+	err = Decode(buf, &decoded)
+	require.Error(t, err)
+}
+
+func Test_Encoding_JSON(t *testing.T) {
+	privKey, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	account := NewAccount(*NewAddress(&privKey.PublicKey))
+	signedTxn, err := NewTransactionStake(account, Coins(1)).Sign(privKey)
+	require.NoError(t, err)
+
+	block := NewBlockBuilder().SetPrevHash("prev").SetHeight(1).SetMiner(account.GetAddress().Hex).
+		SetState(storage.NewBasicKV())
+	require.NoError(t, block.AddTxn(signedTxn))
+	expected := block.Build()
+	require.NoError(t, expected.Sign(privKey))
+
+	buf, err := json.Marshal(expected)
+	require.NoError(t, err)
+
+	var decoded Block
+	err = json.Unmarshal(buf, &decoded)
+	require.NoError(t, err)
+	require.Equal(t, expected.Hash(), decoded.Hash())
+	require.Equal(t, expected.Signature, decoded.Signature)
+	require.Len(t, decoded.Transactions, 1)
+	require.Equal(t, signedTxn.Txn.ID, decoded.Transactions[0].Txn.ID)
+
+	signer, err := decoded.Signer()
+	require.NoError(t, err)
+	require.Equal(t, account.GetAddress().Hex, signer)
+}
@@ -0,0 +1,247 @@
+package permissioned
+
+import (
+	"fmt"
+
+	"go.dedis.ch/cs438/storage"
+)
+
+// -----------------------------------------------------------------------------
+// Events
+
+type EventType string
+
+const (
+	// EventBlock is sent when a block joins the canonical chain
+	EventBlock EventType = "block"
+	// EventTxn is sent for each txn of a block joining the canonical chain
+	EventTxn EventType = "txn"
+	// EventRevert is sent for each txn of a block leaving the canonical
+	// chain on a reorganisation
+	EventRevert EventType = "revert"
+	// EventMPC is sent when a txn of a block changes the state of an MPC
+	EventMPC EventType = "mpc"
+)
+
+// Event is delivered to the subscribers of a node
+type Event struct {
+	Type      EventType
+	Height    uint
+	BlockHash string
+	// set for all events but EventBlock
+	Txn *Transaction `json:",omitempty"`
+	// set for EventMPC
+	MPC *MPCState `json:",omitempty"`
+}
+
+// MPCState is the state of an MPC after a block
+type MPCState struct {
+	UniqID string
+	// nil if the MPC is not in the world state anymore
+	Endorsement *MPCEndorsement `json:",omitempty"`
+}
+
+// String implements Describable.String()
+func (e Event) String() string {
+	if e.Txn == nil {
+		return fmt.Sprintf("%s at height %d: %s", e.Type, e.Height, e.BlockHash)
+	}
+	return fmt.Sprintf("%s at height %d: %s (%s)", e.Type, e.Height, e.Txn.ID, e.Txn.Type)
+}
+
+// NewBlockEvents returns the events of a block joining the canonical chain
+// given the world state after the block
+func NewBlockEvents(block *Block, worldState storage.KVStore) []Event {
+	events := []Event{{
+		Type:      EventBlock,
+		Height:    block.Height,
+		BlockHash: block.Hash(),
+	}}
+	for i := range block.Transactions {
+		txn := block.Transactions[i].Txn
+		events = append(events, Event{
+			Type:      EventTxn,
+			Height:    block.Height,
+			BlockHash: block.Hash(),
+			Txn:       &txn,
+		})
+
+		uniqID, ok := TxnMPCID(&txn)
+		if !ok {
+			continue
+		}
+		state := &MPCState{UniqID: uniqID}
+		endorsement, err := GetMPCEndorsementFromWorldState(worldState, mpcKeyFromUniqID(uniqID))
+		if err == nil {
+			state.Endorsement = endorsement
+		}
+		events = append(events, Event{
+			Type:      EventMPC,
+			Height:    block.Height,
+			BlockHash: block.Hash(),
+			Txn:       &txn,
+			MPC:       state,
+		})
+	}
+	return events
+}
+
+// NewRevertEvents returns the events of a block leaving the canonical
+// chain, from its last txn to its first
+func NewRevertEvents(block *Block) []Event {
+	events := make([]Event, 0, len(block.Transactions))
+	for i := len(block.Transactions) - 1; i >= 0; i-- {
+		txn := block.Transactions[i].Txn
+		events = append(events, Event{
+			Type:      EventRevert,
+			Height:    block.Height,
+			BlockHash: block.Hash(),
+			Txn:       &txn,
+		})
+	}
+	return events
+}
+
+// -----------------------------------------------------------------------------
+// Filters
+
+// EventFilter selects the events of a subscription. An empty field matches
+// everything. The fields on txns never match an EventBlock
+type EventFilter struct {
+	Types    []EventType
+	TxnTypes []TxnType
+	Accounts []string
+	Assets   []string
+	MPCs     []string
+}
+
+// Match checks whether the event passes the filter
+func (f EventFilter) Match(event Event) bool {
+	if len(f.Types) > 0 && !containsEventType(f.Types, event.Type) {
+		return false
+	}
+	if event.Txn == nil {
+		return len(f.TxnTypes) == 0 && len(f.Accounts) == 0 &&
+			len(f.Assets) == 0 && len(f.MPCs) == 0
+	}
+
+	txn := event.Txn
+	if len(f.TxnTypes) > 0 && !containsTxnType(f.TxnTypes, txn.Type) {
+		return false
+	}
+	if len(f.Accounts) > 0 && !intersects(f.Accounts, TxnAccounts(txn)) {
+		return false
+	}
+	if len(f.Assets) > 0 && !intersects(f.Assets, TxnAssets(txn)) {
+		return false
+	}
+	if len(f.MPCs) > 0 {
+		uniqID, ok := TxnMPCID(txn)
+		if !ok || !containsString(f.MPCs, uniqID) {
+			return false
+		}
+	}
+	return true
+}
+
+// TxnAccounts returns the addresses a txn is about
+func TxnAccounts(txn *Transaction) []string {
+	accounts := []string{txn.From}
+	if txn.To != "" && txn.To != ZeroAddress.Hex {
+		accounts = append(accounts, txn.To)
+	}
+
+	switch data := txn.Data.(type) {
+	case AssetTransfer:
+		accounts = append(accounts, data.To)
+	case SlashEvidence:
+		accounts = append(accounts, data.Offender)
+	case KeyRotation:
+		accounts = append(accounts, data.NewAddress)
+	case KeyRevocation:
+		accounts = append(accounts, data.Address, data.NewAddress)
+	case MultisigPolicy:
+		accounts = append(accounts, data.Owners...)
+	}
+	return accounts
+}
+
+// TxnAssets returns the keys of the assets a txn is about. The assets of an
+// MPC are the variables of its expression
+func TxnAssets(txn *Transaction) []string {
+	assets := []string{}
+
+	switch data := txn.Data.(type) {
+	case AssetsRegistration:
+		for key := range data.Assets {
+			assets = append(assets, key)
+		}
+		for key := range data.Policies {
+			assets = append(assets, key)
+		}
+		for key := range data.Schemas {
+			assets = append(assets, key)
+		}
+	case map[string]PriceSchedule:
+		for key := range data {
+			assets = append(assets, key)
+		}
+	case map[string]Amount:
+		for key := range data {
+			assets = append(assets, key)
+		}
+	case AssetsRemoval:
+		assets = append(assets, data.Keys...)
+	case AssetTransfer:
+		assets = append(assets, data.Key)
+	case MPCPropose:
+		_, variables, err := GetPostfixAndVariables(data.Expression)
+		if err != nil {
+			return assets
+		}
+		for key := range variables {
+			assets = append(assets, key)
+		}
+	}
+	return assets
+}
+
+// TxnMPCID returns the unique ID of the MPC a txn is about
+func TxnMPCID(txn *Transaction) (string, bool) {
+	switch data := txn.Data.(type) {
+	case MPCPropose:
+		return txn.ID, true
+	case MPCRecord:
+		return data.UniqID, true
+	case SlashEvidence:
+		return data.UniqID, true
+	}
+	return "", false
+}
+
+func containsEventType(types []EventType, eventType EventType) bool {
+	for _, t := range types {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+func containsTxnType(types []TxnType, txnType TxnType) bool {
+	for _, t := range types {
+		if t == txnType {
+			return true
+		}
+	}
+	return false
+}
+
+func intersects(a, b []string) bool {
+	for _, s := range b {
+		if containsString(a, s) {
+			return true
+		}
+	}
+	return false
+}
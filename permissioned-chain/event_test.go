@@ -0,0 +1,62 @@
+package permissioned
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/cs438/storage"
+)
+
+func Test_Event_Filter(t *testing.T) {
+	_, owner := newKeyAccount(t)
+	_, buyer := newKeyAccount(t)
+
+	transfer := NewTransactionTransferAsset(&owner, AssetTransfer{Key: "a", To: buyer.addr.Hex})
+	preMPC := NewTransactionPreMPC(&buyer, MPCPropose{
+		Initiator:  buyer.addr.Hex,
+		Expression: "a*b",
+	})
+	postMPC := NewTransactionPostMPC(&owner, MPCRecord{UniqID: preMPC.ID})
+
+	worldState := storage.NewBasicKV()
+	block := NewBlockBuilder().SetHeight(1).SetPrevHash("prev").SetState(worldState).Build()
+	block.Transactions = []SignedTransaction{{Txn: *transfer}, {Txn: *preMPC}, {Txn: *postMPC}}
+
+	events := NewBlockEvents(block, block.States)
+	require.Len(t, events, 6)
+	require.Equal(t, EventBlock, events[0].Type)
+	require.Equal(t, EventMPC, events[3].Type)
+	require.Equal(t, preMPC.ID, events[3].MPC.UniqID)
+
+	match := func(filter EventFilter) []EventType {
+		matched := []EventType{}
+		for _, event := range events {
+			if filter.Match(event) {
+				matched = append(matched, event.Type)
+			}
+		}
+		return matched
+	}
+
+	require.Len(t, match(EventFilter{}), 6)
+	require.Equal(t, []EventType{EventBlock}, match(EventFilter{Types: []EventType{EventBlock}}))
+
+	// > the receiver of an asset is one of the accounts of the transfer
+	require.Equal(t, []EventType{EventTxn, EventTxn, EventMPC},
+		match(EventFilter{Accounts: []string{buyer.addr.Hex}}))
+
+	// > the variables of an expression are the assets of an MPC
+	require.Equal(t, []EventType{EventTxn, EventTxn, EventMPC},
+		match(EventFilter{Assets: []string{"a"}}))
+	require.Equal(t, []EventType{EventTxn, EventMPC},
+		match(EventFilter{Assets: []string{"b"}}))
+
+	require.Equal(t, []EventType{EventMPC, EventMPC},
+		match(EventFilter{Types: []EventType{EventMPC}, MPCs: []string{preMPC.ID}}))
+	require.Empty(t, match(EventFilter{TxnTypes: []TxnType{TxnTypeStake}}))
+
+	// > a revert goes backward
+	reverts := NewRevertEvents(block)
+	require.Len(t, reverts, 3)
+	require.Equal(t, postMPC.ID, reverts[0].Txn.ID)
+}
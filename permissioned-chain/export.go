@@ -0,0 +1,138 @@
+package permissioned
+
+import (
+	"fmt"
+
+	"go.dedis.ch/cs438/storage"
+)
+
+// -----------------------------------------------------------------------------
+// Utilities - Export
+
+// ChainExport is a copy of the canonical chain taken out of a node
+type ChainExport struct {
+	// from the genesis block to the tip. States are left empty
+	Blocks []*Block
+	// world states after some of the blocks, by block hash
+	Snapshots map[string]storage.KVStore
+}
+
+// chainExport is the encoded form of a ChainExport
+type chainExport struct {
+	Blocks    []persistedBlock
+	Snapshots []exportedSnapshot
+}
+
+// exportedSnapshot is the world state after the block of the given height.
+// The entries are the ones of a persisted snapshot
+type exportedSnapshot struct {
+	Height  uint
+	Entries []byte
+}
+
+// Export returns the canonical chain. With snapshots, the world state of
+// every SNAPSHOT_INTERVAL blocks and of the tip is included. Pruned states
+// are replayed
+func (bc *Blockchain) Export(snapshots bool) ([]byte, error) {
+	blocks := bc.GetBlocksFromGenesis()
+	if len(blocks) == 0 {
+		return nil, fmt.Errorf("chain not initialized")
+	}
+
+	export := chainExport{
+		Blocks:    make([]persistedBlock, len(blocks)),
+		Snapshots: []exportedSnapshot{},
+	}
+	for i, block := range blocks {
+		export.Blocks[i] = persistedBlock{
+			Header:       *block.BlockHeader,
+			Transactions: block.Transactions,
+		}
+
+		if !snapshots || (block.Height%SNAPSHOT_INTERVAL != 0 && i != len(blocks)-1) {
+			continue
+		}
+		worldState, err := bc.StateAt(block.Hash())
+		if err != nil {
+			return nil, err
+		}
+		entries, err := encodeSnapshot(worldState)
+		if err != nil {
+			return nil, err
+		}
+		export.Snapshots = append(export.Snapshots, exportedSnapshot{
+			Height:  block.Height,
+			Entries: entries,
+		})
+	}
+
+	return Encode(export)
+}
+
+// DecodeExport reads an exported chain. The blocks are not verified, see
+// Import and Audit
+func DecodeExport(buf []byte) (*ChainExport, error) {
+	var export chainExport
+	err := Decode(buf, &export)
+	if err != nil {
+		return nil, err
+	}
+	if len(export.Blocks) == 0 {
+		return nil, fmt.Errorf("exported chain has no block")
+	}
+
+	chain := ChainExport{
+		Blocks:    make([]*Block, len(export.Blocks)),
+		Snapshots: map[string]storage.KVStore{},
+	}
+	for i := range export.Blocks {
+		chain.Blocks[i] = &Block{
+			BlockHeader:  &export.Blocks[i].Header,
+			Transactions: export.Blocks[i].Transactions,
+		}
+	}
+	for _, snapshot := range export.Snapshots {
+		if snapshot.Height >= uint(len(chain.Blocks)) {
+			return nil, fmt.Errorf("snapshot at height %d is after the tip", snapshot.Height)
+		}
+		worldState, err := decodeSnapshot(snapshot.Entries)
+		if err != nil {
+			return nil, fmt.Errorf("invalid snapshot at height %d: %v", snapshot.Height, err)
+		}
+		chain.Snapshots[chain.Blocks[snapshot.Height].Hash()] = worldState
+	}
+
+	return &chain, nil
+}
+
+// Import fills an empty blockchain with an exported chain. Each block is
+// verified on the state of its parent as if received from the network,
+// including the miner selection. Snapshots are not trusted, the states are
+// replayed
+func (bc *Blockchain) Import(export *ChainExport) error {
+	if bc.GetLatestBlock() != nil {
+		return fmt.Errorf("chain already initialized")
+	}
+
+	for _, block := range export.Blocks {
+		imported := &Block{
+			BlockHeader:  block.BlockHeader,
+			Transactions: block.Transactions,
+		}
+
+		var err error
+		if imported.Height == 0 {
+			err = bc.SetGenesisBlock(imported)
+		} else {
+			err = bc.VerifyMinerSelection(imported)
+			if err == nil {
+				err = bc.AppendBlock(imported)
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("failed to import block %s at height %d: %v",
+				imported.Hash(), imported.Height, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package permissioned
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/cs438/storage"
+	"go.dedis.ch/cs438/storage/inmemory"
+)
+
+func Test_BC_Export_Import(t *testing.T) {
+	privKey, account, bc := newPersistedChain(t, inmemory.NewPersistency().GetBlockchainStore())
+
+	appendTxnBlock(t, bc, privKey, NewTransactionRegAssets(account, map[string]Amount{
+		"key1": 1,
+	}))
+	account.nonce++
+	appendTxnBlock(t, bc, privKey, NewTransactionStake(account, 100))
+	account.nonce++
+	appendTxnBlock(t, bc, privKey, NewTransactionUnstake(account, 40))
+	latestBlock := bc.GetLatestBlock()
+
+	buf, err := bc.Export(true)
+	require.NoError(t, err)
+	export, err := DecodeExport(buf)
+	require.NoError(t, err)
+	require.Len(t, export.Blocks, 4)
+
+	// > the genesis block and the tip have a snapshot
+	require.Len(t, export.Snapshots, 2)
+	require.Equal(t, latestBlock.States.Hash(), export.Snapshots[latestBlock.Hash()].Hash())
+
+	// > a fresh node replays the imported chain and persists it
+	store := inmemory.NewPersistency().GetBlockchainStore()
+	imported, err := NewBlockchainWithStore(store)
+	require.NoError(t, err)
+	err = imported.Import(export)
+	require.NoError(t, err)
+	require.Equal(t, latestBlock.Hash(), imported.GetLatestBlock().Hash())
+	require.Equal(t, latestBlock.States.Hash(), imported.GetLatestBlock().States.Hash())
+
+	restored, err := NewBlockchainWithStore(store)
+	require.NoError(t, err)
+	require.Equal(t, Amount(60), restored.GetStake(account.addr.Hex))
+
+	err = imported.Import(export)
+	require.Error(t, err)
+}
+
+func Test_BC_Audit(t *testing.T) {
+	privKey, account, bc := newPersistedChain(t, inmemory.NewPersistency().GetBlockchainStore())
+
+	appendTxnBlock(t, bc, privKey, NewTransactionStake(account, 100))
+	account.nonce++
+	appendTxnBlock(t, bc, privKey, NewTransactionUnstake(account, 40))
+
+	buf, err := bc.Export(true)
+	require.NoError(t, err)
+	export, err := DecodeExport(buf)
+	require.NoError(t, err)
+
+	report := Audit(export)
+	require.Empty(t, report.Anomalies)
+	require.Equal(t, uint(2), report.Height)
+	require.Equal(t, 3, report.Blocks)
+	require.Equal(t, Amount(1000), report.Minted)
+	require.Equal(t, Amount(1000), report.Supply)
+	require.Equal(t, Amount(60), report.Staked)
+	require.Equal(t, Amount(940), report.Unlocked)
+
+	// > a snapshot not matching the replay is reported
+	tip := export.Blocks[2].Hash()
+	fake := export.Snapshots[tip].Copy()
+	fake.Put(account.addr.Hex, *NewAccount(account.addr))
+	export.Snapshots[tip] = fake
+	report = Audit(export)
+	require.Len(t, report.Anomalies, 1)
+	require.Equal(t, uint(2), report.Anomalies[0].Height)
+
+	// > the replay stops at a tampered block
+	export, err = DecodeExport(buf)
+	require.NoError(t, err)
+	export.Blocks[1].Transactions[0].Txn.Value = 1000
+	report = Audit(export)
+	require.Len(t, report.Anomalies, 1)
+	require.Equal(t, uint(1), report.Anomalies[0].Height)
+	require.Equal(t, uint(0), report.Height)
+
+	// > locked balances need an ongoing MPC
+	locked := *NewAccount(account.addr)
+	locked.lockedBalance = 10
+	worldState := storage.NewBasicKV()
+	worldState.Put(account.addr.Hex, locked)
+	messages := []string{}
+	auditBalances(worldState, &AuditReport{}, func(format string, a ...interface{}) {
+		messages = append(messages, format)
+	})
+	require.Len(t, messages, 1)
+}
+
+func Test_BC_Import_Audit_Miner_Selection(t *testing.T) {
+	privKeyA, accountA := newKeyAccount(t)
+	privKeyB, accountB := newKeyAccount(t)
+
+	config := *NewChainConfig(map[string]string{
+		accountA.addr.Hex: "",
+		accountB.addr.Hex: "",
+	}, 10, "2h", 0, 10)
+	bc := NewBlockchain()
+	block0, err := bc.InitGenesisBlock(&config, map[string]Amount{accountA.addr.Hex: 1000})
+	require.NoError(t, err)
+	require.NoError(t, bc.SetGenesisBlock(&block0))
+
+	// > only A holds something, hence is always selected. B mines anyway
+	miner, _ := SelectMiner(&block0)
+	require.Equal(t, accountA.addr.Hex, miner)
+
+	signedTxn, err := NewTransactionStake(&accountA, 100).Sign(privKeyA)
+	require.NoError(t, err)
+	worldState := block0.GetWorldStateCopy()
+	require.NoError(t, signedTxn.Verify(worldState))
+	bb := NewBlockBuilder()
+	bb.SetPrevHash(block0.Hash()).SetHeight(1).SetMiner(accountB.addr.Hex).
+		SetSelectionProof(SelectionSeed(block0.BlockHeader)).SetState(worldState)
+	bb.AddTxn(signedTxn)
+	block1 := bb.Build()
+	require.NoError(t, block1.Sign(privKeyB))
+	require.NoError(t, bc.AppendBlock(block1))
+
+	buf, err := bc.Export(false)
+	require.NoError(t, err)
+	export, err := DecodeExport(buf)
+	require.NoError(t, err)
+
+	// > the import rejects the block
+	imported := NewBlockchain()
+	err = imported.Import(export)
+	require.Error(t, err)
+	require.Equal(t, block0.Hash(), imported.GetLatestBlock().Hash())
+
+	// > the audit reports it
+	report := Audit(export)
+	require.Len(t, report.Anomalies, 1)
+	require.Equal(t, uint(1), report.Anomalies[0].Height)
+	require.Contains(t, report.Anomalies[0].Message, "miner was not selected")
+}
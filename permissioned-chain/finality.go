@@ -0,0 +1,171 @@
+package permissioned
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// -----------------------------------------------------------------------------
+// Utilities - Finality
+
+// BlockVote is the signature of a participant approving a block of the
+// canonical chain. A block voted by a quorum of participants is final
+type BlockVote struct {
+	BlockHash string
+	Height    uint
+	Signature []byte
+}
+
+// SignBlockVote signs a vote for the block with the given private key
+func SignBlockVote(privateKey *ecdsa.PrivateKey, block *Block) (*BlockVote, error) {
+	vote := BlockVote{
+		BlockHash: block.Hash(),
+		Height:    block.Height,
+	}
+
+	signature, err := crypto.Sign(vote.HashBytes(), privateKey)
+	if err != nil {
+		return nil, err
+	}
+	vote.Signature = signature
+
+	return &vote, nil
+}
+
+// HashBytes computes the digest signed by the voter
+func (v BlockVote) HashBytes() []byte {
+	h := sha256.New()
+
+	h.Write([]byte(v.BlockHash))
+	h.Write([]byte(fmt.Sprintf("%d", v.Height)))
+
+	return h.Sum(nil)
+}
+
+// Hash implements Hashable.Hash
+func (v BlockVote) Hash() string {
+	return hex.EncodeToString(v.HashBytes())
+}
+
+// Signer returns the address that signed the vote
+func (v BlockVote) Signer() (string, error) {
+	if len(v.Signature) != crypto.SignatureLength {
+		return "", fmt.Errorf("invalid vote signature length: %d", len(v.Signature))
+	}
+
+	digestHash := v.HashBytes()
+	publicKey, err := crypto.SigToPub(digestHash, v.Signature)
+	if err != nil {
+		return "", err
+	}
+	// verify sig input needs to be in [R || S] format
+	sigValid := crypto.VerifySignature(crypto.FromECDSAPub(publicKey), digestHash,
+		v.Signature[:len(v.Signature)-1])
+	if !sigValid {
+		return "", fmt.Errorf("vote for block %s has invalid signature", v.BlockHash)
+	}
+
+	return NewAddress(publicKey).Hex, nil
+}
+
+// FinalityQuorum returns the number of votes finalizing a block among n
+// participants. It tolerates f=(n-1)/3 faulty participants and requires
+// n-f votes, i.e. 2f+1 when n=3f+1, so that two conflicting blocks can
+// never both be final
+func FinalityQuorum(n int) int {
+	f := (n - 1) / 3
+	return n - f
+}
+
+// Finalize makes the block and all its ancestors final. The block must be
+// on the canonical chain. It returns the blocks becoming final, from the
+// oldest one, and nil if the block is already final
+func (bc *Blockchain) Finalize(blockHash string) ([]*Block, error) {
+	bc.Lock()
+	defer bc.Unlock()
+
+	if bc.finalBlock == nil {
+		return nil, fmt.Errorf("need to set genesis block first")
+	}
+
+	block, ok := bc.blocksStore[blockHash]
+	if !ok {
+		return nil, fmt.Errorf("unknown block %s", blockHash)
+	}
+	if block.Height <= bc.finalBlock.Height {
+		if bc.isAncestor(block, bc.finalBlock) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("block %s conflicts with the final block %s",
+			blockHash, bc.finalBlock.Hash())
+	}
+	if !bc.isAncestor(block, bc.latestBlock) {
+		return nil, fmt.Errorf("block %s is not on the canonical chain", blockHash)
+	}
+
+	finalized := make([]*Block, 0, block.Height-bc.finalBlock.Height)
+	for curr := block; curr != bc.finalBlock; curr = bc.blocksStore[curr.PrevHash] {
+		finalized = append(finalized, curr)
+	}
+	for i, j := 0, len(finalized)-1; i < j; i, j = i+1, j-1 {
+		finalized[i], finalized[j] = finalized[j], finalized[i]
+	}
+
+	bc.finalBlock = block
+	if bc.store != nil {
+		persistFinal(bc.store, block)
+	}
+	bc.prune(bc.latestBlock.Height)
+	return finalized, nil
+}
+
+// GetFinalBlock returns the latest final block. Every block up to it on
+// the canonical chain is final. It returns nil if the chain is not initialized
+func (bc *Blockchain) GetFinalBlock() *Block {
+	bc.RLock()
+	defer bc.RUnlock()
+
+	return bc.finalBlock
+}
+
+// IsFinal checks if the block is final
+func (bc *Blockchain) IsFinal(blockHash string) bool {
+	bc.RLock()
+	defer bc.RUnlock()
+
+	block, ok := bc.blocksStore[blockHash]
+	if !ok || bc.finalBlock == nil {
+		return false
+	}
+	return bc.isAncestor(block, bc.finalBlock)
+}
+
+// IsAncestor checks if the first block is the second one or one of its
+// ancestors. Unknown blocks are not
+func (bc *Blockchain) IsAncestor(ancestorHash string, blockHash string) bool {
+	bc.RLock()
+	defer bc.RUnlock()
+
+	ancestor, ok := bc.blocksStore[ancestorHash]
+	if !ok {
+		return false
+	}
+	block, ok := bc.blocksStore[blockHash]
+	if !ok {
+		return false
+	}
+	return bc.isAncestor(ancestor, block)
+}
+
+// isAncestor checks if a is b or one of its ancestors
+func (bc *Blockchain) isAncestor(a *Block, b *Block) bool {
+	curr := b
+	for curr != nil && curr.Height > a.Height {
+		curr = bc.blocksStore[curr.PrevHash]
+	}
+	return curr != nil && curr.Hash() == a.Hash()
+}
@@ -0,0 +1,120 @@
+package permissioned
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/cs438/storage/inmemory"
+)
+
+func Test_BC_Finality_Vote(t *testing.T) {
+	privKey, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	addr := NewAddress(&privKey.PublicKey).Hex
+
+	block := &Block{BlockHeader: &BlockHeader{PrevHash: DUMMY_PREVHASH, Height: 3}}
+	vote, err := SignBlockVote(privKey, block)
+	require.NoError(t, err)
+	require.Equal(t, block.Hash(), vote.BlockHash)
+
+	signer, err := vote.Signer()
+	require.NoError(t, err)
+	require.Equal(t, addr, signer)
+
+	// > a vote cannot be moved to another height
+	vote.Height = 4
+	signer, err = vote.Signer()
+	if err == nil {
+		require.NotEqual(t, addr, signer)
+	}
+
+	vote.Signature = vote.Signature[1:]
+	_, err = vote.Signer()
+	require.Error(t, err)
+}
+
+func Test_BC_Finality_Quorum(t *testing.T) {
+	require.Equal(t, 1, FinalityQuorum(1))
+	require.Equal(t, 2, FinalityQuorum(2))
+	require.Equal(t, 3, FinalityQuorum(3))
+	require.Equal(t, 3, FinalityQuorum(4))
+	require.Equal(t, 5, FinalityQuorum(7))
+	require.Equal(t, 7, FinalityQuorum(10))
+}
+
+func Test_BC_Finality_Finalize(t *testing.T) {
+	store := inmemory.NewPersistency().GetBlockchainStore()
+
+	privKeyA, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	accountA := NewAccount(*NewAddress(&privKeyA.PublicKey))
+	privKeyB, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	accountB := NewAccount(*NewAddress(&privKeyB.PublicKey))
+
+	config := *NewChainConfig(
+		map[string]string{accountA.addr.Hex: "", accountB.addr.Hex: ""},
+		10, "2h", 0, 10,
+	)
+	config.Finality = true
+	bc, err := NewBlockchainWithStore(store)
+	require.NoError(t, err)
+	block0, err := bc.InitGenesisBlock(&config, map[string]Amount{
+		accountA.addr.Hex: 1000,
+		accountB.addr.Hex: 10,
+	})
+	require.NoError(t, err)
+	err = bc.SetGenesisBlock(&block0)
+	require.NoError(t, err)
+	require.Equal(t, block0.Hash(), bc.GetFinalBlock().Hash())
+
+	block1a := buildTxnBlock(t, &block0, accountA.addr.Hex, privKeyA,
+		NewTransactionRegAssets(accountA, map[string]Amount{"keyA": 1}))
+	block1b := buildTxnBlock(t, &block0, accountB.addr.Hex, privKeyB,
+		NewTransactionRegAssets(accountB, map[string]Amount{"keyB": 1}))
+
+	_, err = bc.AddBlock(block1a)
+	require.NoError(t, err)
+	_, err = bc.AddBlock(block1b)
+	require.NoError(t, err)
+
+	accountA.IncreaseNonce()
+	block2a := buildTxnBlock(t, block1a, accountA.addr.Hex, privKeyA,
+		NewTransactionStake(accountA, 1))
+	_, err = bc.AddBlock(block2a)
+	require.NoError(t, err)
+
+	// > only blocks of the canonical chain can be final
+
+	_, err = bc.Finalize(block1b.Hash())
+	require.Error(t, err)
+
+	// > ancestors become final with the block
+
+	finalized, err := bc.Finalize(block2a.Hash())
+	require.NoError(t, err)
+	require.Equal(t, []*Block{block1a, block2a}, finalized)
+	require.Equal(t, block2a.Hash(), bc.GetFinalBlock().Hash())
+	require.True(t, bc.IsFinal(block1a.Hash()))
+	require.False(t, bc.IsFinal(block1b.Hash()))
+
+	finalized, err = bc.Finalize(block1a.Hash())
+	require.NoError(t, err)
+	require.Nil(t, finalized)
+
+	// > a branch forking before the final block is rejected, however long
+
+	accountB.IncreaseNonce()
+	block2b := buildTxnBlock(t, block1b, accountB.addr.Hex, privKeyB,
+		NewTransactionStake(accountB, 1))
+	_, err = bc.AddBlock(block2b)
+	require.Error(t, err)
+	require.Equal(t, block2a.Hash(), bc.GetLatestBlock().Hash())
+
+	// > the final block is restored after a restart
+
+	restored, err := NewBlockchainWithStore(store)
+	require.NoError(t, err)
+	require.Equal(t, block2a.Hash(), restored.GetFinalBlock().Hash())
+}
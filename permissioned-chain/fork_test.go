@@ -0,0 +1,153 @@
+package permissioned
+
+import (
+	"crypto/ecdsa"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/cs438/storage/inmemory"
+)
+
+func Test_BC_Fork_Choice_Reorg(t *testing.T) {
+	store := inmemory.NewPersistency().GetBlockchainStore()
+
+	privKeyA, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	accountA := NewAccount(*NewAddress(&privKeyA.PublicKey))
+	privKeyB, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	accountB := NewAccount(*NewAddress(&privKeyB.PublicKey))
+
+	config := *NewChainConfig(
+		map[string]string{accountA.addr.Hex: "", accountB.addr.Hex: ""},
+		10, "2h", 0, 10,
+	)
+	bc, err := NewBlockchainWithStore(store)
+	require.NoError(t, err)
+	block0, err := bc.InitGenesisBlock(&config, map[string]Amount{
+		accountA.addr.Hex: 1000,
+		accountB.addr.Hex: 10,
+	})
+	require.NoError(t, err)
+	err = bc.SetGenesisBlock(&block0)
+	require.NoError(t, err)
+
+	// > A and B both mine on the genesis block
+
+	block1a := buildTxnBlock(t, &block0, accountA.addr.Hex, privKeyA,
+		NewTransactionRegAssets(accountA, map[string]Amount{"keyA": 1}))
+	block1b := buildTxnBlock(t, &block0, accountB.addr.Hex, privKeyB,
+		NewTransactionRegAssets(accountB, map[string]Amount{"keyB": 1}))
+
+	reorg, err := bc.AddBlock(block1a)
+	require.NoError(t, err)
+	require.Len(t, reorg.Removed, 0)
+	require.Equal(t, []*Block{block1a}, reorg.Added)
+
+	// > the miner with less weight loses the tie
+
+	reorg, err = bc.AddBlock(block1b)
+	require.NoError(t, err)
+	require.Nil(t, reorg)
+	require.Equal(t, block1a.Hash(), bc.GetLatestBlock().Hash())
+
+	tips := bc.GetBranchTips()
+	require.Len(t, tips, 2)
+	require.Equal(t, block1a.Hash(), tips[0].Hash())
+	require.Equal(t, block1b.Hash(), tips[1].Hash())
+
+	_, err = bc.AddBlock(block1b)
+	require.Error(t, err)
+
+	// > the longest branch wins
+
+	accountB.IncreaseNonce()
+	block2b := buildTxnBlock(t, block1b, accountB.addr.Hex, privKeyB,
+		NewTransactionStake(accountB, 1))
+
+	reorg, err = bc.AddBlock(block2b)
+	require.NoError(t, err)
+	require.Equal(t, []*Block{block1a}, reorg.Removed)
+	require.Equal(t, []*Block{block1b, block2b}, reorg.Added)
+
+	// > the world state follows the new branch
+
+	require.Equal(t, block2b.Hash(), bc.GetLatestBlock().Hash())
+	require.Nil(t, bc.GetTxn(block1a.Transactions[0].Txn.ID))
+	require.NotNil(t, bc.GetTxn(block1b.Transactions[0].Txn.ID))
+	require.Len(t, bc.SearchAssets("keyA"), 0)
+	require.Len(t, bc.SearchAssets("keyB"), 1)
+	require.Equal(t, Amount(1), bc.GetStake(accountB.addr.Hex))
+	require.Len(t, bc.GetBlocksFromGenesis(), 3)
+
+	// > blocks must extend a known block
+
+	orphan := buildTxnBlock(t, block2b, accountA.addr.Hex, privKeyA,
+		NewTransactionStake(accountA, 1))
+	orphan.PrevHash = block1a.Hash() + "00"
+	_, err = bc.AddBlock(orphan)
+	require.Error(t, err)
+
+	// > the canonical chain is restored after a restart
+
+	restored, err := NewBlockchainWithStore(store)
+	require.NoError(t, err)
+	require.Equal(t, block2b.Hash(), restored.GetLatestBlock().Hash())
+}
+
+func Test_BC_Fork_Choice_Equal_Weight(t *testing.T) {
+	privKeyA, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	accountA := NewAccount(*NewAddress(&privKeyA.PublicKey))
+
+	config := *NewChainConfig(
+		map[string]string{accountA.addr.Hex: ""},
+		10, "2h", 0, 10,
+	)
+	bc := NewBlockchain()
+	block0, err := bc.InitGenesisBlock(&config, map[string]Amount{
+		accountA.addr.Hex: 1000,
+	})
+	require.NoError(t, err)
+	err = bc.SetGenesisBlock(&block0)
+	require.NoError(t, err)
+
+	// > same height and weight: the smallest hash wins, whatever the order
+
+	block1 := buildTxnBlock(t, &block0, accountA.addr.Hex, privKeyA,
+		NewTransactionRegAssets(accountA, map[string]Amount{"key1": 1}))
+	block2 := buildTxnBlock(t, &block0, accountA.addr.Hex, privKeyA,
+		NewTransactionRegAssets(accountA, map[string]Amount{"key2": 1}))
+	expected := block1
+	if block2.Hash() < block1.Hash() {
+		expected = block2
+	}
+
+	_, err = bc.AddBlock(block2)
+	require.NoError(t, err)
+	_, err = bc.AddBlock(block1)
+	require.NoError(t, err)
+	require.Equal(t, expected.Hash(), bc.GetLatestBlock().Hash())
+}
+
+// buildTxnBlock builds a block made of a single transaction on the parent
+func buildTxnBlock(t *testing.T, parent *Block, miner string,
+	privKey *ecdsa.PrivateKey, txn *Transaction) *Block {
+
+	worldState := parent.GetWorldStateCopy()
+
+	signedTxn, err := txn.Sign(privKey)
+	require.NoError(t, err)
+	err = signedTxn.Verify(worldState)
+	require.NoError(t, err)
+
+	bb := NewBlockBuilder()
+	bb.SetPrevHash(parent.Hash()).SetHeight(parent.Height + 1).
+		SetMiner(miner).SetState(worldState)
+	bb.AddTxn(signedTxn)
+	block := bb.Build()
+	err = block.Sign(privKey)
+	require.NoError(t, err)
+	return block
+}
@@ -0,0 +1,171 @@
+package permissioned
+
+import (
+	"crypto/ecdsa"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"go.dedis.ch/cs438/storage"
+)
+
+// -----------------------------------------------------------------------------
+// Utilities - Genesis
+
+// GenesisMember is a participant of a new chain and its initial balance
+type GenesisMember struct {
+	Address string
+	Balance Amount
+}
+
+// Genesis is the genesis block of a new chain signed by one of its
+// participants. Every participant loads the same file, hence starts with
+// the same block
+type Genesis struct {
+	Block     *Block
+	Signer    string
+	Signature []byte
+}
+
+// NewGenesis validates the members and the config, then builds the genesis
+// block and signs it. The participants of the config are the members and
+// the signer must be one of them
+func NewGenesis(config ChainConfig, members []GenesisMember,
+	privateKey *ecdsa.PrivateKey) (*Genesis, error) {
+
+	config.Participants = map[string]string{}
+	initialGain := map[string]Amount{}
+	for _, member := range members {
+		addr, err := checksumAddress(member.Address)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := config.Participants[addr]; ok {
+			return nil, fmt.Errorf("duplicate member %s", addr)
+		}
+		err = member.Balance.Validate()
+		if err != nil {
+			return nil, fmt.Errorf("invalid balance for %s: %v", addr, err)
+		}
+
+		config.Participants[addr] = ""
+		if member.Balance > 0 {
+			initialGain[addr] = member.Balance
+		}
+	}
+	lightNodes := make([]string, len(config.LightNodes))
+	for i, lightNode := range config.LightNodes {
+		addr, err := checksumAddress(lightNode)
+		if err != nil {
+			return nil, err
+		}
+		lightNodes[i] = addr
+	}
+	config.LightNodes = lightNodes
+
+	err := config.Validate()
+	if err != nil {
+		return nil, err
+	}
+	signer := NewAddress(&privateKey.PublicKey).Hex
+	if _, ok := config.Participants[signer]; !ok {
+		return nil, fmt.Errorf("signer %s is not a member of the chain", signer)
+	}
+
+	block, err := NewBlockchain().InitGenesisBlock(&config, initialGain)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := crypto.Sign(block.HashBytes(), privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Genesis{
+		Block:     &block,
+		Signer:    signer,
+		Signature: signature,
+	}, nil
+}
+
+// Verify replays the genesis block, validates its config and checks that
+// it is signed by one of its participants. The block gets its world state
+func (g *Genesis) Verify() error {
+	if g.Block == nil || g.Block.BlockHeader == nil {
+		return fmt.Errorf("genesis has no block")
+	}
+	if g.Block.Height != 0 || g.Block.PrevHash != DUMMY_PREVHASH {
+		return fmt.Errorf("genesis block needs to be prevHash=%s and height=0", DUMMY_PREVHASH)
+	}
+
+	err := g.Block.Verify(storage.NewBasicKV())
+	if err != nil {
+		return err
+	}
+	config := GetConfigFromWorldState(g.Block.States)
+	if config == nil {
+		return fmt.Errorf("genesis block %s has no config", g.Block.Hash())
+	}
+	err = config.Validate()
+	if err != nil {
+		return fmt.Errorf("invalid genesis config: %v", err)
+	}
+
+	// verify sig input needs to be in [R || S] format
+	if len(g.Signature) != crypto.SignatureLength {
+		return fmt.Errorf("invalid genesis signature length: %d", len(g.Signature))
+	}
+	digestHash := g.Block.HashBytes()
+	publicKey, err := crypto.SigToPub(digestHash, g.Signature)
+	if err != nil {
+		return err
+	}
+	sigValid := crypto.VerifySignature(crypto.FromECDSAPub(publicKey), digestHash,
+		g.Signature[:len(g.Signature)-1])
+	if !sigValid || NewAddress(publicKey).Hex != g.Signer {
+		return fmt.Errorf("genesis block %s is not signed by %s", g.Block.Hash(), g.Signer)
+	}
+	if _, ok := config.Participants[g.Signer]; !ok {
+		return fmt.Errorf("signer %s is not a participant", g.Signer)
+	}
+	return nil
+}
+
+// Save writes the genesis in a file. It holds no secret
+func (g *Genesis) Save(path string) error {
+	buf, err := json.MarshalIndent(g, "", "\t")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf, 0644)
+}
+
+// LoadGenesis reads and verifies a genesis file
+func LoadGenesis(path string) (*Genesis, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var genesis Genesis
+	err = json.Unmarshal(buf, &genesis)
+	if err != nil {
+		return nil, err
+	}
+	err = genesis.Verify()
+	if err != nil {
+		return nil, err
+	}
+	return &genesis, nil
+}
+
+// checksumAddress returns the checksummed form of an address, as derived
+// from public keys
+func checksumAddress(addr string) (string, error) {
+	if !common.IsHexAddress(addr) {
+		return "", fmt.Errorf("invalid address %s", addr)
+	}
+	return common.HexToAddress(addr).Hex(), nil
+}
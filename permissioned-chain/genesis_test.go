@@ -0,0 +1,100 @@
+package permissioned
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_BC_Genesis(t *testing.T) {
+	privKey1, account1 := newKeyAccount(t)
+	_, account2 := newKeyAccount(t)
+	config := *NewChainConfig(nil, 10, "2s", 1, 0.5)
+	members := []GenesisMember{
+		{Address: account1.addr.Hex, Balance: 100},
+		{Address: strings.ToLower(account2.addr.Hex)},
+	}
+
+	genesis, err := NewGenesis(config, members, privKey1)
+	require.NoError(t, err)
+	require.Equal(t, account1.addr.Hex, genesis.Signer)
+
+	// > every node loads the same genesis block
+	path := filepath.Join(t.TempDir(), "genesis.json")
+	err = genesis.Save(path)
+	require.NoError(t, err)
+	loaded, err := LoadGenesis(path)
+	require.NoError(t, err)
+	require.Equal(t, genesis.Block.Hash(), loaded.Block.Hash())
+
+	// > addresses are checksummed and members without balance get no coins
+	loadedConfig := GetConfigFromWorldState(loaded.Block.States)
+	require.Len(t, loadedConfig.Participants, 2)
+	require.Contains(t, loadedConfig.Participants, account2.addr.Hex)
+	require.Equal(t, Amount(100), GetAccountFromWorldState(loaded.Block.States, account1.addr.Hex).balance)
+	require.Len(t, loaded.Block.Transactions, 2)
+
+	bc := NewBlockchain()
+	err = bc.SetGenesisBlock(loaded.Block)
+	require.NoError(t, err)
+
+	// > a genesis signed by someone else is rejected
+	privKey3, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	forged := *genesis
+	forged.Signature, err = crypto.Sign(genesis.Block.HashBytes(), privKey3)
+	require.NoError(t, err)
+	require.Error(t, forged.Verify())
+
+	_, err = NewGenesis(config, members, privKey3)
+	require.Error(t, err)
+}
+
+func Test_BC_Genesis_Validation(t *testing.T) {
+	privKey, account := newKeyAccount(t)
+	_, other := newKeyAccount(t)
+	member := GenesisMember{Address: account.addr.Hex, Balance: 10}
+
+	invalid := map[string]struct {
+		config  func(c *ChainConfig)
+		members []GenesisMember
+	}{
+		"no member":         {members: []GenesisMember{}},
+		"invalid address":   {members: []GenesisMember{member, {Address: "0x1234"}}},
+		"duplicate address": {members: []GenesisMember{member, {Address: strings.ToUpper(account.addr.Hex[2:])}}},
+		"negative balance":  {members: []GenesisMember{member, {Address: other.addr.Hex, Balance: -1}}},
+		"no txn per block":  {config: func(c *ChainConfig) { c.MaxTxnsPerBlk = 0 }},
+		"invalid timeout":   {config: func(c *ChainConfig) { c.WaitTimeout = "2 hours" }},
+		"zero timeout":      {config: func(c *ChainConfig) { c.WaitTimeout = "0s" }},
+		"negative stake":    {config: func(c *ChainConfig) { c.MinStake = -1 }},
+		"join threshold":    {config: func(c *ChainConfig) { c.JoinThreshold = 1.5 }},
+		"minority revoke":   {config: func(c *ChainConfig) { c.RevocationThreshold = 0.5 }},
+		"revoke threshold":  {config: func(c *ChainConfig) { c.RevocationThreshold = 1.5 }},
+		"slash ratio":       {config: func(c *ChainConfig) { c.SlashRatio = -0.1 }},
+		"slash reward":      {config: func(c *ChainConfig) { c.SlashReward = 2 }},
+		"unknown light":     {config: func(c *ChainConfig) { c.LightNodes = []string{other.addr.Hex} }},
+		"only light nodes":  {config: func(c *ChainConfig) { c.LightNodes = []string{account.addr.Hex} }},
+	}
+	for name, test := range invalid {
+		config := *NewChainConfig(nil, 10, "2s", 1, 1)
+		if test.config != nil {
+			test.config(&config)
+		}
+		members := test.members
+		if members == nil {
+			members = []GenesisMember{member}
+		}
+
+		_, err := NewGenesis(config, members, privKey)
+		require.Error(t, err, name)
+	}
+
+	// > encryption keys set in the config must be RSA keys
+	config := *NewChainConfig(map[string]string{account.addr.Hex: "00"}, 10, "2s", 1, 1)
+	require.Error(t, config.Validate())
+	config.Participants[account.addr.Hex] = ""
+	require.NoError(t, config.Validate())
+}
@@ -0,0 +1,141 @@
+package permissioned
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// KEYSTORE_VERSION is the version of the keystore file format
+const KEYSTORE_VERSION = 1
+
+// ENCKEY_BITS is the size of the RSA key used to encrypt messages
+const ENCKEY_BITS = 2048
+
+// Keystore holds the identity of a node: the key of its chain account and
+// the key other nodes use to send it encrypted messages
+type Keystore struct {
+	ChainKey *ecdsa.PrivateKey
+	EncKey   *rsa.PrivateKey
+}
+
+// keystoreFile is the format of a keystore on disk. Both keys are encrypted
+// with a key derived from the passphrase by scrypt. The address is left in
+// plaintext to find the keystore of an account
+type keystoreFile struct {
+	Version  int                 `json:"version"`
+	Address  string              `json:"address"`
+	ChainKey keystore.CryptoJSON `json:"chainKey"`
+	EncKey   keystore.CryptoJSON `json:"encKey"`
+}
+
+// NewKeystore generates a new identity
+func NewKeystore() (*Keystore, error) {
+	chainKey, err := crypto.GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+	return NewKeystoreFromKey(chainKey)
+}
+
+// NewKeystoreFromKey imports an existing chain key, e.g. one written by
+// crypto.SaveECDSA. A new encryption key is generated
+func NewKeystoreFromKey(chainKey *ecdsa.PrivateKey) (*Keystore, error) {
+	encKey, err := rsa.GenerateKey(rand.Reader, ENCKEY_BITS)
+	if err != nil {
+		return nil, err
+	}
+	return &Keystore{ChainKey: chainKey, EncKey: encKey}, nil
+}
+
+// Address returns the chain address of the keystore
+func (k *Keystore) Address() Address {
+	return *NewAddress(&k.ChainKey.PublicKey)
+}
+
+// Encrypt returns the keystore file protected by the passphrase. scryptN and
+// scryptP are the cost parameters of scrypt, see keystore.StandardScryptN
+func (k *Keystore) Encrypt(passphrase string, scryptN, scryptP int) ([]byte, error) {
+	if k.ChainKey == nil || k.EncKey == nil {
+		return nil, fmt.Errorf("keystore must hold a chain key and an encryption key")
+	}
+
+	chainKey, err := keystore.EncryptDataV3(crypto.FromECDSA(k.ChainKey),
+		[]byte(passphrase), scryptN, scryptP)
+	if err != nil {
+		return nil, err
+	}
+	encKey, err := keystore.EncryptDataV3(x509.MarshalPKCS1PrivateKey(k.EncKey),
+		[]byte(passphrase), scryptN, scryptP)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.MarshalIndent(keystoreFile{
+		Version:  KEYSTORE_VERSION,
+		Address:  k.Address().Hex,
+		ChainKey: chainKey,
+		EncKey:   encKey,
+	}, "", "  ")
+}
+
+// DecryptKeystore unlocks a keystore file with its passphrase
+func DecryptKeystore(data []byte, passphrase string) (*Keystore, error) {
+	var file keystoreFile
+	err := json.Unmarshal(data, &file)
+	if err != nil {
+		return nil, fmt.Errorf("invalid keystore file: %w", err)
+	}
+	if file.Version != KEYSTORE_VERSION {
+		return nil, fmt.Errorf("unknown keystore version %d", file.Version)
+	}
+
+	chainKeyBytes, err := keystore.DecryptDataV3(file.ChainKey, passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unlock the chain key: %w", err)
+	}
+	chainKey, err := crypto.ToECDSA(chainKeyBytes)
+	if err != nil {
+		return nil, err
+	}
+	encKeyBytes, err := keystore.DecryptDataV3(file.EncKey, passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unlock the encryption key: %w", err)
+	}
+	encKey, err := x509.ParsePKCS1PrivateKey(encKeyBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	k := &Keystore{ChainKey: chainKey, EncKey: encKey}
+	if k.Address().Hex != file.Address {
+		return nil, fmt.Errorf("keystore of %s holds the key of %s", file.Address, k.Address().Hex)
+	}
+	return k, nil
+}
+
+// Save writes the keystore in the file, readable by the user only. It uses
+// the standard scrypt parameters
+func (k *Keystore) Save(path, passphrase string) error {
+	data, err := k.Encrypt(passphrase, keystore.StandardScryptN, keystore.StandardScryptP)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+// LoadKeystore reads and unlocks the keystore in the file
+func LoadKeystore(path, passphrase string) (*Keystore, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return DecryptKeystore(data, passphrase)
+}
@@ -0,0 +1,43 @@
+package permissioned
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Keystore_RoundTrip(t *testing.T) {
+	keys, err := NewKeystore()
+	require.NoError(t, err)
+
+	data, err := keys.Encrypt("passphrase", keystore.LightScryptN, keystore.LightScryptP)
+	require.NoError(t, err)
+
+	// > the keys are not stored in plaintext
+
+	var file keystoreFile
+	require.NoError(t, json.Unmarshal(data, &file))
+	require.Equal(t, keys.Address().Hex, file.Address)
+	require.NotContains(t, string(data), "PRIVATE KEY")
+
+	// > the passphrase unlocks both keys
+
+	_, err = DecryptKeystore(data, "wrong")
+	require.Error(t, err)
+
+	unlocked, err := DecryptKeystore(data, "passphrase")
+	require.NoError(t, err)
+	require.Equal(t, keys.Address(), unlocked.Address())
+	require.True(t, keys.ChainKey.Equal(unlocked.ChainKey))
+	require.True(t, keys.EncKey.Equal(unlocked.EncKey))
+
+	// > the address must match the chain key
+
+	file.Address = "0x0000000000000000000000000000000000000000"
+	data, err = json.Marshal(file)
+	require.NoError(t, err)
+	_, err = DecryptKeystore(data, "passphrase")
+	require.Error(t, err)
+}
@@ -0,0 +1,396 @@
+package permissioned
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"go.dedis.ch/cs438/storage"
+)
+
+// -----------------------------------------------------------------------------
+// Header Chain
+
+// HeaderChain is the chain followed by a light node. Only the genesis block
+// is kept in full, the following blocks are known by their headers. The
+// world state is not available so the values are proven against the headers.
+//
+// Without the world state, a light node cannot check that the miner of a
+// header won the stake-weighted selection: any single validator can sign a
+// longer chain with forged states. When the chain needs votes to be final,
+// values should only be trusted on final headers, which a quorum of the
+// validators voted for, see AddVote
+type HeaderChain struct {
+	*sync.RWMutex
+	genesis *Block
+	headers map[string]*BlockHeader
+	latest  *BlockHeader // tip of the canonical chain
+	final   *BlockHeader // last header voted by a quorum
+	// configs proven after some headers. They hold the validators of the
+	// following headers, e.g. after a key rotation
+	configs map[string]*ChainConfig
+	// header hash -> voters, and the voted heights to forget them once final
+	votes      map[string]map[string]struct{}
+	voteHeight map[string]uint
+}
+
+func NewHeaderChain() *HeaderChain {
+	hc := HeaderChain{
+		RWMutex:    &sync.RWMutex{},
+		genesis:    nil,
+		headers:    map[string]*BlockHeader{},
+		latest:     nil,
+		final:      nil,
+		configs:    map[string]*ChainConfig{},
+		votes:      map[string]map[string]struct{}{},
+		voteHeight: map[string]uint{},
+	}
+	return &hc
+}
+
+// SetGenesisBlock sets the genesis block of the chain. It is fully verified
+// since the config of the chain is read from its state
+func (hc *HeaderChain) SetGenesisBlock(block *Block) error {
+	if block.PrevHash != DUMMY_PREVHASH || block.Height != 0 {
+		return fmt.Errorf("genesis block needs to be prevHash=%s and height=0", DUMMY_PREVHASH)
+	}
+
+	hc.Lock()
+	defer hc.Unlock()
+
+	if hc.genesis != nil {
+		return fmt.Errorf("chain already initialized")
+	}
+
+	err := checkTimestamp(nil, block)
+	if err != nil {
+		return err
+	}
+	worldState := storage.NewBasicKV()
+	err = block.Verify(worldState)
+	if err != nil {
+		return err
+	}
+
+	header := *block.BlockHeader
+	hc.genesis = &Block{
+		BlockHeader:  &header,
+		States:       worldState,
+		Transactions: block.Transactions,
+	}
+	hc.headers[header.Hash()] = &header
+	hc.configs[header.Hash()] = GetConfigFromWorldState(worldState)
+	hc.latest = &header
+	hc.final = &header
+	return nil
+}
+
+// AddHeader adds a header following a known one. The header must be signed
+// by the miner selected by its proof, which must be a validator in the last
+// config known on its branch, see SetConfig. With finality, it must extend
+// the final header.
+// The header becomes the tip if it makes the longest chain, the first one
+// received winning ties. It returns true if the tip changed
+func (hc *HeaderChain) AddHeader(header BlockHeader) (bool, error) {
+	hc.Lock()
+	defer hc.Unlock()
+
+	if hc.genesis == nil {
+		return false, fmt.Errorf("need to set genesis block first")
+	}
+
+	hash := header.Hash()
+	if _, ok := hc.headers[hash]; ok {
+		return false, fmt.Errorf("header %s already known", hash)
+	}
+	parent, ok := hc.headers[header.PrevHash]
+	if !ok {
+		return false, fmt.Errorf("unknown parent %s of header %s", header.PrevHash, hash)
+	}
+
+	err := hc.verifyHeader(parent, &header)
+	if err != nil {
+		return false, err
+	}
+	if hc.isFinalityMode() && !hc.extends(&header, hc.final) {
+		return false, fmt.Errorf("header %s conflicts with the final header %s",
+			hash, hc.final.Hash())
+	}
+
+	latest := hc.latest
+	hc.headers[hash] = &header
+	if header.Height > hc.latest.Height {
+		hc.latest = &header
+	}
+	// votes may have arrived before the header
+	hc.checkFinality(hash)
+	return hc.latest != latest, nil
+}
+
+// AddVote keeps the vote of a validator for a header, which may not be known
+// yet. With finality, a header voted by a quorum of the validators becomes
+// final with its ancestors. The validators are the ones of the last config
+// known on the final branch: a config proven after a header that is not
+// final could be forged. It returns true if the final header changed
+func (hc *HeaderChain) AddVote(vote BlockVote) (bool, error) {
+	voter, err := vote.Signer()
+	if err != nil {
+		return false, err
+	}
+
+	hc.Lock()
+	defer hc.Unlock()
+
+	if hc.genesis == nil {
+		return false, fmt.Errorf("need to set genesis block first")
+	}
+	if vote.Height <= hc.final.Height {
+		return false, nil
+	}
+
+	voters, ok := hc.votes[vote.BlockHash]
+	if !ok {
+		voters = map[string]struct{}{}
+		hc.votes[vote.BlockHash] = voters
+		hc.voteHeight[vote.BlockHash] = vote.Height
+	}
+	voters[voter] = struct{}{}
+	return hc.checkFinality(vote.BlockHash), nil
+}
+
+// checkFinality makes the header final if a quorum of the validators voted
+// for it. The tip moves to the longest chain extending it if needed
+func (hc *HeaderChain) checkFinality(hash string) bool {
+	header, ok := hc.headers[hash]
+	if !ok || !hc.isFinalityMode() || header.Height <= hc.final.Height ||
+		!hc.extends(header, hc.final) {
+		return false
+	}
+
+	validators := hc.configAt(hc.final.Hash()).Validators()
+	count := 0
+	for voter := range hc.votes[hash] {
+		if _, ok := validators[voter]; ok {
+			count++
+		}
+	}
+	if count < FinalityQuorum(len(validators)) {
+		return false
+	}
+
+	hc.final = header
+	for voted, height := range hc.voteHeight {
+		if height <= header.Height {
+			delete(hc.votes, voted)
+			delete(hc.voteHeight, voted)
+		}
+	}
+	if !hc.extends(hc.latest, header) {
+		hc.latest = header
+		for _, curr := range hc.headers {
+			if curr.Height > hc.latest.Height && hc.extends(curr, header) {
+				hc.latest = curr
+			}
+		}
+	}
+	return true
+}
+
+// extends checks if the ancestor is the header or one of its ancestors
+func (hc *HeaderChain) extends(header *BlockHeader, ancestor *BlockHeader) bool {
+	curr := header
+	for curr != nil && curr.Height > ancestor.Height {
+		curr = hc.headers[curr.PrevHash]
+	}
+	return curr != nil && curr.Hash() == ancestor.Hash()
+}
+
+// isFinalityMode checks if the chain needs votes to be final, given the
+// last config known on the final branch
+func (hc *HeaderChain) isFinalityMode() bool {
+	return hc.configAt(hc.final.Hash()).Finality
+}
+
+// IsFinalityMode checks if the headers need a quorum of votes to be final.
+// Values should then only be proven on final headers
+func (hc *HeaderChain) IsFinalityMode() bool {
+	hc.RLock()
+	defer hc.RUnlock()
+
+	return hc.genesis != nil && hc.isFinalityMode()
+}
+
+// GetFinalHeader returns the last final header, the genesis one if the
+// chain does not need votes. It returns nil if the genesis block is not set
+func (hc *HeaderChain) GetFinalHeader() *BlockHeader {
+	hc.RLock()
+	defer hc.RUnlock()
+
+	return hc.final
+}
+
+// IsFinal checks if the header is final, i.e. the final header or one of
+// its ancestors
+func (hc *HeaderChain) IsFinal(hash string) bool {
+	hc.RLock()
+	defer hc.RUnlock()
+
+	header, ok := hc.headers[hash]
+	if !ok || hc.final == nil {
+		return false
+	}
+	return hc.extends(hc.final, header)
+}
+
+// GetGenesisBlock returns the genesis block, nil if not set
+func (hc *HeaderChain) GetGenesisBlock() *Block {
+	hc.RLock()
+	defer hc.RUnlock()
+
+	return hc.genesis
+}
+
+// GetConfig returns a copy of the last config known on the canonical chain
+func (hc *HeaderChain) GetConfig() ChainConfig {
+	hc.RLock()
+	defer hc.RUnlock()
+
+	if hc.genesis == nil {
+		return ChainConfig{}
+	}
+	return hc.configAt(hc.latest.Hash()).Copy().(ChainConfig)
+}
+
+// SetConfig keeps the config proven after a known header. It holds the
+// validators of the following headers until another config is proven
+func (hc *HeaderChain) SetConfig(proof StateProof) error {
+	if proof.Entry.Key != STATE_CONFIG_KEY {
+		return fmt.Errorf("entry %s is not the chain config", proof.Entry.Key)
+	}
+	err := proof.Verify()
+	if err != nil {
+		return err
+	}
+	value, err := proof.GetValue()
+	if err != nil {
+		return err
+	}
+	config, ok := value.(ChainConfig)
+	if !ok {
+		return fmt.Errorf("wrong type of the chain config: %T", value)
+	}
+
+	hc.Lock()
+	defer hc.Unlock()
+
+	hash := proof.Header.Hash()
+	if _, ok := hc.headers[hash]; !ok {
+		return fmt.Errorf("config proven after unknown header %s", hash)
+	}
+	hc.configs[hash] = &config
+	return nil
+}
+
+// IsValidatorAfter checks if the address is a validator in the last config
+// known on the branch of the header, i.e. if it can mine the next header
+func (hc *HeaderChain) IsValidatorAfter(hash string, addr string) bool {
+	hc.RLock()
+	defer hc.RUnlock()
+
+	if _, ok := hc.headers[hash]; !ok || hc.genesis == nil {
+		return false
+	}
+	_, ok := hc.configAt(hash).Validators()[addr]
+	return ok
+}
+
+// configAt returns the last config known on the branch of the header,
+// at worst the genesis one
+func (hc *HeaderChain) configAt(hash string) *ChainConfig {
+	for curr := hc.headers[hash]; curr != nil; curr = hc.headers[curr.PrevHash] {
+		if config, ok := hc.configs[curr.Hash()]; ok {
+			return config
+		}
+	}
+	return GetConfigFromWorldState(hc.genesis.States)
+}
+
+// GetHeader returns the requested header, nil if not known
+func (hc *HeaderChain) GetHeader(hash string) *BlockHeader {
+	hc.RLock()
+	defer hc.RUnlock()
+
+	return hc.headers[hash]
+}
+
+// GetLatestHeader returns the tip of the canonical chain, nil if the
+// genesis block is not set
+func (hc *HeaderChain) GetLatestHeader() *BlockHeader {
+	hc.RLock()
+	defer hc.RUnlock()
+
+	return hc.latest
+}
+
+// IsCanonical checks if the header is on the canonical chain
+func (hc *HeaderChain) IsCanonical(hash string) bool {
+	hc.RLock()
+	defer hc.RUnlock()
+
+	header, ok := hc.headers[hash]
+	if !ok {
+		return false
+	}
+	for curr := hc.latest; curr != nil; curr = hc.headers[curr.PrevHash] {
+		if curr.Height == header.Height {
+			return curr.Hash() == hash
+		}
+	}
+	return false
+}
+
+// Sprint returns a decription of the chain
+func (hc *HeaderChain) Sprint() string {
+	hc.RLock()
+	defer hc.RUnlock()
+
+	description := ""
+	for curr := hc.latest; curr != nil; curr = hc.headers[curr.PrevHash] {
+		description += fmt.Sprintf("-------------Header %d-------------\n", curr.Height)
+		description += fmt.Sprintf("Hash: %s\n", curr.Hash())
+		description += fmt.Sprintf("Mined by %s at %s\n",
+			curr.Miner, curr.GetTime().Format(time.RFC3339Nano))
+		description += fmt.Sprintf("State root: %s\n", curr.StateHash)
+		description += fmt.Sprintf("Transaction root: %s\n", curr.TransationHash)
+	}
+	return description
+}
+
+// verifyHeader checks everything that can be checked without the world
+// state: the link to the parent, the timestamp and the miner's signature
+// and selection proof. Whether the miner won the stake-weighted selection
+// can only be checked by full nodes, see HeaderChain
+func (hc *HeaderChain) verifyHeader(parent *BlockHeader, header *BlockHeader) error {
+	if header.Height != parent.Height+1 {
+		return fmt.Errorf("header %s does not follow its parent. Expected height: %d. Got: %d",
+			header.Hash(), parent.Height+1, header.Height)
+	}
+
+	err := checkTimestamp(&Block{BlockHeader: parent}, &Block{BlockHeader: header})
+	if err != nil {
+		return err
+	}
+
+	seed := SelectionSeed(parent)
+	if header.SelectionProof != seed {
+		return fmt.Errorf("invalid selection proof. Expected: %s. Got: %s",
+			seed, header.SelectionProof)
+	}
+
+	config := hc.configAt(parent.Hash())
+	if _, ok := config.Validators()[header.Miner]; !ok {
+		return fmt.Errorf("miner %s is not a validator after header %s. "+
+			"The config needs to be proven if the validators changed", header.Miner, parent.Hash())
+	}
+	return header.VerifySignature()
+}
@@ -0,0 +1,266 @@
+package permissioned
+
+import (
+	"crypto/ecdsa"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/cs438/storage/inmemory"
+)
+
+func Test_BC_Header_Chain(t *testing.T) {
+	privKeyA, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	addrA := NewAddress(&privKeyA.PublicKey).Hex
+	privKeyL, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	addrL := NewAddress(&privKeyL.PublicKey).Hex
+
+	config := *NewChainConfig(map[string]string{addrA: "", addrL: ""}, 10, "2h", 0, 10)
+	config.LightNodes = []string{addrL}
+	block0, err := NewBlockchain().InitGenesisBlock(&config, map[string]Amount{
+		addrA: 10,
+		addrL: 10,
+	})
+	require.NoError(t, err)
+
+	hc := NewHeaderChain()
+	_, err = hc.AddHeader(*newSignedHeader(t, block0.BlockHeader, addrA, privKeyA))
+	require.Error(t, err)
+
+	err = hc.SetGenesisBlock(&block0)
+	require.NoError(t, err)
+	require.Equal(t, block0.Hash(), hc.GetLatestHeader().Hash())
+	require.Equal(t, []string{addrL}, hc.GetConfig().LightNodes)
+	require.Equal(t, Amount(10), GetAccountFromWorldState(hc.GetGenesisBlock().States, addrA).GetBalance())
+
+	// > a valid header becomes the tip
+
+	header1 := newSignedHeader(t, block0.BlockHeader, addrA, privKeyA)
+	tip, err := hc.AddHeader(*header1)
+	require.NoError(t, err)
+	require.True(t, tip)
+	require.Equal(t, header1.Hash(), hc.GetLatestHeader().Hash())
+
+	_, err = hc.AddHeader(*header1)
+	require.Error(t, err)
+
+	// > invalid headers are rejected
+
+	unknownParent := newSignedHeader(t, header1, addrA, privKeyA)
+	unknownParent.PrevHash = DUMMY_PREVHASH
+	require.NoError(t, unknownParent.Sign(privKeyA))
+	_, err = hc.AddHeader(*unknownParent)
+	require.Error(t, err)
+
+	unsigned := newSignedHeader(t, header1, addrA, privKeyA)
+	unsigned.Signature = nil
+	_, err = hc.AddHeader(*unsigned)
+	require.Error(t, err)
+
+	wrongSigner := newSignedHeader(t, header1, addrA, privKeyL)
+	_, err = hc.AddHeader(*wrongSigner)
+	require.Error(t, err)
+
+	lightMiner := newSignedHeader(t, header1, addrL, privKeyL)
+	_, err = hc.AddHeader(*lightMiner)
+	require.Error(t, err)
+
+	wrongProof := newSignedHeader(t, header1, addrA, privKeyA)
+	wrongProof.SelectionProof = SelectionSeed(wrongProof)
+	require.NoError(t, wrongProof.Sign(privKeyA))
+	_, err = hc.AddHeader(*wrongProof)
+	require.Error(t, err)
+
+	wrongHeight := newSignedHeader(t, header1, addrA, privKeyA)
+	wrongHeight.Height++
+	wrongHeight.SelectionProof = SelectionSeed(&BlockHeader{
+		Height:         wrongHeight.Height - 1,
+		SelectionProof: header1.SelectionProof,
+	})
+	require.NoError(t, wrongHeight.Sign(privKeyA))
+	_, err = hc.AddHeader(*wrongHeight)
+	require.Error(t, err)
+
+	// > the longest chain wins, the first one received on ties
+
+	header1b := newSignedHeader(t, block0.BlockHeader, addrA, privKeyA)
+	header1b.StateHash = "competing"
+	require.NoError(t, header1b.Sign(privKeyA))
+	tip, err = hc.AddHeader(*header1b)
+	require.NoError(t, err)
+	require.False(t, tip)
+	require.True(t, hc.IsCanonical(header1.Hash()))
+	require.False(t, hc.IsCanonical(header1b.Hash()))
+
+	header2b := newSignedHeader(t, header1b, addrA, privKeyA)
+	tip, err = hc.AddHeader(*header2b)
+	require.NoError(t, err)
+	require.True(t, tip)
+	require.Equal(t, header2b.Hash(), hc.GetLatestHeader().Hash())
+	require.False(t, hc.IsCanonical(header1.Hash()))
+	require.True(t, hc.IsCanonical(header1b.Hash()))
+	require.True(t, hc.IsCanonical(block0.Hash()))
+	require.False(t, hc.IsCanonical(unsigned.Hash()))
+}
+
+// newSignedHeader creates a header following the parent, signed with the
+// private key
+func newSignedHeader(t *testing.T, parent *BlockHeader, miner string,
+	privKey *ecdsa.PrivateKey) *BlockHeader {
+
+	header := BlockHeader{
+		PrevHash:       parent.Hash(),
+		Height:         parent.Height + 1,
+		Miner:          miner,
+		SelectionProof: SelectionSeed(parent),
+		StateHash:      parent.StateHash,
+		TransationHash: parent.TransationHash,
+		Timestamp:      time.Now().UnixNano(),
+	}
+	require.NoError(t, header.Sign(privKey))
+	return &header
+}
+
+func Test_BC_Header_Chain_Validator_Change(t *testing.T) {
+	privKey, account, bc := newPersistedChain(t, inmemory.NewPersistency().GetBlockchainStore())
+	newKey, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	newAddr := NewAddress(&newKey.PublicKey).Hex
+
+	// > the miner rotates its key and mines the next block with the new one
+
+	rotation, err := NewTransactionRotateKey(account, newKey)
+	require.NoError(t, err)
+	appendTxnBlock(t, bc, privKey, rotation)
+	newAccount := GetAccountFromWorldState(bc.GetLatestBlock().States, newAddr)
+	appendTxnBlock(t, bc, newKey, NewTransactionStake(newAccount, 1))
+	blocks := bc.GetBlocksFromGenesis()
+	require.Equal(t, newAddr, blocks[2].Miner)
+
+	hc := NewHeaderChain()
+	require.NoError(t, hc.SetGenesisBlock(blocks[0]))
+	_, err = hc.AddHeader(*blocks[1].BlockHeader)
+	require.NoError(t, err)
+
+	// > the light node does not know the new validator yet
+
+	require.False(t, hc.IsValidatorAfter(blocks[1].Hash(), newAddr))
+	_, err = hc.AddHeader(*blocks[2].BlockHeader)
+	require.Error(t, err)
+
+	// > only a config proven after a known header is kept
+
+	accountProof, err := bc.GetStateProofAt(blocks[1].Hash(), newAddr)
+	require.NoError(t, err)
+	require.Error(t, hc.SetConfig(*accountProof))
+
+	unknownProof, err := bc.GetStateProofAt(blocks[2].Hash(), STATE_CONFIG_KEY)
+	require.NoError(t, err)
+	require.Error(t, hc.SetConfig(*unknownProof))
+
+	// > the proven config holds the new validator
+
+	configProof, err := bc.GetStateProofAt(blocks[1].Hash(), STATE_CONFIG_KEY)
+	require.NoError(t, err)
+	require.NoError(t, hc.SetConfig(*configProof))
+	require.True(t, hc.IsValidatorAfter(blocks[1].Hash(), newAddr))
+	require.False(t, hc.IsValidatorAfter(blocks[1].Hash(), account.addr.Hex))
+	require.False(t, hc.IsValidatorAfter(blocks[0].Hash(), newAddr))
+
+	tip, err := hc.AddHeader(*blocks[2].BlockHeader)
+	require.NoError(t, err)
+	require.True(t, tip)
+	require.Contains(t, hc.GetConfig().Participants, newAddr)
+}
+
+func Test_BC_Header_Chain_Finality(t *testing.T) {
+	keys := make([]*ecdsa.PrivateKey, 5)
+	addrs := make([]string, 5)
+	participants := map[string]string{}
+	for i := range keys {
+		privKey, err := crypto.GenerateKey()
+		require.NoError(t, err)
+		keys[i] = privKey
+		addrs[i] = NewAddress(&privKey.PublicKey).Hex
+		participants[addrs[i]] = ""
+	}
+	// 4 validators and a light node, 3 votes make a header final
+	config := *NewChainConfig(participants, 10, "2h", 0, 10)
+	config.Finality = true
+	config.LightNodes = []string{addrs[4]}
+	block0, err := NewBlockchain().InitGenesisBlock(&config, nil)
+	require.NoError(t, err)
+
+	hc := NewHeaderChain()
+	require.NoError(t, hc.SetGenesisBlock(&block0))
+	require.True(t, hc.IsFinalityMode())
+	require.Equal(t, block0.Hash(), hc.GetFinalHeader().Hash())
+
+	vote := func(header *BlockHeader, voter int) bool {
+		blockVote, err := SignBlockVote(keys[voter], &Block{BlockHeader: header})
+		require.NoError(t, err)
+		final, err := hc.AddVote(*blockVote)
+		require.NoError(t, err)
+		return final
+	}
+
+	// > a single validator signing a longer chain makes the tip, not a
+	// final header
+
+	header1 := newSignedHeader(t, block0.BlockHeader, addrs[0], keys[0])
+	_, err = hc.AddHeader(*header1)
+	require.NoError(t, err)
+	forged1 := newSignedHeader(t, block0.BlockHeader, addrs[3], keys[3])
+	forged1.StateHash = "forged"
+	require.NoError(t, forged1.Sign(keys[3]))
+	forged2 := newSignedHeader(t, forged1, addrs[3], keys[3])
+	for _, forged := range []*BlockHeader{forged1, forged2} {
+		_, err = hc.AddHeader(*forged)
+		require.NoError(t, err)
+	}
+	require.Equal(t, forged2.Hash(), hc.GetLatestHeader().Hash())
+	require.False(t, hc.IsFinal(forged1.Hash()))
+	require.False(t, vote(forged2, 3))
+
+	// > votes of light nodes and short of a quorum don't make it final
+
+	require.False(t, vote(header1, 0))
+	require.False(t, vote(header1, 1))
+	require.False(t, vote(header1, 4))
+	require.False(t, hc.IsFinal(header1.Hash()))
+
+	// > a quorum makes the header final and the tip moves back on its branch
+
+	require.True(t, vote(header1, 2))
+	require.True(t, hc.IsFinal(header1.Hash()))
+	require.True(t, hc.IsFinal(block0.Hash()))
+	require.False(t, hc.IsFinal(forged1.Hash()))
+	require.Equal(t, header1.Hash(), hc.GetLatestHeader().Hash())
+
+	// > headers conflicting with the final one are rejected
+
+	_, err = hc.AddHeader(*newSignedHeader(t, forged2, addrs[3], keys[3]))
+	require.Error(t, err)
+
+	// > votes arriving before their header count once it is added
+
+	header2 := newSignedHeader(t, header1, addrs[1], keys[1])
+	require.False(t, vote(header2, 0))
+	require.False(t, vote(header2, 1))
+	require.False(t, vote(header2, 2))
+	tip, err := hc.AddHeader(*header2)
+	require.NoError(t, err)
+	require.True(t, tip)
+	require.Equal(t, header2.Hash(), hc.GetFinalHeader().Hash())
+
+	// > invalid votes are rejected
+
+	blockVote, err := SignBlockVote(keys[0], &Block{BlockHeader: header2})
+	require.NoError(t, err)
+	blockVote.Signature = blockVote.Signature[:10]
+	_, err = hc.AddVote(*blockVote)
+	require.Error(t, err)
+}
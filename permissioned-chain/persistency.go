@@ -0,0 +1,259 @@
+package permissioned
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"go.dedis.ch/cs438/storage"
+)
+
+// -----------------------------------------------------------------------------
+// Utilities - Persistency
+
+var STORE_BLOCK_PREFIX = "PermissionedChain-Block|"
+var STORE_SNAPSHOT_PREFIX = "PermissionedChain-Snapshot|"
+var STORE_LAST_BLOCK_KEY = "PermissionedChain-LastBlock"
+var STORE_FINAL_BLOCK_KEY = "PermissionedChain-FinalBlock"
+
+// SNAPSHOT_INTERVAL is the number of blocks between two persisted world
+// state snapshots. Blocks without a snapshot are replayed on startup.
+// The genesis block always has one
+var SNAPSHOT_INTERVAL uint = 10
+
+const (
+	snapshotTypeAccount    = "account"
+	snapshotTypeConfig     = "config"
+	snapshotTypeAssets     = "assets"
+	snapshotTypeMPC        = "mpc"
+	snapshotTypeMultisig   = "multisig"
+	snapshotTypeRotation   = "rotation"
+	snapshotTypeRevocation = "revocation"
+	snapshotTypeHeight     = "height"
+)
+
+// persistedBlock is the stored form of a block. States are not included
+// and are rebuilt either from a snapshot or by replaying the transactions
+type persistedBlock struct {
+	Header       BlockHeader
+	Transactions []SignedTransaction
+}
+
+// snapshotEntry is the stored form of a single world state entry. Value is
+// the canonical encoding of the entry
+type snapshotEntry struct {
+	Key   string
+	Type  string
+	Value []byte
+}
+
+// accountRecord is the stored form of an Account
+type accountRecord struct {
+	Addr          string
+	Balance       Amount
+	LockedBalance Amount
+	Stake         Amount
+	Nonce         uint
+}
+
+func blockStoreKey(hash string) string {
+	return STORE_BLOCK_PREFIX + hash
+}
+
+func snapshotStoreKey(hash string) string {
+	return STORE_SNAPSHOT_PREFIX + hash
+}
+
+// persistBlock writes the block and its snapshot if needed
+func persistBlock(store storage.Store, block *Block) error {
+	hash := block.Hash()
+
+	buf, err := Encode(persistedBlock{
+		Header:       *block.BlockHeader,
+		Transactions: block.Transactions,
+	})
+	if err != nil {
+		return err
+	}
+	store.Set(blockStoreKey(hash), buf)
+
+	if block.Height%SNAPSHOT_INTERVAL == 0 {
+		buf, err = encodeSnapshot(block.States)
+		if err != nil {
+			return err
+		}
+		store.Set(snapshotStoreKey(hash), buf)
+	}
+
+	return nil
+}
+
+// persistTip moves the last block pointer to the tip of the canonical chain
+func persistTip(store storage.Store, block *Block) {
+	store.Set(STORE_LAST_BLOCK_KEY, []byte(block.Hash()))
+}
+
+// persistFinal moves the final block pointer
+func persistFinal(store storage.Store, block *Block) {
+	store.Set(STORE_FINAL_BLOCK_KEY, []byte(block.Hash()))
+}
+
+// loadBlock reads the block with the given hash. States are left empty
+func loadBlock(store storage.Store, hash string) (*Block, error) {
+	buf := store.Get(blockStoreKey(hash))
+	if buf == nil {
+		return nil, fmt.Errorf("block %s not found in store", hash)
+	}
+
+	var stored persistedBlock
+	err := Decode(buf, &stored)
+	if err != nil {
+		return nil, err
+	}
+
+	block := Block{
+		BlockHeader:  &stored.Header,
+		Transactions: stored.Transactions,
+	}
+	if block.Hash() != hash {
+		return nil, fmt.Errorf("stored block %s has hash %s", hash, block.Hash())
+	}
+	return &block, nil
+}
+
+// loadSnapshot reads the world state snapshot of the block. It returns nil
+// if there is none or if it does not match the block's state hash
+func loadSnapshot(store storage.Store, block *Block) storage.KVStore {
+	buf := store.Get(snapshotStoreKey(block.Hash()))
+	if buf == nil {
+		return nil
+	}
+
+	worldState, err := decodeSnapshot(buf)
+	if err != nil {
+		return nil
+	}
+
+	// the state hash of a block is computed before its height is recorded
+	check := committedState(&Block{BlockHeader: block.BlockHeader, States: worldState})
+	if hex.EncodeToString(check.Hash()) != block.StateHash {
+		return nil
+	}
+	return worldState
+}
+
+// encodeSnapshot serializes a world state
+func encodeSnapshot(worldState storage.KVStore) ([]byte, error) {
+	entries := make([]snapshotEntry, 0)
+	err := worldState.For(func(key string, value interface{}) error {
+		entry, err := encodeEntry(key, value)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, *entry)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return Encode(entries)
+}
+
+// decodeSnapshot deserializes a world state
+func decodeSnapshot(buf []byte) (storage.KVStore, error) {
+	var entries []snapshotEntry
+	err := Decode(buf, &entries)
+	if err != nil {
+		return nil, err
+	}
+
+	worldState := storage.NewBasicKV()
+	for _, entry := range entries {
+		value, err := decodeEntry(entry)
+		if err != nil {
+			return nil, err
+		}
+
+		worldState.Put(entry.Key, value)
+	}
+
+	return worldState, nil
+}
+
+// encodeEntry serializes a single world state entry
+func encodeEntry(key string, value interface{}) (*snapshotEntry, error) {
+	var entryType string
+	var object interface{}
+	switch vv := value.(type) {
+	case Account:
+		entryType, object = snapshotTypeAccount, vv
+	case ChainConfig:
+		entryType, object = snapshotTypeConfig, vv
+	case AssetsRecord:
+		entryType, object = snapshotTypeAssets, vv
+	case MPCEndorsement:
+		entryType, object = snapshotTypeMPC, vv
+	case MultisigPolicy:
+		entryType, object = snapshotTypeMultisig, vv
+	case RotationRecord:
+		entryType, object = snapshotTypeRotation, vv
+	case RevocationVotes:
+		entryType, object = snapshotTypeRevocation, vv
+	case uint:
+		entryType, object = snapshotTypeHeight, vv
+	default:
+		return nil, fmt.Errorf("unknown world state entry %s: %T", key, value)
+	}
+
+	buf, err := Encode(object)
+	if err != nil {
+		return nil, err
+	}
+	return &snapshotEntry{Key: key, Type: entryType, Value: buf}, nil
+}
+
+// decodeEntry deserializes the value of a single world state entry
+func decodeEntry(entry snapshotEntry) (interface{}, error) {
+	var value interface{}
+	var err error
+	switch entry.Type {
+	case snapshotTypeAccount:
+		var account Account
+		err = Decode(entry.Value, &account)
+		value = account
+	case snapshotTypeConfig:
+		var config ChainConfig
+		err = Decode(entry.Value, &config)
+		value = config
+	case snapshotTypeAssets:
+		var record AssetsRecord
+		err = Decode(entry.Value, &record)
+		value = record
+	case snapshotTypeMPC:
+		var endorsement MPCEndorsement
+		err = Decode(entry.Value, &endorsement)
+		value = endorsement
+	case snapshotTypeMultisig:
+		var policy MultisigPolicy
+		err = Decode(entry.Value, &policy)
+		value = policy
+	case snapshotTypeRotation:
+		var record RotationRecord
+		err = Decode(entry.Value, &record)
+		value = record
+	case snapshotTypeRevocation:
+		var votes RevocationVotes
+		err = Decode(entry.Value, &votes)
+		value = votes
+	case snapshotTypeHeight:
+		var height uint
+		err = Decode(entry.Value, &height)
+		value = height
+	default:
+		err = fmt.Errorf("unknown snapshot entry type %s", entry.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return value, nil
+}
@@ -0,0 +1,148 @@
+package permissioned
+
+import (
+	"crypto/ecdsa"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/cs438/storage"
+	"go.dedis.ch/cs438/storage/inmemory"
+)
+
+func Test_BC_Persist_Reload(t *testing.T) {
+	defer func(interval uint) { SNAPSHOT_INTERVAL = interval }(SNAPSHOT_INTERVAL)
+	SNAPSHOT_INTERVAL = 2
+
+	store := inmemory.NewPersistency().GetBlockchainStore()
+	privKey, account, bc := newPersistedChain(t, store)
+
+	// block 1 to 4 alternate between replayed and snapshotted blocks
+	appendTxnBlock(t, bc, privKey, NewTransactionRegAssets(account, map[string]Amount{
+		"key1": 1,
+	}))
+	account.nonce++
+	appendTxnBlock(t, bc, privKey, NewTransactionStake(account, 100))
+	account.nonce++
+	appendTxnBlock(t, bc, privKey, NewTransactionUpdateAssets(account, map[string]Amount{
+		"key1": 5,
+	}))
+	account.nonce++
+	appendTxnBlock(t, bc, privKey, NewTransactionUnstake(account, 40))
+	latestBlock := bc.GetLatestBlock()
+	require.Equal(t, uint(4), latestBlock.Height)
+
+	// > restart
+	restored, err := NewBlockchainWithStore(store)
+	require.NoError(t, err)
+
+	restoredBlock := restored.GetLatestBlock()
+	require.NotNil(t, restoredBlock)
+	require.Equal(t, latestBlock.Hash(), restoredBlock.Hash())
+	require.Equal(t, latestBlock.States.Hash(), restoredBlock.States.Hash())
+	require.Len(t, restored.GetBlocksFromGenesis(), 5)
+
+	require.Equal(t, Amount(940), restored.GetBalance(account.addr.Hex))
+	require.Equal(t, Amount(60), restored.GetStake(account.addr.Hex))
+	assets := GetAssetsFromWorldState(restoredBlock.States, account.addr.Hex)
+	require.Equal(t, Amount(5), assets.Assets["key1"])
+	require.Equal(t, uint(4), GetHeightFromWorldState(restoredBlock.States))
+
+	// > the restored chain keeps growing and persisting
+	account.nonce++
+	appendTxnBlock(t, restored, privKey, NewTransactionRegAssets(account, map[string]Amount{
+		"key2": 2,
+	}))
+
+	restored, err = NewBlockchainWithStore(store)
+	require.NoError(t, err)
+	require.Equal(t, uint(5), restored.GetLatestBlock().Height)
+	require.Len(t, restored.SearchAssets("key2"), 1)
+}
+
+func Test_BC_Persist_Corrupted_Snapshot(t *testing.T) {
+	store := inmemory.NewPersistency().GetBlockchainStore()
+	privKey, account, bc := newPersistedChain(t, store)
+
+	appendTxnBlock(t, bc, privKey, NewTransactionRegAssets(account, map[string]Amount{
+		"key1": 1,
+	}))
+	genesis := bc.GetBlocksFromGenesis()[0]
+
+	// > a snapshot not matching the block is ignored and the block is replayed
+	fake := storage.NewBasicKV()
+	fake.Put(STATE_CONFIG_KEY, genesis.GetConfig())
+	buf, err := encodeSnapshot(fake)
+	require.NoError(t, err)
+	store.Set(snapshotStoreKey(genesis.Hash()), buf)
+
+	restored, err := NewBlockchainWithStore(store)
+	require.NoError(t, err)
+	require.Equal(t, bc.GetLatestBlock().Hash(), restored.GetLatestBlock().Hash())
+	require.Equal(t, Amount(1000), restored.GetBalance(account.addr.Hex))
+
+	// > a missing block cannot be recovered
+	store.Delete(blockStoreKey(genesis.Hash()))
+
+	restored, err = NewBlockchainWithStore(store)
+	require.Error(t, err)
+	require.Nil(t, restored.GetLatestBlock())
+}
+
+func Test_BC_Persist_Empty_Store(t *testing.T) {
+	store := inmemory.NewPersistency().GetBlockchainStore()
+
+	bc, err := NewBlockchainWithStore(store)
+	require.NoError(t, err)
+	require.Nil(t, bc.GetLatestBlock())
+	require.Len(t, bc.GetBlocksFromGenesis(), 0)
+}
+
+// newPersistedChain creates a chain with a single funded participant
+func newPersistedChain(t *testing.T, store storage.Store) (*ecdsa.PrivateKey,
+	*Account, *Blockchain) {
+
+	privKey, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	account := NewAccount(*NewAddress(&privKey.PublicKey))
+
+	config := *NewChainConfig(
+		map[string]string{account.addr.Hex: ""},
+		10, "2h", 0, 10,
+	)
+	bc, err := NewBlockchainWithStore(store)
+	require.NoError(t, err)
+	block0, err := bc.InitGenesisBlock(&config, map[string]Amount{
+		account.addr.Hex: 1000,
+	})
+	require.NoError(t, err)
+	err = bc.SetGenesisBlock(&block0)
+	require.NoError(t, err)
+
+	return privKey, account, bc
+}
+
+// appendTxnBlock mines and appends a block made of a single transaction
+func appendTxnBlock(t *testing.T, bc *Blockchain, privKey *ecdsa.PrivateKey,
+	txn *Transaction) {
+
+	prevBlock := bc.GetLatestBlock()
+	worldState := prevBlock.GetWorldStateCopy()
+
+	signedTxn, err := txn.Sign(privKey)
+	require.NoError(t, err)
+	err = signedTxn.Verify(worldState)
+	require.NoError(t, err)
+
+	bb := NewBlockBuilder()
+	bb.SetPrevHash(prevBlock.Hash()).SetHeight(prevBlock.Height + 1).
+		SetMiner(NewAddress(&privKey.PublicKey).Hex).SetState(worldState).
+		SetSelectionProof(SelectionSeed(prevBlock.BlockHeader))
+	bb.AddTxn(signedTxn)
+	block := bb.Build()
+	err = block.Sign(privKey)
+	require.NoError(t, err)
+
+	err = bc.AppendBlock(block)
+	require.NoError(t, err)
+}
@@ -0,0 +1,201 @@
+package permissioned
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"go.dedis.ch/cs438/storage"
+)
+
+// -----------------------------------------------------------------------------
+// Utilities - Inclusion Proofs
+
+// TxnProof proves that a transaction is included in the block of the header
+type TxnProof struct {
+	Header BlockHeader
+	Txn    SignedTransaction
+	// position of the transaction in the block
+	Index int
+	Proof storage.MerkleProof
+}
+
+// Verify checks that the transaction is committed by the transaction root
+// of the header at its index. The caller must trust the header, e.g. by its
+// hash
+func (p TxnProof) Verify() error {
+	root, err := hex.DecodeString(p.Header.TransationHash)
+	if err != nil {
+		return err
+	}
+	// the path of the Merkle proof is checked against its index
+	if p.Index != p.Proof.Index {
+		return fmt.Errorf("txn %s is proven at index %d, not %d",
+			p.Txn.Txn.ID, p.Proof.Index, p.Index)
+	}
+	if !p.Proof.Verify(root, p.Txn.HashBytes()) {
+		return fmt.Errorf("txn %s is not included in block %s", p.Txn.Txn.ID, p.Header.Hash())
+	}
+	return nil
+}
+
+// StateProof proves the value of a world state entry after the block of
+// the header
+type StateProof struct {
+	Header BlockHeader
+	Entry  storage.StateProof
+	// serialized value of the entry, so that the proof can be used
+	// without the world state
+	Value []byte
+}
+
+// Verify checks that the entry is committed by the state root of the
+// header and that the value matches it. The caller must trust the header,
+// e.g. by its hash
+func (p StateProof) Verify() error {
+	root, err := hex.DecodeString(p.Header.StateHash)
+	if err != nil {
+		return err
+	}
+	if !p.Entry.Verify(root) {
+		return fmt.Errorf("entry %s is not included in the state of block %s",
+			p.Entry.Key, p.Header.Hash())
+	}
+
+	value, err := p.GetValue()
+	if err != nil {
+		return err
+	}
+	if !p.Matches(value) {
+		return fmt.Errorf("value of entry %s does not match its proof", p.Entry.Key)
+	}
+	return nil
+}
+
+// GetValue returns the value of the proven entry. It is only trustworthy
+// once the proof is verified
+func (p StateProof) GetValue() (interface{}, error) {
+	var entry snapshotEntry
+	err := Decode(p.Value, &entry)
+	if err != nil {
+		return nil, err
+	}
+	if entry.Key != p.Entry.Key {
+		return nil, fmt.Errorf("value of entry %s given for entry %s", entry.Key, p.Entry.Key)
+	}
+	return decodeEntry(entry)
+}
+
+// GetAccount returns the proven account. It is only trustworthy once the
+// proof is verified
+func (p StateProof) GetAccount() (*Account, error) {
+	value, err := p.GetValue()
+	if err != nil {
+		return nil, err
+	}
+	account, ok := value.(Account)
+	if !ok {
+		return nil, fmt.Errorf("entry %s is not an account: %T", p.Entry.Key, value)
+	}
+	return &account, nil
+}
+
+// Matches checks that the proven entry has the given value
+func (p StateProof) Matches(value interface{}) bool {
+	return storage.ValueHash(value) == p.Entry.ValueHash
+}
+
+// GetTxnProof returns the proof that the transaction is included in the
+// canonical chain
+func (bc *Blockchain) GetTxnProof(txnID string) (*TxnProof, error) {
+	bc.RLock()
+	defer bc.RUnlock()
+
+	for curr := bc.latestBlock; curr != nil; curr = bc.blocksStore[curr.PrevHash] {
+		for i, txn := range curr.Transactions {
+			if txn.Txn.ID != txnID {
+				continue
+			}
+
+			proof, err := storage.NewMerkleProof(txnLeaves(curr.Transactions), i)
+			if err != nil {
+				return nil, err
+			}
+			return &TxnProof{
+				Header: *curr.BlockHeader,
+				Txn:    txn,
+				Index:  i,
+				Proof:  *proof,
+			}, nil
+		}
+	}
+	return nil, fmt.Errorf("txn %s not found in blockchain", txnID)
+}
+
+// GetStateProof returns the proof of the current value of a world state
+// entry, e.g. an account address or AssetsKeyFromUniqID(owner)
+func (bc *Blockchain) GetStateProof(key string) (*StateProof, error) {
+	bc.RLock()
+	defer bc.RUnlock()
+
+	if bc.latestBlock == nil {
+		return nil, fmt.Errorf("need to set genesis block first")
+	}
+	return bc.getStateProof(bc.latestBlock, key)
+}
+
+// GetStateProofAt returns the proof of the value of a world state entry
+// after the given block, e.g. the config a light node needs to check the
+// miner of the next block
+func (bc *Blockchain) GetStateProofAt(blockHash string, key string) (*StateProof, error) {
+	bc.RLock()
+	defer bc.RUnlock()
+
+	block, ok := bc.blocksStore[blockHash]
+	if !ok {
+		return nil, fmt.Errorf("unknown block %s", blockHash)
+	}
+	return bc.getStateProof(block, key)
+}
+
+// getStateProof is the unlocked version of GetStateProofAt. The state of
+// the block is replayed if it was pruned
+func (bc *Blockchain) getStateProof(block *Block, key string) (*StateProof, error) {
+	blockState, err := bc.stateAt(block)
+	if err != nil {
+		return nil, err
+	}
+	worldState := committedState(&Block{BlockHeader: block.BlockHeader, States: blockState})
+	entry, err := storage.ProveKey(worldState, key)
+	if err != nil {
+		return nil, err
+	}
+
+	// the entry exists as it is proven
+	value, _ := worldState.Get(key)
+	encoded, err := encodeEntry(key, value)
+	if err != nil {
+		return nil, err
+	}
+	buf, err := Encode(*encoded)
+	if err != nil {
+		return nil, err
+	}
+
+	return &StateProof{
+		Header: *block.BlockHeader,
+		Entry:  *entry,
+		Value:  buf,
+	}, nil
+}
+
+// committedState returns the world state as committed by the block's state
+// hash, i.e. before the block's height is recorded
+func committedState(block *Block) storage.KVStore {
+	worldState := block.GetWorldStateCopy()
+	if block.Height == 0 {
+		worldState.Del(STATE_HEIGHT_KEY)
+	} else {
+		worldState.Put(STATE_HEIGHT_KEY, block.Height-1)
+	}
+	return worldState
+}
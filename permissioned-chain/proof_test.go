@@ -0,0 +1,95 @@
+package permissioned
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/cs438/storage/inmemory"
+)
+
+func Test_BC_Proof_Txn(t *testing.T) {
+	privKey, account, bc := newPersistedChain(t, inmemory.NewPersistency().GetBlockchainStore())
+
+	txn1 := NewTransactionRegAssets(account, map[string]Amount{"key1": 1})
+	appendTxnBlock(t, bc, privKey, txn1)
+	account.IncreaseNonce()
+	txn2 := NewTransactionStake(account, 1)
+	appendTxnBlock(t, bc, privKey, txn2)
+
+	proof, err := bc.GetTxnProof(txn1.ID)
+	require.NoError(t, err)
+	require.NoError(t, proof.Verify())
+	require.Equal(t, txn1.ID, proof.Txn.Txn.ID)
+	require.Equal(t, uint(1), proof.Header.Height)
+	require.NotNil(t, bc.GetBlock(proof.Header.Hash()))
+
+	// > the genesis transactions are provable as well
+	genesis := bc.GetBlocksFromGenesis()[0]
+	proof, err = bc.GetTxnProof(genesis.Transactions[len(genesis.Transactions)-1].Txn.ID)
+	require.NoError(t, err)
+	require.NoError(t, proof.Verify())
+	require.Equal(t, len(genesis.Transactions)-1, proof.Index)
+
+	// > the position of the transaction is proven as well
+	require.Greater(t, len(genesis.Transactions), 1)
+	moved := *proof
+	moved.Index = 0
+	require.Error(t, moved.Verify())
+	moved.Proof.Index = 0
+	require.Error(t, moved.Verify())
+
+	// > a forged transaction is rejected
+	proof, err = bc.GetTxnProof(txn2.ID)
+	require.NoError(t, err)
+	proof.Txn.Txn.Value = 100
+	require.Error(t, proof.Verify())
+
+	_, err = bc.GetTxnProof("unknown")
+	require.Error(t, err)
+}
+
+func Test_BC_Proof_State(t *testing.T) {
+	privKey, account, bc := newPersistedChain(t, inmemory.NewPersistency().GetBlockchainStore())
+
+	txn := NewTransactionRegAssets(account, map[string]Amount{"key1": 1})
+	appendTxnBlock(t, bc, privKey, txn)
+	latestBlock := bc.GetLatestBlock()
+
+	// > account balance
+	proof, err := bc.GetStateProof(account.addr.Hex)
+	require.NoError(t, err)
+	require.NoError(t, proof.Verify())
+	require.Equal(t, latestBlock.Hash(), proof.Header.Hash())
+	require.True(t, proof.Matches(*GetAccountFromWorldState(latestBlock.States, account.addr.Hex)))
+
+	forged := *GetAccountFromWorldState(latestBlock.States, account.addr.Hex)
+	forged.balance++
+	require.False(t, proof.Matches(forged))
+
+	// > the proof carries the value
+	proven, err := proof.GetAccount()
+	require.NoError(t, err)
+	require.Equal(t, *GetAccountFromWorldState(latestBlock.States, account.addr.Hex), *proven)
+
+	forgedProof := *proof
+	encoded, err := encodeEntry(account.addr.Hex, forged)
+	require.NoError(t, err)
+	forgedProof.Value, err = json.Marshal(encoded)
+	require.NoError(t, err)
+	require.Error(t, forgedProof.Verify())
+
+	// > assets
+	assetsKey := AssetsKeyFromUniqID(account.addr.Hex)
+	proof, err = bc.GetStateProof(assetsKey)
+	require.NoError(t, err)
+	require.NoError(t, proof.Verify())
+	require.True(t, proof.Matches(*GetAssetsFromWorldState(latestBlock.States, account.addr.Hex)))
+
+	// > a proof does not hold for another key
+	proof.Entry.Key = account.addr.Hex
+	require.Error(t, proof.Verify())
+
+	_, err = bc.GetStateProof("unknown")
+	require.Error(t, err)
+}
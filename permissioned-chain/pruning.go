@@ -0,0 +1,113 @@
+package permissioned
+
+import (
+	"fmt"
+
+	"go.dedis.ch/cs438/storage"
+)
+
+// -----------------------------------------------------------------------------
+// Utilities - Pruning
+
+// STATE_CHECKPOINT_INTERVAL is the number of blocks between two flat world
+// states. The states of the blocks in between only hold their changes on
+// top of their parent's state, see storage.LayeredKV
+var STATE_CHECKPOINT_INTERVAL uint = 10
+
+// STATE_RETENTION is the number of blocks below the tip whose world states
+// are kept in memory. Older states are dropped, except the genesis one, and
+// rebuilt on demand by StateAt. Headers and transactions are always kept.
+// 0 disables pruning
+var STATE_RETENTION uint = 100
+
+// checkpointState returns the world state to keep for the block at the
+// given height. It is flattened on checkpoints so that lookups never go
+// through more than STATE_CHECKPOINT_INTERVAL layers
+func checkpointState(height uint, worldState storage.KVStore) storage.KVStore {
+	if _, ok := worldState.(*storage.LayeredKV); !ok || height%STATE_CHECKPOINT_INTERVAL != 0 {
+		return worldState
+	}
+	return storage.Flatten(worldState)
+}
+
+// StateAt returns the world state after the block. If it was pruned, it is
+// replayed from the closest persisted snapshot or kept state. The returned
+// state must not be modified
+func (bc *Blockchain) StateAt(blockHash string) (storage.KVStore, error) {
+	bc.RLock()
+	defer bc.RUnlock()
+
+	block, ok := bc.blocksStore[blockHash]
+	if !ok {
+		return nil, fmt.Errorf("unknown block %s", blockHash)
+	}
+	return bc.stateAt(block)
+}
+
+// stateAt is the unlocked version of StateAt
+func (bc *Blockchain) stateAt(block *Block) (storage.KVStore, error) {
+	// walk back to a block with a state, at worst the genesis block
+	replay := make([]*Block, 0)
+	curr := block
+	worldState := curr.States
+	for worldState == nil {
+		if bc.store != nil {
+			worldState = loadSnapshot(bc.store, curr)
+			if worldState != nil {
+				break
+			}
+		}
+		replay = append(replay, curr)
+
+		parent, ok := bc.blocksStore[curr.PrevHash]
+		if !ok {
+			return nil, fmt.Errorf("unknown parent block %s", curr.PrevHash)
+		}
+		curr = parent
+		worldState = curr.States
+	}
+
+	// replay forward on copies of the blocks, which stay pruned
+	for i := len(replay) - 1; i >= 0; i-- {
+		replayed := &Block{
+			BlockHeader:  replay[i].BlockHeader,
+			Transactions: replay[i].Transactions,
+		}
+		err := replayed.Verify(storage.NewLayeredKV(worldState))
+		if err != nil {
+			return nil, fmt.Errorf("failed to replay block %s: %v", replayed.Hash(), err)
+		}
+		worldState = replayed.States
+	}
+	return worldState, nil
+}
+
+// prune drops the world states of the blocks more than STATE_RETENTION
+// below the tip, and below maxHeight. When the chain needs votes to be
+// final, the states of the blocks that are not final yet are kept. The cut
+// is on a checkpoint so that the kept states do not refer to dropped ones
+func (bc *Blockchain) prune(maxHeight uint) {
+	if STATE_RETENTION == 0 || bc.latestBlock.Height <= STATE_RETENTION {
+		return
+	}
+
+	cut := bc.latestBlock.Height - STATE_RETENTION
+	config := GetConfigFromWorldState(bc.latestBlock.States)
+	if config != nil && config.Finality && bc.finalBlock.Height < cut {
+		cut = bc.finalBlock.Height
+	}
+	if maxHeight < cut {
+		cut = maxHeight
+	}
+	cut -= cut % STATE_CHECKPOINT_INTERVAL
+	if cut <= bc.prunedHeight {
+		return
+	}
+
+	for _, block := range bc.blocksStore {
+		if block.Height > 0 && block.Height < cut {
+			block.States = nil
+		}
+	}
+	bc.prunedHeight = cut
+}
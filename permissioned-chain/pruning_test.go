@@ -0,0 +1,132 @@
+package permissioned
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/cs438/storage"
+	"go.dedis.ch/cs438/storage/inmemory"
+)
+
+func Test_BC_State_Checkpoints(t *testing.T) {
+	defer func(interval uint) { STATE_CHECKPOINT_INTERVAL = interval }(STATE_CHECKPOINT_INTERVAL)
+	STATE_CHECKPOINT_INTERVAL = 3
+
+	privKey, account, bc := newPersistedChain(t, inmemory.NewPersistency().GetBlockchainStore())
+	for i := 0; i < 6; i++ {
+		appendTxnBlock(t, bc, privKey, NewTransactionStake(account, 1))
+		account.nonce++
+	}
+
+	// > blocks only hold their changes, up to the next checkpoint
+	for _, block := range bc.GetBlocksFromGenesis() {
+		layered, ok := block.States.(*storage.LayeredKV)
+		if block.Height%STATE_CHECKPOINT_INTERVAL == 0 {
+			require.False(t, ok, "height %d", block.Height)
+			continue
+		}
+		require.True(t, ok, "height %d", block.Height)
+		require.Equal(t, int(block.Height%STATE_CHECKPOINT_INTERVAL), layered.Depth())
+	}
+	require.Equal(t, Amount(6), bc.GetStake(account.addr.Hex))
+}
+
+func Test_BC_State_Pruning(t *testing.T) {
+	defer func(retention uint) { STATE_RETENTION = retention }(STATE_RETENTION)
+	defer func(interval uint) { STATE_CHECKPOINT_INTERVAL = interval }(STATE_CHECKPOINT_INTERVAL)
+	defer func(interval uint) { SNAPSHOT_INTERVAL = interval }(SNAPSHOT_INTERVAL)
+	STATE_RETENTION = 4
+	STATE_CHECKPOINT_INTERVAL = 2
+	SNAPSHOT_INTERVAL = 5
+
+	store := inmemory.NewPersistency().GetBlockchainStore()
+	privKey, account, bc := newPersistedChain(t, store)
+	stateHashes := map[string][]byte{}
+	for i := 0; i < 10; i++ {
+		appendTxnBlock(t, bc, privKey, NewTransactionStake(account, 1))
+		account.nonce++
+		latestBlock := bc.GetLatestBlock()
+		stateHashes[latestBlock.Hash()] = latestBlock.States.Hash()
+	}
+
+	// > states below the last checkpoint before the retention are dropped,
+	// except the genesis one
+	blocks := bc.GetBlocksFromGenesis()
+	for _, block := range blocks {
+		pruned := block.Height > 0 && block.Height < 6
+		require.Equal(t, pruned, block.States == nil, "height %d", block.Height)
+		require.NotNil(t, block.BlockHeader)
+		require.NotEmpty(t, block.Transactions)
+	}
+
+	// > pruned states are replayed
+	for _, block := range blocks[1:] {
+		worldState, err := bc.StateAt(block.Hash())
+		require.NoError(t, err)
+		require.Equal(t, stateHashes[block.Hash()], worldState.Hash(), "height %d", block.Height)
+	}
+	_, err := bc.StateAt("unknown")
+	require.Error(t, err)
+
+	// > without a store, from the genesis state
+	memory := NewBlockchain()
+	memory.blocksStore, memory.latestBlock = bc.blocksStore, bc.latestBlock
+	worldState, err := memory.StateAt(blocks[3].Hash())
+	require.NoError(t, err)
+	require.Equal(t, stateHashes[blocks[3].Hash()], worldState.Hash())
+
+	// > a restarted node prunes the restored chain, and the export replays it
+	restored, err := NewBlockchainWithStore(store)
+	require.NoError(t, err)
+	require.Nil(t, restored.GetBlocksFromGenesis()[3].States)
+	require.Equal(t, Amount(10), restored.GetStake(account.addr.Hex))
+
+	buf, err := restored.Export(true)
+	require.NoError(t, err)
+	export, err := DecodeExport(buf)
+	require.NoError(t, err)
+	require.Len(t, export.Snapshots, 3)
+	require.Empty(t, Audit(export).Anomalies)
+}
+
+func Test_BC_State_Pruning_Stale_Block(t *testing.T) {
+	defer func(retention uint) { STATE_RETENTION = retention }(STATE_RETENTION)
+	defer func(interval uint) { STATE_CHECKPOINT_INTERVAL = interval }(STATE_CHECKPOINT_INTERVAL)
+	STATE_RETENTION = 4
+	STATE_CHECKPOINT_INTERVAL = 2
+
+	privKey, account, bc := newPersistedChain(t, inmemory.NewPersistency().GetBlockchainStore())
+	for i := 0; i < 10; i++ {
+		appendTxnBlock(t, bc, privKey, NewTransactionStake(account, 1))
+		account.nonce++
+	}
+
+	// > a block extending a pruned ancestor
+	parent := bc.GetBlocksFromGenesis()[2]
+	require.Nil(t, parent.States)
+	parentState, err := bc.StateAt(parent.Hash())
+	require.NoError(t, err)
+	miner, proof := selectMiner(parent, parentState)
+
+	bb := NewBlockBuilder()
+	bb.SetPrevHash(parent.Hash()).SetHeight(parent.Height + 1).
+		SetMiner(miner).SetSelectionProof(proof).
+		SetState(storage.NewLayeredKV(parentState))
+	stale := bb.Build()
+	require.NoError(t, stale.Sign(privKey))
+
+	// > the selection is checked on the replayed state of the parent
+	require.NoError(t, bc.VerifyMinerSelection(stale))
+	require.Error(t, VerifyMinerSelection(parent, stale))
+	require.Nil(t, parent.States)
+
+	wrongMiner := *stale.BlockHeader
+	wrongMiner.Miner = ZeroAddress.Hex
+	require.Error(t, bc.VerifyMinerSelection(&Block{BlockHeader: &wrongMiner}))
+
+	// > the block stays on a competing branch
+	reorg, err := bc.AddBlock(stale)
+	require.NoError(t, err)
+	require.Nil(t, reorg)
+	require.Equal(t, uint(10), bc.GetLatestBlock().Height)
+}
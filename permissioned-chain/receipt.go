@@ -0,0 +1,51 @@
+package permissioned
+
+import "fmt"
+
+// -----------------------------------------------------------------------------
+// Utilities - Transaction Receipts
+
+type TxnStatus string
+
+const (
+	// the transaction is sent but not in the canonical chain yet
+	TxnStatusPending TxnStatus = "pending"
+	// the transaction is included in a block of the canonical chain
+	TxnStatusIncluded TxnStatus = "included"
+	// the transaction failed its verification and was dropped
+	TxnStatusRejected TxnStatus = "rejected"
+)
+
+// TxnReceipt describes what happened to a transaction sent by the node.
+// The block fields are only set once included, the error once rejected
+type TxnReceipt struct {
+	TxnID  string
+	Type   TxnType
+	Status TxnStatus
+	Error  string
+
+	BlockHash string
+	Height    uint
+	Index     int
+}
+
+// NewTxnReceipt creates the receipt of a freshly sent transaction
+func NewTxnReceipt(txn *Transaction) *TxnReceipt {
+	return &TxnReceipt{
+		TxnID:  txn.ID,
+		Type:   txn.Type,
+		Status: TxnStatusPending,
+	}
+}
+
+// String returns a description of the receipt
+func (r TxnReceipt) String() string {
+	switch r.Status {
+	case TxnStatusIncluded:
+		return fmt.Sprintf("%s %s: included in block %s at height=%d, index=%d",
+			r.Type, r.TxnID, r.BlockHash, r.Height, r.Index)
+	case TxnStatusRejected:
+		return fmt.Sprintf("%s %s: rejected, %s", r.Type, r.TxnID, r.Error)
+	}
+	return fmt.Sprintf("%s %s: %s", r.Type, r.TxnID, r.Status)
+}
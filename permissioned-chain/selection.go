@@ -0,0 +1,140 @@
+package permissioned
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+
+	"go.dedis.ch/cs438/storage"
+)
+
+// -----------------------------------------------------------------------------
+// Utilities - Miner Selection
+
+// SelectionSeed returns the seed selecting the miner of the block following
+// the parent. Seeds form a hash chain starting from the genesis block: each
+// one only depends on the previous seed and the height, so the miner of the
+// parent cannot grind it by changing the content of its block
+func SelectionSeed(parent *BlockHeader) string {
+	prevSeed := parent.SelectionProof
+	if parent.Height == 0 {
+		// the genesis block is not mined, the chain starts from its hash
+		prevSeed = parent.Hash()
+	}
+
+	h := sha256.New()
+	h.Write([]byte(prevSeed))
+	h.Write([]byte(strconv.Itoa(int(parent.Height + 1))))
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// SelectMiner selects the miner of the block following the parent. A
+// participant is selected with a probability proportional to its balance
+// plus stake in the parent's state. If nobody holds anything, participants
+// are equally likely. Light nodes are never selected. The selection only
+// depends on the parent block so every node can check it. It returns the
+// miner and the seed used as proof
+func SelectMiner(parent *Block) (string, string) {
+	return selectMiner(parent, parent.States)
+}
+
+// selectMiner selects the miner of the block following the parent given the
+// parent's world state, which may have been pruned from the parent
+func selectMiner(parent *Block, parentState storage.KVStore) (string, string) {
+	seed := SelectionSeed(parent.BlockHeader)
+
+	config := GetConfigFromWorldState(parentState)
+	validators := config.Validators()
+	participants := make([]string, 0, len(validators))
+	for participant := range validators {
+		participants = append(participants, participant)
+	}
+	sort.Strings(participants)
+	if len(participants) == 0 {
+		return "", seed
+	}
+
+	// weights are summed as big integers: the selection must be exact for
+	// every node to agree on it, and the total may not fit in an Amount
+	weights := make([]*big.Int, len(participants))
+	total := new(big.Int)
+	for i, participant := range participants {
+		account := GetAccountFromWorldState(parentState, participant)
+		weights[i] = new(big.Int).Add(big.NewInt(int64(account.balance)),
+			big.NewInt(int64(account.stake)))
+		if weights[i].Sign() < 0 {
+			weights[i].SetInt64(0)
+		}
+		total.Add(total, weights[i])
+	}
+
+	// draw a number from the whole seed. It is much larger than the total
+	// so the bias of the modulo is negligible
+	buf, _ := hex.DecodeString(seed)
+	draw := new(big.Int).SetBytes(buf)
+
+	if total.Sign() == 0 {
+		index := draw.Mod(draw, big.NewInt(int64(len(participants))))
+		return participants[index.Int64()], seed
+	}
+
+	target := draw.Mod(draw, total)
+	cumulative := new(big.Int)
+	for i, participant := range participants {
+		cumulative.Add(cumulative, weights[i])
+		if target.Cmp(cumulative) < 0 {
+			return participant, seed
+		}
+	}
+	// unreachable, the target is below the total
+	return participants[len(participants)-1], seed
+}
+
+// VerifyMinerSelection checks that the block was mined by the miner
+// selected after its parent and carries the matching proof. The parent
+// needs its world state, see Blockchain.VerifyMinerSelection otherwise
+func VerifyMinerSelection(parent *Block, block *Block) error {
+	if parent.States == nil {
+		return fmt.Errorf("the state of block %s was pruned", parent.Hash())
+	}
+	return verifyMinerSelection(parent, parent.States, block)
+}
+
+// VerifyMinerSelection checks the miner selection of a block following a
+// block of the chain. The state of the parent is replayed if it was pruned
+func (bc *Blockchain) VerifyMinerSelection(block *Block) error {
+	bc.RLock()
+	defer bc.RUnlock()
+
+	parent, ok := bc.blocksStore[block.PrevHash]
+	if !ok {
+		return fmt.Errorf("unknown parent block %s", block.PrevHash)
+	}
+	parentState, err := bc.stateAt(parent)
+	if err != nil {
+		return err
+	}
+	return verifyMinerSelection(parent, parentState, block)
+}
+
+// verifyMinerSelection checks the miner selection of a block given the
+// world state of its parent
+func verifyMinerSelection(parent *Block, parentState storage.KVStore, block *Block) error {
+	if block.PrevHash != parent.Hash() {
+		return fmt.Errorf("block %s does not follow block %s", block.Hash(), parent.Hash())
+	}
+
+	miner, seed := selectMiner(parent, parentState)
+	if block.SelectionProof != seed {
+		return fmt.Errorf("invalid selection proof. Expected: %s. Got: %s",
+			seed, block.SelectionProof)
+	}
+	if block.Miner != miner {
+		return fmt.Errorf("invalid miner. Expected: %s. Got: %s", miner, block.Miner)
+	}
+	return nil
+}
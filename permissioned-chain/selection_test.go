@@ -0,0 +1,148 @@
+package permissioned
+
+import (
+	"math"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/cs438/storage"
+)
+
+func Test_BC_Selection_Stake_Weighted(t *testing.T) {
+	rich, poor, broke := newSelectionAccount(t), newSelectionAccount(t), newSelectionAccount(t)
+	rich.balance = 900
+	poor.balance = 50
+	poor.stake = 50
+
+	parent := newSelectionParent(rich, poor, broke)
+
+	// > the selection only depends on the parent
+	miner, seed := SelectMiner(parent)
+	miner2, seed2 := SelectMiner(parent)
+	require.Equal(t, miner, miner2)
+	require.Equal(t, seed, seed2)
+	require.Equal(t, SelectionSeed(parent.BlockHeader), seed)
+
+	// > the miner of the parent cannot grind the seed with the content of
+	// its block
+	parent.StateHash = "other state"
+	parent.Timestamp++
+	_, seed2 = SelectMiner(parent)
+	require.Equal(t, seed, seed2)
+
+	// > participants are selected in proportion to balance plus stake
+	count := map[string]int{}
+	for i := 0; i < 1000; i++ {
+		parent.SelectionProof = SelectionSeed(parent.BlockHeader)
+		miner, _ := SelectMiner(parent)
+		count[miner]++
+	}
+	require.InDelta(t, 900, count[rich.addr.Hex], 60)
+	require.InDelta(t, 100, count[poor.addr.Hex], 60)
+	require.Equal(t, 0, count[broke.addr.Hex])
+}
+
+func Test_BC_Selection_Uniform(t *testing.T) {
+	accounts := []*Account{newSelectionAccount(t), newSelectionAccount(t)}
+	parent := newSelectionParent(accounts...)
+
+	// > everybody is eligible if nobody holds anything
+	count := map[string]int{}
+	for i := 0; i < 200; i++ {
+		parent.SelectionProof = SelectionSeed(parent.BlockHeader)
+		miner, _ := SelectMiner(parent)
+		count[miner]++
+	}
+	require.Len(t, count, 2)
+}
+
+func Test_BC_Selection_Exact(t *testing.T) {
+	rich, broke := newSelectionAccount(t), newSelectionAccount(t)
+	rich.balance = math.MaxInt64
+	rich.stake = math.MaxInt64
+	parent := newSelectionParent(rich, broke)
+
+	// > weights larger than an amount don't overflow, nor lose precision
+	for i := 0; i < 100; i++ {
+		parent.SelectionProof = SelectionSeed(parent.BlockHeader)
+		miner, _ := SelectMiner(parent)
+		require.Equal(t, rich.addr.Hex, miner)
+	}
+}
+
+func Test_BC_Selection_Light_Nodes(t *testing.T) {
+	full, light := newSelectionAccount(t), newSelectionAccount(t)
+	full.balance = 1
+	light.balance = 1000
+	parent := newSelectionParent(full, light)
+
+	config := parent.GetConfig()
+	config.LightNodes = []string{light.addr.Hex}
+	parent.States.Put(STATE_CONFIG_KEY, config)
+	require.Len(t, config.Validators(), 1)
+
+	// > light nodes are never selected, whatever they hold
+	for i := 0; i < 100; i++ {
+		parent.SelectionProof = SelectionSeed(parent.BlockHeader)
+		miner, _ := SelectMiner(parent)
+		require.Equal(t, full.addr.Hex, miner)
+	}
+}
+
+func Test_BC_Selection_Verify(t *testing.T) {
+	accounts := []*Account{newSelectionAccount(t), newSelectionAccount(t)}
+	accounts[0].balance = 10
+	parent := newSelectionParent(accounts...)
+	miner, seed := SelectMiner(parent)
+	require.Equal(t, accounts[0].addr.Hex, miner)
+
+	header := BlockHeader{
+		PrevHash:       parent.Hash(),
+		Height:         parent.Height + 1,
+		Miner:          miner,
+		SelectionProof: seed,
+	}
+	require.NoError(t, VerifyMinerSelection(parent, &Block{BlockHeader: &header}))
+
+	// > wrong miner
+	wrong := header
+	wrong.Miner = accounts[1].addr.Hex
+	require.Error(t, VerifyMinerSelection(parent, &Block{BlockHeader: &wrong}))
+
+	// > wrong proof
+	wrong = header
+	wrong.SelectionProof = SelectionSeed(&header)
+	require.Error(t, VerifyMinerSelection(parent, &Block{BlockHeader: &wrong}))
+
+	// > wrong parent
+	wrong = header
+	wrong.PrevHash = DUMMY_PREVHASH
+	require.Error(t, VerifyMinerSelection(parent, &Block{BlockHeader: &wrong}))
+}
+
+func newSelectionAccount(t *testing.T) *Account {
+	privKey, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	return NewAccount(*NewAddress(&privKey.PublicKey))
+}
+
+// newSelectionParent creates a block whose state has the accounts as participants
+func newSelectionParent(accounts ...*Account) *Block {
+	worldState := storage.NewBasicKV()
+	participants := map[string]string{}
+	for _, account := range accounts {
+		participants[account.addr.Hex] = ""
+		worldState.Put(account.addr.Hex, *account)
+	}
+	worldState.Put(STATE_CONFIG_KEY, *NewChainConfig(participants, 1, "2h", 0, 1))
+
+	return &Block{
+		BlockHeader: &BlockHeader{
+			PrevHash:       DUMMY_PREVHASH,
+			Height:         1,
+			SelectionProof: "seed",
+		},
+		States: worldState,
+	}
+}
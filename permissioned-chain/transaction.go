@@ -2,10 +2,9 @@ package permissioned
 
 import (
 	"crypto/ecdsa"
-	"crypto/sha256"
 	"encoding/hex"
-	"encoding/json"
 	"fmt"
+	"reflect"
 
 	"github.com/ethereum/go-ethereum/crypto"
 	"go.dedis.ch/cs438/storage"
@@ -17,33 +16,68 @@ import (
 type TxnType string
 
 const (
-	TxnTypeCoinbase  TxnType = "txn-coinbase"
-	TxnTypePreMPC    TxnType = "txn-preMPC"
-	TxnTypePostMPC   TxnType = "txn-postMPC"
-	TxnTypeRegAssets TxnType = "txn-regAssets"
+	TxnTypeCoinbase      TxnType = "txn-coinbase"
+	TxnTypePreMPC        TxnType = "txn-preMPC"
+	TxnTypePostMPC       TxnType = "txn-postMPC"
+	TxnTypeRegAssets     TxnType = "txn-regAssets"
+	TxnTypeStake         TxnType = "txn-stake"
+	TxnTypeUnstake       TxnType = "txn-unstake"
+	TxnTypeSlash         TxnType = "txn-slash"
+	TxnTypeRegPricing    TxnType = "txn-regPricing"
+	TxnTypeUpdateAssets  TxnType = "txn-updateAssets"
+	TxnTypeRemoveAssets  TxnType = "txn-removeAssets"
+	TxnTypeTransferAsset TxnType = "txn-transferAsset"
+	TxnTypeCancel        TxnType = "txn-cancel"
+	TxnTypeRegMultisig   TxnType = "txn-regMultisig"
+	TxnTypeRotateKey     TxnType = "txn-rotateKey"
+	TxnTypeRevokeKey     TxnType = "txn-revokeKey"
 
 	TxnTypeInitConfig TxnType = "txn-initConfig"
 	TxnTypeRegEnckey  TxnType = "txn-regEnckey"
 )
 
 var txnHandlerStore = map[TxnType]func(storage.KVStore, *ChainConfig, *Transaction) error{
-	TxnTypeCoinbase:  execCoinbase,
-	TxnTypePreMPC:    execPreMPC,
-	TxnTypePostMPC:   execPostMPC,
-	TxnTypeRegAssets: execRegAssets,
+	TxnTypeCoinbase:      execCoinbase,
+	TxnTypePreMPC:        execPreMPC,
+	TxnTypePostMPC:       execPostMPC,
+	TxnTypeRegAssets:     execRegAssets,
+	TxnTypeStake:         execStake,
+	TxnTypeUnstake:       execUnstake,
+	TxnTypeSlash:         execSlash,
+	TxnTypeRegPricing:    execRegPricing,
+	TxnTypeUpdateAssets:  execUpdateAssets,
+	TxnTypeRemoveAssets:  execRemoveAssets,
+	TxnTypeTransferAsset: execTransferAsset,
+	TxnTypeCancel:        execCancel,
+	TxnTypeRegMultisig:   execRegMultisig,
+	TxnTypeRotateKey:     execRotateKey,
+	TxnTypeRevokeKey:     execRevokeKey,
 
 	TxnTypeInitConfig: execInitConfig,
 	TxnTypeRegEnckey:  execRegEnckey,
 }
 
-var txnUnmarshalerStore = map[TxnType]func(json.RawMessage) (interface{}, error){
-	TxnTypeCoinbase:  unmarshalCoinbase,
-	TxnTypePreMPC:    unmarshalPreMPC,
-	TxnTypePostMPC:   unmarshalPostMPC,
-	TxnTypeRegAssets: unmarshalRegAssets,
-
-	TxnTypeInitConfig: unmarshalInitConfig,
-	TxnTypeRegEnckey:  unmarshalRegEnckey,
+// txnDataTypes gives the type of the data of each transaction type. It is
+// how the canonical encoding decodes the data. nil means no data
+var txnDataTypes = map[TxnType]reflect.Type{
+	TxnTypeCoinbase:      nil,
+	TxnTypePreMPC:        reflect.TypeOf(MPCPropose{}),
+	TxnTypePostMPC:       reflect.TypeOf(MPCRecord{}),
+	TxnTypeRegAssets:     reflect.TypeOf(AssetsRegistration{}),
+	TxnTypeStake:         nil,
+	TxnTypeUnstake:       nil,
+	TxnTypeSlash:         reflect.TypeOf(SlashEvidence{}),
+	TxnTypeRegPricing:    reflect.TypeOf(map[string]PriceSchedule{}),
+	TxnTypeUpdateAssets:  reflect.TypeOf(map[string]Amount{}),
+	TxnTypeRemoveAssets:  reflect.TypeOf(AssetsRemoval{}),
+	TxnTypeTransferAsset: reflect.TypeOf(AssetTransfer{}),
+	TxnTypeCancel:        nil,
+	TxnTypeRegMultisig:   reflect.TypeOf(MultisigPolicy{}),
+	TxnTypeRotateKey:     reflect.TypeOf(KeyRotation{}),
+	TxnTypeRevokeKey:     reflect.TypeOf(KeyRevocation{}),
+
+	TxnTypeInitConfig: reflect.TypeOf(ChainConfig{}),
+	TxnTypeRegEnckey:  reflect.TypeOf(""),
 }
 
 // -----------------------------------------------------------------------------
@@ -56,13 +90,16 @@ type Transaction struct {
 	From  string
 	To    string
 	Type  TxnType
-	Value float64
+	Value Amount
 	Data  interface{}
+	// optional amount paid to the miner. Transactions with a higher tip
+	// are included first
+	Tip Amount
 }
 
 // NewTransaction creates a new transaction and computes its ID
 func NewTransaction(from *Account, to *Address, txntype TxnType,
-	value float64, data interface{}) *Transaction {
+	value Amount, data interface{}) *Transaction {
 	txn := Transaction{
 		Nonce: from.nonce,
 		From:  from.addr.Hex,
@@ -76,30 +113,9 @@ func NewTransaction(from *Account, to *Address, txntype TxnType,
 	return &txn
 }
 
-// HashBytes computes the hash of the transaction
+// HashBytes computes the hash of the canonical encoding of the transaction
 func (txn *Transaction) HashBytes() []byte {
-	h := sha256.New()
-
-	h.Write([]byte(fmt.Sprintf("%d", txn.Nonce)))
-	h.Write([]byte(txn.From))
-	h.Write([]byte(txn.To))
-	h.Write([]byte(txn.Type))
-	h.Write([]byte(fmt.Sprintf("%f", txn.Value)))
-
-	// bytes, err := json.Marshal(txn.Data)
-	// if err != nil {
-	// 	panic(err)
-	// }
-	// h.Write(bytes)
-
-	switch hh := txn.Data.(type) {
-	case storage.Hashable:
-		h.Write([]byte(hh.Hash()))
-	default:
-		h.Write([]byte(storage.Hash(hh)))
-	}
-
-	return h.Sum(nil)
+	return hashCanonicalBytes(*txn)
 }
 
 // Hash computes the hex-encoded hash of the transaction
@@ -107,41 +123,107 @@ func (txn *Transaction) Hash() string {
 	return hex.EncodeToString(txn.HashBytes())
 }
 
+// Bump returns a copy of the transaction with the given tip. It replaces
+// the transaction in the pools if the tip is higher
+func (txn *Transaction) Bump(tip Amount) *Transaction {
+	bumped := *txn
+	bumped.Tip = tip
+	bumped.ID = bumped.Hash()
+	return &bumped
+}
+
 // String returns a description string for the transaction
 func (txn *Transaction) String() string {
 	return fmt.Sprintf("{%s: from=%s, id=%s}", txn.Type, txn.Hash(), txn.ID)
 }
 
-// Unmarshal helps to unmarshal the data part
-func (txn *Transaction) Unmarshal() error {
-	if txn.Data == nil {
+// encodeCanonical implements canonicalMarshaler. The ID is left out as it
+// is the hash of the encoding
+func (txn Transaction) encodeCanonical(e *encoder) error {
+	e.uvarint(uint64(txn.Nonce))
+	e.string(txn.From)
+	e.string(txn.To)
+	e.string(string(txn.Type))
+	e.varint(int64(txn.Value))
+	e.varint(int64(txn.Tip))
+
+	// unknown types carry no data. They are rejected when decoded or
+	// executed
+	dataType := txnDataTypes[txn.Type]
+	if dataType == nil {
+		if txn.Data != nil {
+			return fmt.Errorf("transaction type %s has no data. Got: %T", txn.Type, txn.Data)
+		}
 		return nil
 	}
+	data := reflect.ValueOf(txn.Data)
+	if !data.IsValid() || data.Type() != dataType {
+		return fmt.Errorf("transaction type %s has data %s. Got: %T", txn.Type, dataType, txn.Data)
+	}
+	return e.value(data)
+}
 
-	dict, ok := txn.Data.(map[string]interface{})
-	if !ok {
-		// simple type, no need to do futhur operations
-		return nil
+// decodeCanonical implements canonicalUnmarshaler. The data gets the type
+// of the transaction type and the ID is recomputed
+func (txn *Transaction) decodeCanonical(d *decoder) error {
+	nonce, err := d.uvarint()
+	if err != nil {
+		return err
+	}
+	txn.Nonce = uint(nonce)
+	txn.From, err = d.string()
+	if err != nil {
+		return err
+	}
+	txn.To, err = d.string()
+	if err != nil {
+		return err
+	}
+	txnType, err := d.string()
+	if err != nil {
+		return err
 	}
-	jsonbody, err := json.Marshal(dict)
+	txn.Type = TxnType(txnType)
+	value, err := d.varint()
 	if err != nil {
 		return err
 	}
+	txn.Value = Amount(value)
+	tip, err := d.varint()
+	if err != nil {
+		return err
+	}
+	txn.Tip = Amount(tip)
 
-	unmarshaler, ok := txnUnmarshalerStore[txn.Type]
+	dataType, ok := txnDataTypes[txn.Type]
 	if !ok {
 		return fmt.Errorf("invalid transaction type: %s", txn.Type)
 	}
-
-	data, err := unmarshaler(jsonbody)
-	if err != nil {
-		return err
+	txn.Data = nil
+	if dataType != nil {
+		data := reflect.New(dataType).Elem()
+		err = d.value(data)
+		if err != nil {
+			return err
+		}
+		txn.Data = data.Interface()
 	}
 
-	txn.Data = data
+	txn.ID = txn.Hash()
 	return nil
 }
 
+// MarshalJSON implements json.Marshaler. Transactions are gossiped and
+// stored in their canonical encoding
+func (txn Transaction) MarshalJSON() ([]byte, error) {
+	return marshalCanonicalJSON(txn)
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (txn *Transaction) UnmarshalJSON(data []byte) error {
+	return unmarshalCanonicalJSON(data, txn)
+}
+
 // Exec executes the transaction based on the input worldState
 func (txn *Transaction) Exec(worldState storage.KVStore) error {
 	config := GetConfigFromWorldState(worldState)
@@ -152,6 +234,18 @@ func (txn *Transaction) Exec(worldState storage.KVStore) error {
 		return err
 	}
 
+	// no handler may create coins out of a negative or huge value
+	err = txn.Value.Validate()
+	if err != nil {
+		return fmt.Errorf("transaction %s has an invalid value: %v", txn.ID, err)
+	}
+
+	// charge the tip. It is paid to the miner once the block is built
+	err = chargeTip(worldState, txn)
+	if err != nil {
+		return err
+	}
+
 	// execute handler
 	handler, ok := txnHandlerStore[txn.Type]
 	if !ok {
@@ -172,6 +266,9 @@ func (txn *Transaction) Exec(worldState storage.KVStore) error {
 type SignedTransaction struct {
 	Txn       Transaction
 	Signature []byte
+	// signatures of the owners when the sender is a multisig account.
+	// Signature is empty then
+	Signatures [][]byte
 }
 
 // Sign creates a signature for the trasaction using the given private key
@@ -189,15 +286,20 @@ func (txn *Transaction) Sign(privateKey *ecdsa.PrivateKey) (*SignedTransaction,
 	return &SignedTransaction{Txn: *txn, Signature: signature}, nil
 }
 
-// HashBytes computes the hashbytes of the signed transaction
+// HashBytes computes the hash of the canonical encoding of the signed
+// transaction
 func (signedTxn *SignedTransaction) HashBytes() []byte {
-	h := sha256.New()
+	return hashCanonicalBytes(*signedTxn)
+}
 
-	h.Write(signedTxn.Txn.HashBytes())
-	h.Write([]byte("||"))
-	h.Write(signedTxn.Signature)
+// MarshalJSON implements json.Marshaler
+func (signedTxn SignedTransaction) MarshalJSON() ([]byte, error) {
+	return marshalCanonicalJSON(signedTxn)
+}
 
-	return h.Sum(nil)
+// UnmarshalJSON implements json.Unmarshaler
+func (signedTxn *SignedTransaction) UnmarshalJSON(data []byte) error {
+	return unmarshalCanonicalJSON(data, signedTxn)
 }
 
 // Hash computes the hash of the signed transaction
@@ -217,13 +319,24 @@ func (signedTxn *SignedTransaction) Verify(worldState storage.KVStore) error {
 	txn := signedTxn.Txn
 	config := GetConfigFromWorldState(worldState)
 
+	// multisig accounts are verified against the owners registered on chain
+	policy, isMultisig := GetMultisigFromWorldState(worldState, txn.From)
+
 	// verify origin is inside the chain
-	if !CheckPariticipation(worldState, config, txn.From) {
+	if !isMultisig && !CheckPariticipation(worldState, config, txn.From) {
 		return fmt.Errorf("address %s is not a participant of the permissined chain", txn.From)
 	}
 
 	// verify signature
-	if txn.Type != TxnTypeCoinbase && txn.Type != TxnTypeInitConfig {
+	if isMultisig {
+		err := signedTxn.verifyMultisig(policy)
+		if err != nil {
+			return err
+		}
+	} else if len(signedTxn.Signatures) > 0 {
+		return fmt.Errorf("transaction %s has owner signatures but %s is not a multisig account",
+			txn.ID, txn.From)
+	} else if txn.Type != TxnTypeCoinbase && txn.Type != TxnTypeInitConfig {
 		digestHash := txn.HashBytes()
 		publicKey, err := crypto.SigToPub(digestHash, signedTxn.Signature)
 		if err != nil {
@@ -254,7 +367,7 @@ func (signedTxn *SignedTransaction) Verify(worldState storage.KVStore) error {
 // -----------------------------------------------------------------------------
 // Transaction Polymophism - Coinbase
 
-func NewTransactionCoinbase(to Address, value float64) *Transaction {
+func NewTransactionCoinbase(to Address, value Amount) *Transaction {
 	return NewTransaction(
 		NewAccount(ZeroAddress),
 		&to,
@@ -266,13 +379,34 @@ func NewTransactionCoinbase(to Address, value float64) *Transaction {
 
 func execCoinbase(worldState storage.KVStore, config *ChainConfig, txn *Transaction) error {
 	account := GetAccountFromWorldState(worldState, txn.To)
-	account.balance += txn.Value
+	balance, err := account.balance.Add(txn.Value)
+	if err != nil {
+		return err
+	}
+	account.balance = balance
 	worldState.Put(account.addr.Hex, *account)
 	return nil
 }
 
-func unmarshalCoinbase(data json.RawMessage) (interface{}, error) {
-	return nil, nil
+// -----------------------------------------------------------------------------
+// Transaction Polymophism - Cancel
+
+// NewTransactionCancel creates a transaction doing nothing but using the
+// nonce, so that it replaces the pending transaction with this nonce
+func NewTransactionCancel(from *Account, nonce uint, tip Amount) *Transaction {
+	txn := NewTransaction(
+		from,
+		&ZeroAddress,
+		TxnTypeCancel,
+		0,
+		nil,
+	)
+	txn.Nonce = nonce
+	return txn.Bump(tip)
+}
+
+func execCancel(worldState storage.KVStore, config *ChainConfig, txn *Transaction) error {
+	return nil
 }
 
 // -----------------------------------------------------------------------------
@@ -349,36 +483,99 @@ func updateNonce(worldState storage.KVStore, accountID string) error {
 	return nil
 }
 
-func lockBalance(worldState storage.KVStore, accountID string, amount float64) error {
+func chargeTip(worldState storage.KVStore, txn *Transaction) error {
+	err := txn.Tip.Validate()
+	if err != nil {
+		return fmt.Errorf("transaction %s has an invalid tip: %v", txn.ID, err)
+	}
+	if txn.Tip == 0 {
+		return nil
+	}
+
+	account := GetAccountFromWorldState(worldState, txn.From)
+	if account.balance < txn.Tip {
+		return fmt.Errorf("%s balance not enough to pay the tip. Remain balance: %s",
+			txn.From, account.balance)
+	}
+	account.balance -= txn.Tip
+	err = worldState.Put(txn.From, *account)
+	if err != nil {
+		panic(err)
+	}
+	return nil
+}
+
+// payTips pays the tips of the transactions of a block to its miner
+func payTips(worldState storage.KVStore, miner string, txns []SignedTransaction) error {
+	var total Amount = 0
+	for _, txn := range txns {
+		var err error
+		total, err = total.Add(txn.Txn.Tip)
+		if err != nil {
+			return err
+		}
+	}
+	if total == 0 {
+		return nil
+	}
+
+	account := GetAccountFromWorldState(worldState, miner)
+	balance, err := account.balance.Add(total)
+	if err != nil {
+		return err
+	}
+	account.balance = balance
+	err = worldState.Put(miner, *account)
+	if err != nil {
+		panic(err)
+	}
+	return nil
+}
+
+func lockBalance(worldState storage.KVStore, accountID string, amount Amount) error {
+	if amount <= 0 {
+		return fmt.Errorf("%s can't lock a non-positive amount %s", accountID, amount)
+	}
 	account := GetAccountFromWorldState(worldState, accountID)
 	if account.balance < amount {
-		return fmt.Errorf("Initiator(%s) balance not enough. Remain balance: %f",
+		return fmt.Errorf("Initiator(%s) balance not enough. Remain balance: %s",
 			accountID, account.balance)
 	}
 
 	// lock balance
+	locked, err := account.lockedBalance.Add(amount)
+	if err != nil {
+		return err
+	}
 	account.balance -= amount
-	account.lockedBalance += amount
-	err := worldState.Put(accountID, *account)
+	account.lockedBalance = locked
+	err = worldState.Put(accountID, *account)
 	if err != nil {
 		panic(err)
 	}
 	return nil
 }
 
-func claimAward(worldState storage.KVStore, from *Account, to string, amount float64) error {
+func claimAward(worldState storage.KVStore, from *Account, to string, amount Amount) error {
+	if amount <= 0 {
+		return fmt.Errorf("%s can't claim a non-positive amount %s", to, amount)
+	}
 	account := from
 	if from.addr.Hex != to {
 		account = GetAccountFromWorldState(worldState, to)
 	}
 
 	if from.lockedBalance < amount {
-		return fmt.Errorf("%s's locked balance not enough. Expected: %f. Got: %f",
+		return fmt.Errorf("%s's locked balance not enough. Expected: %s. Got: %s",
 			from.addr.Hex, amount, from.lockedBalance)
 	}
+	balance, err := account.balance.Add(amount)
+	if err != nil {
+		return err
+	}
 	from.lockedBalance -= amount
-	account.balance += amount
-	err := worldState.Put(from.addr.Hex, *from)
+	account.balance = balance
+	err = worldState.Put(from.addr.Hex, *from)
 	if err != nil {
 		panic(err)
 	}
@@ -1,6 +1,7 @@
 package permissioned
 
 import (
+	"math"
 	"testing"
 
 	"github.com/ethereum/go-ethereum/crypto"
@@ -41,10 +42,10 @@ func Test_Txn_Execution_PreMPC_Correct(t *testing.T) {
 	pubkey := privKey.PublicKey
 	account := *NewAccount(*NewAddress(&pubkey))
 	account.balance = 20
-	require.Equal(t, float64(0), account.lockedBalance)
+	require.Equal(t, Amount(0), account.lockedBalance)
 
 	// create signedTxn
-	var budget float64 = 10
+	var budget Amount = 10
 	txn := NewTransactionPreMPC(&account, MPCPropose{
 		Initiator:  account.addr.Hex,
 		Budget:     budget,
@@ -60,7 +61,7 @@ func Test_Txn_Execution_PreMPC_Correct(t *testing.T) {
 	worldState.Put(STATE_CONFIG_KEY, config)
 	worldState.Put(account.addr.Hex, account)
 	asset := NewAssetsRecord(account.addr.Hex)
-	asset.Add(map[string]float64{"a": budget})
+	asset.Add(map[string]Amount{"a": budget})
 	worldState.Put(AssetsKeyFromUniqID(account.addr.Hex), *asset)
 	stateCopy := worldState.Copy()
 
@@ -89,10 +90,10 @@ func Test_Txn_Execution_PreMPC_InCorrect(t *testing.T) {
 	pubkey := privKey.PublicKey
 	account := *NewAccount(*NewAddress(&pubkey))
 	account.balance = 200
-	require.Equal(t, float64(0), account.lockedBalance)
+	require.Equal(t, Amount(0), account.lockedBalance)
 
 	// create signedTxn
-	var budget float64 = 10
+	var budget Amount = 10
 	txn := NewTransactionPreMPC(&account, MPCPropose{
 		Initiator:  account.addr.Hex,
 		Budget:     budget,
@@ -107,7 +108,7 @@ func Test_Txn_Execution_PreMPC_InCorrect(t *testing.T) {
 	)
 	worldState.Put(STATE_CONFIG_KEY, config)
 	asset := NewAssetsRecord(account.addr.Hex)
-	asset.Add(map[string]float64{"a": budget})
+	asset.Add(map[string]Amount{"a": budget})
 	worldState.Put(AssetsKeyFromUniqID(account.addr.Hex), *asset)
 
 	// > balance not enough should fail
@@ -146,6 +147,51 @@ func Test_Txn_Execution_PreMPC_InCorrect(t *testing.T) {
 	require.Equal(t, stateCopy.Hash(), worldState.Hash())
 }
 
+func Test_Txn_Execution_PreMPC_Fee_Overflow(t *testing.T) {
+	initiator := *NewAccount(*NewAddressFromHex("initiator"))
+	initiator.balance = 100
+	owner := *NewAccount(*NewAddressFromHex("owner"))
+
+	// create worldstate
+	worldState := storage.NewBasicKV()
+	config := *NewChainConfig(
+		map[string]string{
+			initiator.addr.Hex: "",
+			owner.addr.Hex:     ""},
+		1, "2h", 0, 10,
+	)
+	worldState.Put(STATE_CONFIG_KEY, config)
+	worldState.Put(initiator.addr.Hex, initiator)
+	worldState.Put(owner.addr.Hex, owner)
+	asset := NewAssetsRecord(owner.addr.Hex)
+	asset.Add(map[string]Amount{"a": 10})
+	worldState.Put(AssetsKeyFromUniqID(owner.addr.Hex), *asset)
+
+	// > a fee making the total price wrap around should fail
+
+	stateCopy := worldState.Copy()
+	err := NewTransactionPreMPC(&initiator, MPCPropose{
+		Initiator:  initiator.addr.Hex,
+		Budget:     100,
+		Expression: "a",
+		Fee:        math.MaxInt64 - 1000000000,
+	}).Exec(worldState)
+	require.Error(t, err)
+	require.Equal(t, stateCopy.Hash(), worldState.Hash())
+	_, _, err = CalculateTotalPrice(worldState, initiator.addr.Hex, "a", MAX_AMOUNT+1)
+	require.Error(t, err)
+
+	// > no amount is created from nothing
+
+	require.Error(t, lockBalance(worldState, initiator.addr.Hex, -100))
+	require.Error(t, lockBalance(worldState, initiator.addr.Hex, 0))
+	require.Error(t, claimAward(worldState, &initiator, owner.addr.Hex, -100))
+	require.Equal(t, stateCopy.Hash(), worldState.Hash())
+	newInitiator := GetAccountFromWorldState(worldState, initiator.addr.Hex)
+	require.Equal(t, Amount(100), newInitiator.balance)
+	require.Equal(t, Amount(0), newInitiator.lockedBalance)
+}
+
 func Test_Txn_Execution_PostMPC_Correct(t *testing.T) {
 	account := *NewAccount(*NewAddressFromHex("account1"))
 
@@ -155,7 +201,7 @@ func Test_Txn_Execution_PostMPC_Correct(t *testing.T) {
 	initiator.lockedBalance = 200
 
 	// create signedTxn
-	var budget float64 = 10
+	var budget Amount = 10
 	var uniqID = "test"
 	record := MPCRecord{
 		UniqID: uniqID,
@@ -176,7 +222,7 @@ func Test_Txn_Execution_PostMPC_Correct(t *testing.T) {
 	worldState.Put(mpcKeyFromUniqID(uniqID), MPCEndorsement{
 		Peers:     config.Participants,
 		Endorsers: map[string]struct{}{},
-		Budget: map[string]float64{
+		Budget: map[string]Amount{
 			account.addr.Hex:   budget,
 			initiator.addr.Hex: budget},
 		Initiator: initiator.addr.Hex,
@@ -250,7 +296,7 @@ func Test_Txn_Execution_PostMPC_InCorrect(t *testing.T) {
 	initiator.lockedBalance = 200
 
 	// create signedTxn
-	var budget float64 = 10
+	var budget Amount = 10
 	var uniqID = "test"
 	record := MPCRecord{
 		UniqID: uniqID,
@@ -282,7 +328,7 @@ func Test_Txn_Execution_PostMPC_InCorrect(t *testing.T) {
 	worldState.Put(mpcKeyFromUniqID(uniqID), MPCEndorsement{
 		Peers:     config.Participants,
 		Endorsers: map[string]struct{}{},
-		Budget: map[string]float64{
+		Budget: map[string]Amount{
 			account.addr.Hex:   budget,
 			initiator.addr.Hex: budget},
 		Locked: true,
@@ -311,3 +357,83 @@ func Test_Txn_Execution_PostMPC_InCorrect(t *testing.T) {
 	require.Error(t, err)
 	require.Equal(t, stateCopy.Hash(), worldState.Hash())
 }
+
+func Test_Txn_Tip(t *testing.T) {
+	privKey, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	account := *NewAccount(*NewAddress(&privKey.PublicKey))
+	minerKey, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	miner := NewAddress(&minerKey.PublicKey).Hex
+
+	config := *NewChainConfig(
+		map[string]string{account.addr.Hex: "", miner: ""},
+		10, "2h", 0, 10,
+	)
+	block0, err := NewBlockchain().InitGenesisBlock(&config, map[string]Amount{
+		account.addr.Hex: 20,
+	})
+	require.NoError(t, err)
+
+	// > the tip is only hashed if set
+
+	txn := NewTransactionStake(&account, 5)
+	require.Equal(t, txn.ID, txn.Bump(0).ID)
+	bumped := txn.Bump(2)
+	require.NotEqual(t, txn.ID, bumped.ID)
+	require.Equal(t, txn.Nonce, bumped.Nonce)
+	require.Equal(t, Amount(0), txn.Tip)
+
+	// > the tip is charged to the sender
+
+	worldState := block0.GetWorldStateCopy()
+	signedTxn, err := bumped.Sign(privKey)
+	require.NoError(t, err)
+	err = signedTxn.Verify(worldState)
+	require.NoError(t, err)
+	require.Equal(t, Amount(13), GetAccountFromWorldState(worldState, account.addr.Hex).balance)
+
+	signedTxn, err = NewTransactionCancel(&account, 1, 100).Sign(privKey)
+	require.NoError(t, err)
+	require.Error(t, signedTxn.Verify(worldState))
+
+	signedTxn, err = NewTransactionCancel(&account, 1, -1).Sign(privKey)
+	require.NoError(t, err)
+	require.Error(t, signedTxn.Verify(worldState))
+
+	// > a negative value should fail, whatever the txn type
+
+	negative := NewTransactionCancel(&account, 1, 0)
+	negative.Value = -5
+	require.Error(t, negative.Exec(worldState.Copy()))
+
+	// > a cancel txn only uses the nonce and pays the tip
+
+	signedTxn, err = NewTransactionCancel(&account, 1, 3).Sign(privKey)
+	require.NoError(t, err)
+	err = signedTxn.Verify(worldState)
+	require.NoError(t, err)
+	newAccount := GetAccountFromWorldState(worldState, account.addr.Hex)
+	require.Equal(t, Amount(10), newAccount.balance)
+	require.Equal(t, uint(2), newAccount.nonce)
+
+	// > the tips are paid to the miner of the block
+
+	stake, err := bumped.Sign(privKey)
+	require.NoError(t, err)
+	blockState := block0.GetWorldStateCopy()
+	require.NoError(t, stake.Verify(blockState))
+
+	bb := NewBlockBuilder()
+	bb.SetPrevHash(block0.Hash()).
+		SetHeight(1).
+		SetMiner(miner).
+		SetState(blockState)
+	require.NoError(t, bb.AddTxn(stake))
+	block := bb.Build()
+	require.Equal(t, Amount(2), GetAccountFromWorldState(block.States, miner).balance)
+
+	require.NoError(t, block.Sign(minerKey))
+	err = block.Verify(block0.GetWorldStateCopy())
+	require.NoError(t, err)
+}
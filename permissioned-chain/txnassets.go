@@ -1,9 +1,6 @@
 package permissioned
 
 import (
-	"crypto/sha256"
-	"encoding/hex"
-	"encoding/json"
 	"fmt"
 	"regexp"
 	"sort"
@@ -15,24 +12,114 @@ import (
 // -----------------------------------------------------------------------------
 // Transaction Polymophism - RegAssets
 
-func NewTransactionRegAssets(from *Account, assets map[string]float64) *Transaction {
+// AssetsRegistration registers assets with their prices. Policies and schemas
+// can also target assets registered before. A multisig account names the
+// owner whose node holds the values, see AssetsRecord.Holder
+type AssetsRegistration struct {
+	Assets   map[string]Amount
+	Policies map[string]AssetPolicy
+	Schemas  map[string]AssetSchema
+	Holder   string
+}
+
+// String implements Describable.String()
+func (r AssetsRegistration) String() string {
+	return fmt.Sprintf("Assets: %v, Policies: %v, Schemas: %v, Holder: %s\n",
+		r.Assets, r.Policies, r.Schemas, r.Holder)
+}
+
+func NewTransactionRegAssets(from *Account, assets map[string]Amount) *Transaction {
+	return NewTransactionRegAssetsWithDetails(from, AssetsRegistration{Assets: assets})
+}
+
+func NewTransactionRegAssetsWithDetails(from *Account, registration AssetsRegistration) *Transaction {
 	return NewTransaction(
 		from,
 		&ZeroAddress,
 		TxnTypeRegAssets,
 		0,
-		assets,
+		registration,
 	)
 }
 
 func execRegAssets(worldState storage.KVStore, config *ChainConfig, txn *Transaction) error {
-	assets := txn.Data.(map[string]float64)
+	registration := txn.Data.(AssetsRegistration)
+
+	for asset, price := range registration.Assets {
+		err := price.Validate()
+		if err != nil {
+			return fmt.Errorf("invalid price for asset %s: %v", asset, err)
+		}
+	}
 
 	key := AssetsKeyFromUniqID(txn.From)
 	oldAssets := GetAssetsFromWorldState(worldState, txn.From)
-	oldAssets.Add(assets)
+	err := setAssetsHolder(worldState, config, oldAssets, registration.Holder)
+	if err != nil {
+		return err
+	}
+	oldAssets.Add(registration.Assets)
+
+	for asset, policy := range registration.Policies {
+		if _, ok := oldAssets.Assets[asset]; !ok {
+			return fmt.Errorf("%s does not own asset %s", txn.From, asset)
+		}
+		err := policy.Validate()
+		if err != nil {
+			return fmt.Errorf("invalid policy for asset %s: %s", asset, err)
+		}
+		oldAssets.Policies[asset] = policy.Copy()
+	}
+	for asset, schema := range registration.Schemas {
+		if _, ok := oldAssets.Assets[asset]; !ok {
+			return fmt.Errorf("%s does not own asset %s", txn.From, asset)
+		}
+		err := schema.Validate()
+		if err != nil {
+			return fmt.Errorf("invalid schema for asset %s: %s", asset, err)
+		}
+		oldAssets.Schemas[asset] = schema.Copy()
+	}
+
+	err = worldState.Put(key, *oldAssets)
+	if err != nil {
+		panic(err)
+	}
+
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// Transaction Polymophism - RegPricing
+
+func NewTransactionRegPricing(from *Account, schedules map[string]PriceSchedule) *Transaction {
+	return NewTransaction(
+		from,
+		&ZeroAddress,
+		TxnTypeRegPricing,
+		0,
+		schedules,
+	)
+}
+
+func execRegPricing(worldState storage.KVStore, config *ChainConfig, txn *Transaction) error {
+	schedules := txn.Data.(map[string]PriceSchedule)
 
-	err := worldState.Put(key, *oldAssets)
+	record := GetAssetsFromWorldState(worldState, txn.From)
+	for asset, schedule := range schedules {
+		if _, ok := record.Assets[asset]; !ok {
+			return fmt.Errorf("%s does not own asset %s", txn.From, asset)
+		}
+		err := schedule.Validate()
+		if err != nil {
+			return fmt.Errorf("invalid price schedule for asset %s: %s", asset, err)
+		}
+	}
+	for asset, schedule := range schedules {
+		record.Schedules[asset] = schedule.Copy()
+	}
+
+	err := worldState.Put(AssetsKeyFromUniqID(txn.From), *record)
 	if err != nil {
 		panic(err)
 	}
@@ -40,71 +127,293 @@ func execRegAssets(worldState storage.KVStore, config *ChainConfig, txn *Transac
 	return nil
 }
 
-func unmarshalRegAssets(data json.RawMessage) (interface{}, error) {
-	var r map[string]float64
-	err := json.Unmarshal(data, &r)
+// -----------------------------------------------------------------------------
+// Transaction Polymophism - UpdateAssets
 
-	return r, err
+func NewTransactionUpdateAssets(from *Account, prices map[string]Amount) *Transaction {
+	return NewTransaction(
+		from,
+		&ZeroAddress,
+		TxnTypeUpdateAssets,
+		0,
+		prices,
+	)
+}
+
+func execUpdateAssets(worldState storage.KVStore, config *ChainConfig, txn *Transaction) error {
+	prices := txn.Data.(map[string]Amount)
+
+	record := GetAssetsFromWorldState(worldState, txn.From)
+	for asset, price := range prices {
+		if _, ok := record.Assets[asset]; !ok {
+			return fmt.Errorf("%s does not own asset %s", txn.From, asset)
+		}
+		err := price.Validate()
+		if err != nil {
+			return fmt.Errorf("invalid price for asset %s: %v", asset, err)
+		}
+	}
+	// ongoing MPCs keep the prices recorded in their endorsement
+	record.Add(prices)
+
+	err := worldState.Put(AssetsKeyFromUniqID(txn.From), *record)
+	if err != nil {
+		panic(err)
+	}
+
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// Transaction Polymophism - RemoveAssets
+
+type AssetsRemoval struct {
+	Keys []string
+}
+
+// String implements Describable.String()
+func (r AssetsRemoval) String() string {
+	return fmt.Sprintf("Keys: %v\n", r.Keys)
+}
+
+func NewTransactionRemoveAssets(from *Account, keys []string) *Transaction {
+	return NewTransaction(
+		from,
+		&ZeroAddress,
+		TxnTypeRemoveAssets,
+		0,
+		AssetsRemoval{Keys: keys},
+	)
+}
+
+func execRemoveAssets(worldState storage.KVStore, config *ChainConfig, txn *Transaction) error {
+	removal := txn.Data.(AssetsRemoval)
+
+	record := GetAssetsFromWorldState(worldState, txn.From)
+	for _, asset := range removal.Keys {
+		if _, ok := record.Assets[asset]; !ok {
+			return fmt.Errorf("%s does not own asset %s", txn.From, asset)
+		}
+		// the node holding the value needs it until it endorsed
+		if uniqID := getMPCUsingAsset(worldState, record.GetHolder(), asset); uniqID != "" {
+			return fmt.Errorf("asset %s is used by ongoing MPC %s", asset, uniqID)
+		}
+		record.Remove(asset)
+	}
+
+	err := worldState.Put(AssetsKeyFromUniqID(txn.From), *record)
+	if err != nil {
+		panic(err)
+	}
+
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// Transaction Polymophism - TransferAsset
+
+// AssetTransfer moves an asset to another participant or to a multisig
+// account. The value of the asset is encrypted with the encryption key of the
+// node holding the values of the new owner
+type AssetTransfer struct {
+	Key            string
+	To             string
+	EncryptedValue []byte
+}
+
+// String implements Describable.String()
+func (t AssetTransfer) String() string {
+	return fmt.Sprintf("Key: %s, To: %s\n", t.Key, t.To)
+}
+
+func NewTransactionTransferAsset(from *Account, transfer AssetTransfer) *Transaction {
+	return NewTransaction(
+		from,
+		&ZeroAddress,
+		TxnTypeTransferAsset,
+		0,
+		transfer,
+	)
+}
+
+func execTransferAsset(worldState storage.KVStore, config *ChainConfig, txn *Transaction) error {
+	transfer := txn.Data.(AssetTransfer)
+
+	to := GetAssetsFromWorldState(worldState, transfer.To)
+	if _, ok := config.Participants[transfer.To]; !ok && to.Holder == "" {
+		return fmt.Errorf("%s is neither a participant nor a multisig account with a holder",
+			transfer.To)
+	}
+	if transfer.To == txn.From {
+		return fmt.Errorf("%s transfers asset %s to itself", txn.From, transfer.Key)
+	}
+
+	from := GetAssetsFromWorldState(worldState, txn.From)
+	if _, ok := from.Assets[transfer.Key]; !ok {
+		return fmt.Errorf("%s does not own asset %s", txn.From, transfer.Key)
+	}
+	// the node holding the value needs it until it endorsed
+	if uniqID := getMPCUsingAsset(worldState, from.GetHolder(), transfer.Key); uniqID != "" {
+		return fmt.Errorf("asset %s is used by ongoing MPC %s", transfer.Key, uniqID)
+	}
+	if _, ok := to.Assets[transfer.Key]; ok {
+		return fmt.Errorf("%s already owns asset %s", transfer.To, transfer.Key)
+	}
+
+	// the asset keeps its price, schedule, policy, schema and usage
+	to.Assets[transfer.Key] = from.Assets[transfer.Key]
+	if schedule, ok := from.Schedules[transfer.Key]; ok {
+		to.Schedules[transfer.Key] = schedule
+	}
+	if policy, ok := from.Policies[transfer.Key]; ok {
+		to.Policies[transfer.Key] = policy
+	}
+	if schema, ok := from.Schemas[transfer.Key]; ok {
+		to.Schemas[transfer.Key] = schema
+	}
+	if usage, ok := from.Usage[transfer.Key]; ok {
+		to.Usage[transfer.Key] = usage
+	}
+	from.Remove(transfer.Key)
+
+	err := worldState.Put(AssetsKeyFromUniqID(txn.From), *from)
+	if err != nil {
+		panic(err)
+	}
+	err = worldState.Put(AssetsKeyFromUniqID(transfer.To), *to)
+	if err != nil {
+		panic(err)
+	}
+
+	return nil
 }
 
 // -----------------------------------------------------------------------------
 // Utilities - Assets
 
 type AssetsRecord struct {
-	Owner  string
-	Assets map[string]float64
+	Owner string
+	// participant whose node holds the values of the assets of a multisig
+	// account and inputs them into MPCs. Empty for a participant, its own
+	// node holds them
+	Holder string
+	Assets map[string]Amount
+	// optional price schedules on top of the per-use price in Assets
+	Schedules map[string]PriceSchedule
+	// optional restrictions on who can use the asset and how
+	Policies map[string]AssetPolicy
+	// optional description of what the asset means
+	Schemas map[string]AssetSchema
+	// number of MPCs each initiator has used the asset in
+	Usage map[string]map[string]uint
 }
 
 // Copy implements Copyable.Copy()
 func (r AssetsRecord) Copy() storage.Copyable {
-	assets := map[string]float64{}
+	assets := map[string]Amount{}
 	for asset, price := range r.Assets {
 		assets[asset] = price
 	}
+	schedules := map[string]PriceSchedule{}
+	for asset, schedule := range r.Schedules {
+		schedules[asset] = schedule.Copy()
+	}
+	policies := map[string]AssetPolicy{}
+	for asset, policy := range r.Policies {
+		policies[asset] = policy.Copy()
+	}
+	schemas := map[string]AssetSchema{}
+	for asset, schema := range r.Schemas {
+		schemas[asset] = schema.Copy()
+	}
+	usage := map[string]map[string]uint{}
+	for asset, counts := range r.Usage {
+		usage[asset] = map[string]uint{}
+		for initiator, count := range counts {
+			usage[asset][initiator] = count
+		}
+	}
 	record := AssetsRecord{
-		Owner:  r.Owner,
-		Assets: assets,
+		Owner:     r.Owner,
+		Holder:    r.Holder,
+		Assets:    assets,
+		Schedules: schedules,
+		Policies:  policies,
+		Schemas:   schemas,
+		Usage:     usage,
 	}
 	return record
 }
 
 // Hash implements Hashable.Hash
 func (r AssetsRecord) Hash() string {
-	h := sha256.New()
-
-	h.Write([]byte(r.Owner))
-	assets := make([]string, 0, len(r.Assets))
-	for asset := range r.Assets {
-		assets = append(assets, asset)
-	}
-	sort.Strings(assets)
-	for _, asset := range assets {
-		h.Write([]byte(asset))
-		h.Write([]byte(fmt.Sprintf("%f", r.Assets[asset])))
-	}
-
-	return hex.EncodeToString(h.Sum(nil))
+	return hashCanonical(r)
 }
 
 func NewAssetsRecord(owner string) *AssetsRecord {
 	return &AssetsRecord{
-		Owner:  owner,
-		Assets: map[string]float64{},
+		Owner:     owner,
+		Assets:    map[string]Amount{},
+		Schedules: map[string]PriceSchedule{},
+		Policies:  map[string]AssetPolicy{},
+		Schemas:   map[string]AssetSchema{},
+		Usage:     map[string]map[string]uint{},
+	}
+}
+
+// GetHolder returns the participant whose node holds the values of the assets
+func (r AssetsRecord) GetHolder() string {
+	if r.Holder == "" {
+		return r.Owner
 	}
+	return r.Holder
 }
 
-func (r AssetsRecord) Add(newAssets map[string]float64) {
+func (r AssetsRecord) Add(newAssets map[string]Amount) {
 	for newAsset, price := range newAssets {
 		r.Assets[newAsset] = price
 	}
 }
 
+// Remove deletes the asset with its schedule, policy, schema and usage
+func (r AssetsRecord) Remove(asset string) {
+	delete(r.Assets, asset)
+	delete(r.Schedules, asset)
+	delete(r.Policies, asset)
+	delete(r.Schemas, asset)
+	delete(r.Usage, asset)
+}
+
+// PriceFor returns the price the initiator pays for one use of the asset
+func (r AssetsRecord) PriceFor(asset string, initiator string) (Amount, bool) {
+	price, ok := r.Assets[asset]
+	if !ok {
+		return 0, false
+	}
+	schedule, ok := r.Schedules[asset]
+	if !ok {
+		return price, true
+	}
+	return schedule.Apply(price, initiator, r.Usage[asset][initiator]), true
+}
+
+// recordUsage increases the number of uses of the asset by the initiator
+func (r *AssetsRecord) recordUsage(asset string, initiator string) {
+	if r.Usage == nil {
+		r.Usage = map[string]map[string]uint{}
+	}
+	if r.Usage[asset] == nil {
+		r.Usage[asset] = map[string]uint{}
+	}
+	r.Usage[asset][initiator]++
+}
+
 func GetAssetsFromWorldState(worldState storage.KVStore, owner string) *AssetsRecord {
 	object, ok := worldState.Get(AssetsKeyFromUniqID(owner))
 	if !ok {
 		return NewAssetsRecord(owner)
 	}
-	assets := object.(AssetsRecord)
+	assets := object.(AssetsRecord).Copy().(AssetsRecord)
 	return &assets
 }
 
@@ -112,19 +421,369 @@ func AssetsKeyFromUniqID(uniqID string) string {
 	return fmt.Sprintf("assets|%s", uniqID)
 }
 
-func GetAllAssetsFromWorldState(worldState storage.KVStore) map[string]map[string]float64 {
-	assets := make(map[string]map[string]float64)
-	config := GetConfigFromWorldState(worldState)
-	for participate := range config.Participants {
-		prices := GetAssetsFromWorldState(worldState, participate).Assets
+func GetAllAssetsFromWorldState(worldState storage.KVStore) map[string]map[string]Amount {
+	assets := make(map[string]map[string]Amount)
+	for _, owner := range assetOwners(worldState) {
+		prices := GetAssetsFromWorldState(worldState, owner).Assets
 		if len(prices) == 0 {
 			continue
 		}
-		assets[participate] = prices
+		assets[owner] = prices
 	}
 	return assets
 }
 
+// assetOwners returns the accounts that can own assets: the participants
+// and the multisig accounts
+func assetOwners(worldState storage.KVStore) []string {
+	config := GetConfigFromWorldState(worldState)
+	owners := make([]string, 0, len(config.Participants))
+	for participant := range config.Participants {
+		owners = append(owners, participant)
+	}
+	_ = worldState.For(func(key string, value interface{}) error {
+		if _, ok := value.(MultisigPolicy); ok && strings.HasPrefix(key, MultisigKeyFromAddr("")) {
+			owners = append(owners, strings.TrimPrefix(key, MultisigKeyFromAddr("")))
+		}
+		return nil
+	})
+	return owners
+}
+
+// setAssetsHolder sets the holder of the assets of a multisig account. It
+// must be an owner of the account and cannot change while the account has
+// assets, their values are on the node of the current holder
+func setAssetsHolder(worldState storage.KVStore, config *ChainConfig, record *AssetsRecord,
+	holder string) error {
+
+	policy, ok := GetMultisigFromWorldState(worldState, record.Owner)
+	if !ok {
+		if holder != "" && holder != record.Owner {
+			return fmt.Errorf("the node of %s holds the values of its assets", record.Owner)
+		}
+		return nil
+	}
+
+	if holder == "" || holder == record.Holder {
+		if record.Holder == "" {
+			return fmt.Errorf("multisig account %s needs a holder for the values of its assets",
+				record.Owner)
+		}
+		return nil
+	}
+	if !policy.IsOwner(holder) {
+		return fmt.Errorf("%s is not an owner of multisig account %s", holder, record.Owner)
+	}
+	if _, ok := config.Participants[holder]; !ok {
+		return fmt.Errorf("%s is not a participant", holder)
+	}
+	if len(record.Assets) > 0 {
+		return fmt.Errorf("the values of the assets of %s are held by %s", record.Owner, record.Holder)
+	}
+	record.Holder = holder
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// Utilities - Asset Policy
+
+// AssetPolicy restricts the MPCs an asset can take part in
+type AssetPolicy struct {
+	// initiators allowed to use the asset. Empty means everyone
+	AllowedInitiators []string
+	// operators the expression may contain. Empty means all
+	AllowedOps []string
+	// minimum number of distinct inputs in the expression,
+	// so that the asset is only used in aggregates
+	MinInputs uint
+	// maximum number of MPCs each initiator can use the asset in.
+	// 0 means unlimited
+	Quota uint
+}
+
+// Check verifies that the initiator can use the asset in the expression
+func (p AssetPolicy) Check(initiator string, postfix []string,
+	variables map[string]struct{}, uses uint) error {
+	if len(p.AllowedInitiators) > 0 && !containsString(p.AllowedInitiators, initiator) {
+		return fmt.Errorf("initiator %s is not allowed", initiator)
+	}
+	if len(p.AllowedOps) > 0 {
+		for _, token := range postfix {
+			if isOperator(token) && !containsString(p.AllowedOps, token) {
+				return fmt.Errorf("operation %s is not allowed", token)
+			}
+		}
+	}
+	if uint(len(variables)) < p.MinInputs {
+		return fmt.Errorf("expression has %d inputs, at least %d required",
+			len(variables), p.MinInputs)
+	}
+	if p.Quota > 0 && uses >= p.Quota {
+		return fmt.Errorf("initiator %s has used up its quota of %d", initiator, p.Quota)
+	}
+	return nil
+}
+
+// Validate checks that the policy only contains supported operations
+func (p AssetPolicy) Validate() error {
+	for _, op := range p.AllowedOps {
+		if !isOperator(op) {
+			return fmt.Errorf("unknown operation %s", op)
+		}
+	}
+	return nil
+}
+
+// Copy returns a deep copy of the policy
+func (p AssetPolicy) Copy() AssetPolicy {
+	initiators := make([]string, len(p.AllowedInitiators))
+	copy(initiators, p.AllowedInitiators)
+	ops := make([]string, len(p.AllowedOps))
+	copy(ops, p.AllowedOps)
+
+	return AssetPolicy{
+		AllowedInitiators: initiators,
+		AllowedOps:        ops,
+		MinInputs:         p.MinInputs,
+		Quota:             p.Quota,
+	}
+}
+
+// String implements Describable.String()
+func (p AssetPolicy) String() string {
+	return fmt.Sprintf("AllowedInitiators: %v, AllowedOps: %v, MinInputs: %d, Quota: %d",
+		p.AllowedInitiators, p.AllowedOps, p.MinInputs, p.Quota)
+}
+
+// CheckAssetPolicies verifies the policies of all assets used in the expression
+func CheckAssetPolicies(worldState storage.KVStore, initiator string, expression string) error {
+	postfix, variables, err := GetPostfixAndVariables(expression)
+	if err != nil {
+		return err
+	}
+	owners, err := getExprAssetOwners(worldState, expression)
+	if err != nil {
+		return err
+	}
+
+	for asset, owner := range owners {
+		record := GetAssetsFromWorldState(worldState, owner)
+		policy, ok := record.Policies[asset]
+		if !ok {
+			continue
+		}
+		err = policy.Check(initiator, postfix, variables, record.Usage[asset][initiator])
+		if err != nil {
+			return fmt.Errorf("policy of asset %s rejects MPC: %s", asset, err)
+		}
+	}
+	return nil
+}
+
+func containsString(list []string, target string) bool {
+	for _, s := range list {
+		if s == target {
+			return true
+		}
+	}
+	return false
+}
+
+// -----------------------------------------------------------------------------
+// Utilities - Asset Schema
+
+// AssetSchema describes the meaning of an asset to its consumers
+type AssetSchema struct {
+	Description string
+	Unit        string
+	// type of the value, e.g. "count", "bool", "category"
+	Type string
+	// valid range of the value, only if bounded
+	Bounded bool
+	Min     int
+	Max     int
+	Contact string
+	Tags    []string
+}
+
+// HasRange tells whether the schema restricts the value range
+func (s AssetSchema) HasRange() bool {
+	return s.Bounded
+}
+
+// InRange checks whether the value is inside the valid range
+func (s AssetSchema) InRange(value int) bool {
+	return !s.HasRange() || (value >= s.Min && value <= s.Max)
+}
+
+// Validate checks that the schema is consistent
+func (s AssetSchema) Validate() error {
+	if !s.Bounded {
+		if s.Min != 0 || s.Max != 0 {
+			return fmt.Errorf("range [%d, %d] of an unbounded schema", s.Min, s.Max)
+		}
+		return nil
+	}
+	if s.Min > s.Max {
+		return fmt.Errorf("min %d larger than max %d", s.Min, s.Max)
+	}
+	return nil
+}
+
+// Matches tells whether the query appears in the schema, case-insensitive
+func (s AssetSchema) Matches(query string) bool {
+	query = strings.ToLower(query)
+	fields := append([]string{s.Description, s.Unit, s.Type, s.Contact}, s.Tags...)
+	for _, field := range fields {
+		if strings.Contains(strings.ToLower(field), query) {
+			return true
+		}
+	}
+	return false
+}
+
+// Copy returns a deep copy of the schema
+func (s AssetSchema) Copy() AssetSchema {
+	tags := make([]string, len(s.Tags))
+	copy(tags, s.Tags)
+
+	schema := s
+	schema.Tags = tags
+	return schema
+}
+
+// String implements Describable.String()
+func (s AssetSchema) String() string {
+	description := fmt.Sprintf("Description: %s, Unit: %s, Type: %s",
+		s.Description, s.Unit, s.Type)
+	if s.HasRange() {
+		description += fmt.Sprintf(", Range: [%d, %d]", s.Min, s.Max)
+	}
+	return description + fmt.Sprintf(", Contact: %s, Tags: %v", s.Contact, s.Tags)
+}
+
+// AssetInfo is the public information of an asset
+type AssetInfo struct {
+	Key    string
+	Owner  string
+	Price  Amount
+	Schema AssetSchema
+}
+
+// SearchAssets returns the assets whose key, owner or schema contains the
+// query, sorted by key. An empty query returns all assets
+func SearchAssets(worldState storage.KVStore, query string) []AssetInfo {
+	infos := []AssetInfo{}
+	for _, owner := range assetOwners(worldState) {
+		record := GetAssetsFromWorldState(worldState, owner)
+		for asset, price := range record.Assets {
+			schema := record.Schemas[asset]
+			if query != "" && !strings.Contains(strings.ToLower(asset), strings.ToLower(query)) &&
+				!strings.Contains(strings.ToLower(owner), strings.ToLower(query)) &&
+				!schema.Matches(query) {
+				continue
+			}
+			infos = append(infos, AssetInfo{
+				Key:    asset,
+				Owner:  owner,
+				Price:  price,
+				Schema: schema.Copy(),
+			})
+		}
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		if infos[i].Key != infos[j].Key {
+			return infos[i].Key < infos[j].Key
+		}
+		return infos[i].Owner < infos[j].Owner
+	})
+	return infos
+}
+
+// -----------------------------------------------------------------------------
+// Utilities - Price Schedule
+
+// VolumeDiscount reduces the price once the initiator has used the asset
+// in at least MinUses MPCs
+type VolumeDiscount struct {
+	MinUses  uint
+	Discount float64
+}
+
+// PriceSchedule describes how the price of an asset depends on the initiator
+type PriceSchedule struct {
+	// members use the asset for free
+	Members []string
+	// price for specific initiators, replacing the per-use price
+	InitiatorPrices map[string]Amount
+	// discounts on the price. The one with the largest reached MinUses applies
+	VolumeDiscounts []VolumeDiscount
+}
+
+// Apply computes the price for the initiator given its previous uses
+func (s PriceSchedule) Apply(price Amount, initiator string, uses uint) Amount {
+	for _, member := range s.Members {
+		if member == initiator {
+			return 0
+		}
+	}
+	if initiatorPrice, ok := s.InitiatorPrices[initiator]; ok {
+		price = initiatorPrice
+	}
+
+	var bestUses uint = 0
+	discount := 0.0
+	for _, volume := range s.VolumeDiscounts {
+		if uses >= volume.MinUses && volume.MinUses >= bestUses {
+			bestUses = volume.MinUses
+			discount = volume.Discount
+		}
+	}
+	// the discount is rounded down, the owner keeps the fraction
+	return price - price.MulRatio(discount)
+}
+
+// Validate checks that the schedule never produces negative prices
+func (s PriceSchedule) Validate() error {
+	for initiator, price := range s.InitiatorPrices {
+		err := price.Validate()
+		if err != nil {
+			return fmt.Errorf("invalid price for initiator %s: %v", initiator, err)
+		}
+	}
+	for _, volume := range s.VolumeDiscounts {
+		if volume.Discount < 0 || volume.Discount > 1 {
+			return fmt.Errorf("discount %f after %d uses not in [0, 1]",
+				volume.Discount, volume.MinUses)
+		}
+	}
+	return nil
+}
+
+// Copy returns a deep copy of the schedule
+func (s PriceSchedule) Copy() PriceSchedule {
+	members := make([]string, len(s.Members))
+	copy(members, s.Members)
+	initiatorPrices := map[string]Amount{}
+	for initiator, price := range s.InitiatorPrices {
+		initiatorPrices[initiator] = price
+	}
+	volumeDiscounts := make([]VolumeDiscount, len(s.VolumeDiscounts))
+	copy(volumeDiscounts, s.VolumeDiscounts)
+
+	return PriceSchedule{
+		Members:         members,
+		InitiatorPrices: initiatorPrices,
+		VolumeDiscounts: volumeDiscounts,
+	}
+}
+
+// String implements Describable.String()
+func (s PriceSchedule) String() string {
+	return fmt.Sprintf("Members: %v, InitiatorPrices: %v, VolumeDiscounts: %v",
+		s.Members, s.InitiatorPrices, s.VolumeDiscounts)
+}
+
 // -----------------------------------------------------------------------------
 // Utils - Expression Parser
 
@@ -253,3 +912,7 @@ func prec(s string) int {
 		return -1
 	}
 }
+
+func isOperator(s string) bool {
+	return prec(s) > 0
+}
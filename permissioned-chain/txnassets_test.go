@@ -0,0 +1,463 @@
+package permissioned
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/cs438/storage"
+)
+
+func Test_Txn_Execution_RegPricing(t *testing.T) {
+	owner := *NewAccount(*NewAddressFromHex("owner"))
+	other := *NewAccount(*NewAddressFromHex("other"))
+
+	// create worldstate
+	worldState := storage.NewBasicKV()
+	config := *NewChainConfig(
+		map[string]string{
+			owner.addr.Hex: "",
+			other.addr.Hex: ""},
+		1, "2h", 0, 10,
+	)
+	worldState.Put(STATE_CONFIG_KEY, config)
+	worldState.Put(owner.addr.Hex, owner)
+	worldState.Put(other.addr.Hex, other)
+	asset := NewAssetsRecord(owner.addr.Hex)
+	asset.Add(map[string]Amount{"a": 10})
+	worldState.Put(AssetsKeyFromUniqID(owner.addr.Hex), *asset)
+	schedules := map[string]PriceSchedule{"a": {Members: []string{other.addr.Hex}}}
+
+	// > pricing an asset owned by someone else should fail
+
+	stateCopy := worldState.Copy()
+	err := NewTransactionRegPricing(&other, schedules).Exec(worldState)
+	require.Error(t, err)
+	require.Equal(t, stateCopy.Hash(), worldState.Hash())
+
+	// > invalid discount should fail
+
+	err = NewTransactionRegPricing(&owner, map[string]PriceSchedule{
+		"a": {VolumeDiscounts: []VolumeDiscount{{MinUses: 1, Discount: 2}}},
+	}).Exec(worldState)
+	require.Error(t, err)
+	require.Equal(t, stateCopy.Hash(), worldState.Hash())
+
+	// > owner can price its asset
+
+	err = NewTransactionRegPricing(&owner, schedules).Exec(worldState)
+	require.NoError(t, err)
+	record := GetAssetsFromWorldState(worldState, owner.addr.Hex)
+	price, ok := record.PriceFor("a", other.addr.Hex)
+	require.True(t, ok)
+	require.Equal(t, Amount(0), price)
+	price, _ = record.PriceFor("a", owner.addr.Hex)
+	require.Equal(t, Amount(10), price)
+}
+
+func Test_Price_Schedule_Apply(t *testing.T) {
+	schedule := PriceSchedule{
+		Members:         []string{"member"},
+		InitiatorPrices: map[string]Amount{"partner": 6},
+		VolumeDiscounts: []VolumeDiscount{
+			{MinUses: 5, Discount: 0.5},
+			{MinUses: 2, Discount: 0.25},
+		},
+	}
+
+	require.Equal(t, Amount(0), schedule.Apply(100, "member", 0))
+	require.Equal(t, Amount(100), schedule.Apply(100, "someone", 1))
+	require.Equal(t, Amount(75), schedule.Apply(100, "someone", 2))
+	require.Equal(t, Amount(50), schedule.Apply(100, "someone", 7))
+	require.Equal(t, Amount(6), schedule.Apply(100, "partner", 0))
+	require.Equal(t, Amount(3), schedule.Apply(100, "partner", 5))
+
+	// > the discount is rounded down, the owner keeps the fraction
+	require.Equal(t, Amount(12), schedule.Apply(15, "someone", 2))
+	require.Equal(t, Amount(2), schedule.Apply(3, "someone", 7))
+}
+
+func Test_Txn_Execution_PreMPC_Pricing(t *testing.T) {
+	initiator := *NewAccount(*NewAddressFromHex("initiator"))
+	initiator.balance = 100
+	owner := *NewAccount(*NewAddressFromHex("owner"))
+
+	// create worldstate
+	worldState := storage.NewBasicKV()
+	config := *NewChainConfig(
+		map[string]string{
+			initiator.addr.Hex: "",
+			owner.addr.Hex:     ""},
+		1, "2h", 2, 10,
+	)
+	worldState.Put(STATE_CONFIG_KEY, config)
+	worldState.Put(initiator.addr.Hex, initiator)
+	worldState.Put(owner.addr.Hex, owner)
+	asset := NewAssetsRecord(owner.addr.Hex)
+	asset.Add(map[string]Amount{"a": 10})
+	asset.Schedules["a"] = PriceSchedule{
+		VolumeDiscounts: []VolumeDiscount{{MinUses: 1, Discount: 0.5}},
+	}
+	worldState.Put(AssetsKeyFromUniqID(owner.addr.Hex), *asset)
+
+	// > fee lower than the chain default should fail
+
+	_, _, err := CalculateTotalPrice(worldState, initiator.addr.Hex, "a", 1)
+	require.Error(t, err)
+	stateCopy := worldState.Copy()
+	err = NewTransactionPreMPC(&initiator, MPCPropose{
+		Initiator:  initiator.addr.Hex,
+		Budget:     20,
+		Expression: "a",
+		Fee:        1,
+	}).Exec(worldState)
+	require.Error(t, err)
+	require.Equal(t, stateCopy.Hash(), worldState.Hash())
+
+	// > higher fee is paid to every committee member
+
+	_, total, err := CalculateTotalPrice(worldState, initiator.addr.Hex, "a", 3)
+	require.NoError(t, err)
+	require.Equal(t, Amount(16), total)
+
+	txn := NewTransactionPreMPC(&initiator, MPCPropose{
+		Initiator:  initiator.addr.Hex,
+		Budget:     total,
+		Expression: "a",
+		Fee:        3,
+	})
+	err = txn.Exec(worldState)
+	require.NoError(t, err)
+	endorsement, err := GetMPCEndorsementFromWorldState(worldState, mpcKeyFromUniqID(txn.ID))
+	require.NoError(t, err)
+	require.Equal(t, Amount(3), endorsement.Fee)
+	require.Equal(t, Amount(10), endorsement.Budget[owner.addr.Hex])
+
+	// > usage is recorded and the volume discount applies next time
+
+	record := GetAssetsFromWorldState(worldState, owner.addr.Hex)
+	require.Equal(t, uint(1), record.Usage["a"][initiator.addr.Hex])
+
+	prices, total, err := CalculateTotalPrice(worldState, initiator.addr.Hex, "a", 0)
+	require.NoError(t, err)
+	require.Equal(t, Amount(5), prices[owner.addr.Hex])
+	require.Equal(t, Amount(9), total)
+}
+
+func Test_Txn_Execution_PreMPC_Asset_Policy(t *testing.T) {
+	initiator := *NewAccount(*NewAddressFromHex("initiator"))
+	initiator.balance = 100
+	stranger := *NewAccount(*NewAddressFromHex("stranger"))
+	stranger.balance = 100
+	owner := *NewAccount(*NewAddressFromHex("owner"))
+
+	// create worldstate
+	worldState := storage.NewBasicKV()
+	config := *NewChainConfig(
+		map[string]string{
+			initiator.addr.Hex: "",
+			stranger.addr.Hex:  "",
+			owner.addr.Hex:     ""},
+		1, "2h", 0, 10,
+	)
+	worldState.Put(STATE_CONFIG_KEY, config)
+	worldState.Put(initiator.addr.Hex, initiator)
+	worldState.Put(stranger.addr.Hex, stranger)
+	worldState.Put(owner.addr.Hex, owner)
+
+	// > policy on an unknown asset should fail
+
+	stateCopy := worldState.Copy()
+	policy := AssetPolicy{
+		AllowedInitiators: []string{initiator.addr.Hex},
+		AllowedOps:        []string{"+"},
+		MinInputs:         2,
+		Quota:             1,
+	}
+	err := NewTransactionRegAssetsWithDetails(&owner, AssetsRegistration{
+		Policies: map[string]AssetPolicy{"a": policy},
+	}).Exec(worldState)
+	require.Error(t, err)
+	require.Equal(t, stateCopy.Hash(), worldState.Hash())
+
+	// > unknown operation should fail
+
+	err = NewTransactionRegAssetsWithDetails(&owner, AssetsRegistration{
+		Assets:   map[string]Amount{"a": 1, "b": 1, "c": 1},
+		Policies: map[string]AssetPolicy{"a": {AllowedOps: []string{"%"}}},
+	}).Exec(worldState)
+	require.Error(t, err)
+	require.Equal(t, stateCopy.Hash(), worldState.Hash())
+
+	err = NewTransactionRegAssetsWithDetails(&owner, AssetsRegistration{
+		Assets:   map[string]Amount{"a": 1, "b": 1, "c": 1},
+		Policies: map[string]AssetPolicy{"a": policy},
+	}).Exec(worldState)
+	require.NoError(t, err)
+
+	propose := func(account *Account, expression string) error {
+		return NewTransactionPreMPC(account, MPCPropose{
+			Initiator:  account.addr.Hex,
+			Budget:     100,
+			Expression: expression,
+		}).Exec(worldState)
+	}
+
+	// > initiator not in the allow-list should fail
+
+	stateCopy = worldState.Copy()
+	require.Error(t, propose(&stranger, "a+b"))
+	require.Equal(t, stateCopy.Hash(), worldState.Hash())
+
+	// > forbidden operation and too few inputs should fail
+
+	require.Error(t, propose(&initiator, "a*b"))
+	require.Error(t, propose(&initiator, "a"))
+	require.Equal(t, stateCopy.Hash(), worldState.Hash())
+
+	// > assets without policy are unrestricted
+
+	require.NoError(t, propose(&stranger, "b*c"))
+
+	// > allowed usage should succeed until the quota is used up
+
+	require.NoError(t, propose(&initiator, "a+b"))
+	newInitiator := GetAccountFromWorldState(worldState, initiator.addr.Hex)
+	require.Error(t, propose(newInitiator, "a+b"))
+}
+
+func Test_Txn_Execution_Asset_Lifecycle(t *testing.T) {
+	owner := *NewAccount(*NewAddressFromHex("owner"))
+	owner.balance = 100
+	buyer := *NewAccount(*NewAddressFromHex("buyer"))
+
+	// create worldstate
+	worldState := storage.NewBasicKV()
+	config := *NewChainConfig(
+		map[string]string{
+			owner.addr.Hex: "",
+			buyer.addr.Hex: ""},
+		1, "2h", 0, 10,
+	)
+	worldState.Put(STATE_CONFIG_KEY, config)
+	worldState.Put(owner.addr.Hex, owner)
+	worldState.Put(buyer.addr.Hex, buyer)
+	asset := NewAssetsRecord(owner.addr.Hex)
+	asset.Add(map[string]Amount{"a": 10, "b": 10})
+	asset.Policies["a"] = AssetPolicy{Quota: 5}
+	worldState.Put(AssetsKeyFromUniqID(owner.addr.Hex), *asset)
+
+	// > updating an asset owned by someone else should fail
+
+	stateCopy := worldState.Copy()
+	err := NewTransactionUpdateAssets(&buyer, map[string]Amount{"a": 1}).Exec(worldState)
+	require.Error(t, err)
+	require.Equal(t, stateCopy.Hash(), worldState.Hash())
+
+	// > negative or huge prices should fail
+
+	err = NewTransactionUpdateAssets(&owner, map[string]Amount{"a": -1}).Exec(worldState)
+	require.Error(t, err)
+	err = NewTransactionRegAssets(&owner, map[string]Amount{"c": -1}).Exec(worldState)
+	require.Error(t, err)
+	err = NewTransactionRegAssets(&owner, map[string]Amount{"c": MAX_AMOUNT + 1}).Exec(worldState)
+	require.Error(t, err)
+	require.Equal(t, stateCopy.Hash(), worldState.Hash())
+
+	// > owner can update the price
+
+	err = NewTransactionUpdateAssets(&owner, map[string]Amount{"a": 20}).Exec(worldState)
+	require.NoError(t, err)
+	newOwner := GetAccountFromWorldState(worldState, owner.addr.Hex)
+	require.Equal(t, Amount(20), GetAssetsFromWorldState(worldState, owner.addr.Hex).Assets["a"])
+
+	// > transfer to a non-participant should fail
+
+	stateCopy = worldState.Copy()
+	err = NewTransactionTransferAsset(newOwner, AssetTransfer{Key: "a", To: "nobody"}).Exec(worldState)
+	require.Error(t, err)
+	require.Equal(t, stateCopy.Hash(), worldState.Hash())
+
+	// > transfer moves the asset with its policy
+
+	err = NewTransactionTransferAsset(newOwner, AssetTransfer{Key: "a", To: buyer.addr.Hex}).Exec(worldState)
+	require.NoError(t, err)
+	ownerAssets := GetAssetsFromWorldState(worldState, owner.addr.Hex)
+	require.NotContains(t, ownerAssets.Assets, "a")
+	require.NotContains(t, ownerAssets.Policies, "a")
+	buyerAssets := GetAssetsFromWorldState(worldState, buyer.addr.Hex)
+	require.Equal(t, Amount(20), buyerAssets.Assets["a"])
+	require.Equal(t, uint(5), buyerAssets.Policies["a"].Quota)
+
+	// > removed asset can no longer be used
+
+	newOwner = GetAccountFromWorldState(worldState, owner.addr.Hex)
+	err = NewTransactionRemoveAssets(newOwner, []string{"a"}).Exec(worldState)
+	require.Error(t, err)
+	err = NewTransactionRemoveAssets(newOwner, []string{"b"}).Exec(worldState)
+	require.NoError(t, err)
+	require.Empty(t, GetAllAssetsFromWorldState(worldState)[owner.addr.Hex])
+	_, _, err = CalculateTotalPrice(worldState, owner.addr.Hex, "b", 0)
+	require.Error(t, err)
+}
+
+func Test_Txn_Execution_Asset_Update_During_MPC(t *testing.T) {
+	initiator := *NewAccount(*NewAddressFromHex("initiator"))
+	initiator.balance = 100
+	owner := *NewAccount(*NewAddressFromHex("owner"))
+
+	// create worldstate
+	worldState := storage.NewBasicKV()
+	config := *NewChainConfig(
+		map[string]string{
+			initiator.addr.Hex: "",
+			owner.addr.Hex:     ""},
+		1, "2h", 0, 10,
+	)
+	worldState.Put(STATE_CONFIG_KEY, config)
+	worldState.Put(initiator.addr.Hex, initiator)
+	worldState.Put(owner.addr.Hex, owner)
+	asset := NewAssetsRecord(owner.addr.Hex)
+	asset.Add(map[string]Amount{"a": 10})
+	worldState.Put(AssetsKeyFromUniqID(owner.addr.Hex), *asset)
+
+	txn := NewTransactionPreMPC(&initiator, MPCPropose{
+		Initiator:  initiator.addr.Hex,
+		Budget:     10,
+		Expression: "a",
+	})
+	err := txn.Exec(worldState)
+	require.NoError(t, err)
+
+	// > price change after PreMPC does not affect the ongoing MPC
+
+	err = NewTransactionUpdateAssets(&owner, map[string]Amount{"a": 50}).Exec(worldState)
+	require.NoError(t, err)
+
+	// > the asset can't be removed nor transferred before its owner endorsed
+
+	stateCopy := worldState.Copy()
+	newOwner := GetAccountFromWorldState(worldState, owner.addr.Hex)
+	err = NewTransactionRemoveAssets(newOwner, []string{"a"}).Exec(worldState)
+	require.Error(t, err)
+	require.Equal(t, stateCopy.Hash(), worldState.Hash())
+	err = NewTransactionTransferAsset(newOwner, AssetTransfer{
+		Key: "a",
+		To:  initiator.addr.Hex,
+	}).Exec(worldState)
+	require.Error(t, err)
+	require.Equal(t, stateCopy.Hash(), worldState.Hash())
+
+	err = NewTransactionPostMPC(newOwner, MPCRecord{UniqID: txn.ID}).Exec(worldState)
+	require.NoError(t, err)
+
+	// > once endorsed, the owner can remove it
+
+	newOwner = GetAccountFromWorldState(worldState, owner.addr.Hex)
+	err = NewTransactionRemoveAssets(newOwner, []string{"a"}).Exec(worldState)
+	require.NoError(t, err)
+	newInitiator := GetAccountFromWorldState(worldState, initiator.addr.Hex)
+	err = NewTransactionPostMPC(newInitiator, MPCRecord{UniqID: txn.ID}).Exec(worldState)
+	require.NoError(t, err)
+
+	newOwner = GetAccountFromWorldState(worldState, owner.addr.Hex)
+	require.Equal(t, Amount(10), newOwner.balance)
+	newInitiator = GetAccountFromWorldState(worldState, initiator.addr.Hex)
+	require.Equal(t, Amount(90), newInitiator.balance)
+}
+
+func Test_Txn_Execution_RegAssets_Schema_Search(t *testing.T) {
+	owner1 := *NewAccount(*NewAddressFromHex("owner1"))
+	owner2 := *NewAccount(*NewAddressFromHex("owner2"))
+
+	// create worldstate
+	worldState := storage.NewBasicKV()
+	config := *NewChainConfig(
+		map[string]string{
+			owner1.addr.Hex: "",
+			owner2.addr.Hex: ""},
+		1, "2h", 0, 10,
+	)
+	worldState.Put(STATE_CONFIG_KEY, config)
+	worldState.Put(owner1.addr.Hex, owner1)
+	worldState.Put(owner2.addr.Hex, owner2)
+
+	// > invalid range should fail
+
+	stateCopy := worldState.Copy()
+	err := NewTransactionRegAssetsWithDetails(&owner1, AssetsRegistration{
+		Assets:  map[string]Amount{"x7": 1},
+		Schemas: map[string]AssetSchema{"x7": {Bounded: true, Min: 10, Max: 0}},
+	}).Exec(worldState)
+	require.Error(t, err)
+	require.Equal(t, stateCopy.Hash(), worldState.Hash())
+	err = NewTransactionRegAssetsWithDetails(&owner1, AssetsRegistration{
+		Assets:  map[string]Amount{"x7": 1},
+		Schemas: map[string]AssetSchema{"x7": {Min: 10, Max: 20}},
+	}).Exec(worldState)
+	require.Error(t, err)
+	require.Equal(t, stateCopy.Hash(), worldState.Hash())
+
+	// > schema of an asset owned by someone else should fail
+
+	err = NewTransactionRegAssetsWithDetails(&owner2, AssetsRegistration{
+		Schemas: map[string]AssetSchema{"x7": {Description: "fake"}},
+	}).Exec(worldState)
+	require.Error(t, err)
+	require.Equal(t, stateCopy.Hash(), worldState.Hash())
+
+	// > register assets with schemas
+
+	err = NewTransactionRegAssetsWithDetails(&owner1, AssetsRegistration{
+		Assets: map[string]Amount{"x7": 1},
+		Schemas: map[string]AssetSchema{"x7": {
+			Description: "Daily steps",
+			Unit:        "steps",
+			Type:        "count",
+			Bounded:     true,
+			Min:         0,
+			Max:         100000,
+			Tags:        []string{"health"},
+		}},
+	}).Exec(worldState)
+	require.NoError(t, err)
+	err = NewTransactionRegAssetsWithDetails(&owner2, AssetsRegistration{
+		Assets: map[string]Amount{"y": 2, "z": 3},
+		Schemas: map[string]AssetSchema{"y": {
+			Description: "Monthly income",
+			Unit:        "CHF",
+			Tags:        []string{"finance"},
+		}},
+	}).Exec(worldState)
+	require.NoError(t, err)
+
+	// > search by keyword
+
+	infos := SearchAssets(worldState, "")
+	require.Len(t, infos, 3)
+	require.Equal(t, "x7", infos[0].Key)
+	require.Equal(t, "y", infos[1].Key)
+	require.Equal(t, "z", infos[2].Key)
+
+	infos = SearchAssets(worldState, "HEALTH")
+	require.Len(t, infos, 1)
+	require.Equal(t, "x7", infos[0].Key)
+	require.Equal(t, owner1.addr.Hex, infos[0].Owner)
+	require.Equal(t, "steps", infos[0].Schema.Unit)
+	require.True(t, infos[0].Schema.InRange(500))
+	require.False(t, infos[0].Schema.InRange(-1))
+
+	// > [0, 0] is a range, the zero schema has none
+
+	require.False(t, AssetSchema{Bounded: true}.InRange(1))
+	require.True(t, AssetSchema{Bounded: true}.InRange(0))
+	require.True(t, AssetSchema{}.InRange(1))
+
+	infos = SearchAssets(worldState, "income")
+	require.Len(t, infos, 1)
+	require.Equal(t, "y", infos[0].Key)
+
+	infos = SearchAssets(worldState, owner2.addr.Hex)
+	require.Len(t, infos, 2)
+
+	require.Empty(t, SearchAssets(worldState, "nothing"))
+}
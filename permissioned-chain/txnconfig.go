@@ -1,13 +1,14 @@
 package permissioned
 
 import (
-	"crypto/sha256"
+	"crypto/rsa"
+	"crypto/x509"
 	"encoding/hex"
-	"encoding/json"
 	"fmt"
 	"os"
-	"sort"
+	"time"
 
+	"github.com/ethereum/go-ethereum/common"
 	"go.dedis.ch/cs438/storage"
 	"gopkg.in/yaml.v3"
 )
@@ -30,16 +31,44 @@ type ChainConfig struct {
 	WaitTimeout string
 
 	// the basic gain for participating in a round of MPC (working fee)
-	MPCParticipationGain float64
+	MPCParticipationGain Amount
 
 	// the percentage of total participants should endorse
 	// so that a new node can join the network
 	JoinThreshold float64
+	// the fraction of the other participants that must approve the
+	// revocation of a participant's key. More than half of them always
+	// have to approve
+	RevocationThreshold float64
+
+	// the minimal stake a participant must lock to be eligible for
+	// MPC committees. 0 means every participant is eligible
+	MinStake Amount
+	// the number of blocks after a PreMPC within which every committee
+	// member must endorse. 0 means no deadline
+	EndorseDeadline uint
+	// the fraction of the offender's stake removed by a slash
+	SlashRatio float64
+	// the fraction of the slashed amount given to the reporter.
+	// The rest is burnt
+	SlashReward float64
+
+	// if true, a block is only final once a quorum of participants voted
+	// for it. Callbacks on transactions are delayed until then and final
+	// blocks are never reverted
+	Finality bool
+
+	// participants running as light nodes. They only follow the headers
+	// so they are never selected as miners and do not vote for blocks
+	LightNodes []string
 }
 
+// DEFAULT_REVOCATION_THRESHOLD is the revocation threshold of new configs
+const DEFAULT_REVOCATION_THRESHOLD = 2.0 / 3
+
 // NewChainConfig creates a new config and computes its ID
 func NewChainConfig(participant map[string]string,
-	maxTxnsPerBlk int, waitTimeout string, mpcGain float64, threshold float64) *ChainConfig {
+	maxTxnsPerBlk int, waitTimeout string, mpcGain Amount, threshold float64) *ChainConfig {
 	cc := ChainConfig{
 		Participants: participant,
 
@@ -48,7 +77,8 @@ func NewChainConfig(participant map[string]string,
 
 		MPCParticipationGain: mpcGain,
 
-		JoinThreshold: threshold,
+		JoinThreshold:       threshold,
+		RevocationThreshold: DEFAULT_REVOCATION_THRESHOLD,
 	}
 
 	return &cc
@@ -72,26 +102,7 @@ func ChainConfigFromYAML(path string) (*ChainConfig, error) {
 
 // Hash implements Hashable.Hash
 func (c ChainConfig) Hash() string {
-	h := sha256.New()
-
-	keys := make([]string, 0, len(c.Participants))
-	for k := range c.Participants {
-		keys = append(keys, k)
-	}
-	sort.Strings(keys)
-
-	for _, participant := range keys {
-		h.Write([]byte(participant))
-		h.Write([]byte(c.Participants[participant]))
-	}
-	h.Write([]byte(fmt.Sprintf("%d", c.MaxTxnsPerBlk)))
-	h.Write([]byte(c.WaitTimeout))
-
-	h.Write([]byte(fmt.Sprintf("%f", c.MPCParticipationGain)))
-
-	h.Write([]byte(fmt.Sprintf("%f", c.JoinThreshold)))
-
-	return hex.EncodeToString(h.Sum(nil))
+	return hashCanonical(c)
 }
 
 // String implements Describable.String()
@@ -102,8 +113,11 @@ func (c ChainConfig) String() string {
 	}
 	participants = participants[:len(participants)-2] + "]"
 	description := fmt.Sprintf(`Participants: %s, MaxNumTxn: %d, 
-	MaxBlockWaitTime: %s, MPCParticipationGain %f, JoinThreshold: %f, `,
-		participants, c.MaxTxnsPerBlk, c.WaitTimeout, c.MPCParticipationGain, c.JoinThreshold)
+	MaxBlockWaitTime: %s, MPCParticipationGain %s, JoinThreshold: %f, 
+	RevocationThreshold: %f, MinStake: %s, EndorseDeadline: %d, SlashRatio: %f, SlashReward: %f, Finality: %t,
+	LightNodes: %v`,
+		participants, c.MaxTxnsPerBlk, c.WaitTimeout, c.MPCParticipationGain, c.JoinThreshold,
+		c.RevocationThreshold, c.MinStake, c.EndorseDeadline, c.SlashRatio, c.SlashReward, c.Finality, c.LightNodes)
 	return description
 
 }
@@ -120,10 +134,128 @@ func (c ChainConfig) Copy() storage.Copyable {
 		WaitTimeout:          c.WaitTimeout,
 		MPCParticipationGain: c.MPCParticipationGain,
 		JoinThreshold:        c.JoinThreshold,
+		RevocationThreshold:  c.RevocationThreshold,
+		MinStake:             c.MinStake,
+		EndorseDeadline:      c.EndorseDeadline,
+		SlashRatio:           c.SlashRatio,
+		SlashReward:          c.SlashReward,
+		Finality:             c.Finality,
+		LightNodes:           append([]string{}, c.LightNodes...),
 	}
 	return config
 }
 
+// IsLightNode checks if the participant runs as a light node
+func (c ChainConfig) IsLightNode(addr string) bool {
+	for _, lightNode := range c.LightNodes {
+		if lightNode == addr {
+			return true
+		}
+	}
+	return false
+}
+
+// Validators returns the participants mining and voting for blocks,
+// i.e. all participants but the light nodes
+func (c ChainConfig) Validators() map[string]string {
+	validators := make(map[string]string)
+	for participant, pubkey := range c.Participants {
+		if !c.IsLightNode(participant) {
+			validators[participant] = pubkey
+		}
+	}
+	return validators
+}
+
+// Validate checks the config of a new chain:
+//   - participants have checksummed addresses and valid encryption keys if set
+//   - the wait timeout is a positive duration
+//   - amounts are not negative and ratios are in [0, 1]
+//   - the revocation threshold is a majority, i.e. in (0.5, 1]
+//   - light nodes are distinct participants, and at least one is not
+func (c ChainConfig) Validate() error {
+	if len(c.Participants) == 0 {
+		return fmt.Errorf("no participant")
+	}
+	for participant, pubkey := range c.Participants {
+		if !common.IsHexAddress(participant) || common.HexToAddress(participant).Hex() != participant {
+			return fmt.Errorf("invalid participant address %s", participant)
+		}
+		if pubkey == "" {
+			continue
+		}
+		err := checkPubkeyString(pubkey)
+		if err != nil {
+			return fmt.Errorf("invalid encryption key of %s: %v", participant, err)
+		}
+	}
+
+	if c.MaxTxnsPerBlk <= 0 {
+		return fmt.Errorf("invalid max number of txns per block: %d", c.MaxTxnsPerBlk)
+	}
+	timeout, err := time.ParseDuration(c.WaitTimeout)
+	if err != nil {
+		return fmt.Errorf("invalid wait timeout %q: %v", c.WaitTimeout, err)
+	}
+	if timeout <= 0 {
+		return fmt.Errorf("wait timeout must be positive, got %s", c.WaitTimeout)
+	}
+
+	if c.MPCParticipationGain < 0 || c.MinStake < 0 {
+		return fmt.Errorf("negative MPC gain %s or min stake %s", c.MPCParticipationGain, c.MinStake)
+	}
+	ratios := []struct {
+		name  string
+		value float64
+	}{
+		{"join threshold", c.JoinThreshold},
+		{"slash ratio", c.SlashRatio},
+		{"slash reward", c.SlashReward},
+	}
+	for _, ratio := range ratios {
+		if ratio.value < 0 || ratio.value > 1 {
+			return fmt.Errorf("%s must be in [0, 1], got %f", ratio.name, ratio.value)
+		}
+	}
+
+	// a minority must not take over the identity of a participant
+	if c.RevocationThreshold <= 0.5 || c.RevocationThreshold > 1 {
+		return fmt.Errorf("revocation threshold must be in (0.5, 1], got %f", c.RevocationThreshold)
+	}
+
+	lightNodes := map[string]struct{}{}
+	for _, lightNode := range c.LightNodes {
+		if _, ok := c.Participants[lightNode]; !ok {
+			return fmt.Errorf("light node %s is not a participant", lightNode)
+		}
+		if _, ok := lightNodes[lightNode]; ok {
+			return fmt.Errorf("duplicate light node %s", lightNode)
+		}
+		lightNodes[lightNode] = struct{}{}
+	}
+	if len(c.Validators()) == 0 {
+		return fmt.Errorf("all participants are light nodes, nobody can mine")
+	}
+	return nil
+}
+
+// checkPubkeyString checks that the string registered on chain is an RSA
+// public key
+func checkPubkeyString(pubkey string) error {
+	buf, err := hex.DecodeString(pubkey)
+	if err != nil {
+		return err
+	}
+	key, err := x509.ParsePKIXPublicKey(buf)
+	if err != nil {
+		return err
+	}
+	if _, ok := key.(*rsa.PublicKey); !ok {
+		return fmt.Errorf("not an RSA key: %T", key)
+	}
+	return nil
+}
+
 // -----------------------------------------------------------------------------
 // Transaction Polymophism - InitConfig
 
@@ -147,13 +279,6 @@ func execInitConfig(worldState storage.KVStore, config *ChainConfig, txn *Transa
 	return nil
 }
 
-func unmarshalInitConfig(data json.RawMessage) (interface{}, error) {
-	var c ChainConfig
-	err := json.Unmarshal(data, &c)
-
-	return c, err
-}
-
 // -----------------------------------------------------------------------------
 // Transaction Polymophism - RegEnckey
 
@@ -174,13 +299,7 @@ func execRegEnckey(worldState storage.KVStore, config *ChainConfig, txn *Transac
 
 	pubkey := txn.Data.(string)
 	config.Participants[txn.From] = pubkey
+	worldState.Put(STATE_CONFIG_KEY, *config)
 
 	return nil
 }
-
-func unmarshalRegEnckey(data json.RawMessage) (interface{}, error) {
-	var c ChainConfig
-	err := json.Unmarshal(data, &c)
-
-	return c, err
-}
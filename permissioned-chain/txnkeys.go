@@ -0,0 +1,407 @@
+package permissioned
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"go.dedis.ch/cs438/storage"
+)
+
+// -----------------------------------------------------------------------------
+// Transaction Polymophism - RotateKey
+
+// KeyRotation moves the identity of the sender to the address of a new key
+type KeyRotation struct {
+	NewAddress string
+	// signature of the new key on the rotation, proving that the new
+	// address is owned
+	Proof []byte
+}
+
+// String implements Describable.String()
+func (r KeyRotation) String() string {
+	return fmt.Sprintf("NewAddress: %s", r.NewAddress)
+}
+
+// NewTransactionRotateKey moves the account, balance, stake, assets and
+// participant slot of the sender to the address of the new key. It must be
+// signed by the old key
+func NewTransactionRotateKey(from *Account, newKey *ecdsa.PrivateKey) (*Transaction, error) {
+	newAddr := NewAddress(&newKey.PublicKey).Hex
+	proof, err := crypto.Sign(rotationDigest(from.addr.Hex, newAddr), newKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewTransaction(
+		from,
+		&ZeroAddress,
+		TxnTypeRotateKey,
+		0,
+		KeyRotation{NewAddress: newAddr, Proof: proof},
+	), nil
+}
+
+func execRotateKey(worldState storage.KVStore, config *ChainConfig, txn *Transaction) error {
+	rotation := txn.Data.(KeyRotation)
+
+	if len(rotation.Proof) != crypto.SignatureLength {
+		return fmt.Errorf("invalid rotation proof length: %d", len(rotation.Proof))
+	}
+	publicKey, err := crypto.SigToPub(rotationDigest(txn.From, rotation.NewAddress), rotation.Proof)
+	if err != nil {
+		return err
+	}
+	if NewAddress(publicKey).Hex != rotation.NewAddress {
+		return fmt.Errorf("rotation of %s is not signed by the new key %s", txn.From, rotation.NewAddress)
+	}
+
+	return rotateIdentity(worldState, config, txn.From, rotation.NewAddress, false)
+}
+
+// rotationDigest is the digest the new key signs
+func rotationDigest(oldAddr string, newAddr string) []byte {
+	return hashCanonicalBytes([]string{oldAddr, newAddr})
+}
+
+// -----------------------------------------------------------------------------
+// Transaction Polymophism - RevokeKey
+
+// KeyRevocation is the approval by a participant to move the identity of
+// an address whose key leaked to a new address
+type KeyRevocation struct {
+	Address    string
+	NewAddress string
+}
+
+// String implements Describable.String()
+func (r KeyRevocation) String() string {
+	return fmt.Sprintf("Address: %s, NewAddress: %s", r.Address, r.NewAddress)
+}
+
+// NewTransactionRevokeKey approves the revocation. Once approved by the
+// RevocationThreshold of the other participants, the identity of the revoked
+// address is moved as by a key rotation
+func NewTransactionRevokeKey(from *Account, revocation KeyRevocation) *Transaction {
+	return NewTransaction(
+		from,
+		&ZeroAddress,
+		TxnTypeRevokeKey,
+		0,
+		revocation,
+	)
+}
+
+func execRevokeKey(worldState storage.KVStore, config *ChainConfig, txn *Transaction) error {
+	revocation := txn.Data.(KeyRevocation)
+
+	if revocation.Address == txn.From {
+		return fmt.Errorf("%s cannot approve the revocation of its own key", txn.From)
+	}
+	if _, ok := config.Participants[revocation.Address]; !ok {
+		return fmt.Errorf("%s is not a participant", revocation.Address)
+	}
+	err := checkNewAddress(worldState, config, revocation.NewAddress)
+	if err != nil {
+		return err
+	}
+
+	key := revocationKeyFromAddrs(revocation.Address, revocation.NewAddress)
+	votes := RevocationVotes{KeyRevocation: revocation, Approvers: map[string]struct{}{}}
+	object, ok := worldState.Get(key)
+	if ok {
+		votes = object.(RevocationVotes).Copy().(RevocationVotes)
+	}
+	if _, ok := votes.Approvers[txn.From]; ok {
+		return fmt.Errorf("%s already approved the revocation of %s", txn.From, revocation.Address)
+	}
+	votes.Approvers[txn.From] = struct{}{}
+
+	if len(votes.Approvers) < revocationThreshold(config) {
+		err = worldState.Put(key, votes)
+		if err != nil {
+			panic(err)
+		}
+		return nil
+	}
+
+	return rotateIdentity(worldState, config, revocation.Address, revocation.NewAddress, true)
+}
+
+// -----------------------------------------------------------------------------
+// Utilities - Key Rotation
+
+// RotationRecord tells where the identity of a former address went
+type RotationRecord struct {
+	From string
+	To   string
+	// true if the key was revoked by the participants instead of rotated
+	Revoked bool
+}
+
+// Copy implements Copyable.Copy()
+func (r RotationRecord) Copy() storage.Copyable {
+	return r
+}
+
+// Hash implements Hashable.Hash
+func (r RotationRecord) Hash() string {
+	return hashCanonical(r)
+}
+
+// String implements Describable.String()
+func (r RotationRecord) String() string {
+	return fmt.Sprintf("From: %s, To: %s, Revoked: %t", r.From, r.To, r.Revoked)
+}
+
+// RevocationVotes collects the approvals of a revocation
+type RevocationVotes struct {
+	KeyRevocation
+	Approvers map[string]struct{}
+}
+
+// Copy implements Copyable.Copy()
+func (v RevocationVotes) Copy() storage.Copyable {
+	approvers := map[string]struct{}{}
+	for approver := range v.Approvers {
+		approvers[approver] = struct{}{}
+	}
+	return RevocationVotes{KeyRevocation: v.KeyRevocation, Approvers: approvers}
+}
+
+// Hash implements Hashable.Hash
+func (v RevocationVotes) Hash() string {
+	return hashCanonical(v)
+}
+
+func RotationKeyFromAddr(addr string) string {
+	return fmt.Sprintf("rotation|%s", addr)
+}
+
+func revocationKeyFromAddrs(addr string, newAddr string) string {
+	return fmt.Sprintf("revocation|%s|%s", addr, newAddr)
+}
+
+// GetRotationFromWorldState returns where the identity of the address
+// went. It returns false if the address was never rotated
+func GetRotationFromWorldState(worldState storage.KVStore, addr string) (*RotationRecord, bool) {
+	object, ok := worldState.Get(RotationKeyFromAddr(addr))
+	if !ok {
+		return nil, false
+	}
+	record := object.(RotationRecord)
+	return &record, true
+}
+
+// ResolveAddress follows the rotations of the address and returns its
+// current address
+func ResolveAddress(worldState storage.KVStore, addr string) string {
+	for {
+		record, ok := GetRotationFromWorldState(worldState, addr)
+		if !ok {
+			return addr
+		}
+		addr = record.To
+	}
+}
+
+// revocationThreshold returns the number of other participants that must
+// approve a revocation. It is at least a majority of them, even if the config
+// was not validated
+func revocationThreshold(config *ChainConfig) int {
+	others := len(config.Participants) - 1
+	required := int(math.Ceil(config.RevocationThreshold * float64(others)))
+	if majority := others/2 + 1; required < majority {
+		required = majority
+	}
+	if required > others {
+		required = others
+	}
+	return required
+}
+
+// dropRevocations removes the pending approvals of revocations of the address
+func dropRevocations(worldState storage.KVStore, addr string) {
+	prefix := revocationKeyFromAddrs(addr, "")
+	keys := make([]string, 0)
+	_ = worldState.For(func(key string, value interface{}) error {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	for _, key := range keys {
+		_ = worldState.Del(key)
+	}
+}
+
+// checkNewAddress checks that the address is a fresh key address
+func checkNewAddress(worldState storage.KVStore, config *ChainConfig, newAddr string) error {
+	if !common.IsHexAddress(newAddr) || common.HexToAddress(newAddr).Hex() != newAddr {
+		return fmt.Errorf("invalid address %s", newAddr)
+	}
+	if _, ok := config.Participants[newAddr]; ok {
+		return fmt.Errorf("%s is already a participant", newAddr)
+	}
+	if _, ok := worldState.Get(newAddr); ok {
+		return fmt.Errorf("%s already has an account", newAddr)
+	}
+	if _, ok := worldState.Get(RotationKeyFromAddr(newAddr)); ok {
+		return fmt.Errorf("%s is a former address", newAddr)
+	}
+	return nil
+}
+
+// rotateIdentity moves the account, assets and participant slot of the old
+// address to the new one, and replaces the old address wherever the world
+// state refers to it. The old account keeps its nonce so that its
+// transactions cannot be replayed
+func rotateIdentity(worldState storage.KVStore, config *ChainConfig,
+	oldAddr string, newAddr string, revoked bool) error {
+	err := checkNewAddress(worldState, config, newAddr)
+	if err != nil {
+		return err
+	}
+	// pending revocations of the old address are void
+	dropRevocations(worldState, oldAddr)
+	rename := func(addr string) string {
+		if addr == oldAddr {
+			return newAddr
+		}
+		return addr
+	}
+
+	// account
+	oldAccount := GetAccountFromWorldState(worldState, oldAddr)
+	newAccount := NewAccount(*NewAddressFromHex(newAddr))
+	newAccount.balance = oldAccount.balance
+	newAccount.lockedBalance = oldAccount.lockedBalance
+	newAccount.stake = oldAccount.stake
+	oldAccount.balance, oldAccount.lockedBalance, oldAccount.stake = 0, 0, 0
+
+	// participant slot
+	newConfig := config.Copy().(ChainConfig)
+	newConfig.Participants[newAddr] = newConfig.Participants[oldAddr]
+	delete(newConfig.Participants, oldAddr)
+	for i, lightNode := range newConfig.LightNodes {
+		newConfig.LightNodes[i] = rename(lightNode)
+	}
+
+	updates := map[string]interface{}{
+		oldAddr:                      *oldAccount,
+		newAddr:                      *newAccount,
+		STATE_CONFIG_KEY:             newConfig,
+		RotationKeyFromAddr(oldAddr): RotationRecord{From: oldAddr, To: newAddr, Revoked: revoked},
+	}
+
+	// assets, ongoing MPCs and multisig accounts refering to the old address
+	_ = worldState.For(func(key string, value interface{}) error {
+		switch vv := value.(type) {
+		case AssetsRecord:
+			updates[key] = renameInAssets(vv, rename)
+		case MPCEndorsement:
+			updates[key] = renameInEndorsement(vv, rename)
+		case MultisigPolicy:
+			updates[key] = renameInMultisig(vv, rename)
+		case RevocationVotes:
+			updates[key] = renameInRevocation(vv, rename)
+		}
+		return nil
+	})
+	// the assets of the old address move to the new one
+	removed := make([]string, 0)
+	if record, ok := updates[AssetsKeyFromUniqID(oldAddr)]; ok {
+		updates[AssetsKeyFromUniqID(newAddr)] = record
+		delete(updates, AssetsKeyFromUniqID(oldAddr))
+		removed = append(removed, AssetsKeyFromUniqID(oldAddr))
+	}
+
+	for key, value := range updates {
+		err = worldState.Put(key, value)
+		if err != nil {
+			panic(err)
+		}
+	}
+	for _, key := range removed {
+		err = worldState.Del(key)
+		if err != nil {
+			panic(err)
+		}
+	}
+	return nil
+}
+
+func renameInAssets(record AssetsRecord, rename func(string) string) AssetsRecord {
+	renamed := record.Copy().(AssetsRecord)
+	renamed.Owner = rename(record.Owner)
+	for asset, schedule := range renamed.Schedules {
+		for i, member := range schedule.Members {
+			schedule.Members[i] = rename(member)
+		}
+		prices := map[string]Amount{}
+		for initiator, price := range schedule.InitiatorPrices {
+			prices[rename(initiator)] = price
+		}
+		schedule.InitiatorPrices = prices
+		renamed.Schedules[asset] = schedule
+	}
+	for asset, policy := range renamed.Policies {
+		for i, initiator := range policy.AllowedInitiators {
+			policy.AllowedInitiators[i] = rename(initiator)
+		}
+		renamed.Policies[asset] = policy
+	}
+	for asset, counts := range renamed.Usage {
+		usage := map[string]uint{}
+		for initiator, count := range counts {
+			usage[rename(initiator)] += count
+		}
+		renamed.Usage[asset] = usage
+	}
+	return renamed
+}
+
+func renameInEndorsement(endorsement MPCEndorsement, rename func(string) string) MPCEndorsement {
+	renamed := endorsement.Copy().(MPCEndorsement)
+	renamed.Initiator = rename(endorsement.Initiator)
+	renamed.Peers = map[string]string{}
+	for peer, pubkey := range endorsement.Peers {
+		renamed.Peers[rename(peer)] = pubkey
+	}
+	renamed.Endorsers = map[string]struct{}{}
+	for endorser := range endorsement.Endorsers {
+		renamed.Endorsers[rename(endorser)] = struct{}{}
+	}
+	renamed.Slashed = map[string]struct{}{}
+	for slashed := range endorsement.Slashed {
+		renamed.Slashed[rename(slashed)] = struct{}{}
+	}
+	renamed.Budget = map[string]Amount{}
+	for owner, price := range endorsement.Budget {
+		renamed.Budget[rename(owner)] = price
+	}
+	return renamed
+}
+
+func renameInMultisig(policy MultisigPolicy, rename func(string) string) MultisigPolicy {
+	renamed := policy.Copy().(MultisigPolicy)
+	for i, owner := range renamed.Owners {
+		renamed.Owners[i] = rename(owner)
+	}
+	sort.Strings(renamed.Owners)
+	return renamed
+}
+
+func renameInRevocation(votes RevocationVotes, rename func(string) string) RevocationVotes {
+	renamed := votes.Copy().(RevocationVotes)
+	renamed.Approvers = map[string]struct{}{}
+	for approver := range votes.Approvers {
+		renamed.Approvers[rename(approver)] = struct{}{}
+	}
+	return renamed
+}
@@ -0,0 +1,160 @@
+package permissioned
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/cs438/storage"
+)
+
+func newKeyAccount(t *testing.T) (*ecdsa.PrivateKey, Account) {
+	privKey, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	return privKey, *NewAccount(*NewAddress(&privKey.PublicKey))
+}
+
+func Test_Txn_Execution_RotateKey(t *testing.T) {
+	_, member := newKeyAccount(t)
+	member.balance = 20
+	member.stake = 5
+	_, other := newKeyAccount(t)
+	newKey, newAccount := newKeyAccount(t)
+	newAddr := newAccount.addr.Hex
+
+	// create worldstate
+	worldState := storage.NewBasicKV()
+	config := *NewChainConfig(
+		map[string]string{member.addr.Hex: "enckey", other.addr.Hex: ""},
+		1, "2h", 0, 1,
+	)
+	config.LightNodes = []string{member.addr.Hex}
+	worldState.Put(STATE_CONFIG_KEY, config)
+	worldState.Put(member.addr.Hex, member)
+	worldState.Put(other.addr.Hex, other)
+	assets := NewAssetsRecord(member.addr.Hex)
+	assets.Add(map[string]Amount{"a": 5})
+	worldState.Put(AssetsKeyFromUniqID(member.addr.Hex), *assets)
+	otherAssets := NewAssetsRecord(other.addr.Hex)
+	otherAssets.Add(map[string]Amount{"b": 1})
+	otherAssets.Policies["b"] = AssetPolicy{AllowedInitiators: []string{member.addr.Hex}}
+	worldState.Put(AssetsKeyFromUniqID(other.addr.Hex), *otherAssets)
+
+	// > the new key must prove it agrees
+
+	_, forged := newKeyAccount(t)
+	txn, err := NewTransactionRotateKey(&member, newKey)
+	require.NoError(t, err)
+	rotation := txn.Data.(KeyRotation)
+	rotation.NewAddress = forged.addr.Hex
+	txn.Data = rotation
+	stateCopy := worldState.Copy()
+	err = txn.Exec(worldState)
+	require.Error(t, err)
+	require.Equal(t, stateCopy.Hash(), worldState.Hash())
+
+	// > the identity moves to the new address
+
+	txn, err = NewTransactionRotateKey(&member, newKey)
+	require.NoError(t, err)
+	err = txn.Exec(worldState)
+	require.NoError(t, err)
+
+	rotated := GetAccountFromWorldState(worldState, newAddr)
+	require.Equal(t, Amount(20), rotated.balance)
+	require.Equal(t, Amount(5), rotated.stake)
+	old := GetAccountFromWorldState(worldState, member.addr.Hex)
+	require.Equal(t, Amount(0), old.balance)
+	require.Equal(t, member.nonce+1, old.nonce)
+
+	newConfig := GetConfigFromWorldState(worldState)
+	require.Equal(t, "enckey", newConfig.Participants[newAddr])
+	require.NotContains(t, newConfig.Participants, member.addr.Hex)
+	require.Equal(t, []string{newAddr}, newConfig.LightNodes)
+
+	require.Equal(t, Amount(5), GetAssetsFromWorldState(worldState, newAddr).Assets["a"])
+	require.Empty(t, GetAssetsFromWorldState(worldState, member.addr.Hex).Assets)
+	require.Equal(t, []string{newAddr},
+		GetAssetsFromWorldState(worldState, other.addr.Hex).Policies["b"].AllowedInitiators)
+
+	require.Equal(t, newAddr, ResolveAddress(worldState, member.addr.Hex))
+
+	// > the old address can no longer be reused
+
+	err = NewTransactionRevokeKey(&other, KeyRevocation{
+		Address:    newAddr,
+		NewAddress: member.addr.Hex,
+	}).Exec(worldState)
+	require.Error(t, err)
+}
+
+func Test_Txn_Execution_RevokeKey(t *testing.T) {
+	accounts := make([]Account, 3)
+	participants := map[string]string{}
+	for i := range accounts {
+		_, accounts[i] = newKeyAccount(t)
+		participants[accounts[i].addr.Hex] = ""
+	}
+	leaked := &accounts[0]
+	leaked.balance = 10
+	_, newAccount := newKeyAccount(t)
+	newAddr := newAccount.addr.Hex
+
+	// create worldstate
+	worldState := storage.NewBasicKV()
+	config := *NewChainConfig(participants, 1, "2h", 0, 1)
+	worldState.Put(STATE_CONFIG_KEY, config)
+	for _, account := range accounts {
+		worldState.Put(account.addr.Hex, account)
+	}
+	revocation := KeyRevocation{Address: leaked.addr.Hex, NewAddress: newAddr}
+
+	// > the owner of the key cannot approve
+
+	err := NewTransactionRevokeKey(leaked, revocation).Exec(worldState)
+	require.Error(t, err)
+
+	// > the identity moves once all other participants approved
+
+	err = NewTransactionRevokeKey(&accounts[1], revocation).Exec(worldState)
+	require.NoError(t, err)
+	require.Contains(t, GetConfigFromWorldState(worldState).Participants, leaked.addr.Hex)
+
+	err = NewTransactionRevokeKey(GetAccountFromWorldState(worldState, accounts[1].addr.Hex),
+		revocation).Exec(worldState)
+	require.Error(t, err)
+
+	err = NewTransactionRevokeKey(&accounts[2], revocation).Exec(worldState)
+	require.NoError(t, err)
+	require.NotContains(t, GetConfigFromWorldState(worldState).Participants, leaked.addr.Hex)
+	require.Equal(t, Amount(10), GetAccountFromWorldState(worldState, newAddr).balance)
+
+	record, ok := GetRotationFromWorldState(worldState, leaked.addr.Hex)
+	require.True(t, ok)
+	require.True(t, record.Revoked)
+	_, ok = worldState.Get(revocationKeyFromAddrs(leaked.addr.Hex, newAddr))
+	require.False(t, ok)
+}
+
+func Test_Txn_Revocation_Threshold(t *testing.T) {
+	participants := map[string]string{}
+	config := NewChainConfig(participants, 1, "2h", 0, 0)
+	require.Equal(t, DEFAULT_REVOCATION_THRESHOLD, config.RevocationThreshold)
+
+	// > the join threshold does not matter, a majority of the others must
+	// approve whatever the config
+	for i := 0; i < 5; i++ {
+		participants[fmt.Sprintf("participant%d", i)] = ""
+	}
+	expected := map[float64]int{0: 3, 0.5: 3, DEFAULT_REVOCATION_THRESHOLD: 3, 1: 4}
+	for threshold, required := range expected {
+		config.RevocationThreshold = threshold
+		require.Equal(t, required, revocationThreshold(config), threshold)
+	}
+
+	config.Participants = map[string]string{"a": "", "b": ""}
+	config.RevocationThreshold = 0
+	require.Equal(t, 1, revocationThreshold(config))
+}
@@ -1,9 +1,6 @@
 package permissioned
 
 import (
-	"crypto/sha256"
-	"encoding/hex"
-	"encoding/json"
 	"fmt"
 	"sort"
 
@@ -15,15 +12,18 @@ import (
 
 type MPCPropose struct {
 	Initiator  string
-	Budget     float64
+	Budget     Amount
 	Expression string
 	Prime      string
+	// participation fee paid to each committee member. 0 means the
+	// MPCParticipationGain of the chain config
+	Fee Amount
 }
 
 // String implements Describable.String()
 func (p MPCPropose) String() string {
-	return fmt.Sprintf("Initiator: %s, Budget: %f, Expression: %s, Prime: %s\n",
-		p.Initiator, p.Budget, p.Expression, p.Prime)
+	return fmt.Sprintf("Initiator: %s, Budget: %s, Expression: %s, Prime: %s, Fee: %s\n",
+		p.Initiator, p.Budget, p.Expression, p.Prime, p.Fee)
 }
 
 func NewTransactionPreMPC(initiator *Account, data MPCPropose) *Transaction {
@@ -43,29 +43,80 @@ func execPreMPC(worldState storage.KVStore, config *ChainConfig, txn *Transactio
 	}
 
 	// calculate the total price need for the transaction
-	prices, totalPrice, err := CalculateTotalPrice(worldState, record.Expression)
+	prices, totalPrice, err := CalculateTotalPrice(worldState, txn.From, record.Expression, record.Fee)
 	if err != nil {
 		return err
 	}
+	fee, err := GetParticipationFee(config, record.Fee)
+	if err != nil {
+		return err
+	}
+	// asset owners decide who can use their assets and how
+	err = CheckAssetPolicies(worldState, txn.From, record.Expression)
+	if err != nil {
+		return err
+	}
+
+	// initiator and asset owners must be inside the MPC committee. A
+	// multisig initiator only pays, the committee computes for it
+	committee := GetMPCCommittee(worldState, config)
+	if _, ok := committee[txn.From]; !ok && !IsMultisig(worldState, txn.From) {
+		return fmt.Errorf("initiator %s does not have enough stake to join MPC", txn.From)
+	}
+	// the node holding the values of a multisig account computes for it
+	holders := map[string]string{}
+	for owner := range prices {
+		holder := GetAssetsFromWorldState(worldState, owner).GetHolder()
+		if _, ok := committee[holder]; !ok {
+			return fmt.Errorf("asset holder %s does not have enough stake to join MPC", holder)
+		}
+		if holder != owner {
+			holders[owner] = holder
+		}
+	}
 	if totalPrice > txn.Value {
-		return fmt.Errorf("price not enough to pay for MPC. Expected: %f. Got: %f", totalPrice, txn.Value)
+		return fmt.Errorf("price not enough to pay for MPC. Expected: %s. Got: %s", totalPrice, txn.Value)
 	}
 
-	// lock balance to avoid double spending
-	// FIXME: now do not support customized fee
-	err = lockBalance(worldState, txn.From, totalPrice)
+	// lock balance to avoid double spending. Free MPCs lock nothing
+	if totalPrice > 0 {
+		err = lockBalance(worldState, txn.From, totalPrice)
+		if err != nil {
+			return err
+		}
+	}
+	// volume discounts depend on the number of past uses
+	err = recordAssetsUsage(worldState, txn.From, record.Expression)
+	if err != nil {
+		return err
+	}
+	owners, err := getExprAssetOwners(worldState, record.Expression)
 	if err != nil {
 		return err
 	}
+	assets := make([]string, 0, len(owners))
+	for asset := range owners {
+		assets = append(assets, asset)
+	}
+	sort.Strings(assets)
 	// add MPC record to worldState
 	// use txnHash has uniqID
+	deadline := uint(0)
+	if config.EndorseDeadline > 0 {
+		deadline = GetHeightFromWorldState(worldState) + 1 + config.EndorseDeadline
+	}
 	err = worldState.Put(mpcKeyFromUniqID(txn.Hash()), MPCEndorsement{
-		Peers:     config.Participants,
+		Peers:     committee,
 		Endorsers: map[string]struct{}{},
+		Slashed:   map[string]struct{}{},
 		Initiator: txn.From,
 		Budget:    prices,
-		Fee:       config.MPCParticipationGain,
+		Holders:   holders,
+		Fee:       fee,
 		Locked:    true,
+		Prime:     record.Prime,
+		Assets:    assets,
+		Deadline:  deadline,
 	})
 	if err != nil {
 		panic(err)
@@ -74,13 +125,6 @@ func execPreMPC(worldState storage.KVStore, config *ChainConfig, txn *Transactio
 	return nil
 }
 
-func unmarshalPreMPC(data json.RawMessage) (interface{}, error) {
-	var p MPCPropose
-	err := json.Unmarshal(data, &p)
-
-	return p, err
-}
-
 // -----------------------------------------------------------------------------
 // Transaction Polymophism - PostMPC
 
@@ -116,13 +160,6 @@ func execPostMPC(worldState storage.KVStore, config *ChainConfig, txn *Transacti
 	return nil
 }
 
-func unmarshalPostMPC(data json.RawMessage) (interface{}, error) {
-	var p MPCRecord
-	err := json.Unmarshal(data, &p)
-
-	return p, err
-}
-
 // -----------------------------------------------------------------------------
 // Utilities - MPC
 
@@ -132,50 +169,27 @@ type MPCEndorsement struct {
 	// FIXME: not copy peers. Use Config ID
 	Peers     map[string]string
 	Endorsers map[string]struct{}
+	Slashed   map[string]struct{}
 	Initiator string
 	Locked    bool
-	Budget    map[string]float64
-	Fee       float64
+	Budget    map[string]Amount
+	// committee members holding the values of the assets of multisig
+	// accounts. The price of the assets goes to the accounts once the
+	// holder endorsed
+	Holders map[string]string
+	Fee     Amount
+	Prime   string
+	// keys of the assets the MPC uses. Their owners cannot remove or
+	// transfer them before endorsing, their nodes still need the values
+	Assets []string
+	// last block height at which endorsement is accepted. 0 means no deadline.
+	// Late members are slashed and their share goes back to the initiator
+	Deadline uint
 }
 
 // Hash implements Hashable.Hash()
 func (e MPCEndorsement) Hash() string {
-	h := sha256.New()
-
-	keys := make([]string, 0, len(e.Peers))
-	for k := range e.Peers {
-		keys = append(keys, k)
-	}
-	sort.Strings(keys)
-	for _, participant := range keys {
-		h.Write([]byte(participant))
-	}
-
-	keys = make([]string, 0, len(e.Endorsers))
-	for k := range e.Endorsers {
-		keys = append(keys, k)
-	}
-	sort.Strings(keys)
-	for _, participant := range keys {
-		h.Write([]byte(participant))
-	}
-
-	h.Write([]byte(e.Initiator))
-	h.Write([]byte(fmt.Sprintf("%t", e.Locked)))
-
-	keys = make([]string, 0, len(e.Budget))
-	for k := range e.Endorsers {
-		keys = append(keys, k)
-	}
-	sort.Strings(keys)
-	for _, participant := range keys {
-		h.Write([]byte(participant))
-		h.Write([]byte(fmt.Sprintf("%f", e.Budget[participant])))
-	}
-
-	h.Write([]byte(fmt.Sprintf("%f", e.Fee)))
-
-	return hex.EncodeToString(h.Sum(nil))
+	return hashCanonical(e)
 }
 
 // Copy implements Copyable.Copy()
@@ -184,17 +198,38 @@ func (e MPCEndorsement) Copy() storage.Copyable {
 	for endorser := range e.Endorsers {
 		endorsers[endorser] = struct{}{}
 	}
-	budget := map[string]float64{}
+	slashed := map[string]struct{}{}
+	for offender := range e.Slashed {
+		slashed[offender] = struct{}{}
+	}
+	budget := map[string]Amount{}
 	for k, v := range e.Budget {
 		budget[k] = v
 	}
+	var holders map[string]string
+	if e.Holders != nil {
+		holders = map[string]string{}
+		for owner, holder := range e.Holders {
+			holders[owner] = holder
+		}
+	}
+	var assets []string
+	if e.Assets != nil {
+		assets = make([]string, len(e.Assets))
+		copy(assets, e.Assets)
+	}
 	endorsement := MPCEndorsement{
 		Peers:     e.Peers,
 		Endorsers: endorsers,
+		Slashed:   slashed,
 		Initiator: e.Initiator,
 		Budget:    budget,
+		Holders:   holders,
 		Fee:       e.Fee,
 		Locked:    e.Locked,
+		Prime:     e.Prime,
+		Assets:    assets,
+		Deadline:  e.Deadline,
 	}
 	return endorsement
 }
@@ -208,27 +243,128 @@ func GetMPCEndorsementFromWorldState(worldState storage.KVStore, key string) (*M
 	return &endorsement, nil
 }
 
-func CalculateTotalPrice(worldState storage.KVStore, expression string) (map[string]float64, float64, error) {
-	prices, err := calculateExprPrices(worldState, expression)
+// CalculateTotalPrice computes the price the initiator pays for the expression:
+// the price of each asset under its owner's schedule plus the participation
+// fee for every committee member
+func CalculateTotalPrice(worldState storage.KVStore, initiator string,
+	expression string, fee Amount) (map[string]Amount, Amount, error) {
+	config := GetConfigFromWorldState(worldState)
+	fee, err := GetParticipationFee(config, fee)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	prices, err := calculateExprPrices(worldState, initiator, expression)
 	if err != nil {
 		return nil, 0, err
 	}
-	var valuePrice float64 = 0
+	var valuePrice Amount = 0
 	for _, price := range prices {
-		valuePrice += price
+		valuePrice, err = valuePrice.Add(price)
+		if err != nil {
+			return nil, 0, err
+		}
 	}
 
-	config := GetConfigFromWorldState(worldState)
-	totalPrice := valuePrice + config.MPCParticipationGain*float64(len(config.Participants))
+	committee := GetMPCCommittee(worldState, config)
+	fees, err := fee.Mul(len(committee))
+	if err != nil {
+		return nil, 0, err
+	}
+	totalPrice, err := valuePrice.Add(fees)
+	if err != nil {
+		return nil, 0, err
+	}
 
 	return prices, totalPrice, nil
 }
 
+// GetParticipationFee returns the fee offered to each committee member.
+// The initiator can offer more than the chain default, but never less
+func GetParticipationFee(config *ChainConfig, fee Amount) (Amount, error) {
+	if fee == 0 {
+		return config.MPCParticipationGain, nil
+	}
+	err := fee.Validate()
+	if err != nil {
+		return 0, fmt.Errorf("invalid participation fee: %v", err)
+	}
+	if fee < config.MPCParticipationGain {
+		return 0, fmt.Errorf("participation fee lower than the minimum. Expected: %s. Got: %s",
+			config.MPCParticipationGain, fee)
+	}
+	return fee, nil
+}
+
+// claimShare moves the amount locked for the committee member, the price of
+// its assets and the participation fee, from the initiator to the member. The
+// price of the assets it holds for multisig accounts goes to the accounts.
+// With refund, everything goes back to the initiator instead
+func claimShare(worldState storage.KVStore, initiator *Account, endorsement *MPCEndorsement,
+	peer string, refund bool) error {
+	payee := func(account string) string {
+		if refund {
+			return endorsement.Initiator
+		}
+		return account
+	}
+
+	share, err := endorsement.Budget[peer].Add(endorsement.Fee)
+	if err != nil {
+		return err
+	}
+	// free assets without fee lock nothing
+	if share > 0 {
+		err = claimAward(worldState, initiator, payee(peer), share)
+		if err != nil {
+			return err
+		}
+	}
+
+	owners := make([]string, 0, len(endorsement.Holders))
+	for owner, holder := range endorsement.Holders {
+		if holder == peer && endorsement.Budget[owner] > 0 {
+			owners = append(owners, owner)
+		}
+	}
+	sort.Strings(owners)
+	for _, owner := range owners {
+		err = claimAward(worldState, initiator, payee(owner), endorsement.Budget[owner])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func mpcKeyFromUniqID(uniqID string) string {
 	return fmt.Sprintf("ongoging-mpc|%s", uniqID)
 }
 
-func calculateExprPrices(worldState storage.KVStore, expression string) (map[string]float64, error) {
+func calculateExprPrices(worldState storage.KVStore, initiator string,
+	expression string) (map[string]Amount, error) {
+	owners, err := getExprAssetOwners(worldState, expression)
+	if err != nil {
+		return nil, err
+	}
+
+	prices := make(map[string]Amount)
+	for asset, owner := range owners {
+		record := GetAssetsFromWorldState(worldState, owner)
+		price, _ := record.PriceFor(asset, initiator)
+		if price > 0 {
+			prices[owner], err = prices[owner].Add(price)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return prices, nil
+}
+
+// getExprAssetOwners maps each variable of the expression to its owner
+func getExprAssetOwners(worldState storage.KVStore, expression string) (map[string]string, error) {
 	_, variables, err := GetPostfixAndVariables(expression)
 	if err != nil {
 		return nil, err
@@ -237,22 +373,17 @@ func calculateExprPrices(worldState storage.KVStore, expression string) (map[str
 	// FIXME: now only support all variable names to be distinct
 	assets := GetAllAssetsFromWorldState(worldState)
 
-	prices := make(map[string]float64)
+	owners := make(map[string]string)
 	for peer, peerAssets := range assets {
 		for asset := range variables {
-			price, ok := peerAssets[asset]
-			if !ok {
+			if _, ok := peerAssets[asset]; !ok {
 				continue
 			}
 			delete(variables, asset)
-
-			if price > 0 {
-				prices[peer] += price
-			}
+			owners[asset] = peer
 		}
 	}
 	if len(variables) > 0 {
-		fmt.Println(variables)
 		missing := ""
 		for v := range variables {
 			missing += fmt.Sprintf(", %s", v)
@@ -261,7 +392,31 @@ func calculateExprPrices(worldState storage.KVStore, expression string) (map[str
 		return nil, fmt.Errorf("expression \"%s\" needs missing variables: {%s}", expression, missing)
 	}
 
-	return prices, nil
+	return owners, nil
+}
+
+func recordAssetsUsage(worldState storage.KVStore, initiator string, expression string) error {
+	owners, err := getExprAssetOwners(worldState, expression)
+	if err != nil {
+		return err
+	}
+
+	records := make(map[string]*AssetsRecord)
+	for asset, owner := range owners {
+		record, ok := records[owner]
+		if !ok {
+			record = GetAssetsFromWorldState(worldState, owner)
+			records[owner] = record
+		}
+		record.recordUsage(asset, initiator)
+	}
+	for owner, record := range records {
+		err = worldState.Put(AssetsKeyFromUniqID(owner), *record)
+		if err != nil {
+			panic(err)
+		}
+	}
+	return nil
 }
 
 func updateMPCEndorsement(worldState storage.KVStore, key string, accountID string) error {
@@ -275,41 +430,36 @@ func updateMPCEndorsement(worldState storage.KVStore, key string, accountID stri
 	if _, ok := endorsement.Endorsers[accountID]; ok {
 		return fmt.Errorf("%s has already endorsed in MPC %s. Potentially a double-claim", accountID, key)
 	}
+	if _, ok := endorsement.Slashed[accountID]; ok {
+		return fmt.Errorf("%s has been slashed in MPC %s", accountID, key)
+	}
+	if endorsement.Deadline > 0 && GetHeightFromWorldState(worldState)+1 > endorsement.Deadline {
+		return fmt.Errorf("%s endorses MPC %s after deadline %d", accountID, key, endorsement.Deadline)
+	}
 
 	endorsement.Endorsers[accountID] = struct{}{}
+	err = worldState.Put(key, *endorsement)
+	if err != nil {
+		panic(err)
+	}
 	initiator := GetAccountFromWorldState(worldState, endorsement.Initiator)
 	if !endorsement.Locked {
-		err := claimAward(worldState, initiator, accountID, endorsement.Budget[accountID]+endorsement.Fee)
+		err := claimShare(worldState, initiator, endorsement, accountID, false)
 		if err != nil {
 			return err
 		}
-		if len(endorsement.Endorsers) == len(endorsement.Peers) {
-			err := worldState.Del(key)
-			if err != nil {
-				panic(err)
-			}
-		}
-		return nil
+		return settleMPCEndorsement(worldState, key, endorsement)
 	}
 
 	threshold := float64(len(endorsement.Peers)) * AWARD_UNLOCK_THRESHOLD
 	if float64(len(endorsement.Endorsers)) > threshold {
 		for endorser := range endorsement.Endorsers {
-			err = claimAward(worldState, initiator, endorser, endorsement.Budget[endorser]+endorsement.Fee)
+			err := claimShare(worldState, initiator, endorsement, endorser, false)
 			if err != nil {
 				return err
 			}
 		}
 
-		// delete if fully claimed
-		if len(endorsement.Endorsers) == len(endorsement.Peers) {
-			err := worldState.Del(key)
-			if err != nil {
-				panic(err)
-			}
-			return nil
-		}
-
 		// unlock endorsement
 		endorsement.Locked = false
 		err = worldState.Put(key, *endorsement)
@@ -317,5 +467,32 @@ func updateMPCEndorsement(worldState storage.KVStore, key string, accountID stri
 			panic(err)
 		}
 	}
+	return settleMPCEndorsement(worldState, key, endorsement)
+}
+
+// settleMPCEndorsement deletes the record once every committee member
+// endorsed or was slashed. No endorsement can come anymore, so endorsers
+// still waiting for the unlock threshold are paid
+func settleMPCEndorsement(worldState storage.KVStore, key string, endorsement *MPCEndorsement) error {
+	for peer := range endorsement.Peers {
+		if !hasEndorsedOrSlashed(endorsement, peer) {
+			return nil
+		}
+	}
+
+	if endorsement.Locked {
+		initiator := GetAccountFromWorldState(worldState, endorsement.Initiator)
+		for endorser := range endorsement.Endorsers {
+			err := claimShare(worldState, initiator, endorsement, endorser, false)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	err := worldState.Del(key)
+	if err != nil {
+		panic(err)
+	}
 	return nil
 }
@@ -0,0 +1,249 @@
+package permissioned
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"go.dedis.ch/cs438/storage"
+)
+
+// -----------------------------------------------------------------------------
+// Transaction Polymophism - RegMultisig
+
+// MultisigPolicy lists the owners of a multisig account and the number of
+// them that must sign each of its transactions. The chain has no transfer of
+// coins: the deposit of the account and the income of its assets are locked
+// to MPC use, they only pay for the MPCs the account initiates
+type MultisigPolicy struct {
+	Owners    []string
+	Threshold uint
+}
+
+// Copy implements Copyable.Copy()
+func (p MultisigPolicy) Copy() storage.Copyable {
+	owners := make([]string, len(p.Owners))
+	copy(owners, p.Owners)
+	return MultisigPolicy{
+		Owners:    owners,
+		Threshold: p.Threshold,
+	}
+}
+
+// Hash implements Hashable.Hash
+func (p MultisigPolicy) Hash() string {
+	return hashCanonical(p)
+}
+
+// String implements Describable.String()
+func (p MultisigPolicy) String() string {
+	return fmt.Sprintf("Owners: %v, Threshold: %d", p.Owners, p.Threshold)
+}
+
+// IsOwner checks whether the address is an owner of the account
+func (p MultisigPolicy) IsOwner(addr string) bool {
+	return containsString(p.Owners, addr)
+}
+
+// NewTransactionRegMultisig creates a multisig account owned by the
+// participants of the policy. The value is moved from the creator's balance
+// to the new account. The address of the account is the To of the
+// transaction, see MultisigAddress
+func NewTransactionRegMultisig(creator *Account, policy MultisigPolicy, deposit Amount) *Transaction {
+	owners := make([]string, len(policy.Owners))
+	copy(owners, policy.Owners)
+	sort.Strings(owners)
+	policy.Owners = owners
+
+	addr := MultisigAddress(creator.addr.Hex, creator.nonce)
+	return NewTransaction(
+		creator,
+		&addr,
+		TxnTypeRegMultisig,
+		deposit,
+		policy,
+	)
+}
+
+func execRegMultisig(worldState storage.KVStore, config *ChainConfig, txn *Transaction) error {
+	policy := txn.Data.(MultisigPolicy)
+
+	if txn.To != MultisigAddress(txn.From, txn.Nonce).Hex {
+		return fmt.Errorf("multisig account of %s with nonce %d must have address %s. Got: %s",
+			txn.From, txn.Nonce, MultisigAddress(txn.From, txn.Nonce).Hex, txn.To)
+	}
+	if _, ok := worldState.Get(MultisigKeyFromAddr(txn.To)); ok {
+		return fmt.Errorf("multisig account %s already exists", txn.To)
+	}
+	if policy.Threshold == 0 || int(policy.Threshold) > len(policy.Owners) {
+		return fmt.Errorf("multisig threshold must be between 1 and %d. Got: %d",
+			len(policy.Owners), policy.Threshold)
+	}
+	if !sort.StringsAreSorted(policy.Owners) {
+		return fmt.Errorf("multisig owners must be sorted")
+	}
+	for i, owner := range policy.Owners {
+		if i > 0 && policy.Owners[i-1] == owner {
+			return fmt.Errorf("multisig owner %s is given twice", owner)
+		}
+		// owners sign with their node key
+		if _, ok := config.Participants[owner]; !ok {
+			return fmt.Errorf("multisig owner %s is not a participant", owner)
+		}
+	}
+
+	// fund the new account
+	if txn.Value < 0 {
+		return fmt.Errorf("multisig deposit must not be negative. Got: %s", txn.Value)
+	}
+	creator := GetAccountFromWorldState(worldState, txn.From)
+	if creator.balance < txn.Value {
+		return fmt.Errorf("%s balance not enough to fund the multisig account. Remain balance: %s",
+			txn.From, creator.balance)
+	}
+	account := GetAccountFromWorldState(worldState, txn.To)
+	balance, err := account.balance.Add(txn.Value)
+	if err != nil {
+		return err
+	}
+	creator.balance -= txn.Value
+	account.balance = balance
+
+	err = worldState.Put(txn.From, *creator)
+	if err != nil {
+		panic(err)
+	}
+	err = worldState.Put(txn.To, *account)
+	if err != nil {
+		panic(err)
+	}
+	err = worldState.Put(MultisigKeyFromAddr(txn.To), policy)
+	if err != nil {
+		panic(err)
+	}
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// Utilities - Multisig
+
+// multisigTxnTypes are the transactions a multisig account can send. The
+// others need the node of the sender, e.g. to mine or to join an MPC
+// committee. The values of the assets of the account are held by the node of
+// one of its owners, see AssetsRecord.Holder
+var multisigTxnTypes = map[TxnType]struct{}{
+	TxnTypePreMPC:        {},
+	TxnTypeRegAssets:     {},
+	TxnTypeRegPricing:    {},
+	TxnTypeUpdateAssets:  {},
+	TxnTypeRemoveAssets:  {},
+	TxnTypeTransferAsset: {},
+	TxnTypeCancel:        {},
+}
+
+// MultisigAddress returns the address of the multisig account created by
+// the creator with the given nonce. It cannot collide with the address of
+// a key
+func MultisigAddress(creator string, nonce uint) Address {
+	addr := crypto.CreateAddress(common.HexToAddress(creator), uint64(nonce))
+	return Address{Hex: addr.Hex()}
+}
+
+func MultisigKeyFromAddr(addr string) string {
+	return fmt.Sprintf("multisig|%s", addr)
+}
+
+// GetMultisigFromWorldState returns the policy of the multisig account. It
+// returns false if the address is not a multisig account
+func GetMultisigFromWorldState(worldState storage.KVStore, addr string) (*MultisigPolicy, bool) {
+	object, ok := worldState.Get(MultisigKeyFromAddr(addr))
+	if !ok {
+		return nil, false
+	}
+	policy := object.(MultisigPolicy).Copy().(MultisigPolicy)
+	return &policy, true
+}
+
+// IsMultisig checks whether the address is a multisig account
+func IsMultisig(worldState storage.KVStore, addr string) bool {
+	_, ok := worldState.Get(MultisigKeyFromAddr(addr))
+	return ok
+}
+
+// Cosign adds the signature of an owner to a transaction of a multisig
+// account. The owners sign the same transaction off-chain until the
+// threshold is reached, then any of them sends it
+func (signedTxn *SignedTransaction) Cosign(privateKey *ecdsa.PrivateKey) error {
+	signature, err := crypto.Sign(signedTxn.Txn.HashBytes(), privateKey)
+	if err != nil {
+		return err
+	}
+
+	// signatures are deterministic
+	for _, existing := range signedTxn.Signatures {
+		if string(existing) == string(signature) {
+			return nil
+		}
+	}
+	signedTxn.Signatures = append(signedTxn.Signatures, signature)
+	return nil
+}
+
+// Cosigners returns the addresses that signed the transaction of a
+// multisig account
+func (signedTxn *SignedTransaction) Cosigners() ([]string, error) {
+	digestHash := signedTxn.Txn.HashBytes()
+	signers := make([]string, 0, len(signedTxn.Signatures))
+	for _, signature := range signedTxn.Signatures {
+		if len(signature) != crypto.SignatureLength {
+			return nil, fmt.Errorf("invalid signature length: %d", len(signature))
+		}
+		publicKey, err := crypto.SigToPub(digestHash, signature)
+		if err != nil {
+			return nil, err
+		}
+		// verify sig input needs to be in [R || S] format
+		if !crypto.VerifySignature(crypto.FromECDSAPub(publicKey), digestHash,
+			signature[:len(signature)-1]) {
+			return nil, fmt.Errorf("transaction %s has an invalid signature", signedTxn.Txn.ID)
+		}
+		signers = append(signers, NewAddress(publicKey).Hex)
+	}
+	return signers, nil
+}
+
+// verifyMultisig checks that enough distinct owners of the multisig
+// sender signed the transaction
+func (signedTxn *SignedTransaction) verifyMultisig(policy *MultisigPolicy) error {
+	txn := signedTxn.Txn
+	if _, ok := multisigTxnTypes[txn.Type]; !ok {
+		return fmt.Errorf("multisig account %s cannot send transaction %s", txn.From, txn.Type)
+	}
+	if len(signedTxn.Signature) > 0 {
+		return fmt.Errorf("transaction %s of multisig account %s has a single signature",
+			txn.ID, txn.From)
+	}
+
+	signers, err := signedTxn.Cosigners()
+	if err != nil {
+		return err
+	}
+	owners := map[string]struct{}{}
+	for _, signer := range signers {
+		if !policy.IsOwner(signer) {
+			return fmt.Errorf("transaction %s is signed by %s, not an owner of %s",
+				txn.ID, signer, txn.From)
+		}
+		if _, ok := owners[signer]; ok {
+			return fmt.Errorf("transaction %s is signed twice by %s", txn.ID, signer)
+		}
+		owners[signer] = struct{}{}
+	}
+	if uint(len(owners)) < policy.Threshold {
+		return fmt.Errorf("transaction %s of multisig account %s has %d of %d signatures",
+			txn.ID, txn.From, len(owners), policy.Threshold)
+	}
+	return nil
+}
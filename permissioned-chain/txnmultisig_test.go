@@ -0,0 +1,190 @@
+package permissioned
+
+import (
+	"crypto/ecdsa"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/cs438/storage"
+)
+
+func Test_Txn_Multisig(t *testing.T) {
+	keys := make([]*ecdsa.PrivateKey, 3)
+	accounts := make([]Account, 3)
+	participants := map[string]string{}
+	for i := range keys {
+		privKey, err := crypto.GenerateKey()
+		require.NoError(t, err)
+		keys[i] = privKey
+		accounts[i] = *NewAccount(*NewAddress(&privKey.PublicKey))
+		accounts[i].balance = 100
+		participants[accounts[i].addr.Hex] = ""
+	}
+
+	// create worldstate
+	worldState := storage.NewBasicKV()
+	config := *NewChainConfig(participants, 1, "2h", 0, 10)
+	worldState.Put(STATE_CONFIG_KEY, config)
+	for _, account := range accounts {
+		worldState.Put(account.addr.Hex, account)
+	}
+
+	sign := func(txn *Transaction, signers ...int) *SignedTransaction {
+		signedTxn, err := txn.Sign(nil)
+		require.NoError(t, err)
+		for _, i := range signers {
+			require.NoError(t, signedTxn.Cosign(keys[i]))
+		}
+		return signedTxn
+	}
+
+	// > the threshold must be reachable by participants
+
+	creator := &accounts[0]
+	policy := MultisigPolicy{
+		Owners:    []string{accounts[0].addr.Hex, accounts[1].addr.Hex},
+		Threshold: 3,
+	}
+	err := NewTransactionRegMultisig(creator, policy, 10).Exec(worldState)
+	require.Error(t, err)
+	policy.Threshold = 2
+	policy.Owners = append(policy.Owners, "unknown")
+	err = NewTransactionRegMultisig(creator, policy, 10).Exec(worldState)
+	require.Error(t, err)
+
+	// > the deposit is moved to the new account
+
+	policy.Owners = policy.Owners[:2]
+	txn := NewTransactionRegMultisig(creator, policy, 10)
+	signedTxn, err := txn.Sign(keys[0])
+	require.NoError(t, err)
+	err = signedTxn.Verify(worldState)
+	require.NoError(t, err)
+
+	addr := txn.To
+	require.Equal(t, MultisigAddress(creator.addr.Hex, 0).Hex, addr)
+	require.True(t, IsMultisig(worldState, addr))
+	require.Equal(t, Amount(90), GetAccountFromWorldState(worldState, creator.addr.Hex).balance)
+	multisig := GetAccountFromWorldState(worldState, addr)
+	require.Equal(t, Amount(10), multisig.balance)
+
+	// > transactions need the threshold of owner signatures
+
+	owned := NewAssetsRecord(accounts[2].addr.Hex)
+	owned.Add(map[string]Amount{"a": 1})
+	worldState.Put(AssetsKeyFromUniqID(accounts[2].addr.Hex), *owned)
+	preMPC := NewTransactionPreMPC(multisig, MPCPropose{
+		Initiator:  addr,
+		Budget:     1,
+		Expression: "a",
+		Prime:      "1000000009",
+	})
+	err = sign(preMPC, 0).Verify(worldState.Copy())
+	require.Error(t, err)
+	err = sign(preMPC, 0, 2).Verify(worldState.Copy())
+	require.Error(t, err)
+	single, err := preMPC.Sign(keys[0])
+	require.NoError(t, err)
+	err = single.Verify(worldState.Copy())
+	require.Error(t, err)
+
+	// > cosigning twice does not count twice
+	twice := sign(preMPC, 1, 1)
+	require.Len(t, twice.Signatures, 1)
+	err = twice.Verify(worldState.Copy())
+	require.Error(t, err)
+
+	// > the multisig account pays an MPC computed by the committee
+	err = sign(preMPC, 1, 0).Verify(worldState)
+	require.NoError(t, err)
+	multisig = GetAccountFromWorldState(worldState, addr)
+	require.Equal(t, Amount(1), multisig.lockedBalance)
+	require.Equal(t, uint(1), multisig.nonce)
+	endorsement, err := GetMPCEndorsementFromWorldState(worldState, mpcKeyFromUniqID(preMPC.Hash()))
+	require.NoError(t, err)
+	require.NotContains(t, endorsement.Peers, addr)
+
+	// > node operations are not allowed
+
+	err = sign(NewTransactionStake(multisig, 5), 0, 1).Verify(worldState)
+	require.Error(t, err)
+
+	// > assets need an owner of the account whose node holds the values
+
+	holder := accounts[1].addr.Hex
+	regAssets := func(holder string) *SignedTransaction {
+		multisig = GetAccountFromWorldState(worldState, addr)
+		return sign(NewTransactionRegAssetsWithDetails(multisig, AssetsRegistration{
+			Assets: map[string]Amount{"b": 2},
+			Holder: holder,
+		}), 0, 1)
+	}
+	require.Error(t, regAssets("").Verify(worldState))
+	require.Error(t, regAssets(accounts[2].addr.Hex).Verify(worldState))
+	require.NoError(t, regAssets(holder).Verify(worldState))
+	require.Equal(t, holder, GetAssetsFromWorldState(worldState, addr).GetHolder())
+	require.Equal(t, Amount(2), GetAllAssetsFromWorldState(worldState)[addr]["b"])
+
+	// > the holder cannot change while the account has assets, and
+	// participants hold their own values
+	require.Error(t, regAssets(accounts[0].addr.Hex).Verify(worldState))
+	owner := GetAccountFromWorldState(worldState, accounts[2].addr.Hex)
+	signedTxn, err = NewTransactionRegAssetsWithDetails(owner, AssetsRegistration{
+		Assets: map[string]Amount{"c": 1},
+		Holder: holder,
+	}).Sign(keys[2])
+	require.NoError(t, err)
+	require.Error(t, signedTxn.Verify(worldState))
+
+	// > an MPC on the assets of the account pays the account once the
+	// holder endorsed
+
+	initiator := GetAccountFromWorldState(worldState, accounts[2].addr.Hex)
+	useAssets := NewTransactionPreMPC(initiator, MPCPropose{
+		Initiator:  initiator.addr.Hex,
+		Budget:     2,
+		Expression: "b",
+		Prime:      "1000000009",
+	})
+	signedTxn, err = useAssets.Sign(keys[2])
+	require.NoError(t, err)
+	require.NoError(t, signedTxn.Verify(worldState))
+	endorsement, err = GetMPCEndorsementFromWorldState(worldState, mpcKeyFromUniqID(useAssets.ID))
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{addr: holder}, endorsement.Holders)
+
+	// > the holder needs the value until it endorsed
+	transfer := func(from int, to string) *SignedTransaction {
+		if from < 0 {
+			multisig = GetAccountFromWorldState(worldState, addr)
+			return sign(NewTransactionTransferAsset(multisig, AssetTransfer{Key: "b", To: to}), 0, 1)
+		}
+		account := GetAccountFromWorldState(worldState, accounts[from].addr.Hex)
+		signedTxn, err := NewTransactionTransferAsset(account, AssetTransfer{Key: "b", To: to}).Sign(keys[from])
+		require.NoError(t, err)
+		return signedTxn
+	}
+	require.Error(t, transfer(-1, accounts[0].addr.Hex).Verify(worldState.Copy()))
+
+	balance := GetAccountFromWorldState(worldState, addr).balance
+	for i := range accounts {
+		endorser := GetAccountFromWorldState(worldState, accounts[i].addr.Hex)
+		signedTxn, err = NewTransactionPostMPC(endorser, MPCRecord{UniqID: useAssets.ID}).Sign(keys[i])
+		require.NoError(t, err)
+		require.NoError(t, signedTxn.Verify(worldState))
+	}
+	require.Equal(t, balance+2, GetAccountFromWorldState(worldState, addr).balance)
+	require.Equal(t, Amount(100), GetAccountFromWorldState(worldState, holder).balance)
+
+	// > assets move between the account and participants
+	require.NoError(t, transfer(-1, accounts[0].addr.Hex).Verify(worldState))
+	require.Empty(t, GetAssetsFromWorldState(worldState, addr).Assets)
+	require.NoError(t, transfer(0, addr).Verify(worldState))
+	require.Equal(t, Amount(2), GetAssetsFromWorldState(worldState, addr).Assets["b"])
+
+	// > owner signatures are only for multisig accounts
+
+	err = sign(NewTransactionStake(&accounts[1], 5), 1).Verify(worldState)
+	require.Error(t, err)
+}
@@ -0,0 +1,432 @@
+package permissioned
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"go.dedis.ch/cs438/storage"
+)
+
+// -----------------------------------------------------------------------------
+// Transaction Polymophism - Stake
+
+func NewTransactionStake(from *Account, amount Amount) *Transaction {
+	return NewTransaction(
+		from,
+		&ZeroAddress,
+		TxnTypeStake,
+		amount,
+		nil,
+	)
+}
+
+func execStake(worldState storage.KVStore, config *ChainConfig, txn *Transaction) error {
+	if txn.Value <= 0 {
+		return fmt.Errorf("stake amount must be positive. Got: %s", txn.Value)
+	}
+
+	account := GetAccountFromWorldState(worldState, txn.From)
+	if account.balance < txn.Value {
+		return fmt.Errorf("%s balance not enough to stake. Remain balance: %s",
+			txn.From, account.balance)
+	}
+
+	stake, err := account.stake.Add(txn.Value)
+	if err != nil {
+		return err
+	}
+	account.balance -= txn.Value
+	account.stake = stake
+	err = worldState.Put(txn.From, *account)
+	if err != nil {
+		panic(err)
+	}
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// Transaction Polymophism - Unstake
+
+func NewTransactionUnstake(from *Account, amount Amount) *Transaction {
+	return NewTransaction(
+		from,
+		&ZeroAddress,
+		TxnTypeUnstake,
+		amount,
+		nil,
+	)
+}
+
+func execUnstake(worldState storage.KVStore, config *ChainConfig, txn *Transaction) error {
+	if txn.Value <= 0 {
+		return fmt.Errorf("unstake amount must be positive. Got: %s", txn.Value)
+	}
+
+	account := GetAccountFromWorldState(worldState, txn.From)
+	if account.stake < txn.Value {
+		return fmt.Errorf("%s stake not enough to withdraw. Remain stake: %s",
+			txn.From, account.stake)
+	}
+
+	// stake is bonded until the participant has endorsed all its MPCs
+	uniqID := getBondedMPC(worldState, txn.From)
+	if uniqID != "" {
+		return fmt.Errorf("stake of %s is bonded to ongoing MPC %s", txn.From, uniqID)
+	}
+
+	balance, err := account.balance.Add(txn.Value)
+	if err != nil {
+		return err
+	}
+	account.stake -= txn.Value
+	account.balance = balance
+	err = worldState.Put(txn.From, *account)
+	if err != nil {
+		panic(err)
+	}
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// Transaction Polymophism - Slash
+
+type SlashReason string
+
+const (
+	// SlashReasonTimeout punishes a committee member that does not
+	// endorse the MPC before the deadline
+	SlashReasonTimeout SlashReason = "timeout"
+	// SlashReasonInvalidShare punishes a committee member that signs
+	// a share which is not a valid element of the MPC field
+	SlashReasonInvalidShare SlashReason = "invalid-share"
+)
+
+type SlashEvidence struct {
+	UniqID   string
+	Offender string
+	Reason   SlashReason
+	Share    *MPCShareProof
+}
+
+// String implements Describable.String()
+func (e SlashEvidence) String() string {
+	return fmt.Sprintf("UniqID: %s, Offender: %s, Reason: %s\n",
+		e.UniqID, e.Offender, e.Reason)
+}
+
+func NewTransactionSlash(reporter *Account, evidence SlashEvidence) *Transaction {
+	return NewTransaction(
+		reporter,
+		&ZeroAddress,
+		TxnTypeSlash,
+		0,
+		evidence,
+	)
+}
+
+func execSlash(worldState storage.KVStore, config *ChainConfig, txn *Transaction) error {
+	evidence := txn.Data.(SlashEvidence)
+
+	key := mpcKeyFromUniqID(evidence.UniqID)
+	endorsement, err := GetMPCEndorsementFromWorldState(worldState, key)
+	if err != nil {
+		return fmt.Errorf("%s reports a non-existing MPC %s", txn.From, evidence.UniqID)
+	}
+	if _, ok := endorsement.Peers[evidence.Offender]; !ok {
+		return fmt.Errorf("%s does not participant in MPC %s", evidence.Offender, evidence.UniqID)
+	}
+	if _, ok := endorsement.Slashed[evidence.Offender]; ok {
+		return fmt.Errorf("%s has already been slashed in MPC %s", evidence.Offender, evidence.UniqID)
+	}
+
+	switch evidence.Reason {
+	case SlashReasonTimeout:
+		err = checkEndorseTimeout(worldState, endorsement, evidence.Offender)
+	case SlashReasonInvalidShare:
+		err = checkInvalidShare(endorsement, &evidence)
+	default:
+		err = fmt.Errorf("invalid slash reason: %s", evidence.Reason)
+	}
+	if err != nil {
+		return err
+	}
+
+	err = slashStake(worldState, config, evidence.Offender, txn.From)
+	if err != nil {
+		return err
+	}
+
+	if endorsement.Slashed == nil {
+		endorsement.Slashed = map[string]struct{}{}
+	}
+	endorsement.Slashed[evidence.Offender] = struct{}{}
+	err = worldState.Put(key, *endorsement)
+	if err != nil {
+		panic(err)
+	}
+
+	// the share locked for the offender goes back to the initiator,
+	// unless the offender endorsed and is paid
+	if _, ok := endorsement.Endorsers[evidence.Offender]; !ok {
+		initiator := GetAccountFromWorldState(worldState, endorsement.Initiator)
+		err = claimShare(worldState, initiator, endorsement, evidence.Offender, true)
+		if err != nil {
+			return err
+		}
+	}
+
+	return settleMPCEndorsement(worldState, key, endorsement)
+}
+
+// -----------------------------------------------------------------------------
+// Utilities - MPC Share Proof
+
+// MPCShareProof is a share signed by its sender. It allows the receiver
+// to prove on chain that the sender distributed an invalid share
+type MPCShareProof struct {
+	UniqID    string
+	Key       string
+	Value     string
+	Signature []byte
+}
+
+// SignMPCShare signs a share with the given private key
+func SignMPCShare(privateKey *ecdsa.PrivateKey, uniqID string,
+	key string, value string) (*MPCShareProof, error) {
+	proof := MPCShareProof{
+		UniqID: uniqID,
+		Key:    key,
+		Value:  value,
+	}
+
+	signature, err := crypto.Sign(proof.HashBytes(), privateKey)
+	if err != nil {
+		return nil, err
+	}
+	proof.Signature = signature
+
+	return &proof, nil
+}
+
+// HashBytes computes the digest signed by the sender
+func (p MPCShareProof) HashBytes() []byte {
+	h := sha256.New()
+
+	h.Write([]byte(p.UniqID))
+	h.Write([]byte(p.Key))
+	h.Write([]byte(p.Value))
+
+	return h.Sum(nil)
+}
+
+// Hash implements Hashable.Hash
+func (p MPCShareProof) Hash() string {
+	return hex.EncodeToString(p.HashBytes())
+}
+
+// Signer returns the address that signed the share
+func (p MPCShareProof) Signer() (string, error) {
+	if len(p.Signature) != crypto.SignatureLength {
+		return "", fmt.Errorf("invalid share signature length: %d", len(p.Signature))
+	}
+
+	digestHash := p.HashBytes()
+	publicKey, err := crypto.SigToPub(digestHash, p.Signature)
+	if err != nil {
+		return "", err
+	}
+	// verify sig input needs to be in [R || S] format
+	sigValid := crypto.VerifySignature(crypto.FromECDSAPub(publicKey), digestHash,
+		p.Signature[:len(p.Signature)-1])
+	if !sigValid {
+		return "", fmt.Errorf("share %s has invalid signature", p.Key)
+	}
+
+	return NewAddress(publicKey).Hex, nil
+}
+
+// IsValidShare checks whether the share value is an element of Zp
+func IsValidShare(value string, prime string) bool {
+	share, ok := new(big.Int).SetString(value, 10)
+	if !ok || share.Sign() < 0 {
+		return false
+	}
+	p, ok := new(big.Int).SetString(prime, 10)
+	if ok && share.Cmp(p) >= 0 {
+		return false
+	}
+	return true
+}
+
+// -----------------------------------------------------------------------------
+// Utilities - Stake
+
+// GetMPCCommittee returns the participants eligible for MPC committees
+// with their encryption keys
+func GetMPCCommittee(worldState storage.KVStore, config *ChainConfig) map[string]string {
+	committee := make(map[string]string)
+	if config == nil {
+		return committee
+	}
+	for participant, pubkey := range config.Participants {
+		if config.MinStake > 0 {
+			account := GetAccountFromWorldState(worldState, participant)
+			if account.stake < config.MinStake {
+				continue
+			}
+		}
+		committee[participant] = pubkey
+	}
+	return committee
+}
+
+// GetLateEndorsers returns the committee members of ongoing MPCs started by
+// the initiator that missed the endorsement deadline and have not been slashed yet
+func GetLateEndorsers(worldState storage.KVStore, initiator string) map[string][]string {
+	height := GetHeightFromWorldState(worldState)
+	lateEndorsers := make(map[string][]string)
+
+	_ = worldState.For(func(key string, value interface{}) error {
+		endorsement, ok := value.(MPCEndorsement)
+		if !ok || !strings.HasPrefix(key, mpcKeyFromUniqID("")) {
+			return nil
+		}
+		if endorsement.Initiator != initiator {
+			return nil
+		}
+		if endorsement.Deadline == 0 || height+1 <= endorsement.Deadline {
+			return nil
+		}
+		uniqID := strings.TrimPrefix(key, mpcKeyFromUniqID(""))
+		for peer := range endorsement.Peers {
+			if hasEndorsedOrSlashed(&endorsement, peer) {
+				continue
+			}
+			lateEndorsers[uniqID] = append(lateEndorsers[uniqID], peer)
+		}
+		sort.Strings(lateEndorsers[uniqID])
+		return nil
+	})
+
+	return lateEndorsers
+}
+
+func checkEndorseTimeout(worldState storage.KVStore, endorsement *MPCEndorsement, offender string) error {
+	if endorsement.Deadline == 0 {
+		return fmt.Errorf("MPC has no endorsement deadline")
+	}
+	if height := GetHeightFromWorldState(worldState) + 1; height <= endorsement.Deadline {
+		return fmt.Errorf("endorsement deadline not reached. Deadline: %d, Height: %d",
+			endorsement.Deadline, height)
+	}
+	if _, ok := endorsement.Endorsers[offender]; ok {
+		return fmt.Errorf("%s has endorsed the MPC in time", offender)
+	}
+	return nil
+}
+
+func checkInvalidShare(endorsement *MPCEndorsement, evidence *SlashEvidence) error {
+	share := evidence.Share
+	if share == nil {
+		return fmt.Errorf("invalid share evidence without share")
+	}
+	if share.UniqID != evidence.UniqID {
+		return fmt.Errorf("share belongs to MPC %s instead of %s", share.UniqID, evidence.UniqID)
+	}
+	signer, err := share.Signer()
+	if err != nil {
+		return err
+	}
+	if signer != evidence.Offender {
+		return fmt.Errorf("share is not signed by offender %s", evidence.Offender)
+	}
+	if IsValidShare(share.Value, endorsement.Prime) {
+		return fmt.Errorf("share %s from %s is valid", share.Key, evidence.Offender)
+	}
+	return nil
+}
+
+func hasEndorsedOrSlashed(endorsement *MPCEndorsement, peer string) bool {
+	if _, ok := endorsement.Endorsers[peer]; ok {
+		return true
+	}
+	_, ok := endorsement.Slashed[peer]
+	return ok
+}
+
+// getBondedMPC returns the ID of an ongoing MPC the account has not endorsed yet
+func getBondedMPC(worldState storage.KVStore, accountID string) string {
+	bonded := ""
+	_ = worldState.For(func(key string, value interface{}) error {
+		endorsement, ok := value.(MPCEndorsement)
+		if !ok || !strings.HasPrefix(key, mpcKeyFromUniqID("")) {
+			return nil
+		}
+		if _, ok := endorsement.Peers[accountID]; !ok {
+			return nil
+		}
+		if hasEndorsedOrSlashed(&endorsement, accountID) {
+			return nil
+		}
+		uniqID := strings.TrimPrefix(key, mpcKeyFromUniqID(""))
+		if bonded == "" || uniqID < bonded {
+			bonded = uniqID
+		}
+		return nil
+	})
+	return bonded
+}
+
+// getMPCUsingAsset returns the ID of an ongoing MPC using the asset, which
+// the holder of its value has not endorsed yet
+func getMPCUsingAsset(worldState storage.KVStore, holder string, asset string) string {
+	using := ""
+	_ = worldState.For(func(key string, value interface{}) error {
+		endorsement, ok := value.(MPCEndorsement)
+		if !ok || !strings.HasPrefix(key, mpcKeyFromUniqID("")) {
+			return nil
+		}
+		if !containsString(endorsement.Assets, asset) || hasEndorsedOrSlashed(&endorsement, holder) {
+			return nil
+		}
+		uniqID := strings.TrimPrefix(key, mpcKeyFromUniqID(""))
+		if using == "" || uniqID < using {
+			using = uniqID
+		}
+		return nil
+	})
+	return using
+}
+
+// slashStake removes a fraction of the offender's stake. Part of it is given
+// to the reporter and the rest is burnt
+func slashStake(worldState storage.KVStore, config *ChainConfig, offender string, reporter string) error {
+	account := GetAccountFromWorldState(worldState, offender)
+	amount := account.stake.MulRatio(config.SlashRatio)
+	account.stake -= amount
+	err := worldState.Put(offender, *account)
+	if err != nil {
+		panic(err)
+	}
+
+	reward := amount.MulRatio(config.SlashReward)
+	if reward <= 0 {
+		return nil
+	}
+	reporterAccount := GetAccountFromWorldState(worldState, reporter)
+	reporterAccount.balance, err = reporterAccount.balance.Add(reward)
+	if err != nil {
+		return err
+	}
+	err = worldState.Put(reporter, *reporterAccount)
+	if err != nil {
+		panic(err)
+	}
+	return nil
+}
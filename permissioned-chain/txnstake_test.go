@@ -0,0 +1,344 @@
+package permissioned
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/cs438/storage"
+)
+
+func Test_Txn_Execution_Stake_Unstake(t *testing.T) {
+	account := *NewAccount(*NewAddressFromHex("account1"))
+	account.balance = 20
+
+	// create worldstate
+	worldState := storage.NewBasicKV()
+	config := *NewChainConfig(
+		map[string]string{account.addr.Hex: ""},
+		1, "2h", 0, 10,
+	)
+	worldState.Put(STATE_CONFIG_KEY, config)
+	worldState.Put(account.addr.Hex, account)
+
+	// > stake more than balance should fail
+
+	stateCopy := worldState.Copy()
+	err := NewTransactionStake(&account, 30).Exec(worldState)
+	require.Error(t, err)
+	require.Equal(t, stateCopy.Hash(), worldState.Hash())
+
+	// > stake should move balance to stake
+
+	err = NewTransactionStake(&account, 15).Exec(worldState)
+	require.NoError(t, err)
+	newAccount := GetAccountFromWorldState(worldState, account.addr.Hex)
+	require.Equal(t, Amount(5), newAccount.balance)
+	require.Equal(t, Amount(15), newAccount.stake)
+	require.Equal(t, account.nonce+1, newAccount.nonce)
+
+	// > unstake more than stake should fail
+
+	err = NewTransactionUnstake(newAccount, 20).Exec(worldState)
+	require.Error(t, err)
+
+	// > unstake should move stake back to balance
+
+	err = NewTransactionUnstake(newAccount, 10).Exec(worldState)
+	require.NoError(t, err)
+	newAccount = GetAccountFromWorldState(worldState, account.addr.Hex)
+	require.Equal(t, Amount(15), newAccount.balance)
+	require.Equal(t, Amount(5), newAccount.stake)
+}
+
+func Test_Txn_Execution_PreMPC_Committee(t *testing.T) {
+	initiator := *NewAccount(*NewAddressFromHex("initiator"))
+	initiator.balance = 100
+	initiator.stake = 10
+	owner := *NewAccount(*NewAddressFromHex("owner"))
+	owner.stake = 10
+	other := *NewAccount(*NewAddressFromHex("other"))
+
+	// create worldstate
+	worldState := storage.NewBasicKV()
+	config := *NewChainConfig(
+		map[string]string{
+			initiator.addr.Hex: "",
+			owner.addr.Hex:     "",
+			other.addr.Hex:     ""},
+		1, "2h", 1, 10,
+	)
+	config.MinStake = 10
+	worldState.Put(STATE_CONFIG_KEY, config)
+	worldState.Put(initiator.addr.Hex, initiator)
+	worldState.Put(owner.addr.Hex, owner)
+	worldState.Put(other.addr.Hex, other)
+	asset := NewAssetsRecord(owner.addr.Hex)
+	asset.Add(map[string]Amount{"a": 5})
+	worldState.Put(AssetsKeyFromUniqID(owner.addr.Hex), *asset)
+
+	// > only staked participants are in the committee and paid
+
+	committee := GetMPCCommittee(worldState, &config)
+	require.Len(t, committee, 2)
+	require.Contains(t, committee, initiator.addr.Hex)
+	require.Contains(t, committee, owner.addr.Hex)
+
+	_, total, err := CalculateTotalPrice(worldState, initiator.addr.Hex, "a", 0)
+	require.NoError(t, err)
+	require.Equal(t, Amount(7), total)
+
+	txn := NewTransactionPreMPC(&initiator, MPCPropose{
+		Initiator:  initiator.addr.Hex,
+		Budget:     total,
+		Expression: "a",
+	})
+	err = txn.Exec(worldState.Copy())
+	require.NoError(t, err)
+
+	// > asset owner without stake should fail
+
+	owner.stake = 0
+	worldState.Put(owner.addr.Hex, owner)
+	stateCopy := worldState.Copy()
+	err = txn.Exec(worldState)
+	require.Error(t, err)
+	require.Equal(t, stateCopy.Hash(), worldState.Hash())
+
+	// > initiator without stake should fail
+
+	owner.stake = 10
+	worldState.Put(owner.addr.Hex, owner)
+	initiator.stake = 0
+	worldState.Put(initiator.addr.Hex, initiator)
+	err = txn.Exec(worldState)
+	require.Error(t, err)
+}
+
+func Test_Txn_Execution_Slash_Timeout(t *testing.T) {
+	account := *NewAccount(*NewAddressFromHex("account1"))
+	account.stake = 100
+	initiator := *NewAccount(*NewAddressFromHex("initiator"))
+	initiator.lockedBalance = 200
+
+	// create worldstate
+	worldState := storage.NewBasicKV()
+	config := *NewChainConfig(
+		map[string]string{
+			account.addr.Hex:   "",
+			initiator.addr.Hex: ""},
+		1, "2h", 0, 10,
+	)
+	config.SlashRatio = 0.5
+	config.SlashReward = 0.2
+	worldState.Put(STATE_CONFIG_KEY, config)
+	worldState.Put(STATE_HEIGHT_KEY, uint(3))
+	worldState.Put(account.addr.Hex, account)
+	worldState.Put(initiator.addr.Hex, initiator)
+	worldState.Put(mpcKeyFromUniqID("test"), MPCEndorsement{
+		Peers:     config.Participants,
+		Endorsers: map[string]struct{}{initiator.addr.Hex: {}},
+		Initiator: initiator.addr.Hex,
+		Locked:    true,
+		Deadline:  4,
+	})
+	evidence := SlashEvidence{
+		UniqID:   "test",
+		Offender: account.addr.Hex,
+		Reason:   SlashReasonTimeout,
+	}
+
+	// > slash before deadline should fail
+
+	require.Empty(t, GetLateEndorsers(worldState, initiator.addr.Hex))
+	stateCopy := worldState.Copy()
+	err := NewTransactionSlash(&initiator, evidence).Exec(worldState)
+	require.Error(t, err)
+	require.Equal(t, stateCopy.Hash(), worldState.Hash())
+
+	// > endorse after deadline should fail
+
+	worldState.Put(STATE_HEIGHT_KEY, uint(4))
+	err = NewTransactionPostMPC(&account, MPCRecord{UniqID: "test"}).Exec(worldState)
+	require.Error(t, err)
+
+	// > stake bonded to the MPC cannot be withdrawn
+
+	err = NewTransactionUnstake(&account, 10).Exec(worldState)
+	require.Error(t, err)
+
+	// > slash after deadline should succeed
+
+	late := GetLateEndorsers(worldState, initiator.addr.Hex)
+	require.Equal(t, map[string][]string{"test": {account.addr.Hex}}, late)
+
+	err = NewTransactionSlash(&initiator, evidence).Exec(worldState)
+	require.NoError(t, err)
+	newAccount := GetAccountFromWorldState(worldState, account.addr.Hex)
+	require.Equal(t, Amount(50), newAccount.stake)
+	newInitiator := GetAccountFromWorldState(worldState, initiator.addr.Hex)
+	require.Equal(t, Amount(10), newInitiator.balance)
+	require.Equal(t, initiator.nonce+1, newInitiator.nonce)
+	require.Empty(t, GetLateEndorsers(worldState, initiator.addr.Hex))
+
+	// > double slash should fail
+
+	stateCopy = worldState.Copy()
+	err = NewTransactionSlash(newInitiator, evidence).Exec(worldState)
+	require.Error(t, err)
+	require.Equal(t, stateCopy.Hash(), worldState.Hash())
+
+	// > slashed stake is no longer bonded
+
+	err = NewTransactionUnstake(newAccount, 50).Exec(worldState)
+	require.NoError(t, err)
+}
+
+func Test_Txn_Execution_Slash_Invalid_Share(t *testing.T) {
+	privKey, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	account := *NewAccount(*NewAddress(&privKey.PublicKey))
+	account.stake = 100
+	reporter := *NewAccount(*NewAddressFromHex("reporter"))
+
+	// create worldstate
+	worldState := storage.NewBasicKV()
+	config := *NewChainConfig(
+		map[string]string{
+			account.addr.Hex:  "",
+			reporter.addr.Hex: ""},
+		1, "2h", 0, 10,
+	)
+	config.SlashRatio = 1
+	worldState.Put(STATE_CONFIG_KEY, config)
+	worldState.Put(account.addr.Hex, account)
+	worldState.Put(reporter.addr.Hex, reporter)
+	worldState.Put(mpcKeyFromUniqID("test"), MPCEndorsement{
+		Peers:     config.Participants,
+		Endorsers: map[string]struct{}{},
+		Initiator: reporter.addr.Hex,
+		Locked:    true,
+		Prime:     "1000000009",
+	})
+
+	// > valid share should not be slashed
+
+	share, err := SignMPCShare(privKey, "test", "a|reporter", "12")
+	require.NoError(t, err)
+	stateCopy := worldState.Copy()
+	err = NewTransactionSlash(&reporter, SlashEvidence{
+		UniqID:   "test",
+		Offender: account.addr.Hex,
+		Reason:   SlashReasonInvalidShare,
+		Share:    share,
+	}).Exec(worldState)
+	require.Error(t, err)
+	require.Equal(t, stateCopy.Hash(), worldState.Hash())
+
+	// > share not signed by offender should fail
+
+	otherKey, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	share, err = SignMPCShare(otherKey, "test", "a|reporter", "1000000010")
+	require.NoError(t, err)
+	err = NewTransactionSlash(&reporter, SlashEvidence{
+		UniqID:   "test",
+		Offender: account.addr.Hex,
+		Reason:   SlashReasonInvalidShare,
+		Share:    share,
+	}).Exec(worldState)
+	require.Error(t, err)
+
+	// > share out of field should be slashed
+
+	share, err = SignMPCShare(privKey, "test", "a|reporter", "1000000010")
+	require.NoError(t, err)
+	err = NewTransactionSlash(&reporter, SlashEvidence{
+		UniqID:   "test",
+		Offender: account.addr.Hex,
+		Reason:   SlashReasonInvalidShare,
+		Share:    share,
+	}).Exec(worldState)
+	require.NoError(t, err)
+	newAccount := GetAccountFromWorldState(worldState, account.addr.Hex)
+	require.Equal(t, Amount(0), newAccount.stake)
+
+	// > slashed participant cannot endorse
+
+	err = NewTransactionPostMPC(newAccount, MPCRecord{UniqID: "test"}).Exec(worldState)
+	require.Error(t, err)
+}
+
+func Test_Txn_Execution_Slash_Refund(t *testing.T) {
+	initiator := *NewAccount(*NewAddressFromHex("initiator"))
+	initiator.balance = 100
+	owner := *NewAccount(*NewAddressFromHex("owner"))
+	owner.stake = 10
+	other := *NewAccount(*NewAddressFromHex("other"))
+	other.stake = 10
+
+	// create worldstate
+	worldState := storage.NewBasicKV()
+	config := *NewChainConfig(
+		map[string]string{
+			initiator.addr.Hex: "",
+			owner.addr.Hex:     "",
+			other.addr.Hex:     ""},
+		1, "2h", 2, 10,
+	)
+	config.EndorseDeadline = 1
+	config.SlashRatio = 0.5
+	worldState.Put(STATE_CONFIG_KEY, config)
+	worldState.Put(STATE_HEIGHT_KEY, uint(3))
+	worldState.Put(initiator.addr.Hex, initiator)
+	worldState.Put(owner.addr.Hex, owner)
+	worldState.Put(other.addr.Hex, other)
+	asset := NewAssetsRecord(owner.addr.Hex)
+	asset.Add(map[string]Amount{"a": 5})
+	worldState.Put(AssetsKeyFromUniqID(owner.addr.Hex), *asset)
+
+	// > the initiator locks the owner's price and the fees
+
+	preMPC := NewTransactionPreMPC(&initiator, MPCPropose{
+		Initiator:  initiator.addr.Hex,
+		Budget:     11,
+		Expression: "a",
+	})
+	err := preMPC.Exec(worldState)
+	require.NoError(t, err)
+	newInitiator := GetAccountFromWorldState(worldState, initiator.addr.Hex)
+	require.Equal(t, Amount(11), newInitiator.lockedBalance)
+
+	err = NewTransactionPostMPC(newInitiator, MPCRecord{UniqID: preMPC.Hash()}).Exec(worldState)
+	require.NoError(t, err)
+
+	// > each slashed member's share goes back to the initiator
+
+	worldState.Put(STATE_HEIGHT_KEY, uint(5))
+	newInitiator = GetAccountFromWorldState(worldState, initiator.addr.Hex)
+	err = NewTransactionSlash(newInitiator, SlashEvidence{
+		UniqID:   preMPC.Hash(),
+		Offender: owner.addr.Hex,
+		Reason:   SlashReasonTimeout,
+	}).Exec(worldState)
+	require.NoError(t, err)
+	newInitiator = GetAccountFromWorldState(worldState, initiator.addr.Hex)
+	require.Equal(t, Amount(4), newInitiator.lockedBalance)
+	require.Equal(t, Amount(96), newInitiator.balance)
+
+	// > once every member endorsed or was slashed, the record is deleted and
+	// the endorsers paid
+
+	err = NewTransactionSlash(newInitiator, SlashEvidence{
+		UniqID:   preMPC.Hash(),
+		Offender: other.addr.Hex,
+		Reason:   SlashReasonTimeout,
+	}).Exec(worldState)
+	require.NoError(t, err)
+	newInitiator = GetAccountFromWorldState(worldState, initiator.addr.Hex)
+	require.Equal(t, Amount(0), newInitiator.lockedBalance)
+	require.Equal(t, Amount(100), newInitiator.balance)
+	_, err = GetMPCEndorsementFromWorldState(worldState, mpcKeyFromUniqID(preMPC.Hash()))
+	require.Error(t, err)
+	require.Empty(t, GetLateEndorsers(worldState, initiator.addr.Hex))
+}
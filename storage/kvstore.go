@@ -1,11 +1,9 @@
 package storage
 
 import (
-	"crypto"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
-	"sort"
 )
 
 type Copyable interface {
@@ -75,41 +73,131 @@ func (kv *BasicKV) Copy() KVStore {
 	return cp
 }
 
+// Hash returns the root of the Merkle tree of the sorted keys and their
+// values. See ProveKey for the inclusion proofs
 func (kv *BasicKV) Hash() []byte {
-	sorted := make([]string, 0, len(kv.store))
-	for k := range kv.store {
-		sorted = append(sorted, k)
+	_, leaves := stateLeaves(kv)
+	return MerkleRoot(leaves)
+}
+
+func Hash(value interface{}) string {
+	h := sha256.New()
+	bytes, err := json.Marshal(value)
+	if err != nil {
+		panic(err)
+	}
+	h.Write(bytes)
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// LayeredKV is a copy-on-write KVStore. It only records the changes made on
+// top of its parent, which must not be modified anymore. Values read from
+// the parent are copied so that changing them does not alter the parent
+type LayeredKV struct {
+	parent  KVStore
+	changes map[string]interface{}
+	deleted map[string]struct{}
+}
+
+func NewLayeredKV(parent KVStore) *LayeredKV {
+	return &LayeredKV{
+		parent:  parent,
+		changes: make(map[string]interface{}),
+		deleted: make(map[string]struct{}),
 	}
-	sort.Strings(sorted)
+}
+
+func (kv *LayeredKV) Get(key string) (interface{}, bool) {
+	if value, ok := kv.changes[key]; ok {
+		return value, true
+	}
+	if _, ok := kv.deleted[key]; ok {
+		return nil, false
+	}
+
+	value, ok := kv.parent.Get(key)
+	if vv, copyable := value.(Copyable); ok && copyable {
+		return vv.Copy(), true
+	}
+	return value, ok
+}
+
+func (kv *LayeredKV) Put(key string, value interface{}) error {
+	kv.changes[key] = value
+	delete(kv.deleted, key)
+	return nil
+}
 
-	h := crypto.SHA256.New()
-	for _, key := range sorted {
-		v, ok := kv.store[key]
-		if !ok {
-			continue
+func (kv *LayeredKV) Del(key string) error {
+	delete(kv.changes, key)
+	kv.deleted[key] = struct{}{}
+	return nil
+}
+
+func (kv *LayeredKV) For(action func(key string, value interface{}) error) error {
+	for k, v := range kv.changes {
+		err := action(k, v)
+		if err != nil {
+			return err
 		}
-		h.Write([]byte(key))
+	}
+	return kv.parent.For(func(k string, v interface{}) error {
+		if _, ok := kv.changes[k]; ok {
+			return nil
+		}
+		if _, ok := kv.deleted[k]; ok {
+			return nil
+		}
+		return action(k, v)
+	})
+}
 
+// Copy returns a store sharing the same parent. Only the changes are copied
+func (kv *LayeredKV) Copy() KVStore {
+	cp := NewLayeredKV(kv.parent)
+	for k, v := range kv.changes {
 		switch vv := v.(type) {
-		case Hashable:
-			hash := vv.Hash()
-			h.Write([]byte(hash))
+		case Copyable:
+			cp.changes[k] = vv.Copy()
 		default:
-			hash := Hash(vv)
-			h.Write([]byte(hash))
+			cp.changes[k] = v
 		}
 	}
+	for k := range kv.deleted {
+		cp.deleted[k] = struct{}{}
+	}
+	return cp
+}
 
-	return h.Sum(nil)
+// Hash implements KVStore.Hash. It is the same as the one of a BasicKV with
+// the same content
+func (kv *LayeredKV) Hash() []byte {
+	_, leaves := stateLeaves(kv)
+	return MerkleRoot(leaves)
 }
 
-func Hash(value interface{}) string {
-	h := sha256.New()
-	bytes, err := json.Marshal(value)
-	if err != nil {
-		panic(err)
+// Depth returns the number of layers down to a store that is not layered
+func (kv *LayeredKV) Depth() int {
+	parent, ok := kv.parent.(*LayeredKV)
+	if !ok {
+		return 1
 	}
-	h.Write(bytes)
+	return parent.Depth() + 1
+}
 
-	return hex.EncodeToString(h.Sum(nil))
+// Flatten returns a copy of the store without layers. The parents are no
+// longer referenced by the copy
+func Flatten(kv KVStore) *BasicKV {
+	flat := NewBasicKV()
+	kv.For(func(k string, v interface{}) error {
+		switch vv := v.(type) {
+		case Copyable:
+			flat.Put(k, vv.Copy())
+		default:
+			flat.Put(k, v)
+		}
+		return nil
+	})
+	return flat
 }
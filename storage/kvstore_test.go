@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// copyableMap is a value that must be copied to be changed safely
+type copyableMap map[string]int
+
+func (m copyableMap) Copy() Copyable {
+	cp := copyableMap{}
+	for k, v := range m {
+		cp[k] = v
+	}
+	return cp
+}
+
+func Test_LayeredKV(t *testing.T) {
+	base := NewBasicKV()
+	base.Put("a", 1)
+	base.Put("b", 2)
+	base.Put("m", copyableMap{"x": 1})
+
+	layer := NewLayeredKV(base)
+	layer.Put("a", 10)
+	layer.Put("c", 3)
+	layer.Del("b")
+
+	// > the layer sees its changes, the parent does not
+	value, ok := layer.Get("a")
+	require.True(t, ok)
+	require.Equal(t, 10, value)
+	_, ok = layer.Get("b")
+	require.False(t, ok)
+	value, _ = base.Get("a")
+	require.Equal(t, 1, value)
+	_, ok = base.Get("c")
+	require.False(t, ok)
+
+	// > changing a value read from the parent does not alter it
+	value, _ = layer.Get("m")
+	value.(copyableMap)["x"] = 2
+	value, _ = base.Get("m")
+	require.Equal(t, 1, value.(copyableMap)["x"])
+
+	// > the content and hash are the ones of a flat store
+	expected := NewBasicKV()
+	expected.Put("a", 10)
+	expected.Put("c", 3)
+	expected.Put("m", copyableMap{"x": 1})
+	keys := map[string]interface{}{}
+	layer.For(func(key string, value interface{}) error {
+		keys[key] = value
+		return nil
+	})
+	require.Len(t, keys, 3)
+	require.Equal(t, expected.Hash(), layer.Hash())
+	require.Equal(t, expected.Hash(), Flatten(layer).Hash())
+
+	// > copies are independent of each other
+	cp := layer.Copy()
+	cp.Put("b", 4)
+	cp.Del("c")
+	_, ok = layer.Get("b")
+	require.False(t, ok)
+	_, ok = layer.Get("c")
+	require.True(t, ok)
+
+	// > layers stack on top of each other
+	top := NewLayeredKV(layer)
+	require.Equal(t, 2, top.Depth())
+	require.Equal(t, layer.Hash(), top.Hash())
+}
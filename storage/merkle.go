@@ -0,0 +1,237 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"sort"
+)
+
+// -----------------------------------------------------------------------------
+// Merkle Tree
+
+// leaves and inner nodes are hashed with different prefixes so that an inner
+// node can never be presented as a leaf. The root commits to the number of
+// leaves, which fixes the shape of the tree
+var (
+	merkleLeafPrefix = []byte{0}
+	merkleNodePrefix = []byte{1}
+	merkleRootPrefix = []byte{2}
+)
+
+// MerkleStep is a sibling on the path from a leaf to the root
+type MerkleStep struct {
+	// hex encoded hash of the sibling
+	Hash string
+	// true if the sibling is on the left of the path
+	Left bool
+}
+
+// MerkleProof proves that a leaf is at the index of a Merkle tree of count
+// leaves. The index and count give the levels where the path has a sibling
+// and on which side
+type MerkleProof struct {
+	Index int
+	Count int
+	Steps []MerkleStep
+}
+
+// MerkleRoot computes the root of the Merkle tree of the leaves. A node
+// without sibling is moved up as is, and the top node is hashed with the
+// number of leaves. The root of no leaf is the hash of nothing
+func MerkleRoot(leaves [][]byte) []byte {
+	if len(leaves) == 0 {
+		h := sha256.New()
+		return h.Sum(nil)
+	}
+
+	level := make([][]byte, len(leaves))
+	for i, leaf := range leaves {
+		level[i] = merkleLeaf(leaf)
+	}
+	for len(level) > 1 {
+		level = merkleLevelUp(level)
+	}
+	return merkleTop(level[0], len(leaves))
+}
+
+// NewMerkleProof returns the proof that the leaf at the index is part of
+// the Merkle tree of the leaves
+func NewMerkleProof(leaves [][]byte, index int) (*MerkleProof, error) {
+	if index < 0 || index >= len(leaves) {
+		return nil, fmt.Errorf("leaf %d out of range [0, %d)", index, len(leaves))
+	}
+
+	level := make([][]byte, len(leaves))
+	for i, leaf := range leaves {
+		level[i] = merkleLeaf(leaf)
+	}
+
+	proof := MerkleProof{Index: index, Count: len(leaves), Steps: make([]MerkleStep, 0)}
+	for len(level) > 1 {
+		sibling := index ^ 1
+		if sibling < len(level) {
+			proof.Steps = append(proof.Steps, MerkleStep{
+				Hash: hex.EncodeToString(level[sibling]),
+				Left: sibling < index,
+			})
+		}
+		level = merkleLevelUp(level)
+		index /= 2
+	}
+	return &proof, nil
+}
+
+// Verify checks that the leaf is at the index of the Merkle tree of the given
+// root. The steps must follow the path of the index
+func (p MerkleProof) Verify(root []byte, leaf []byte) bool {
+	if p.Index < 0 || p.Index >= p.Count {
+		return false
+	}
+
+	hash := merkleLeaf(leaf)
+	steps := p.Steps
+	for index, size := p.Index, p.Count; size > 1; index, size = index/2, (size+1)/2 {
+		// the last node of an odd level has no sibling
+		if index^1 >= size {
+			continue
+		}
+		if len(steps) == 0 || steps[0].Left != (index%2 == 1) {
+			return false
+		}
+		sibling, err := hex.DecodeString(steps[0].Hash)
+		if err != nil {
+			return false
+		}
+		if steps[0].Left {
+			hash = merkleNode(sibling, hash)
+		} else {
+			hash = merkleNode(hash, sibling)
+		}
+		steps = steps[1:]
+	}
+	if len(steps) > 0 {
+		return false
+	}
+	return bytes.Equal(merkleTop(hash, p.Count), root)
+}
+
+func merkleLeaf(leaf []byte) []byte {
+	h := sha256.New()
+	h.Write(merkleLeafPrefix)
+	h.Write(leaf)
+	return h.Sum(nil)
+}
+
+func merkleNode(left []byte, right []byte) []byte {
+	h := sha256.New()
+	h.Write(merkleNodePrefix)
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
+
+// merkleTop hashes the top node of the tree with the number of leaves
+func merkleTop(node []byte, count int) []byte {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, uint64(count))
+
+	h := sha256.New()
+	h.Write(merkleRootPrefix)
+	h.Write(buf)
+	h.Write(node)
+	return h.Sum(nil)
+}
+
+func merkleLevelUp(level [][]byte) [][]byte {
+	next := make([][]byte, 0, (len(level)+1)/2)
+	for i := 0; i < len(level); i += 2 {
+		if i+1 == len(level) {
+			next = append(next, level[i])
+			continue
+		}
+		next = append(next, merkleNode(level[i], level[i+1]))
+	}
+	return next
+}
+
+// -----------------------------------------------------------------------------
+// State Commitment
+
+// StateLeaf is the leaf committing to a key and the hash of its value in
+// the Merkle tree of a KVStore
+func StateLeaf(key string, valueHash string) []byte {
+	leaf := make([]byte, 0, len(key)+len(valueHash)+1)
+	leaf = append(leaf, []byte(key)...)
+	// keys never contain a NUL byte
+	leaf = append(leaf, 0)
+	leaf = append(leaf, []byte(valueHash)...)
+	return leaf
+}
+
+// ValueHash returns the hash of a value stored in a KVStore
+func ValueHash(value interface{}) string {
+	switch vv := value.(type) {
+	case Hashable:
+		return vv.Hash()
+	default:
+		return Hash(vv)
+	}
+}
+
+// StateProof proves that a key has a value in the KVStore with the given hash
+type StateProof struct {
+	Key       string
+	ValueHash string
+	Proof     MerkleProof
+}
+
+// Verify checks that the key has the value hash in the state of the given hash
+func (p StateProof) Verify(stateHash []byte) bool {
+	return p.Proof.Verify(stateHash, StateLeaf(p.Key, p.ValueHash))
+}
+
+// ProveKey returns the proof that the key has its current value in the
+// KVStore, verifiable against KVStore.Hash
+func ProveKey(kv KVStore, key string) (*StateProof, error) {
+	value, ok := kv.Get(key)
+	if !ok {
+		return nil, fmt.Errorf("key %s not found", key)
+	}
+
+	keys, leaves := stateLeaves(kv)
+	index := sort.SearchStrings(keys, key)
+	proof, err := NewMerkleProof(leaves, index)
+	if err != nil {
+		return nil, err
+	}
+
+	return &StateProof{
+		Key:       key,
+		ValueHash: ValueHash(value),
+		Proof:     *proof,
+	}, nil
+}
+
+// stateLeaves returns the sorted keys of the KVStore and their leaves
+func stateLeaves(kv KVStore) ([]string, [][]byte) {
+	values := map[string]interface{}{}
+	kv.For(func(key string, value interface{}) error {
+		values[key] = value
+		return nil
+	})
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	leaves := make([][]byte, len(keys))
+	for i, key := range keys {
+		leaves[i] = StateLeaf(key, ValueHash(values[key]))
+	}
+	return keys, leaves
+}
@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Merkle_Proofs(t *testing.T) {
+	for n := 1; n <= 9; n++ {
+		leaves := make([][]byte, n)
+		for i := range leaves {
+			leaves[i] = []byte(fmt.Sprintf("leaf%d", i))
+		}
+		root := MerkleRoot(leaves)
+
+		for i := range leaves {
+			proof, err := NewMerkleProof(leaves, i)
+			require.NoError(t, err)
+			require.True(t, proof.Verify(root, leaves[i]), "n=%d, i=%d", n, i)
+
+			// > another leaf at the same position is rejected
+			require.False(t, proof.Verify(root, []byte("other")))
+		}
+	}
+
+	_, err := NewMerkleProof([][]byte{[]byte("a")}, 1)
+	require.Error(t, err)
+	require.Equal(t, MerkleRoot(nil), MerkleRoot([][]byte{}))
+}
+
+func Test_Merkle_Inner_Node_Not_Leaf(t *testing.T) {
+	leaves := [][]byte{[]byte("a"), []byte("b"), []byte("c"), []byte("d")}
+	root := MerkleRoot(leaves)
+
+	// > an inner node cannot be proven as a leaf of a shorter path
+	inner := merkleNode(merkleLeaf(leaves[0]), merkleLeaf(leaves[1]))
+	proof := MerkleProof{Index: 0, Count: 2, Steps: []MerkleStep{{
+		Hash: fmt.Sprintf("%x", merkleNode(merkleLeaf(leaves[2]), merkleLeaf(leaves[3]))),
+	}}}
+	require.False(t, proof.Verify(root, inner))
+	proof.Count = 4
+	require.False(t, proof.Verify(root, inner))
+}
+
+func Test_Merkle_Proof_Position(t *testing.T) {
+	leaves := make([][]byte, 5)
+	for i := range leaves {
+		leaves[i] = []byte(fmt.Sprintf("leaf%d", i))
+	}
+	root := MerkleRoot(leaves)
+
+	proof, err := NewMerkleProof(leaves, 4)
+	require.NoError(t, err)
+	require.True(t, proof.Verify(root, leaves[4]))
+
+	// > the last leaf is promoted twice, its path has the same shape as
+	// the one of leaf 1 in a tree of 2 leaves. The index must match
+	for _, index := range []int{-1, 0, 1, 3, 5} {
+		moved := *proof
+		moved.Index = index
+		require.False(t, moved.Verify(root, leaves[4]), index)
+	}
+	moved := *proof
+	moved.Index, moved.Count = 1, 2
+	require.False(t, moved.Verify(root, leaves[4]))
+
+	// > the sides must follow the index
+	proof, err = NewMerkleProof(leaves, 2)
+	require.NoError(t, err)
+	require.True(t, proof.Verify(root, leaves[2]))
+	flipped := *proof
+	flipped.Steps = append([]MerkleStep{}, proof.Steps...)
+	flipped.Steps[0].Left = !flipped.Steps[0].Left
+	require.False(t, flipped.Verify(root, leaves[2]))
+
+	// > no step can be added or removed
+	longer := *proof
+	longer.Steps = append(append([]MerkleStep{}, proof.Steps...), proof.Steps[0])
+	require.False(t, longer.Verify(root, leaves[2]))
+	shorter := *proof
+	shorter.Steps = proof.Steps[:len(proof.Steps)-1]
+	require.False(t, shorter.Verify(root, leaves[2]))
+}
+
+func Test_Merkle_State_Proof(t *testing.T) {
+	kv := NewBasicKV()
+	kv.Put("alice", 10)
+	kv.Put("bob", "value")
+	kv.Put("carol", []int{1, 2})
+
+	proof, err := ProveKey(kv, "bob")
+	require.NoError(t, err)
+	require.True(t, proof.Verify(kv.Hash()))
+	require.Equal(t, ValueHash("value"), proof.ValueHash)
+
+	// > the proof no longer holds once the value changes
+	kv.Put("bob", "changed")
+	require.False(t, proof.Verify(kv.Hash()))
+
+	_, err = ProveKey(kv, "dave")
+	require.Error(t, err)
+}
@@ -75,49 +75,169 @@ func (m BCBlkMessage) HTML() string {
 }
 
 // -----------------------------------------------------------------------------
-// BCAskSyncMessage
+// BCAskHeadersMessage
 
 // NewEmpty implements types.Message.
-func (m BCAskSyncMessage) NewEmpty() Message {
-	return &BCAskSyncMessage{}
+func (m BCAskHeadersMessage) NewEmpty() Message {
+	return &BCAskHeadersMessage{}
 }
 
 // Name implements types.Message.
-func (m BCAskSyncMessage) Name() string {
-	return "blockchainSync"
+func (m BCAskHeadersMessage) Name() string {
+	return "blockchainAskHeaders"
 }
 
 // String implements types.Message.
-func (m BCAskSyncMessage) String() string {
-	return fmt.Sprintf("{blockchainAskSync %s-%d}",
-		m.Origin, m.LatestHeight)
+func (m BCAskHeadersMessage) String() string {
+	return fmt.Sprintf("{blockchainAskHeaders %s - %d+%d}",
+		m.Origin, m.FromHeight, m.Count)
 }
 
 // HTML implements types.Message.
-func (m BCAskSyncMessage) HTML() string {
+func (m BCAskHeadersMessage) HTML() string {
 	return m.String()
 }
 
 // -----------------------------------------------------------------------------
-// BCSyncMessage
+// BCHeadersMessage
 
 // NewEmpty implements types.Message.
-func (m BCSyncMessage) NewEmpty() Message {
-	return &BCSyncMessage{}
+func (m BCHeadersMessage) NewEmpty() Message {
+	return &BCHeadersMessage{}
 }
 
 // Name implements types.Message.
-func (m BCSyncMessage) Name() string {
-	return "blockchainSync"
+func (m BCHeadersMessage) Name() string {
+	return "blockchainHeaders"
 }
 
 // String implements types.Message.
-func (m BCSyncMessage) String() string {
-	return fmt.Sprintf("{blockchainSync from %s}",
-		m.Origin)
+func (m BCHeadersMessage) String() string {
+	return fmt.Sprintf("{blockchainHeaders from %s - %d headers}",
+		m.Origin, len(m.Headers))
 }
 
 // HTML implements types.Message.
-func (m BCSyncMessage) HTML() string {
+func (m BCHeadersMessage) HTML() string {
+	return m.String()
+}
+
+// -----------------------------------------------------------------------------
+// BCAskBlocksMessage
+
+// NewEmpty implements types.Message.
+func (m BCAskBlocksMessage) NewEmpty() Message {
+	return &BCAskBlocksMessage{}
+}
+
+// Name implements types.Message.
+func (m BCAskBlocksMessage) Name() string {
+	return "blockchainAskBlocks"
+}
+
+// String implements types.Message.
+func (m BCAskBlocksMessage) String() string {
+	return fmt.Sprintf("{blockchainAskBlocks %s - %d blocks}",
+		m.Origin, len(m.Hashes))
+}
+
+// HTML implements types.Message.
+func (m BCAskBlocksMessage) HTML() string {
+	return m.String()
+}
+
+// -----------------------------------------------------------------------------
+// BCBlocksMessage
+
+// NewEmpty implements types.Message.
+func (m BCBlocksMessage) NewEmpty() Message {
+	return &BCBlocksMessage{}
+}
+
+// Name implements types.Message.
+func (m BCBlocksMessage) Name() string {
+	return "blockchainBlocks"
+}
+
+// String implements types.Message.
+func (m BCBlocksMessage) String() string {
+	return fmt.Sprintf("{blockchainBlocks from %s - %d blocks}",
+		m.Origin, len(m.Blocks))
+}
+
+// HTML implements types.Message.
+func (m BCBlocksMessage) HTML() string {
+	return m.String()
+}
+
+// -----------------------------------------------------------------------------
+// BCVoteMessage
+
+// NewEmpty implements types.Message.
+func (m BCVoteMessage) NewEmpty() Message {
+	return &BCVoteMessage{}
+}
+
+// Name implements types.Message.
+func (m BCVoteMessage) Name() string {
+	return "blockchainVote"
+}
+
+// String implements types.Message.
+func (m BCVoteMessage) String() string {
+	return fmt.Sprintf("{blockchainVote %s - %s (h=%d)}",
+		m.Origin, m.Vote.BlockHash, m.Vote.Height)
+}
+
+// HTML implements types.Message.
+func (m BCVoteMessage) HTML() string {
+	return m.String()
+}
+
+// -----------------------------------------------------------------------------
+// BCAskProofMessage
+
+// NewEmpty implements types.Message.
+func (m BCAskProofMessage) NewEmpty() Message {
+	return &BCAskProofMessage{}
+}
+
+// Name implements types.Message.
+func (m BCAskProofMessage) Name() string {
+	return "blockchainAskProof"
+}
+
+// String implements types.Message.
+func (m BCAskProofMessage) String() string {
+	return fmt.Sprintf("{blockchainAskProof %s - txn=%s key=%s block=%s}",
+		m.Origin, m.TxnID, m.Key, m.BlockHash)
+}
+
+// HTML implements types.Message.
+func (m BCAskProofMessage) HTML() string {
+	return m.String()
+}
+
+// -----------------------------------------------------------------------------
+// BCProofMessage
+
+// NewEmpty implements types.Message.
+func (m BCProofMessage) NewEmpty() Message {
+	return &BCProofMessage{}
+}
+
+// Name implements types.Message.
+func (m BCProofMessage) Name() string {
+	return "blockchainProof"
+}
+
+// String implements types.Message.
+func (m BCProofMessage) String() string {
+	return fmt.Sprintf("{blockchainProof from %s - txn=%t state=%t}",
+		m.Origin, m.TxnProof != nil, m.StateProof != nil)
+}
+
+// HTML implements types.Message.
+func (m BCProofMessage) HTML() string {
 	return m.String()
 }
@@ -24,16 +24,61 @@ type BCBlkMessage struct {
 	Txns      []permissioned.SignedTransaction
 }
 
-type BCAskSyncMessage struct {
-	UniqID       string
-	Origin       string
-	LatestHeight uint
+// BCAskHeadersMessage asks a peer for the headers of its chain, starting
+// at the given height
+type BCAskHeadersMessage struct {
+	UniqID     string
+	Origin     string
+	FromHeight uint
+	Count      uint
 }
-type BCSyncMessage struct {
+
+// BCHeadersMessage answers a BCAskHeadersMessage. Headers are sorted by
+// increasing height
+type BCHeadersMessage struct {
+	UniqID  string
+	Origin  string
+	Headers []permissioned.BlockHeader
+}
+
+// BCAskBlocksMessage asks a peer for the bodies of blocks
+type BCAskBlocksMessage struct {
+	UniqID string
+	Origin string
+	Hashes []string
+}
+
+// BCBlocksMessage answers a BCAskBlocksMessage. Blocks the peer does not
+// have are omitted
+type BCBlocksMessage struct {
 	UniqID string
 	Origin string
 	// States is not included
 	Blocks []permissioned.Block
-	// BlkHeader permissioned.BlockHeader
-	// Txns      []permissioned.SignedTransaction
+}
+
+// BCVoteMessage carries the vote of a participant for a block
+type BCVoteMessage struct {
+	Origin string
+	Vote   permissioned.BlockVote
+}
+
+// BCAskProofMessage asks a full node for the proof of a transaction or of
+// a world state entry. Exactly one of TxnID and Key is set. The entry is
+// proven after the block BlockHash if set, after the tip otherwise
+type BCAskProofMessage struct {
+	UniqID    string
+	Origin    string
+	TxnID     string
+	Key       string
+	BlockHash string
+}
+
+// BCProofMessage answers a BCAskProofMessage. The proof is nil if the peer
+// cannot provide it
+type BCProofMessage struct {
+	UniqID     string
+	Origin     string
+	TxnProof   *permissioned.TxnProof
+	StateProof *permissioned.StateProof
 }
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 
+	permissioned "go.dedis.ch/cs438/permissioned-chain"
 	"golang.org/x/xerrors"
 )
 
@@ -121,7 +122,7 @@ func (v PaxosTagValue) UniqIdentifier() string {
 type PaxosMPCValue struct {
 	UniqID     string
 	Initiator  string
-	Budget     float64
+	Budget     permissioned.Amount
 	Expression string
 	Prime      string
 }
@@ -138,7 +139,7 @@ func (v PaxosMPCValue) Name() PaxosType {
 
 // String implements types.PaxosValueContent.
 func (v PaxosMPCValue) String() string {
-	return fmt.Sprintf("(paxos MPC - {Initiator: %s, Budget: %f})", v.Initiator, v.Budget)
+	return fmt.Sprintf("(paxos MPC - {Initiator: %s, Budget: %s})", v.Initiator, v.Budget)
 }
 
 // UniqID implements types.PaxosTagValue.
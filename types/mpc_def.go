@@ -4,6 +4,8 @@ type MPCSecretValue struct {
 	Owner string // node identifier
 	Key   string // key in the node DB
 	Value string
+	// Signature of the owner on the share. Only set with blockchain consensus
+	Signature []byte
 }
 
 // MPCShareMessage describes a message for MPC secret sharing.